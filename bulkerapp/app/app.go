@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/jitsucom/bulker/bulkerapp/metrics"
+	"github.com/jitsucom/bulker/bulkerlib/implementations/sql"
 	"github.com/jitsucom/bulker/jitsubase/appbase"
 	"github.com/jitsucom/bulker/jitsubase/logging"
 	"github.com/jitsucom/bulker/jitsubase/safego"
@@ -15,18 +16,19 @@ import (
 )
 
 type Context struct {
-	config              *Config
-	kafkaConfig         *kafka.ConfigMap
-	configurationSource ConfigurationSource
-	repository          *Repository
-	cron                *Cron
-	producer            *Producer
-	eventsLogService    EventsLogService
-	topicManager        *TopicManager
-	fastStore           *FastStore
-	server              *http.Server
-	metricsServer       *MetricsServer
-	metricsRelay        *MetricsRelay
+	config                *Config
+	kafkaConfig           *kafka.ConfigMap
+	configurationSource   ConfigurationSource
+	tableCacheInvalidator sql.TableCacheInvalidator
+	repository            *Repository
+	cron                  *Cron
+	producer              *Producer
+	eventsLogService      EventsLogService
+	topicManager          *TopicManager
+	fastStore             *FastStore
+	server                *http.Server
+	metricsServer         *MetricsServer
+	metricsRelay          *MetricsRelay
 }
 
 func (a *Context) InitContext(settings *appbase.AppSettings) error {
@@ -51,6 +53,12 @@ func (a *Context) InitContext(settings *appbase.AppSettings) error {
 	if err != nil {
 		return err
 	}
+	a.tableCacheInvalidator = sql.DummyTableCacheInvalidator{}
+	//cross-instance invalidation is opt-in: it requires all bulker instances to share the same Redis
+	if a.config.TableCacheRedisURL != "" {
+		a.tableCacheInvalidator = NewRedisTableCacheInvalidator(a.config, a.config.TableCacheRedisURL)
+	}
+	sql.SetTableCacheSettings(a.tableCacheInvalidator, time.Duration(a.config.TableCacheTTLSec)*time.Second)
 	a.repository, err = NewRepository(a.config, a.configurationSource)
 	if err != nil {
 		return err
@@ -107,6 +115,7 @@ func (a *Context) Shutdown() error {
 	_ = a.topicManager.Close()
 	a.cron.Close()
 	_ = a.repository.Close()
+	_ = a.tableCacheInvalidator.Close()
 	_ = a.configurationSource.Close()
 	if a.config.ShutdownExtraDelay > 0 {
 		logging.Infof("Waiting %d seconds before http server shutdown...", a.config.ShutdownExtraDelay)
@@ -36,6 +36,13 @@ type Config struct {
 	RedisURL   string `mapstructure:"REDIS_URL"`
 	RedisTLSCA string `mapstructure:"REDIS_TLS_CA"`
 
+	// # TABLE SCHEMA CACHE
+
+	// TableCacheRedisURL Redis used to propagate table schema changes between bulker instances. Invalidation is disabled when not set
+	TableCacheRedisURL string `mapstructure:"TABLE_CACHE_REDIS_URL"`
+	// TableCacheTTLSec max time in seconds table schema stays in cache. 0 - no expiration
+	TableCacheTTLSec int `mapstructure:"TABLE_CACHE_TTL_SEC" default:"0"`
+
 	// # KAFKA
 
 	// KafkaBootstrapServers List of Kafka brokers separated by comma. Each broker should be in format host:port.
@@ -8,6 +8,7 @@ import (
 	"github.com/jitsucom/bulker/bulkerapp/metrics"
 	bulker "github.com/jitsucom/bulker/bulkerlib"
 	"github.com/jitsucom/bulker/bulkerlib/types"
+	"github.com/jitsucom/bulker/jitsubase/errorj"
 	"github.com/jitsucom/bulker/jitsubase/logging"
 	"github.com/jitsucom/bulker/jitsubase/timestamp"
 	jsoniter "github.com/json-iterator/go"
@@ -46,13 +47,15 @@ func (bc *BatchConsumerImpl) processBatchImpl(destination *Destination, batchNum
 
 	//position of last message in batch in case of failed. Needed for processFailed
 	var failedPosition *kafka.TopicPartition
+	//whether batch failed with error that will happen again on retry. Such batch is sent to dead-letter topic right away
+	nonRetryable := false
 	var firstPosition *kafka.TopicPartition
 	defer func() {
 		if err != nil {
 			nextBatch = false
 			counters.failed = counters.consumed - counters.processed
 			if failedPosition != nil {
-				cnts, err2 := bc.processFailed(firstPosition, failedPosition)
+				cnts, err2 := bc.processFailed(firstPosition, failedPosition, nonRetryable)
 				cnts.failed = counters.failed
 				counters = cnts
 				if err2 != nil {
@@ -123,6 +126,7 @@ func (bc *BatchConsumerImpl) processBatchImpl(destination *Destination, batchNum
 		}
 		if err != nil {
 			failedPosition = &latestMessage.TopicPartition
+			nonRetryable = errorj.IsNonRetryableError(err)
 			state, _ := bulkerStream.Abort(ctx)
 			bc.postEventsLog(state, processedObjectsSample, err)
 			return counters, false, bc.NewError("Failed to process event to bulker stream: %v", err)
@@ -145,6 +149,7 @@ func (bc *BatchConsumerImpl) processBatchImpl(destination *Destination, batchNum
 		bc.postEventsLog(state, processedObjectsSample, err)
 		if err != nil {
 			failedPosition = &latestMessage.TopicPartition
+			nonRetryable = errorj.IsNonRetryableError(err)
 			return counters, false, bc.NewError("Failed to commit bulker stream to %s: %v", destination.config.BulkerType, err)
 		}
 		counters.processed = processed
@@ -161,8 +166,9 @@ func (bc *BatchConsumerImpl) processBatchImpl(destination *Destination, batchNum
 	return
 }
 
-// processFailed consumes the latest failed batch of messages and sends them to the 'failed' topic
-func (bc *BatchConsumerImpl) processFailed(firstPosition *kafka.TopicPartition, failedPosition *kafka.TopicPartition) (counters BatchCounters, err error) {
+// processFailed consumes the latest failed batch of messages and sends them to the 'failed' topic.
+// If nonRetryable is true messages are sent to the dead-letter topic regardless of retries count
+func (bc *BatchConsumerImpl) processFailed(firstPosition *kafka.TopicPartition, failedPosition *kafka.TopicPartition, nonRetryable bool) (counters BatchCounters, err error) {
 	defer func() {
 		if err != nil {
 			err = bc.NewError("Failed to put unsuccessful batch to 'failed' producer: %v", err)
@@ -216,8 +222,8 @@ func (bc *BatchConsumerImpl) processFailed(firstPosition *kafka.TopicPartition,
 		if err != nil {
 			bc.Errorf("failed to read retry header: %v", err)
 		}
-		if retries >= bc.config.MessagesRetryCount {
-			//no attempts left - send to dead-letter topic
+		if nonRetryable || retries >= bc.config.MessagesRetryCount {
+			//no attempts left or error is not retryable - send to dead-letter topic
 			deadLettered = true
 			failedTopic, _ = MakeTopicId(bc.destinationId, deadTopicMode, allTablesToken, false)
 		}
@@ -6,7 +6,7 @@ import (
 	"context"
 	_ "embed"
 	"fmt"
-	"github.com/jitsucom/bulker/bulkerapp/app/testcontainers/kafka"
+	kafka "github.com/jitsucom/bulker/bulkerlib/implementations/kafka/testcontainers"
 	_ "github.com/jitsucom/bulker/bulkerlib/implementations/sql"
 	"github.com/jitsucom/bulker/bulkerlib/implementations/sql/testcontainers"
 	"github.com/jitsucom/bulker/jitsubase/appbase"
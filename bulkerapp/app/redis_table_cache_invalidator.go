@@ -0,0 +1,163 @@
+package app
+
+import (
+	"github.com/gomodule/redigo/redis"
+	"github.com/jitsucom/bulker/bulkerlib/implementations/sql"
+	"github.com/jitsucom/bulker/jitsubase/appbase"
+	"github.com/jitsucom/bulker/jitsubase/safego"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	jsoniter "github.com/json-iterator/go"
+	"sync"
+	"time"
+)
+
+const redisTableSchemaVersionKeyPrefix = "bulker:table_schema_version:"
+const redisTableSchemaChannel = "bulker:table_schema_invalidation"
+const redisTableCacheInvalidatorServiceName = "redis_table_cache_invalidator"
+
+// redisTableSchemaVersionMinTTL min expiration of table schema version key.
+// Key lives at least twice as long as table cache TTL so its expiration doesn't reset version of still cached schema
+const redisTableSchemaVersionMinTTL = 7 * 24 * time.Hour
+
+type tableSchemaChange struct {
+	DestinationId string `json:"destinationId"`
+	TableName     string `json:"tableName"`
+	Version       int64  `json:"version"`
+}
+
+// RedisTableCacheInvalidator propagates table schema changes between bulker instances using Redis Pub/Sub.
+// Schema version of each table is stored in Redis and incremented on every change.
+type RedisTableCacheInvalidator struct {
+	appbase.Service
+	sync.Mutex
+	redisPool *redis.Pool
+	closed    chan struct{}
+	// versionTTLSec expiration of table schema version keys
+	versionTTLSec int64
+
+	lastSubscriptionId int64
+	subscriptions      map[string]map[int64]sql.TableCacheInvalidationCallback
+}
+
+func NewRedisTableCacheInvalidator(config *Config, redisUrl string) *RedisTableCacheInvalidator {
+	base := appbase.NewServiceBase(redisTableCacheInvalidatorServiceName)
+	r := &RedisTableCacheInvalidator{
+		Service:       base,
+		redisPool:     newPool(redisUrl, config.RedisTLSCA),
+		closed:        make(chan struct{}),
+		versionTTLSec: int64(utils.MaxDuration(2*time.Duration(config.TableCacheTTLSec)*time.Second, redisTableSchemaVersionMinTTL).Seconds()),
+		subscriptions: map[string]map[int64]sql.TableCacheInvalidationCallback{},
+	}
+	safego.RunWithRestart(r.pubsub)
+	return r
+}
+
+// NextVersion increments schema version of the table. Version key expires if schema isn't changed for versionTTLSec
+func (r *RedisTableCacheInvalidator) NextVersion(destinationId, tableName string) (int64, error) {
+	conn := r.redisPool.Get()
+	defer conn.Close()
+	key := redisTableSchemaVersionKeyPrefix + destinationId + ":" + tableName
+	version, err := redis.Int64(conn.Do("INCR", key))
+	if err != nil {
+		return 0, r.NewError("failed to increment schema version of table %s: %s", tableName, RedisError(err))
+	}
+	_, err = conn.Do("EXPIRE", key, r.versionTTLSec)
+	if err != nil {
+		return 0, r.NewError("failed to set expiration of schema version of table %s: %s", tableName, RedisError(err))
+	}
+	return version, nil
+}
+
+// Publish notifies all bulker instances about new schema version of the table
+func (r *RedisTableCacheInvalidator) Publish(destinationId, tableName string, version int64) error {
+	conn := r.redisPool.Get()
+	defer conn.Close()
+	payload, _ := jsoniter.Marshal(tableSchemaChange{DestinationId: destinationId, TableName: tableName, Version: version})
+	_, err := conn.Do("PUBLISH", redisTableSchemaChannel, payload)
+	if err != nil {
+		return r.NewError("failed to publish schema change of table %s: %s", tableName, RedisError(err))
+	}
+	return nil
+}
+
+// Subscribe registers callback for schema changes of tables of provided destination
+func (r *RedisTableCacheInvalidator) Subscribe(destinationId string, callback sql.TableCacheInvalidationCallback) func() {
+	r.Lock()
+	defer r.Unlock()
+	r.lastSubscriptionId++
+	id := r.lastSubscriptionId
+	callbacks, ok := r.subscriptions[destinationId]
+	if !ok {
+		callbacks = map[int64]sql.TableCacheInvalidationCallback{}
+		r.subscriptions[destinationId] = callbacks
+	}
+	callbacks[id] = callback
+	return func() {
+		r.Lock()
+		defer r.Unlock()
+		delete(callbacks, id)
+	}
+}
+
+func (r *RedisTableCacheInvalidator) notify(change tableSchemaChange) {
+	r.Lock()
+	callbacks := make([]sql.TableCacheInvalidationCallback, 0, len(r.subscriptions[change.DestinationId]))
+	for _, callback := range r.subscriptions[change.DestinationId] {
+		callbacks = append(callbacks, callback)
+	}
+	r.Unlock()
+	for _, callback := range callbacks {
+		callback(change.TableName, change.Version)
+	}
+}
+
+func (r *RedisTableCacheInvalidator) pubsub() {
+	for {
+		select {
+		case <-r.closed:
+			r.Infof("Closed Redis Pub/Sub channel: %s", redisTableSchemaChannel)
+			return
+		default:
+		}
+		r.Infof("Starting Redis Pub/Sub channel: %s", redisTableSchemaChannel)
+		pubSubConn := r.redisPool.Get()
+		psc := redis.PubSubConn{Conn: pubSubConn}
+		err := psc.Subscribe(redisTableSchemaChannel)
+		if err != nil {
+			_ = psc.Unsubscribe(redisTableSchemaChannel)
+			_ = pubSubConn.Close()
+			r.Errorf("Failed to subscribe to Redis Pub/Sub channel %s: %v", redisTableSchemaChannel, err)
+			time.Sleep(10 * time.Second)
+			continue
+		}
+	loop:
+		for {
+			switch v := psc.Receive().(type) {
+			case redis.Message:
+				change := tableSchemaChange{}
+				if err := jsoniter.Unmarshal(v.Data, &change); err != nil {
+					r.Errorf("failed to parse message %s: %v", v.Data, err)
+					continue
+				}
+				r.Debugf("%s: table %s schema changed. version: %d", change.DestinationId, change.TableName, change.Version)
+				r.notify(change)
+			case redis.Subscription:
+				r.Infof("%s: %s %d", v.Channel, v.Kind, v.Count)
+			case error:
+				select {
+				case <-r.closed:
+				default:
+					r.Errorf("Redis PubSub error.: %v", v)
+				}
+				break loop
+			}
+		}
+		_ = psc.Unsubscribe(redisTableSchemaChannel)
+		_ = pubSubConn.Close()
+	}
+}
+
+func (r *RedisTableCacheInvalidator) Close() error {
+	close(r.closed)
+	return r.redisPool.Close()
+}
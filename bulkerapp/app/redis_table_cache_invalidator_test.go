@@ -0,0 +1,111 @@
+package app
+
+import (
+	"context"
+	"github.com/gomodule/redigo/redis"
+	"github.com/jitsucom/bulker/bulkerapp/app/testcontainers"
+	"github.com/jitsucom/bulker/bulkerlib/implementations/sql"
+	"github.com/jitsucom/bulker/jitsubase/appbase"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRedisTableCacheInvalidatorSubscriptions(t *testing.T) {
+	reqr := require.New(t)
+	r := &RedisTableCacheInvalidator{
+		Service:       appbase.NewServiceBase(redisTableCacheInvalidatorServiceName),
+		subscriptions: map[string]map[int64]sql.TableCacheInvalidationCallback{},
+	}
+	received := map[string][]tableSchemaChange{}
+	subscriber := func(name, destinationId string) sql.TableCacheInvalidationCallback {
+		return func(tableName string, version int64) {
+			received[name] = append(received[name], tableSchemaChange{DestinationId: destinationId, TableName: tableName, Version: version})
+		}
+	}
+	unsubscribe1 := r.Subscribe("dest", subscriber("s1", "dest"))
+	unsubscribe2 := r.Subscribe("dest", subscriber("s2", "dest"))
+	unsubscribeOther := r.Subscribe("other", subscriber("other", "other"))
+
+	r.notify(tableSchemaChange{DestinationId: "dest", TableName: "events", Version: 1})
+	reqr.Equal([]tableSchemaChange{{DestinationId: "dest", TableName: "events", Version: 1}}, received["s1"])
+	reqr.Equal([]tableSchemaChange{{DestinationId: "dest", TableName: "events", Version: 1}}, received["s2"])
+	reqr.Empty(received["other"])
+
+	//unsubscribed callback doesn't receive notifications anymore
+	unsubscribe1()
+	r.notify(tableSchemaChange{DestinationId: "dest", TableName: "users", Version: 3})
+	reqr.Len(received["s1"], 1)
+	reqr.Equal(tableSchemaChange{DestinationId: "dest", TableName: "users", Version: 3}, received["s2"][1])
+
+	//notification for destination without subscribers is ignored
+	r.notify(tableSchemaChange{DestinationId: "unknown", TableName: "events", Version: 1})
+
+	unsubscribe2()
+	unsubscribeOther()
+	r.notify(tableSchemaChange{DestinationId: "dest", TableName: "events", Version: 2})
+	r.notify(tableSchemaChange{DestinationId: "other", TableName: "events", Version: 2})
+	reqr.Len(received["s2"], 2)
+	reqr.Empty(received["other"])
+	reqr.Empty(r.subscriptions["dest"])
+	reqr.Empty(r.subscriptions["other"])
+}
+
+func TestRedisTableCacheInvalidator(t *testing.T) {
+	t.Parallel()
+	reqr := require.New(t)
+
+	redisContainer, err := testcontainers.NewRedisContainer(context.Background(), true)
+	reqr.NoError(err)
+	defer redisContainer.Close()
+
+	appConfig := Config{TableCacheTTLSec: 3600}
+	r := NewRedisTableCacheInvalidator(&appConfig, redisContainer.URL())
+	defer r.Close()
+
+	received := make(chan tableSchemaChange, 1)
+	unsubscribe := r.Subscribe("dest", func(tableName string, version int64) {
+		select {
+		case received <- tableSchemaChange{DestinationId: "dest", TableName: tableName, Version: version}:
+		default:
+		}
+	})
+	defer unsubscribe()
+
+	for i := int64(1); i <= 2; i++ {
+		version, err := r.NextVersion("dest", "events")
+		reqr.NoError(err)
+		reqr.Equal(i, version)
+	}
+	conn := r.redisPool.Get()
+	ttl, err := redis.Int64(conn.Do("TTL", redisTableSchemaVersionKeyPrefix+"dest:events"))
+	_ = conn.Close()
+	reqr.NoError(err)
+	reqr.Greater(ttl, int64(2*appConfig.TableCacheTTLSec))
+
+	//publish until pub/sub subscription is established
+	reqr.Eventually(func() bool {
+		_ = r.Publish("dest", "events", 2)
+		select {
+		case <-received:
+			return true
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}, 10*time.Second, 100*time.Millisecond)
+	reqr.NoError(r.Publish("dest", "events", 3))
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case change := <-received:
+			//skip late notifications of previous publishes
+			if change.Version == 3 {
+				reqr.Equal(tableSchemaChange{DestinationId: "dest", TableName: "events", Version: 3}, change)
+				return
+			}
+		case <-timeout:
+			reqr.Fail("schema change notification wasn't received")
+			return
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	bulker "github.com/jitsucom/bulker/bulkerlib"
 	"github.com/jitsucom/bulker/bulkerlib/types"
 	"github.com/jitsucom/bulker/jitsubase/appbase"
+	"github.com/jitsucom/bulker/jitsubase/errorj"
 	"github.com/jitsucom/bulker/jitsubase/safego"
 	"github.com/jitsucom/bulker/jitsubase/timestamp"
 	"github.com/jitsucom/bulker/jitsubase/utils"
@@ -185,14 +186,15 @@ func (sc *StreamConsumer) start() {
 					}
 				}
 				if err != nil {
+					nonRetryable := errorj.IsNonRetryableError(err)
 					failedTopic, _ := MakeTopicId(sc.destination.Id(), retryTopicMode, allTablesToken, false)
 					retries, err := GetKafkaIntHeader(message, retriesCountHeader)
 					if err != nil {
 						sc.Errorf("failed to read retry header: %v", err)
 					}
 					status := "retryScheduled"
-					if retries >= sc.config.MessagesRetryCount {
-						//no attempts left - send to dead-letter topic
+					if nonRetryable || retries >= sc.config.MessagesRetryCount {
+						//no attempts left or error is not retryable (e.g. event was rejected by destination) - send to dead-letter topic
 						status = "deadLettered"
 						failedTopic, _ = MakeTopicId(sc.destination.Id(), deadTopicMode, allTablesToken, false)
 					}
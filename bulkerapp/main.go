@@ -2,8 +2,12 @@ package main
 
 import (
 	"github.com/jitsucom/bulker/bulkerapp/app"
+	_ "github.com/jitsucom/bulker/bulkerlib/implementations/elasticsearch"
 	_ "github.com/jitsucom/bulker/bulkerlib/implementations/file_storage"
+	_ "github.com/jitsucom/bulker/bulkerlib/implementations/kafka"
+	_ "github.com/jitsucom/bulker/bulkerlib/implementations/mongodb"
 	_ "github.com/jitsucom/bulker/bulkerlib/implementations/sql"
+	_ "github.com/jitsucom/bulker/bulkerlib/implementations/webhook"
 	"github.com/jitsucom/bulker/jitsubase/appbase"
 	"os"
 )
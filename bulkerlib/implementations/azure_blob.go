@@ -0,0 +1,194 @@
+package implementations
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	types2 "github.com/jitsucom/bulker/bulkerlib/types"
+	"github.com/jitsucom/bulker/jitsubase/errorj"
+	"github.com/jitsucom/bulker/jitsubase/logging"
+	"github.com/jitsucom/bulker/jitsubase/timestamp"
+	"go.uber.org/atomic"
+	"io"
+	"strings"
+)
+
+// AzureBlobConfig is a dto for config deserialization
+type AzureBlobConfig struct {
+	FileConfig `mapstructure:",squash" json:",inline" yaml:",inline"`
+	// ConnectionString storage account connection string. Either ConnectionString or ServiceURL with SASToken is required
+	ConnectionString string `mapstructure:"connectionString,omitempty" json:"connectionString,omitempty" yaml:"connectionString,omitempty"`
+	// ServiceURL blob service url e.g. https://<account>.blob.core.windows.net/
+	ServiceURL string `mapstructure:"serviceUrl,omitempty" json:"serviceUrl,omitempty" yaml:"serviceUrl,omitempty"`
+	// SASToken shared access signature token for ServiceURL
+	SASToken  string `mapstructure:"sasToken,omitempty" json:"sasToken,omitempty" yaml:"sasToken,omitempty"`
+	Container string `mapstructure:"container,omitempty" json:"container,omitempty" yaml:"container,omitempty"`
+}
+
+// Validate returns err if invalid
+func (ac *AzureBlobConfig) Validate() error {
+	if ac == nil {
+		return errors.New("Azure Blob config is required")
+	}
+	if ac.ConnectionString == "" {
+		if ac.ServiceURL == "" {
+			return errors.New("Azure Blob connectionString or serviceUrl is required parameter")
+		}
+		if ac.SASToken == "" {
+			return errors.New("Azure Blob sasToken is required parameter when serviceUrl is used")
+		}
+	}
+	if ac.Container == "" {
+		return errors.New("Azure Blob container is required parameter")
+	}
+	return nil
+}
+
+// AzureBlob is an Azure Blob Storage adapter for uploading/deleting files
+type AzureBlob struct {
+	AbstractFileAdapter
+	config *AzureBlobConfig
+	client *azblob.Client
+
+	closed *atomic.Bool
+}
+
+// NewAzureBlob returns configured Azure Blob Storage adapter
+func NewAzureBlob(azureConfig *AzureBlobConfig) (*AzureBlob, error) {
+	if err := azureConfig.Validate(); err != nil {
+		return nil, err
+	}
+	if azureConfig.Format == "" {
+		azureConfig.Format = types2.FileFormatNDJSON
+	}
+	var client *azblob.Client
+	var err error
+	if azureConfig.ConnectionString != "" {
+		client, err = azblob.NewClientFromConnectionString(azureConfig.ConnectionString, nil)
+	} else {
+		serviceURL := strings.TrimSuffix(azureConfig.ServiceURL, "/") + "/?" + strings.TrimPrefix(azureConfig.SASToken, "?")
+		client, err = azblob.NewClientWithNoCredential(serviceURL, nil)
+	}
+	if err != nil {
+		return nil, errorj.SaveOnStageError.Wrap(err, "failed to create azure blob client")
+	}
+
+	return &AzureBlob{AbstractFileAdapter: AbstractFileAdapter{config: &azureConfig.FileConfig}, client: client, config: azureConfig, closed: atomic.NewBool(false)}, nil
+}
+
+func (a *AzureBlob) UploadBytes(fileName string, fileBytes []byte) error {
+	return a.Upload(fileName, bytes.NewReader(fileBytes))
+}
+
+// Upload creates named blob in container with payload
+func (a *AzureBlob) Upload(fileName string, fileReader io.ReadSeeker) error {
+	fileName = a.Path(fileName)
+
+	if a.closed.Load() {
+		return fmt.Errorf("attempt to use closed Azure Blob instance")
+	}
+
+	var contentType string
+	if a.config.Format == types2.FileFormatPARQUET {
+		contentType = "application/vnd.apache.parquet"
+	} else if a.config.Format == types2.FileFormatAVRO {
+		contentType = "application/avro"
+	} else if codec := types2.GetCompressionCodec(a.config.Compression); codec != nil {
+		contentType = codec.ContentType()
+	} else {
+		switch a.config.Format {
+		case types2.FileFormatCSV:
+			contentType = "text/csv"
+		case types2.FileFormatNDJSON, types2.FileFormatNDJSONFLAT:
+			contentType = "application/x-ndjson"
+		}
+	}
+	options := &azblob.UploadStreamOptions{}
+	if contentType != "" {
+		options.HTTPHeaders = &blob.HTTPHeaders{BlobContentType: &contentType}
+	}
+	if _, err := a.client.UploadStream(context.Background(), a.config.Container, fileName, fileReader, options); err != nil {
+		return errorj.SaveOnStageError.Wrap(err, "failed to write file to azure blob").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Bucket:    a.config.Container,
+				Statement: fmt.Sprintf("file: %s", fileName),
+			})
+	}
+	return nil
+}
+
+// Download downloads blob from container
+func (a *AzureBlob) Download(fileName string) ([]byte, error) {
+	fileName = a.Path(fileName)
+
+	if a.closed.Load() {
+		return nil, fmt.Errorf("attempt to use closed Azure Blob instance")
+	}
+
+	resp, err := a.client.DownloadStream(context.Background(), a.config.Container, fileName, nil)
+	if err != nil {
+		return nil, errorj.SaveOnStageError.Wrap(err, "failed to read file from azure blob").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Bucket:    a.config.Container,
+				Statement: fmt.Sprintf("file: %s", fileName),
+			})
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errorj.SaveOnStageError.Wrap(err, "failed to read file from azure blob").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Bucket:    a.config.Container,
+				Statement: fmt.Sprintf("file: %s", fileName),
+			})
+	}
+	return data, nil
+}
+
+// DeleteObject deletes blob from container by key. Missing blob is not an error
+func (a *AzureBlob) DeleteObject(key string) error {
+	key = a.Path(key)
+
+	if a.closed.Load() {
+		return fmt.Errorf("attempt to use closed Azure Blob instance")
+	}
+	_, err := a.client.DeleteBlob(context.Background(), a.config.Container, key, nil)
+	if err != nil && !bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return errorj.SaveOnStageError.Wrap(err, "failed to delete from azure blob").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Bucket:    a.config.Container,
+				Statement: fmt.Sprintf("file: %s", key),
+			})
+	}
+
+	return nil
+}
+
+// ValidateWritePermission tries to create temporary file and remove it.
+// returns nil if file creation was successful.
+func (a *AzureBlob) ValidateWritePermission() error {
+	filename := fmt.Sprintf("test_%v", timestamp.NowUTC())
+
+	if err := a.UploadBytes(filename, []byte{}); err != nil {
+		return err
+	}
+
+	if err := a.DeleteObject(filename); err != nil {
+		logging.Warnf("Cannot remove object %q from Azure Blob: %v", filename, err)
+		// Suppressing error because we need to check only write permission
+		// return err
+	}
+
+	return nil
+}
+
+// Close returns nil
+func (a *AzureBlob) Close() error {
+	a.closed.Store(true)
+	return nil
+}
@@ -0,0 +1,372 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	"github.com/jitsucom/bulker/bulkerlib/implementations/elasticsearch/testcontainers"
+	"github.com/jitsucom/bulker/bulkerlib/types"
+	"github.com/jitsucom/bulker/jitsubase/errorj"
+	"github.com/jitsucom/bulker/jitsubase/logging"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	"github.com/jitsucom/bulker/jitsubase/uuid"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// elasticsearchURL url of cluster used by tests. Empty when Elasticsearch tests are not selected
+var elasticsearchURL string
+
+var openSearchContainer *testcontainers.OpenSearchContainer
+
+func init() {
+	//BULKER_TEST_CONFIGS env variable with comma separated list of bulker config ids. Elasticsearch tests run only if it is empty or contains 'elasticsearch'
+	//BULKER_TEST_ELASTICSEARCH env variable with url of existing Elasticsearch or OpenSearch cluster to run tests without Docker
+	if testConfigsEnv := os.Getenv("BULKER_TEST_CONFIGS"); testConfigsEnv != "" && !utils.ArrayContains(strings.Split(testConfigsEnv, ","), ElasticsearchBulkerTypeId) {
+		return
+	}
+	elasticsearchURL = os.Getenv("BULKER_TEST_ELASTICSEARCH")
+	if elasticsearchURL == "" {
+		var err error
+		openSearchContainer, err = testcontainers.NewOpenSearchContainer(context.Background())
+		if err != nil {
+			panic(err)
+		}
+		elasticsearchURL = openSearchContainer.URL()
+	}
+	logging.Infof("Elasticsearch tests url: %s", elasticsearchURL)
+}
+
+type elasticsearchTestConfig struct {
+	//name of the test
+	name string
+	//additional destination config
+	config map[string]any
+	//bulker stream mode
+	mode bulker.BulkMode
+	//objects consumed by consecutive streams. Each stream is completed before the next one is created
+	batches [][]types.Object
+	//bulker stream options
+	streamOptions []bulker.StreamOption
+	//expected documents in target sorted by 'id' field
+	expectedDocuments []map[string]any
+	//expected mapping types of fields
+	expectedMapping map[string]string
+	//error expected from Consume or Complete call of the last stream. String is used for error message partial matching
+	expectedError string
+	//expected item errors in the last stream state
+	expectedErrorRows []int
+}
+
+func TestElasticsearch(t *testing.T) {
+	if elasticsearchURL == "" {
+		t.Skip("Elasticsearch tests are not selected")
+	}
+	if openSearchContainer != nil {
+		t.Cleanup(func() {
+			_ = openSearchContainer.Close()
+		})
+	}
+	tests := []elasticsearchTestConfig{
+		{
+			name:          "stream_upsert",
+			mode:          bulker.Stream,
+			batches:       [][]types.Object{{{"id": 1, "name": "a"}, {"id": 2, "name": "b"}, {"id": 1, "name": "c"}}},
+			streamOptions: []bulker.StreamOption{bulker.WithPrimaryKey("id")},
+			expectedDocuments: []map[string]any{
+				{"id": 1, "name": "c"},
+				{"id": 2, "name": "b"},
+			},
+			expectedMapping: map[string]string{"id": "keyword", "name": "text"},
+		},
+		{
+			name: "batch",
+			mode: bulker.Batch,
+			batches: [][]types.Object{{
+				{"id": 1, "value": 1, "created": "2023-01-01T00:00:00Z", "nested": map[string]any{"flag": true}},
+				{"id": 2, "value": 2.5, "created": "2023-01-02T00:00:00Z"},
+			}},
+			config: map[string]any{"bulkMaxBytes": 100},
+			expectedDocuments: []map[string]any{
+				{"id": 1, "value": 1, "created": "2023-01-01T00:00:00Z", "nested": map[string]any{"flag": true}},
+				{"id": 2, "value": 2.5, "created": "2023-01-02T00:00:00Z"},
+			},
+			expectedMapping: map[string]string{"id": "long", "value": "double", "created": "date"},
+		},
+		{
+			name: "replace_table",
+			mode: bulker.ReplaceTable,
+			batches: [][]types.Object{
+				{{"id": 1}, {"id": 2}},
+				{{"id": 3}},
+			},
+			expectedDocuments: []map[string]any{{"id": 3}},
+		},
+		{
+			name: "replace_partition",
+			mode: bulker.ReplacePartition,
+			batches: [][]types.Object{
+				{{"id": 1}, {"id": 2}},
+				{{"id": 3}},
+			},
+			streamOptions: []bulker.StreamOption{bulker.WithPartition("p1")},
+			expectedDocuments: []map[string]any{
+				{"id": 3, PartitionIdKeyword: "p1"},
+			},
+			expectedMapping: map[string]string{PartitionIdKeyword: "keyword"},
+		},
+		{
+			name:          "data_stream",
+			mode:          bulker.Batch,
+			config:        map[string]any{"dataStream": true},
+			batches:       [][]types.Object{{{"id": 1, "ts": "2023-01-01T00:00:00Z"}, {"id": 1, "ts": "2023-01-01T00:00:00Z"}}},
+			streamOptions: []bulker.StreamOption{bulker.WithPrimaryKey("id"), bulker.WithTimestamp("ts")},
+			expectedDocuments: []map[string]any{
+				{"id": 1, "ts": "2023-01-01T00:00:00Z", "@timestamp": "2023-01-01T00:00:00Z"},
+			},
+			expectedMapping: map[string]string{"@timestamp": "date"},
+		},
+		{
+			name: "rejected_documents",
+			mode: bulker.Batch,
+			batches: [][]types.Object{
+				{{"id": 1, "value": 1}},
+				{{"id": 2, "value": 2}, {"id": 3, "value": "not a number"}},
+			},
+			expectedDocuments: []map[string]any{{"id": 1, "value": 1}, {"id": 2, "value": 2}},
+			expectedError:     "1 of 2 documents failed",
+			expectedErrorRows: []int{1},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			testElasticsearchStream(t, tt)
+		})
+	}
+}
+
+func testElasticsearchStream(t *testing.T, tt elasticsearchTestConfig) {
+	reqr := require.New(t)
+	ctx := context.Background()
+	config := utils.MapPutAll(map[string]any{"hosts": []string{elasticsearchURL}, "indexPrefix": "bulker_test_"}, tt.config)
+	blk, err := bulker.CreateBulker(bulker.Config{Id: "elasticsearch_" + tt.name, BulkerType: ElasticsearchBulkerTypeId, DestinationConfig: config})
+	reqr.NoError(err)
+	defer func() {
+		_ = blk.Close()
+	}()
+	eb := blk.(*ElasticsearchBulker)
+	tableName := fmt.Sprintf("%s_%s", tt.name, uuid.NewLettersNumbers()[:8])
+	index := eb.targetName(tableName)
+	defer func() {
+		if eb.config.DataStream {
+			_, _, _ = eb.request(ctx, http.MethodDelete, "/_data_stream/"+index, nil)
+			_, _, _ = eb.request(ctx, http.MethodDelete, "/_index_template/"+index, nil)
+		} else {
+			_, _, _ = eb.request(ctx, http.MethodDelete, "/"+index+"*", nil)
+		}
+	}()
+
+	var state bulker.State
+	for i, batch := range tt.batches {
+		last := i == len(tt.batches)-1
+		stream, err := blk.CreateStream(tt.name, tableName, tt.mode, tt.streamOptions...)
+		reqr.NoError(err)
+		for _, object := range batch {
+			if _, _, err = stream.Consume(ctx, object); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			state, err = stream.Complete(ctx)
+		}
+		if last && tt.expectedError != "" {
+			reqr.ErrorContains(err, tt.expectedError)
+			reqr.True(errorj.IsNonRetryableError(err))
+			representation := state.Representation.(RepresentationIndex)
+			rows := make([]int, len(representation.Errors))
+			for j, itemError := range representation.Errors {
+				rows[j] = itemError.Row
+			}
+			reqr.Equal(tt.expectedErrorRows, rows)
+			reqr.Equal(len(batch)-len(tt.expectedErrorRows), state.SuccessfulRows)
+		} else {
+			reqr.NoError(err)
+			reqr.Equal(bulker.Completed, state.Status)
+			reqr.Equal(RepresentationIndex{Index: index}, state.Representation)
+		}
+	}
+
+	documents := searchDocuments(t, eb, index)
+	reqr.Len(documents, len(tt.expectedDocuments))
+	for i, expected := range tt.expectedDocuments {
+		expectedJSON, _ := jsoniter.Marshal(expected)
+		actualJSON, _ := jsoniter.Marshal(documents[i])
+		reqr.JSONEq(string(expectedJSON), string(actualJSON), "document %d", i)
+	}
+	if len(tt.expectedMapping) > 0 {
+		mapping := fieldTypes(t, eb, index)
+		for field, expectedType := range tt.expectedMapping {
+			reqr.Equal(expectedType, mapping[field], "mapping of field %s", field)
+		}
+	}
+}
+
+// searchDocuments returns all documents of index sorted by 'id' field
+func searchDocuments(t *testing.T, eb *ElasticsearchBulker, index string) []map[string]any {
+	reqr := require.New(t)
+	ctx := context.Background()
+	_, _, err := eb.request(ctx, http.MethodPost, "/"+url.PathEscape(index)+"/_refresh", nil)
+	reqr.NoError(err)
+	status, body, err := eb.request(ctx, http.MethodGet, "/"+url.PathEscape(index)+"/_search?size=1000", nil)
+	reqr.NoError(err)
+	reqr.Equal(http.StatusOK, status, string(body))
+	resp := struct {
+		Hits struct {
+			Hits []struct {
+				Source map[string]any `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}{}
+	reqr.NoError(jsoniter.Unmarshal(body, &resp))
+	documents := make([]map[string]any, len(resp.Hits.Hits))
+	for i, hit := range resp.Hits.Hits {
+		documents[i] = hit.Source
+	}
+	sort.Slice(documents, func(i, j int) bool {
+		return fmt.Sprint(documents[i]["id"]) < fmt.Sprint(documents[j]["id"])
+	})
+	return documents
+}
+
+// fieldTypes returns types of top level fields from mapping of index or data stream backing index
+func fieldTypes(t *testing.T, eb *ElasticsearchBulker, index string) map[string]string {
+	reqr := require.New(t)
+	status, body, err := eb.request(context.Background(), http.MethodGet, "/"+url.PathEscape(index)+"/_mapping", nil)
+	reqr.NoError(err)
+	reqr.Equal(http.StatusOK, status, string(body))
+	resp := map[string]struct {
+		Mappings struct {
+			Properties map[string]struct {
+				Type string `json:"type"`
+			} `json:"properties"`
+		} `json:"mappings"`
+	}{}
+	reqr.NoError(jsoniter.Unmarshal(body, &resp))
+	types := map[string]string{}
+	for _, indexMapping := range resp {
+		for field, fieldMapping := range indexMapping.Mappings.Properties {
+			types[field] = fieldMapping.Type
+		}
+	}
+	return types
+}
+
+func TestBulkItemErrors(t *testing.T) {
+	reqr := require.New(t)
+	var bulkBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusOK)
+		case strings.HasSuffix(r.URL.Path, "/_bulk"):
+			body, _ := io.ReadAll(r.Body)
+			bulkBody = string(body)
+			_, _ = w.Write([]byte(`{"errors":true,"items":[
+				{"index":{"_id":"1","status":201}},
+				{"index":{"_id":"2","status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse field [value]"}}},
+				{"index":{"_id":"3","status":429,"error":{"type":"es_rejected_execution_exception","reason":"rejected execution"}}}
+			]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	blk, err := NewElasticsearchBulker(bulker.Config{BulkerType: ElasticsearchBulkerTypeId, DestinationConfig: map[string]any{"hosts": []string{server.URL}}})
+	reqr.NoError(err)
+	stream, err := blk.CreateStream("errors", "Events", bulker.Batch, bulker.WithPrimaryKey("id"))
+	reqr.NoError(err)
+	for i := 1; i <= 3; i++ {
+		_, _, err = stream.Consume(context.Background(), types.Object{"id": i, "value": i})
+		reqr.NoError(err)
+	}
+	state, err := stream.Complete(context.Background())
+	reqr.ErrorContains(err, "2 of 3 documents failed")
+	//429 may succeed on retry
+	reqr.False(errorj.IsNonRetryableError(err))
+	reqr.Equal(bulker.Failed, state.Status)
+	reqr.Equal(3, state.ProcessedRows)
+	reqr.Equal(1, state.SuccessfulRows)
+	reqr.Equal(1, state.ErrorRowIndex)
+	reqr.Equal(RepresentationIndex{Index: "events", Errors: []BulkItemError{
+		{Row: 1, Id: "2", Status: 400, Type: "mapper_parsing_exception", Reason: "failed to parse field [value]"},
+		{Row: 2, Id: "3", Status: 429, Type: "es_rejected_execution_exception", Reason: "rejected execution"},
+	}}, state.Representation)
+
+	lines := strings.Split(strings.TrimSuffix(bulkBody, "\n"), "\n")
+	reqr.Len(lines, 6)
+	reqr.JSONEq(`{"index":{"_id":"1"}}`, lines[0])
+	reqr.JSONEq(`{"id":1,"value":1}`, lines[1])
+}
+
+func TestIndexNameAndMapping(t *testing.T) {
+	reqr := require.New(t)
+	blk, err := NewElasticsearchBulker(bulker.Config{BulkerType: ElasticsearchBulkerTypeId, DestinationConfig: map[string]any{
+		"hosts":       []string{"http://localhost:9200"},
+		"indexPrefix": "Jitsu-",
+	}})
+	reqr.NoError(err)
+	eb := blk.(*ElasticsearchBulker)
+	reqr.Equal("jitsu-events", eb.targetName("Events"))
+	reqr.Equal("jitsu-my_events_2024", eb.targetName("my events#2024"))
+	reqr.Len(eb.targetName(strings.Repeat("a", 300)), maxIndexNameLength)
+	reqr.Equal("events", sanitizeIndexName("_Events"))
+
+	blk, err = NewElasticsearchBulker(bulker.Config{BulkerType: ElasticsearchBulkerTypeId, DestinationConfig: map[string]any{
+		"hosts":               []string{"http://localhost:9200"},
+		"indexPrefix":         "jitsu-",
+		"dataStream":          true,
+		"dataStreamNamespace": "prod",
+	}})
+	reqr.NoError(err)
+	eb = blk.(*ElasticsearchBulker)
+	reqr.Equal("logs-jitsu_events-prod", eb.targetName("events"))
+	_, err = eb.CreateStream("replace", "events", bulker.ReplaceTable)
+	reqr.EqualError(err, DataStreamReplaceUnsupported)
+
+	_, err = NewElasticsearchBulker(bulker.Config{BulkerType: ElasticsearchBulkerTypeId, DestinationConfig: map[string]any{"hosts": []string{"localhost:9200"}}})
+	reqr.ErrorContains(err, "invalid Elasticsearch host url")
+
+	properties := mappingProperties(map[string]any{
+		"id":      "abc",
+		"count":   json.Number("10"),
+		"ratio":   1.5,
+		"flag":    true,
+		"created": "2023-01-01T00:00:00Z",
+		"nested":  map[string]any{"a": 1},
+		"tags":    []any{1, 2.5},
+		"empty":   nil,
+	}, []string{"id"})
+	reqr.Equal(map[string]any{
+		"id":      map[string]any{"type": "keyword"},
+		"count":   map[string]any{"type": "long"},
+		"ratio":   map[string]any{"type": "double"},
+		"flag":    map[string]any{"type": "boolean"},
+		"created": map[string]any{"type": "date"},
+		"nested":  map[string]any{"properties": map[string]any{"a": map[string]any{"type": "long"}}},
+		"tags":    map[string]any{"type": "double"},
+	}, properties)
+	merged := mergeProperties(mappingProperties(map[string]any{"v": 1}, nil), mappingProperties(map[string]any{"v": 1.5, "s": "x"}, nil))
+	reqr.Equal(map[string]any{"type": "double"}, merged["v"])
+	reqr.Equal("text", merged["s"].(map[string]any)["type"])
+}
@@ -0,0 +1,387 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	"github.com/jitsucom/bulker/jitsubase/errorj"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	jsoniter "github.com/json-iterator/go"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const ElasticsearchBulkerTypeId = "elasticsearch"
+
+const (
+	DataStreamReplaceUnsupported = "Data streams are append-only and can't be replaced. Please use 'stream' or 'batch' mode"
+
+	defaultBulkMaxBytes = 5 * 1024 * 1024
+	requestTimeout      = 5 * time.Minute
+	// maxIndexNameLength max length of index name in bytes
+	maxIndexNameLength = 255
+	// maxErrorBodySize max number of bytes of response body included in error message
+	maxErrorBodySize = 1024
+	// dataStreamTemplatePriority priority of index templates created for data streams.
+	// Must be higher than priority of built-in templates e.g. 'logs' template of Elasticsearch with priority 100
+	dataStreamTemplatePriority = 200
+)
+
+var illegalIndexCharacters = regexp.MustCompile(`[\\/*?"<>| ,#:]`)
+
+func init() {
+	bulker.RegisterBulker(ElasticsearchBulkerTypeId, NewElasticsearchBulker)
+}
+
+// ElasticsearchConfig is a dto for config deserialization. Compatible with Elasticsearch 7+ and OpenSearch
+type ElasticsearchConfig struct {
+	// Hosts urls of cluster nodes e.g. https://localhost:9200. Requests are distributed between nodes round-robin
+	Hosts    []string `mapstructure:"hosts,omitempty" json:"hosts,omitempty" yaml:"hosts,omitempty"`
+	Username string   `mapstructure:"username,omitempty" json:"username,omitempty" yaml:"username,omitempty"`
+	Password string   `mapstructure:"password,omitempty" json:"password,omitempty" yaml:"password,omitempty"`
+	// APIKey base64 encoded API key. Alternative to username and password
+	APIKey             string `mapstructure:"apiKey,omitempty" json:"apiKey,omitempty" yaml:"apiKey,omitempty"`
+	InsecureSkipVerify bool   `mapstructure:"insecureSkipVerify,omitempty" json:"insecureSkipVerify,omitempty" yaml:"insecureSkipVerify,omitempty"`
+	// IndexPrefix prefix of index (or data stream dataset) names. Index name is prefix + table name in lower case
+	IndexPrefix string `mapstructure:"indexPrefix,omitempty" json:"indexPrefix,omitempty" yaml:"indexPrefix,omitempty"`
+	// DataStream write to data streams named by '<type>-<dataset>-<namespace>' naming scheme where dataset is index name.
+	// Index template with mappings is created for every data stream
+	DataStream bool `mapstructure:"dataStream,omitempty" json:"dataStream,omitempty" yaml:"dataStream,omitempty"`
+	// DataStreamType type part of data stream name. Default: logs
+	DataStreamType string `mapstructure:"dataStreamType,omitempty" json:"dataStreamType,omitempty" yaml:"dataStreamType,omitempty"`
+	// DataStreamNamespace namespace part of data stream name. Default: default
+	DataStreamNamespace string `mapstructure:"dataStreamNamespace,omitempty" json:"dataStreamNamespace,omitempty" yaml:"dataStreamNamespace,omitempty"`
+	// ILMPolicy index lifecycle policy of created indices and data streams ('index.lifecycle.name' setting)
+	ILMPolicy string `mapstructure:"ilmPolicy,omitempty" json:"ilmPolicy,omitempty" yaml:"ilmPolicy,omitempty"`
+	// IndexSettings settings of created indices e.g. {"number_of_shards": 1}
+	IndexSettings map[string]any `mapstructure:"indexSettings,omitempty" json:"indexSettings,omitempty" yaml:"indexSettings,omitempty"`
+	// Refresh 'refresh' parameter of bulk requests: true, false or wait_for. Default: false
+	Refresh string `mapstructure:"refresh,omitempty" json:"refresh,omitempty" yaml:"refresh,omitempty"`
+	// BulkMaxBytes max size of a single bulk request body. Default: 5MB
+	BulkMaxBytes int `mapstructure:"bulkMaxBytes,omitempty" json:"bulkMaxBytes,omitempty" yaml:"bulkMaxBytes,omitempty"`
+}
+
+// Validate returns err if invalid
+func (ec *ElasticsearchConfig) Validate() error {
+	if ec == nil {
+		return errors.New("Elasticsearch config is required")
+	}
+	if len(ec.Hosts) == 0 {
+		return errors.New("Elasticsearch hosts is required parameter")
+	}
+	for _, host := range ec.Hosts {
+		if u, err := url.Parse(host); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid Elasticsearch host url: %s", host)
+		}
+	}
+	if ec.Refresh != "" && ec.Refresh != "true" && ec.Refresh != "false" && ec.Refresh != "wait_for" {
+		return fmt.Errorf("invalid refresh parameter: %s. Supported: true, false, wait_for", ec.Refresh)
+	}
+	return nil
+}
+
+// ElasticsearchBulker writes objects to Elasticsearch or OpenSearch indices or data streams.
+// Table name maps to index name, primary key – to document '_id'
+type ElasticsearchBulker struct {
+	config     *ElasticsearchConfig
+	httpClient *http.Client
+	nextHost   atomic.Uint32
+	// knownTargets indices and data stream templates that are known to exist
+	knownTargets sync.Map
+}
+
+func NewElasticsearchBulker(bulkerConfig bulker.Config) (bulker.Bulker, error) {
+	esConfig := &ElasticsearchConfig{}
+	if err := utils.ParseObject(bulkerConfig.DestinationConfig, esConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse destination config: %v", err)
+	}
+	if err := esConfig.Validate(); err != nil {
+		return nil, err
+	}
+	esConfig.DataStreamType = utils.NvlString(esConfig.DataStreamType, "logs")
+	esConfig.DataStreamNamespace = utils.NvlString(esConfig.DataStreamNamespace, "default")
+	esConfig.Refresh = utils.NvlString(esConfig.Refresh, "false")
+	if esConfig.BulkMaxBytes <= 0 {
+		esConfig.BulkMaxBytes = defaultBulkMaxBytes
+	}
+	for i, host := range esConfig.Hosts {
+		esConfig.Hosts[i] = strings.TrimSuffix(host, "/")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if esConfig.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &ElasticsearchBulker{
+		config:     esConfig,
+		httpClient: &http.Client{Transport: transport, Timeout: requestTimeout},
+	}, nil
+}
+
+func (eb *ElasticsearchBulker) CreateStream(id, tableName string, mode bulker.BulkMode, streamOptions ...bulker.StreamOption) (bulker.BulkerStream, error) {
+	switch mode {
+	case bulker.Stream, bulker.Batch:
+	case bulker.ReplaceTable, bulker.ReplacePartition:
+		if eb.config.DataStream {
+			return nil, errors.New(DataStreamReplaceUnsupported)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported bulk mode: %s", mode)
+	}
+	return newElasticsearchStream(id, eb, eb.targetName(tableName), mode, streamOptions...)
+}
+
+// targetName returns index or data stream name for table name
+func (eb *ElasticsearchBulker) targetName(tableName string) string {
+	name := sanitizeIndexName(eb.config.IndexPrefix + tableName)
+	if eb.config.DataStream {
+		// dashes separate parts of data stream name
+		name = strings.ReplaceAll(name, "-", "_")
+		name = sanitizeIndexName(eb.config.DataStreamType + "-" + name + "-" + eb.config.DataStreamNamespace)
+	}
+	return name
+}
+
+// sanitizeIndexName makes valid index name: lower case, without characters not allowed in index names
+func sanitizeIndexName(name string) string {
+	name = illegalIndexCharacters.ReplaceAllString(strings.ToLower(name), "_")
+	name = strings.TrimLeft(name, "-_+.")
+	if name == "" {
+		name = "unnamed"
+	}
+	if len(name) > maxIndexNameLength {
+		name = name[:maxIndexNameLength]
+	}
+	return name
+}
+
+// ensureIndex creates index with provided mapping properties if it doesn't exist
+func (eb *ElasticsearchBulker) ensureIndex(ctx context.Context, index string, properties map[string]any) error {
+	if _, ok := eb.knownTargets.Load(index); ok {
+		return nil
+	}
+	status, _, err := eb.request(ctx, http.MethodHead, "/"+url.PathEscape(index), nil)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusNotFound {
+		if err = eb.createIndex(ctx, index, properties); err != nil {
+			return err
+		}
+	} else if err = checkStatus(status, nil); err != nil {
+		return err
+	}
+	eb.knownTargets.Store(index, true)
+	return nil
+}
+
+// createIndex creates index with provided mapping properties. Succeeds if index was created concurrently
+func (eb *ElasticsearchBulker) createIndex(ctx context.Context, index string, properties map[string]any) error {
+	body := map[string]any{"mappings": map[string]any{"properties": properties}}
+	if settings := eb.indexSettings(); len(settings) > 0 {
+		body["settings"] = settings
+	}
+	status, respBody, err := eb.requestJSON(ctx, http.MethodPut, "/"+url.PathEscape(index), body)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusBadRequest && bytes.Contains(respBody, []byte("resource_already_exists_exception")) {
+		return nil
+	}
+	return checkStatus(status, respBody)
+}
+
+// ensureDataStreamTemplate creates index template for data stream with provided mapping properties if it doesn't exist.
+// Data stream itself is created on the first write
+func (eb *ElasticsearchBulker) ensureDataStreamTemplate(ctx context.Context, dataStream string, properties map[string]any) error {
+	if _, ok := eb.knownTargets.Load(dataStream); ok {
+		return nil
+	}
+	templatePath := "/_index_template/" + url.PathEscape(dataStream)
+	status, _, err := eb.request(ctx, http.MethodHead, templatePath, nil)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusNotFound {
+		template := map[string]any{"mappings": map[string]any{"properties": properties}}
+		if settings := eb.indexSettings(); len(settings) > 0 {
+			template["settings"] = settings
+		}
+		status, respBody, err := eb.requestJSON(ctx, http.MethodPut, templatePath, map[string]any{
+			"index_patterns": []string{dataStream},
+			"data_stream":    map[string]any{},
+			"priority":       dataStreamTemplatePriority,
+			"template":       template,
+		})
+		if err != nil {
+			return err
+		}
+		if err = checkStatus(status, respBody); err != nil {
+			return err
+		}
+	} else if err = checkStatus(status, nil); err != nil {
+		return err
+	}
+	eb.knownTargets.Store(dataStream, true)
+	return nil
+}
+
+func (eb *ElasticsearchBulker) indexSettings() map[string]any {
+	settings := utils.MapPutAll(map[string]any{}, eb.config.IndexSettings)
+	if eb.config.ILMPolicy != "" {
+		settings["index.lifecycle.name"] = eb.config.ILMPolicy
+	}
+	return settings
+}
+
+// putMapping adds properties to mapping of existing index
+func (eb *ElasticsearchBulker) putMapping(ctx context.Context, index string, properties map[string]any) error {
+	status, respBody, err := eb.requestJSON(ctx, http.MethodPut, "/"+url.PathEscape(index)+"/_mapping", map[string]any{"properties": properties})
+	if err != nil {
+		return err
+	}
+	return checkStatus(status, respBody)
+}
+
+// aliasIndices returns names of indices behind alias. Returns nil if alias doesn't exist
+func (eb *ElasticsearchBulker) aliasIndices(ctx context.Context, alias string) ([]string, error) {
+	status, respBody, err := eb.request(ctx, http.MethodGet, "/_alias/"+url.PathEscape(alias), nil)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+	if err = checkStatus(status, respBody); err != nil {
+		return nil, err
+	}
+	indices := map[string]any{}
+	if err = jsoniter.Unmarshal(respBody, &indices); err != nil {
+		return nil, fmt.Errorf("failed to parse aliases response: %v", err)
+	}
+	names := make([]string, 0, len(indices))
+	for name := range indices {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// switchAlias atomically points alias to newIndex and deletes indices that alias pointed to before.
+// If concrete index with alias name exists, it is deleted
+func (eb *ElasticsearchBulker) switchAlias(ctx context.Context, alias, newIndex string) error {
+	oldIndices, err := eb.aliasIndices(ctx, alias)
+	if err != nil {
+		return err
+	}
+	if oldIndices == nil {
+		status, _, err := eb.request(ctx, http.MethodHead, "/"+url.PathEscape(alias), nil)
+		if err != nil {
+			return err
+		}
+		if status == http.StatusOK {
+			oldIndices = []string{alias}
+		}
+	}
+	actions := []any{map[string]any{"add": map[string]any{"index": newIndex, "alias": alias}}}
+	for _, oldIndex := range oldIndices {
+		actions = append(actions, map[string]any{"remove_index": map[string]any{"index": oldIndex}})
+	}
+	status, respBody, err := eb.requestJSON(ctx, http.MethodPost, "/_aliases", map[string]any{"actions": actions})
+	if err != nil {
+		return err
+	}
+	return checkStatus(status, respBody)
+}
+
+func (eb *ElasticsearchBulker) deleteIndex(ctx context.Context, index string) error {
+	status, respBody, err := eb.request(ctx, http.MethodDelete, "/"+url.PathEscape(index), nil)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusNotFound {
+		return nil
+	}
+	return checkStatus(status, respBody)
+}
+
+func (eb *ElasticsearchBulker) refresh(ctx context.Context, index string) error {
+	status, respBody, err := eb.request(ctx, http.MethodPost, "/"+url.PathEscape(index)+"/_refresh", nil)
+	if err != nil {
+		return err
+	}
+	return checkStatus(status, respBody)
+}
+
+func (eb *ElasticsearchBulker) requestJSON(ctx context.Context, method, path string, body any) (int, []byte, error) {
+	payload, err := jsoniter.Marshal(body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return eb.request(ctx, method, path, payload)
+}
+
+// request sends request to the next cluster node. Returns response status and body.
+// Error is returned only if response wasn't received
+func (eb *ElasticsearchBulker) request(ctx context.Context, method, path string, body []byte) (int, []byte, error) {
+	host := eb.config.Hosts[int(eb.nextHost.Add(1)-1)%len(eb.config.Hosts)]
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, host+path, reader)
+	if err != nil {
+		return 0, nil, errorj.HTTPRequestError.Wrap(err, "failed to create request")
+	}
+	if body != nil {
+		if strings.Contains(path, "_bulk") {
+			req.Header.Set("Content-Type", "application/x-ndjson")
+		} else {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	}
+	if eb.config.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+eb.config.APIKey)
+	} else if eb.config.Username != "" {
+		req.SetBasicAuth(eb.config.Username, eb.config.Password)
+	}
+	resp, err := eb.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, errorj.HTTPRequestError.Wrap(err, "failed to send request %s %s", method, path)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, errorj.HTTPRequestError.Wrap(err, "failed to read response of %s %s", method, path)
+	}
+	return resp.StatusCode, respBody, nil
+}
+
+// checkStatus returns errorj error if status is not 2xx
+func checkStatus(status int, respBody []byte) error {
+	if status >= 200 && status < 300 {
+		return nil
+	}
+	if len(respBody) > maxErrorBodySize {
+		respBody = respBody[:maxErrorBodySize]
+	}
+	err := fmt.Errorf("response code: %d body: %s", status, respBody)
+	if status >= 400 && status < 500 && status != http.StatusTooManyRequests && status != http.StatusRequestTimeout {
+		return errorj.HTTPClientError.Wrap(err, "request was rejected")
+	}
+	return errorj.HTTPServerError.Wrap(err, "request failed")
+}
+
+func (eb *ElasticsearchBulker) Close() error {
+	eb.httpClient.CloseIdleConnections()
+	return nil
+}
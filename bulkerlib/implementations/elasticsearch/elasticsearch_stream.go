@@ -0,0 +1,418 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	"github.com/jitsucom/bulker/bulkerlib/types"
+	"github.com/jitsucom/bulker/jitsubase/errorj"
+	"github.com/jitsucom/bulker/jitsubase/logging"
+	"github.com/jitsucom/bulker/jitsubase/timestamp"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	"github.com/jitsucom/bulker/jitsubase/uuid"
+	jsoniter "github.com/json-iterator/go"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+const (
+	// PartitionIdKeyword field that stores partition id of documents written in ReplacePartition mode
+	PartitionIdKeyword = "__partition_id"
+	// dataStreamTimestampField field required by data streams
+	dataStreamTimestampField = "@timestamp"
+	// maxReportedItemErrors max number of bulk item errors kept in stream state
+	maxReportedItemErrors = 100
+)
+
+// RepresentationIndex index or data stream where stream writes documents and errors of rejected documents
+type RepresentationIndex struct {
+	Index  string          `json:"index"`
+	Errors []BulkItemError `json:"errors,omitempty"`
+}
+
+// BulkItemError error of a single document of bulk request
+type BulkItemError struct {
+	// Row index of object in stream
+	Row    int    `json:"row"`
+	Id     string `json:"id,omitempty"`
+	Status int    `json:"status"`
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+type bulkResponse struct {
+	Errors bool                          `json:"errors"`
+	Items  []map[string]bulkResponseItem `json:"items"`
+}
+
+type bulkResponseItem struct {
+	Id     string `json:"_id"`
+	Status int    `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// bulkItem serialized action and document lines of bulk request
+type bulkItem struct {
+	row  int
+	body []byte
+}
+
+// ElasticsearchStream writes consumed objects to index or data stream with _bulk API.
+// Documents with the same primary key share '_id' so writing them again replaces previous version.
+// In Stream mode each object is written on Consume.
+// In Batch mode objects are buffered and written on Complete in chunks limited by 'bulkMaxBytes'.
+// In ReplaceTable mode objects are written to a new index that replaces the old one
+// by switching alias named by target index on Complete.
+// In ReplacePartition mode documents of the partition are deleted before writing new ones on Complete.
+// Deletion and writing are not atomic: searches may see partition partially written
+type ElasticsearchStream struct {
+	id      string
+	mode    bulker.BulkMode
+	bulker  *ElasticsearchBulker
+	index   string
+	options bulker.StreamOptions
+
+	pkFields        []string
+	timestampColumn string
+	partitionId     string
+
+	// properties mapping derived from consumed objects
+	properties map[string]any
+	// buffer bulk items of Batch, ReplaceTable and ReplacePartition mode streams
+	buffer     []bulkItem
+	itemErrors []BulkItemError
+
+	state bulker.State
+}
+
+func newElasticsearchStream(id string, eb *ElasticsearchBulker, index string, mode bulker.BulkMode, streamOptions ...bulker.StreamOption) (*ElasticsearchStream, error) {
+	ps := ElasticsearchStream{id: id, bulker: eb, index: index, mode: mode}
+	ps.options = bulker.StreamOptions{}
+	for _, option := range streamOptions {
+		ps.options.Add(option)
+	}
+	// primary key is a set. Sort fields to get the same _id for the same object in every stream
+	ps.pkFields = bulker.PrimaryKeyOption.Get(&ps.options).ToSlice()
+	sort.Strings(ps.pkFields)
+	ps.timestampColumn = bulker.TimestampOption.Get(&ps.options)
+	ps.partitionId = bulker.PartitionIdOption.Get(&ps.options)
+	if mode == bulker.ReplacePartition && ps.partitionId == "" {
+		return nil, fmt.Errorf("couldn't start ElasticsearchStream: %s option is required in ReplacePartition mode", bulker.PartitionIdOption.Key)
+	}
+	ps.state = bulker.State{Status: bulker.Active}
+	ps.updateRepresentation()
+	return &ps, nil
+}
+
+func (ps *ElasticsearchStream) Consume(ctx context.Context, object types.Object) (state bulker.State, processedObjects []types.Object, err error) {
+	defer func() {
+		if err != nil {
+			ps.state.ErrorRowIndex = ps.state.ProcessedRows - 1
+			ps.state.SetError(err)
+		} else if ps.mode == bulker.Stream {
+			ps.state.SuccessfulRows++
+		}
+		state = ps.state
+	}()
+	ps.state.ProcessedRows++
+	document := ps.document(object)
+	item, err := ps.bulkItem(document)
+	if err != nil {
+		return
+	}
+	properties := mappingProperties(document, ps.keywordFields())
+	if ps.mode != bulker.Stream {
+		ps.properties = mergeProperties(ps.properties, properties)
+		ps.buffer = append(ps.buffer, item)
+		return
+	}
+	if err = ps.ensureTarget(ctx, ps.index, properties); err != nil {
+		return
+	}
+	err = ps.writeBulk(ctx, ps.index, []bulkItem{item})
+	return
+}
+
+// document returns object to write. Object is copied if fields need to be added
+func (ps *ElasticsearchStream) document(object types.Object) types.Object {
+	if ps.partitionId != "" {
+		object = utils.MapPutAll(types.Object{}, object)
+		object[PartitionIdKeyword] = ps.partitionId
+	}
+	if ps.bulker.config.DataStream {
+		if _, ok := object[dataStreamTimestampField]; !ok {
+			var ts any = timestamp.Now()
+			if ps.timestampColumn != "" && object[ps.timestampColumn] != nil {
+				ts = object[ps.timestampColumn]
+			}
+			object = utils.MapPutAll(types.Object{}, object)
+			object[dataStreamTimestampField] = ts
+		}
+	}
+	return object
+}
+
+// keywordFields fields that are mapped as 'keyword' regardless of values
+func (ps *ElasticsearchStream) keywordFields() []string {
+	if ps.partitionId != "" {
+		return append([]string{PartitionIdKeyword}, ps.pkFields...)
+	}
+	return ps.pkFields
+}
+
+// bulkItem serializes action and document lines of bulk request
+func (ps *ElasticsearchStream) bulkItem(document types.Object) (bulkItem, error) {
+	action := "index"
+	if ps.bulker.config.DataStream {
+		// data streams accept only 'create' action
+		action = "create"
+	}
+	meta := map[string]any{}
+	if len(ps.pkFields) > 0 {
+		id, err := ps.documentId(document)
+		if err != nil {
+			return bulkItem{}, err
+		}
+		meta["_id"] = id
+	}
+	actionLine, err := jsoniter.Marshal(map[string]any{action: meta})
+	if err != nil {
+		return bulkItem{}, fmt.Errorf("failed to serialize bulk action: %v", err)
+	}
+	documentLine, err := jsoniter.Marshal(document)
+	if err != nil {
+		return bulkItem{}, fmt.Errorf("failed to serialize object: %v", err)
+	}
+	body := make([]byte, 0, len(actionLine)+len(documentLine)+2)
+	body = append(append(append(append(body, actionLine...), '\n'), documentLine...), '\n')
+	return bulkItem{row: ps.state.ProcessedRows - 1, body: body}, nil
+}
+
+// documentId returns _id of document: value of single primary key field or JSON array of values of composite primary key
+func (ps *ElasticsearchStream) documentId(document types.Object) (string, error) {
+	values := make([]any, len(ps.pkFields))
+	for i, field := range ps.pkFields {
+		value, ok := document[field]
+		if !ok || value == nil {
+			return "", fmt.Errorf("primary key field '%s' is missing in object", field)
+		}
+		values[i] = value
+	}
+	if len(values) == 1 {
+		if s, ok := values[0].(string); ok {
+			return s, nil
+		}
+		return fmt.Sprint(values[0]), nil
+	}
+	id, err := jsoniter.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize primary key: %v", err)
+	}
+	return string(id), nil
+}
+
+// ensureTarget creates index or data stream template if it doesn't exist
+func (ps *ElasticsearchStream) ensureTarget(ctx context.Context, index string, properties map[string]any) error {
+	var err error
+	if ps.bulker.config.DataStream {
+		err = ps.bulker.ensureDataStreamTemplate(ctx, index, properties)
+	} else {
+		err = ps.bulker.ensureIndex(ctx, index, properties)
+	}
+	return ps.wrapError(err, index, "failed to create index")
+}
+
+func (ps *ElasticsearchStream) Complete(ctx context.Context) (state bulker.State, err error) {
+	defer func() {
+		ps.buffer = nil
+		if err != nil {
+			ps.state.SetError(err)
+			ps.state.Status = bulker.Failed
+		} else {
+			ps.state.Status = bulker.Completed
+		}
+		state = ps.state
+	}()
+	if ps.mode == bulker.Stream {
+		return
+	}
+	if ps.state.LastError != nil {
+		err = ps.state.LastError
+		return
+	}
+	switch ps.mode {
+	case bulker.ReplaceTable:
+		err = ps.replaceTable(ctx)
+	case bulker.ReplacePartition:
+		err = ps.replacePartition(ctx)
+	default:
+		if len(ps.buffer) == 0 {
+			return
+		}
+		if err = ps.ensureTarget(ctx, ps.index, ps.properties); err != nil {
+			return
+		}
+		err = ps.writeBuffer(ctx, ps.index)
+	}
+	return
+}
+
+// replaceTable writes documents to a new index and atomically points alias named by target index to it.
+// Previous index is deleted
+func (ps *ElasticsearchStream) replaceTable(ctx context.Context) (err error) {
+	newIndex := sanitizeIndexName(fmt.Sprintf("%s_%s_%s", ps.index, timestamp.Now().Format("20060102t150405"), uuid.NewLettersNumbers()[:8]))
+	if err = ps.bulker.createIndex(ctx, newIndex, ps.derivedProperties()); err != nil {
+		return ps.wrapError(err, newIndex, "failed to create index")
+	}
+	defer func() {
+		if err != nil {
+			if dropErr := ps.bulker.deleteIndex(context.Background(), newIndex); dropErr != nil {
+				logging.Errorf("[%s] failed to delete index %s: %v", ps.id, newIndex, dropErr)
+			}
+		}
+	}()
+	if err = ps.writeBuffer(ctx, newIndex); err != nil {
+		return err
+	}
+	if err = ps.bulker.refresh(ctx, newIndex); err != nil {
+		return ps.wrapError(err, newIndex, "failed to refresh index")
+	}
+	if err = ps.bulker.switchAlias(ctx, ps.index, newIndex); err != nil {
+		return ps.wrapError(err, ps.index, "failed to switch alias")
+	}
+	ps.bulker.knownTargets.Store(ps.index, true)
+	return nil
+}
+
+// derivedProperties returns mapping properties derived from consumed objects. Empty if nothing was consumed
+func (ps *ElasticsearchStream) derivedProperties() map[string]any {
+	if ps.properties == nil {
+		return map[string]any{}
+	}
+	return ps.properties
+}
+
+// replacePartition deletes documents of the partition and writes new ones
+func (ps *ElasticsearchStream) replacePartition(ctx context.Context) error {
+	properties := ps.derivedProperties()
+	if err := ps.ensureTarget(ctx, ps.index, properties); err != nil {
+		return err
+	}
+	// index may exist before stream. Make sure that partition id field is searchable by exact value
+	if err := ps.bulker.putMapping(ctx, ps.index, map[string]any{PartitionIdKeyword: map[string]any{"type": "keyword"}}); err != nil {
+		return ps.wrapError(err, ps.index, "failed to update mapping")
+	}
+	status, respBody, err := ps.bulker.requestJSON(ctx, http.MethodPost, "/"+url.PathEscape(ps.index)+"/_delete_by_query?conflicts=proceed&refresh=true",
+		map[string]any{"query": map[string]any{"term": map[string]any{PartitionIdKeyword: ps.partitionId}}})
+	if err == nil {
+		err = checkStatus(status, respBody)
+	}
+	if err != nil {
+		return ps.wrapError(err, ps.index, "failed to delete partition")
+	}
+	return ps.writeBuffer(ctx, ps.index)
+}
+
+// writeBuffer writes buffered items in chunks limited by 'bulkMaxBytes' config
+func (ps *ElasticsearchStream) writeBuffer(ctx context.Context, index string) error {
+	start, size := 0, 0
+	for i, item := range ps.buffer {
+		if i > start && size+len(item.body) > ps.bulker.config.BulkMaxBytes {
+			if err := ps.writeBulk(ctx, index, ps.buffer[start:i]); err != nil {
+				return err
+			}
+			start, size = i, 0
+		}
+		size += len(item.body)
+	}
+	if start < len(ps.buffer) {
+		return ps.writeBulk(ctx, index, ps.buffer[start:])
+	}
+	return nil
+}
+
+// writeBulk sends items in a single bulk request. Errors of failed items are reported to stream state.
+// Returns error if any item failed
+func (ps *ElasticsearchStream) writeBulk(ctx context.Context, index string, items []bulkItem) error {
+	var body bytes.Buffer
+	for _, item := range items {
+		body.Write(item.body)
+	}
+	status, respBody, err := ps.bulker.request(ctx, http.MethodPost, "/"+url.PathEscape(index)+"/_bulk?refresh="+ps.bulker.config.Refresh, body.Bytes())
+	if err == nil {
+		err = checkStatus(status, respBody)
+	}
+	if err != nil {
+		return ps.wrapError(err, index, "failed to send bulk request")
+	}
+	resp := bulkResponse{}
+	if err = jsoniter.Unmarshal(respBody, &resp); err != nil {
+		return errorj.BulkWriteError.Wrap(err, "failed to parse bulk response").
+			WithProperty(errorj.DBInfo, &types.ErrorPayload{Table: index, TotalObjects: len(items)})
+	}
+	if len(resp.Items) != len(items) {
+		return errorj.BulkWriteError.New("bulk response contains %d items while %d were sent", len(resp.Items), len(items)).
+			WithProperty(errorj.DBInfo, &types.ErrorPayload{Table: index, TotalObjects: len(items)})
+	}
+	failed, rejected := 0, 0
+	var firstError *BulkItemError
+	for i, itemResult := range resp.Items {
+		for _, result := range itemResult {
+			if result.Error == nil || (result.Status == http.StatusConflict && ps.bulker.config.DataStream) {
+				// 'create' of document with existing _id in data stream. Document is already written
+				continue
+			}
+			failed++
+			if result.Status >= 400 && result.Status < 500 && result.Status != http.StatusTooManyRequests {
+				rejected++
+			}
+			itemError := BulkItemError{Row: items[i].row, Id: result.Id, Status: result.Status, Type: result.Error.Type, Reason: result.Error.Reason}
+			if firstError == nil {
+				firstError = &itemError
+				if ps.mode != bulker.Stream {
+					ps.state.ErrorRowIndex = itemError.Row
+				}
+			}
+			if len(ps.itemErrors) < maxReportedItemErrors {
+				ps.itemErrors = append(ps.itemErrors, itemError)
+			}
+		}
+	}
+	if ps.mode != bulker.Stream {
+		ps.state.SuccessfulRows += len(items) - failed
+	}
+	ps.updateRepresentation()
+	if failed == 0 {
+		return nil
+	}
+	itemsErr := fmt.Errorf("%d of %d documents failed. First error: status: %d type: %s reason: %s", failed, len(items), firstError.Status, firstError.Type, firstError.Reason)
+	errorType := errorj.BulkWriteError
+	if rejected == failed {
+		errorType = errorj.BulkWriteRejectedError
+	}
+	return errorType.Wrap(itemsErr, "failed to write documents").
+		WithProperty(errorj.DBInfo, &types.ErrorPayload{Table: index, TotalObjects: len(items)})
+}
+
+// wrapError adds context and destination info to errors of requests to cluster
+func (ps *ElasticsearchStream) wrapError(err error, index, message string) error {
+	if err == nil {
+		return nil
+	}
+	return errorj.Decorate(err, message).WithProperty(errorj.DBInfo, &types.ErrorPayload{Table: index})
+}
+
+func (ps *ElasticsearchStream) updateRepresentation() {
+	ps.state.Representation = RepresentationIndex{Index: ps.index, Errors: ps.itemErrors}
+}
+
+func (ps *ElasticsearchStream) Abort(ctx context.Context) (state bulker.State, err error) {
+	ps.buffer = nil
+	ps.state.Status = bulker.Aborted
+	return ps.state, nil
+}
@@ -0,0 +1,102 @@
+package elasticsearch
+
+import (
+	"github.com/jitsucom/bulker/bulkerlib/types"
+)
+
+// keywordIgnoreAbove strings longer than this value are not indexed in 'keyword' subfield
+const keywordIgnoreAbove = 256
+
+// mappingProperties derives index mapping properties from object values.
+// Nested objects are mapped as 'object' fields with own properties. Values of unknown types are skipped
+// and left to dynamic mapping. keywordFields are top level fields that are mapped as 'keyword' e.g. primary key fields
+func mappingProperties(object map[string]any, keywordFields []string) map[string]any {
+	properties := map[string]any{}
+	for name, value := range object {
+		if fieldMapping := valueMapping(value); fieldMapping != nil {
+			properties[name] = fieldMapping
+		}
+	}
+	for _, name := range keywordFields {
+		if _, ok := object[name]; ok {
+			properties[name] = map[string]any{"type": "keyword"}
+		}
+	}
+	return properties
+}
+
+// valueMapping returns mapping of a single field by its value. Returns nil if value type is not supported
+func valueMapping(value any) map[string]any {
+	switch v := value.(type) {
+	case map[string]any:
+		return map[string]any{"properties": mappingProperties(v, nil)}
+	case types.Object:
+		return map[string]any{"properties": mappingProperties(v, nil)}
+	case []any:
+		// arrays don't have dedicated type in Elasticsearch. Elements mapping is used
+		var arrayMapping map[string]any
+		for _, element := range v {
+			arrayMapping = mergeFieldMapping(arrayMapping, valueMapping(element))
+		}
+		return arrayMapping
+	}
+	dataType, err := types.TypeFromValue(types.ReformatValue(value))
+	if err != nil {
+		return nil
+	}
+	return dataTypeMapping(dataType)
+}
+
+// dataTypeMapping returns Elasticsearch field mapping for bulker data type
+func dataTypeMapping(dataType types.DataType) map[string]any {
+	switch dataType {
+	case types.BOOL:
+		return map[string]any{"type": "boolean"}
+	case types.INT64:
+		return map[string]any{"type": "long"}
+	case types.FLOAT64:
+		return map[string]any{"type": "double"}
+	case types.TIMESTAMP:
+		return map[string]any{"type": "date"}
+	case types.STRING:
+		return map[string]any{
+			"type":   "text",
+			"fields": map[string]any{"keyword": map[string]any{"type": "keyword", "ignore_above": keywordIgnoreAbove}},
+		}
+	default:
+		return nil
+	}
+}
+
+// mergeProperties merges src mapping properties into dst
+func mergeProperties(dst, src map[string]any) map[string]any {
+	if dst == nil {
+		dst = map[string]any{}
+	}
+	for name, srcMapping := range src {
+		dstMapping, _ := dst[name].(map[string]any)
+		dst[name] = mergeFieldMapping(dstMapping, srcMapping.(map[string]any))
+	}
+	return dst
+}
+
+// mergeFieldMapping merges mappings of the same field derived from different values.
+// The first mapping wins except for numeric types that are widened to 'double'
+// and objects whose properties are merged
+func mergeFieldMapping(dst, src map[string]any) map[string]any {
+	if dst == nil {
+		return src
+	}
+	if src == nil {
+		return dst
+	}
+	dstProperties, dstIsObject := dst["properties"].(map[string]any)
+	srcProperties, srcIsObject := src["properties"].(map[string]any)
+	if dstIsObject && srcIsObject {
+		return map[string]any{"properties": mergeProperties(dstProperties, srcProperties)}
+	}
+	if (dst["type"] == "long" && src["type"] == "double") || (dst["type"] == "double" && src["type"] == "long") {
+		return map[string]any{"type": "double"}
+	}
+	return dst
+}
@@ -0,0 +1,79 @@
+package testcontainers
+
+import (
+	"context"
+	"fmt"
+	"github.com/jitsucom/bulker/jitsubase/logging"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	"github.com/testcontainers/testcontainers-go"
+	tcWait "github.com/testcontainers/testcontainers-go/wait"
+	"net/http"
+	"time"
+)
+
+// OpenSearchContainer is a single node OpenSearch testcontainer with disabled security plugin
+type OpenSearchContainer struct {
+	Container testcontainers.Container
+	Context   context.Context
+	Host      string
+	Port      int
+}
+
+// NewOpenSearchContainer creates new OpenSearch test container
+func NewOpenSearchContainer(ctx context.Context) (*OpenSearchContainer, error) {
+	exposedPort := fmt.Sprintf("%d:%d", utils.GetPort(), 9200)
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "opensearchproject/opensearch:2.11.1",
+			ExposedPorts: []string{exposedPort},
+			Env: map[string]string{
+				"discovery.type":          "single-node",
+				"DISABLE_SECURITY_PLUGIN": "true",
+				"OPENSEARCH_JAVA_OPTS":    "-Xms512m -Xmx512m",
+			},
+			WaitingFor: tcWait.ForHTTP("/_cluster/health?wait_for_status=yellow").WithPort("9200/tcp").
+				WithStatusCodeMatcher(func(status int) bool { return status == http.StatusOK }).
+				WithStartupTimeout(3 * time.Minute),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		container.Terminate(ctx)
+		return nil, err
+	}
+
+	port, err := container.MappedPort(ctx, "9200")
+	if err != nil {
+		container.Terminate(ctx)
+		return nil, err
+	}
+	return &OpenSearchContainer{
+		Container: container,
+		Context:   ctx,
+		Host:      host,
+		Port:      port.Int(),
+	}, nil
+}
+
+// URL returns http url of OpenSearch node
+func (oc *OpenSearchContainer) URL() string {
+	return fmt.Sprintf("http://%s:%d", oc.Host, oc.Port)
+}
+
+// Close terminates underlying docker container
+func (oc *OpenSearchContainer) Close() error {
+	if oc.Container != nil {
+		err := oc.Container.Terminate(oc.Context)
+		if err != nil {
+			logging.Errorf("Failed to stop OpenSearch container: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -1,9 +1,16 @@
 package implementations
 
 import (
+	"cloud.google.com/go/storage"
+	"errors"
 	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/jitsucom/bulker/bulkerlib/types"
+	"github.com/joomcode/errorx"
 	"io"
+	"io/fs"
 	"strings"
 	"time"
 )
@@ -29,6 +36,23 @@ type FileAdapter interface {
 	Compression() types.FileCompression
 }
 
+// IsObjectNotFound returns true if error returned by FileAdapter.Download means that object doesn't exist
+func IsObjectNotFound(err error) bool {
+	for err != nil {
+		var awsErr awserr.Error
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, storage.ErrObjectNotExist) ||
+			(errors.As(err, &awsErr) && awsErr.Code() == s3.ErrCodeNoSuchKey) || bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return true
+		}
+		if ex, ok := err.(*errorx.Error); ok {
+			err = ex.Cause()
+		} else {
+			err = errors.Unwrap(err)
+		}
+	}
+	return false
+}
+
 type FileConfig struct {
 	Folder      string                `mapstructure:"folder" json:"folder,omitempty" yaml:"folder,omitempty"`
 	Format      types.FileFormat      `mapstructure:"format,omitempty" json:"format,omitempty" yaml:"format,omitempty"`
@@ -48,24 +72,30 @@ func (a *AbstractFileAdapter) Compression() types.FileCompression {
 }
 
 func (a *AbstractFileAdapter) AddFileExtension(fileName string) string {
-	gz := ""
+	compressionExt := ""
 	ext := ""
 	switch a.config.Format {
 	case types.FileFormatCSV:
 		ext = ".csv"
 	case types.FileFormatNDJSON, types.FileFormatNDJSONFLAT:
 		ext = ".ndjson"
+	case types.FileFormatPARQUET:
+		//parquet and avro compress data blocks internally
+		ext = ".parquet"
+	case types.FileFormatAVRO:
+		ext = ".avro"
 	}
-	switch a.config.Compression {
-	case types.FileCompressionGZIP:
-		gz += ".gz"
+	if a.config.Format != types.FileFormatPARQUET && a.config.Format != types.FileFormatAVRO {
+		if codec := types.GetCompressionCodec(a.config.Compression); codec != nil {
+			compressionExt = codec.FileExtension()
+		}
 	}
 	if strings.HasSuffix(fileName, ext) {
-		return fileName + gz
-	} else if strings.HasSuffix(fileName, ext+gz) {
+		return fileName + compressionExt
+	} else if strings.HasSuffix(fileName, ext+compressionExt) {
 		return fileName
 	} else {
-		return fileName + ext + gz
+		return fileName + ext + compressionExt
 	}
 }
 
@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	bulker "github.com/jitsucom/bulker/bulkerlib"
@@ -20,25 +22,67 @@ import (
 	"time"
 )
 
+// RepresentationFiles files uploaded by stream
+type RepresentationFiles struct {
+	// Name path of the first uploaded file
+	Name     string   `json:"name"`
+	Files    []string `json:"files,omitempty"`
+	Manifest string   `json:"manifest,omitempty"`
+}
+
+// batchFile local file with objects of a single partition
+type batchFile struct {
+	// partition Hive-style partition path. Empty when partitioning is not enabled
+	partition string
+	// part number of rolled over part file of partition. -1 when rollover is disabled
+	part          int
+	file          *os.File
+	marshaller    types2.Marshaller
+	eventsInBatch int
+	skipLines     utils.Set[int]
+}
+
+func (bf *batchFile) close() {
+	_ = bf.file.Close()
+	_ = os.Remove(bf.file.Name())
+}
+
+// batchFileLine position of object in one of batch files
+type batchFileLine struct {
+	batchFile *batchFile
+	line      int
+}
+
 type AbstractFileStorageStream struct {
-	id           string
-	mode         bulker.BulkMode
-	fileAdapter  implementations2.FileAdapter
-	options      bulker.StreamOptions
-	filenameFunc func(ctx context.Context) string
+	id          string
+	mode        bulker.BulkMode
+	fileAdapter implementations2.FileAdapter
+	options     bulker.StreamOptions
+	// filenameFunc returns file name for partition and part number.
+	// partition is empty when partitioning is not enabled. part is -1 when rollover is disabled
+	filenameFunc func(ctx context.Context, partition string, part int) string
 
 	flatten         bool
 	merge           bool
 	pkColumns       []string
 	timestampColumn string
+	partitions      []hivePartition
+	maxFileSize     int
+	maxRowsPerFile  int
+	manifest        bool
 
-	batchFile          *os.File
-	marshaller         types2.Marshaller
+	batchFiles         map[string]*batchFile
+	partsCount         map[string]int
+	uploadedFiles      []ManifestFile
 	targetMarshaller   types2.Marshaller
-	eventsInBatch      int
-	batchFileLinesByPK map[string]int
-	batchFileSkipLines utils.Set[int]
+	batchFileLinesByPK map[string]batchFileLine
 	csvHeader          utils.Set[string]
+	columnTypes        map[string]types2.DataType
+	// columnTypeFunc when set resolves type of column seen for the first time. Type of known column is not widened afterwards.
+	// Used by table formats with own schema e.g. Apache Iceberg
+	columnTypeFunc func(name string, dataType types2.DataType) types2.DataType
+	// fieldIds stable ids of columns for targets that support them. Columns without id are not written
+	fieldIds map[string]int
 
 	firstEventTime time.Time
 	lastEventTime  time.Time
@@ -47,7 +91,7 @@ type AbstractFileStorageStream struct {
 	inited bool
 }
 
-func newAbstractFileStorageStream(id string, p implementations2.FileAdapter, filenameFunc func(ctx context.Context) string, mode bulker.BulkMode, streamOptions ...bulker.StreamOption) (AbstractFileStorageStream, error) {
+func newAbstractFileStorageStream(id string, p implementations2.FileAdapter, filenameFunc func(ctx context.Context, partition string, part int) string, mode bulker.BulkMode, streamOptions ...bulker.StreamOption) (AbstractFileStorageStream, error) {
 	ps := AbstractFileStorageStream{id: id, fileAdapter: p, filenameFunc: filenameFunc, mode: mode}
 	ps.options = bulker.StreamOptions{}
 	for _, option := range streamOptions {
@@ -60,10 +104,24 @@ func newAbstractFileStorageStream(id string, p implementations2.FileAdapter, fil
 	}
 	ps.pkColumns = pkColumns.ToSlice()
 	ps.timestampColumn = bulker.TimestampOption.Get(&ps.options)
+	partitions, err := parsePartitionBy(PartitionByOption.Get(&ps.options))
+	if err != nil {
+		return AbstractFileStorageStream{}, err
+	}
+	ps.partitions = partitions
+	ps.manifest = ManifestOption.Get(&ps.options)
+	ps.maxFileSize = bulker.MaxFileSizeBytesOption.Get(&ps.options)
+	ps.maxRowsPerFile = bulker.MaxRowsPerFileOption.Get(&ps.options)
+	if ps.maxFileSize < 0 || ps.maxRowsPerFile < 0 {
+		return AbstractFileStorageStream{}, fmt.Errorf("maxFileSizeBytes and maxRowsPerFile options must not be negative")
+	}
 	if ps.merge {
-		ps.batchFileLinesByPK = make(map[string]int)
-		ps.batchFileSkipLines = utils.NewSet[int]()
+		ps.batchFileLinesByPK = make(map[string]batchFileLine)
 	}
+	ps.batchFiles = make(map[string]*batchFile)
+	ps.partsCount = make(map[string]int)
+	ps.csvHeader = utils.NewSet[string]()
+	ps.columnTypes = make(map[string]types2.DataType)
 	ps.state = bulker.State{Status: bulker.Active}
 	return ps, nil
 }
@@ -72,30 +130,127 @@ func (ps *AbstractFileStorageStream) init(ctx context.Context) error {
 	if ps.inited {
 		return nil
 	}
+	var err error
+	ps.targetMarshaller, err = types2.NewMarshaller(ps.fileAdapter.Format(), ps.fileAdapter.Compression())
+	if err != nil {
+		return err
+	}
+	switch ps.fileAdapter.Format() {
+	case types2.FileFormatCSV, types2.FileFormatNDJSONFLAT, types2.FileFormatPARQUET, types2.FileFormatAVRO:
+		ps.flatten = true
+	}
+	ps.inited = true
+	return nil
+}
 
-	if ps.batchFile == nil {
-		var err error
-		ps.batchFile, err = os.CreateTemp("", fmt.Sprintf("bulker_%s", utils.SanitizeString(ps.id)))
-		if err != nil {
-			return err
-		}
-		ps.marshaller, _ = types2.NewMarshaller(types2.FileFormatNDJSON, types2.FileCompressionNONE)
-		ps.targetMarshaller, err = types2.NewMarshaller(ps.fileAdapter.Format(), ps.fileAdapter.Compression())
+// getBatchFile returns batch file of partition. Creates it on first use
+func (ps *AbstractFileStorageStream) getBatchFile(partition string) (*batchFile, error) {
+	if bf, ok := ps.batchFiles[partition]; ok {
+		return bf, nil
+	}
+	file, err := os.CreateTemp("", fmt.Sprintf("bulker_%s", utils.SanitizeString(ps.id)))
+	if err != nil {
+		return nil, err
+	}
+	bf := &batchFile{partition: partition, part: ps.nextPart(partition), file: file}
+	bf.marshaller, _ = types2.NewMarshaller(types2.FileFormatNDJSON, types2.FileCompressionNONE)
+	if !ps.merge && ps.fileAdapter.Format() == types2.FileFormatNDJSON {
+		//without merge we can write file with compression - no need to convert
+		bf.marshaller, _ = types2.NewMarshaller(ps.fileAdapter.Format(), ps.fileAdapter.Compression())
+	}
+	if ps.merge {
+		bf.skipLines = utils.NewSet[int]()
+	}
+	ps.batchFiles[partition] = bf
+	return bf, nil
+}
+
+// nextPart returns number of the next part file of partition. Returns -1 when rollover is disabled
+func (ps *AbstractFileStorageStream) nextPart(partition string) int {
+	if ps.maxFileSize == 0 && ps.maxRowsPerFile == 0 {
+		return -1
+	}
+	part := ps.partsCount[partition]
+	ps.partsCount[partition] = part + 1
+	return part
+}
+
+// partSuffix returns file name suffix of rolled over part file. Empty when rollover is disabled
+func partSuffix(part int) string {
+	if part < 0 {
+		return ""
+	}
+	return fmt.Sprintf("_%05d", part)
+}
+
+// needRollover returns true when batch file has reached one of maxFileSizeBytes or maxRowsPerFile thresholds
+func (ps *AbstractFileStorageStream) needRollover(bf *batchFile) (bool, error) {
+	if ps.maxRowsPerFile > 0 && bf.eventsInBatch >= ps.maxRowsPerFile {
+		return true, nil
+	}
+	if ps.maxFileSize > 0 {
+		fi, err := bf.file.Stat()
 		if err != nil {
-			return err
+			return false, errorj.Decorate(err, "failed to get batch file size")
 		}
-		if !ps.merge && ps.fileAdapter.Format() == types2.FileFormatNDJSON {
-			//without merge we can write file with compression - no need to convert
-			ps.marshaller, _ = types2.NewMarshaller(ps.fileAdapter.Format(), ps.fileAdapter.Compression())
-		}
-		if ps.fileAdapter.Format() == types2.FileFormatCSV || ps.fileAdapter.Format() == types2.FileFormatNDJSONFLAT {
-			ps.flatten = true
+		return fi.Size() >= int64(ps.maxFileSize), nil
+	}
+	return false, nil
+}
+
+// rollover uploads finished part file right away to bound local disk usage.
+// Deduplication by primary key is applied within a single part file only
+func (ps *AbstractFileStorageStream) rollover(ctx context.Context, bf *batchFile) error {
+	defer func() {
+		delete(ps.batchFiles, bf.partition)
+		for pk, line := range ps.batchFileLinesByPK {
+			if line.batchFile == bf {
+				delete(ps.batchFileLinesByPK, pk)
+			}
 		}
+		bf.close()
+	}()
+	uploadedFile, err := ps.flushBatchFile(ctx, bf)
+	if err != nil {
+		return err
 	}
-	ps.inited = true
+	ps.addUploadedFile(uploadedFile)
 	return nil
 }
 
+func (ps *AbstractFileStorageStream) addUploadedFile(uploadedFile *ManifestFile) {
+	if uploadedFile == nil {
+		return
+	}
+	ps.uploadedFiles = append(ps.uploadedFiles, *uploadedFile)
+	representation := RepresentationFiles{Name: ps.uploadedFiles[0].Path}
+	for _, f := range ps.uploadedFiles {
+		representation.Files = append(representation.Files, f.Path)
+	}
+	ps.state.Representation = representation
+}
+
+// uploadEmptyFile replaces existing file with empty one. For ReplacePartition stream when no objects were consumed
+func (ps *AbstractFileStorageStream) uploadEmptyFile(ctx context.Context) error {
+	fileName := ps.fileAdapter.AddFileExtension(ps.filenameFunc(ctx, "", ps.nextPart("")))
+	if err := ps.fileAdapter.UploadBytes(fileName, []byte{}); err != nil {
+		return err
+	}
+	emptySHA256 := sha256.Sum256(nil)
+	ps.addUploadedFile(&ManifestFile{name: fileName, Path: ps.fileAdapter.Path(fileName), SHA256: hex.EncodeToString(emptySHA256[:])})
+	return nil
+}
+
+func (ps *AbstractFileStorageStream) closeBatchFiles() {
+	for _, bf := range ps.batchFiles {
+		bf.close()
+	}
+	ps.batchFiles = make(map[string]*batchFile)
+	if ps.merge {
+		ps.batchFileLinesByPK = make(map[string]batchFileLine)
+	}
+}
+
 func (ps *AbstractFileStorageStream) preprocess(object types2.Object) (types2.Object, error) {
 	if ps.flatten {
 		flatObject, err := implementations2.DefaultFlattener.FlattenObject(object, nil)
@@ -121,8 +276,7 @@ func (ps *AbstractFileStorageStream) postConsume(err error) error {
 }
 
 func (ps *AbstractFileStorageStream) postComplete(err error) (bulker.State, error) {
-	_ = ps.batchFile.Close()
-	_ = os.Remove(ps.batchFile.Name())
+	ps.closeBatchFiles()
 	if err != nil {
 		ps.state.SetError(err)
 		ps.state.Status = bulker.Failed
@@ -132,101 +286,147 @@ func (ps *AbstractFileStorageStream) postComplete(err error) (bulker.State, erro
 	return ps.state, err
 }
 
-func (ps *AbstractFileStorageStream) flushBatchFile(ctx context.Context) (err error) {
-	defer func() {
-		if ps.merge {
-			ps.batchFileLinesByPK = make(map[string]int)
-			ps.batchFileSkipLines = utils.NewSet[int]()
-		}
-		_ = ps.batchFile.Close()
-		_ = os.Remove(ps.batchFile.Name())
-	}()
-	if ps.eventsInBatch > 0 {
-
-		err = ps.marshaller.Flush()
+// flushBatchFiles uploads batch files of all partitions in partitions order
+func (ps *AbstractFileStorageStream) flushBatchFiles(ctx context.Context) error {
+	defer ps.closeBatchFiles()
+	partitions := make([]string, 0, len(ps.batchFiles))
+	for partition := range ps.batchFiles {
+		partitions = append(partitions, partition)
+	}
+	sort.Strings(partitions)
+	for _, partition := range partitions {
+		uploadedFile, err := ps.flushBatchFile(ctx, ps.batchFiles[partition])
 		if err != nil {
-			return errorj.Decorate(err, "failed to flush marshaller")
+			return err
 		}
-		err = ps.batchFile.Sync()
+		ps.addUploadedFile(uploadedFile)
+	}
+	return nil
+}
+
+// flushBatchFile converts batch file to the target format if needed and uploads it. Returns description of uploaded file
+func (ps *AbstractFileStorageStream) flushBatchFile(ctx context.Context, bf *batchFile) (uploadedFile *ManifestFile, err error) {
+	if bf.eventsInBatch == 0 {
+		return nil, nil
+	}
+	err = bf.marshaller.Flush()
+	if err != nil {
+		return nil, errorj.Decorate(err, "failed to flush marshaller")
+	}
+	err = bf.file.Sync()
+	if err != nil {
+		return nil, errorj.Decorate(err, "failed to sync batch file")
+	}
+	workingFile := bf.file
+	needToConvert := false
+	convertStart := time.Now()
+	if !ps.targetMarshaller.Equal(bf.marshaller) {
+		needToConvert = true
+	}
+	if len(bf.skipLines) > 0 || needToConvert {
+		workingFile, err = os.CreateTemp("", path.Base(bf.file.Name())+"_2")
 		if err != nil {
-			return errorj.Decorate(err, "failed to sync batch file")
+			return nil, errorj.Decorate(err, "failed to create tmp file for deduplication")
 		}
-		workingFile := ps.batchFile
-		needToConvert := false
-		convertStart := time.Now()
-		if !ps.targetMarshaller.Equal(ps.marshaller) {
-			needToConvert = true
-		}
-		if len(ps.batchFileSkipLines) > 0 || needToConvert {
-			workingFile, err = os.CreateTemp("", path.Base(ps.batchFile.Name())+"_2")
-			if err != nil {
-				return errorj.Decorate(err, "failed to create tmp file for deduplication")
+		defer func() {
+			_ = workingFile.Close()
+			_ = os.Remove(workingFile.Name())
+		}()
+		//marshallers are stateful: each converted file needs own instance
+		targetMarshaller, _ := types2.NewMarshaller(ps.targetMarshaller.Format(), ps.targetMarshaller.Compression())
+		if needToConvert {
+			header := ps.csvHeader.ToSlice()
+			sort.Strings(header)
+			if sm, ok := targetMarshaller.(types2.SchemaMarshaller); ok {
+				sm.SetDataTypes(ps.columnTypes)
 			}
-			defer func() {
-				_ = workingFile.Close()
-				_ = os.Remove(workingFile.Name())
-			}()
-			if needToConvert {
-				header := ps.csvHeader.ToSlice()
-				sort.Strings(header)
-				err = ps.targetMarshaller.Init(workingFile, header)
-				if err != nil {
-					return errorj.Decorate(err, "failed to write header for converted batch file")
+			if fm, ok := targetMarshaller.(types2.FieldIdsMarshaller); ok && ps.fieldIds != nil {
+				fm.SetFieldIds(ps.fieldIds)
+				idHeader := make([]string, 0, len(header))
+				for _, name := range header {
+					if _, ok := ps.fieldIds[name]; ok {
+						idHeader = append(idHeader, name)
+					}
 				}
+				header = idHeader
 			}
-			file, err := os.Open(ps.batchFile.Name())
+			err = targetMarshaller.Init(workingFile, header)
 			if err != nil {
-				return errorj.Decorate(err, "failed to open tmp file")
+				return nil, errorj.Decorate(err, "failed to write header for converted batch file")
 			}
-			scanner := bufio.NewScanner(file)
-			scanner.Buffer(make([]byte, 1024*100), 1024*1024*10)
-			i := 0
-			for scanner.Scan() {
-				if !ps.batchFileSkipLines.Contains(i) {
-					if needToConvert {
-						dec := jsoniter.NewDecoder(bytes.NewReader(scanner.Bytes()))
-						dec.UseNumber()
-						obj := make(map[string]any)
-						err = dec.Decode(&obj)
-						if err != nil {
-							return errorj.Decorate(err, "failed to decode json object from batch filer")
-						}
-						ps.targetMarshaller.Marshal(obj)
-					} else {
-						_, err = workingFile.Write(scanner.Bytes())
-						if err != nil {
-							return errorj.Decorate(err, "failed write to deduplication file")
-						}
-						_, _ = workingFile.Write([]byte("\n"))
+		}
+		file, err := os.Open(bf.file.Name())
+		if err != nil {
+			return nil, errorj.Decorate(err, "failed to open tmp file")
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 1024*100), 1024*1024*10)
+		i := 0
+		for scanner.Scan() {
+			if !bf.skipLines.Contains(i) {
+				if needToConvert {
+					dec := jsoniter.NewDecoder(bytes.NewReader(scanner.Bytes()))
+					dec.UseNumber()
+					obj := make(map[string]any)
+					err = dec.Decode(&obj)
+					if err != nil {
+						return nil, errorj.Decorate(err, "failed to decode json object from batch filer")
+					}
+					err = targetMarshaller.Marshal(obj)
+					if err != nil {
+						return nil, errorj.Decorate(err, "failed to marshal object into converted batch file")
 					}
+				} else {
+					_, err = workingFile.Write(scanner.Bytes())
+					if err != nil {
+						return nil, errorj.Decorate(err, "failed write to deduplication file")
+					}
+					_, _ = workingFile.Write([]byte("\n"))
 				}
-				i++
-			}
-			if err = scanner.Err(); err != nil {
-				return errorj.Decorate(err, "failed to read batch file")
 			}
-			ps.targetMarshaller.Flush()
-			workingFile.Sync()
+			i++
 		}
-		if needToConvert {
-			logging.Infof("[%s] Converted batch file from %s to %s in %s", ps.id, ps.marshaller.Format(), ps.targetMarshaller.Format(), time.Now().Sub(convertStart))
+		if err = scanner.Err(); err != nil {
+			return nil, errorj.Decorate(err, "failed to read batch file")
 		}
-		//create file reader for workingFile
-		_, err = workingFile.Seek(0, 0)
-		if err != nil {
-			return errorj.Decorate(err, "failed to seek to beginning of tmp file")
-		}
-		fileName := ps.filenameFunc(ctx)
-		fileName = ps.fileAdapter.AddFileExtension(fileName)
-		ps.state.Representation = map[string]string{
-			"name": ps.fileAdapter.Path(fileName),
+		if needToConvert {
+			err = targetMarshaller.Flush()
+			if err != nil {
+				return nil, errorj.Decorate(err, "failed to flush converted batch file")
+			}
 		}
-		err = ps.fileAdapter.Upload(fileName, workingFile)
+		workingFile.Sync()
+	}
+	if needToConvert {
+		logging.Infof("[%s] Converted batch file from %s to %s in %s", ps.id, bf.marshaller.Format(), ps.targetMarshaller.Format(), time.Now().Sub(convertStart))
+	}
+	//create file reader for workingFile
+	_, err = workingFile.Seek(0, 0)
+	if err != nil {
+		return nil, errorj.Decorate(err, "failed to seek to beginning of tmp file")
+	}
+	fileName := ps.filenameFunc(ctx, bf.partition, bf.part)
+	fileName = ps.fileAdapter.AddFileExtension(fileName)
+	uploadedFile = &ManifestFile{name: fileName, Path: ps.fileAdapter.Path(fileName), Partition: bf.partition, RowsCount: bf.eventsInBatch - len(bf.skipLines)}
+	fileInfo, err := workingFile.Stat()
+	if err != nil {
+		return nil, errorj.Decorate(err, "failed to get size of tmp file")
+	}
+	uploadedFile.SizeBytes = fileInfo.Size()
+	if ps.manifest {
+		uploadedFile.SHA256, err = fileChecksum(workingFile)
 		if err != nil {
-			return errorj.Decorate(err, "failed to flush tmp file to the warehouse")
+			return nil, errorj.Decorate(err, "failed to calculate checksum of tmp file")
 		}
 	}
-	return nil
+	err = ps.fileAdapter.Upload(fileName, workingFile)
+	if err != nil {
+		return nil, errorj.Decorate(err, "failed to flush tmp file to the warehouse")
+	}
+	return uploadedFile, nil
 }
 
 func (ps *AbstractFileStorageStream) getPKValue(object types2.Object) (string, error) {
@@ -247,30 +447,31 @@ func (ps *AbstractFileStorageStream) getPKValue(object types2.Object) (string, e
 	return strings.Join(pkArr, "_###_"), nil
 }
 
-func (ps *AbstractFileStorageStream) writeToBatchFile(ctx context.Context, processedObject types2.Object) error {
+func (ps *AbstractFileStorageStream) writeToBatchFile(ctx context.Context, bf *batchFile, processedObject types2.Object) error {
 	header := ps.csvHeader.ToSlice()
 	sort.Strings(header)
-	ps.marshaller.Init(ps.batchFile, header)
+	bf.marshaller.Init(bf.file, header)
 	if ps.merge {
 		pk, err := ps.getPKValue(processedObject)
 		if err != nil {
 			return err
 		}
-		line, ok := ps.batchFileLinesByPK[pk]
+		//previous version of object may be in batch file of another partition
+		prev, ok := ps.batchFileLinesByPK[pk]
 		if ok {
-			ps.batchFileSkipLines.Put(line)
+			prev.batchFile.skipLines.Put(prev.line)
 		}
-		lineNumber := ps.eventsInBatch
-		if ps.marshaller.NeedHeader() {
+		lineNumber := bf.eventsInBatch
+		if bf.marshaller.NeedHeader() {
 			lineNumber++
 		}
-		ps.batchFileLinesByPK[pk] = lineNumber
+		ps.batchFileLinesByPK[pk] = batchFileLine{batchFile: bf, line: lineNumber}
 	}
-	err := ps.marshaller.Marshal(processedObject)
+	err := bf.marshaller.Marshal(processedObject)
 	if err != nil {
 		return errorj.Decorate(err, "failed to marshall into csv file")
 	}
-	ps.eventsInBatch++
+	bf.eventsInBatch++
 	return nil
 }
 
@@ -296,23 +497,59 @@ func (ps *AbstractFileStorageStream) Consume(ctx context.Context, object types2.
 		return
 	}
 
-	if ps.targetMarshaller.Format() == "csv" {
+	if ps.targetMarshaller.NeedHeader() {
 		ps.csvHeader.PutAllKeys(processedObject)
 	}
+	if _, ok := ps.targetMarshaller.(types2.SchemaMarshaller); ok || ps.manifest {
+		ps.updateColumnTypes(processedObject)
+	}
 
-	err = ps.writeToBatchFile(ctx, processedObject)
-
+	bf, err := ps.getBatchFile(hivePartitionPath(ps.partitions, processedObject, eventTime))
+	if err != nil {
+		return
+	}
+	if err = ps.writeToBatchFile(ctx, bf, processedObject); err != nil {
+		return
+	}
+	rollover, err := ps.needRollover(bf)
+	if err != nil || !rollover {
+		return
+	}
+	err = ps.rollover(ctx, bf)
 	return
 }
 
+// updateColumnTypes resolves column types of processed object and widens already known types if needed
+func (ps *AbstractFileStorageStream) updateColumnTypes(object types2.Object) {
+	for name, value := range object {
+		if value == nil {
+			continue
+		}
+		dataType, ok := types2.DefaultTypes[name]
+		if !ok {
+			var err error
+			dataType, err = types2.TypeFromValue(types2.ReformatValue(value))
+			if err != nil {
+				dataType = types2.STRING
+			}
+		}
+		if current, ok := ps.columnTypes[name]; ok {
+			if ps.columnTypeFunc != nil || current == dataType {
+				continue
+			}
+			dataType = types2.GetCommonAncestorType(current, dataType)
+		} else if ps.columnTypeFunc != nil {
+			dataType = ps.columnTypeFunc(name, dataType)
+		}
+		ps.columnTypes[name] = dataType
+	}
+}
+
 func (ps *AbstractFileStorageStream) Abort(ctx context.Context) (state bulker.State, err error) {
 	if ps.state.Status != bulker.Active {
 		return ps.state, errors.New("stream is not active")
 	}
-	if ps.batchFile != nil {
-		_ = ps.batchFile.Close()
-		_ = os.Remove(ps.batchFile.Name())
-	}
+	ps.closeBatchFiles()
 	ps.state.Status = bulker.Aborted
 	return ps.state, err
 }
@@ -337,11 +574,10 @@ func (ps *AbstractFileStorageStream) Complete(ctx context.Context) (state bulker
 	if ps.state.LastError == nil {
 		//if at least one object was inserted
 		if ps.state.SuccessfulRows > 0 {
-			if ps.batchFile != nil {
-				if err = ps.flushBatchFile(ctx); err != nil {
-					return ps.state, err
-				}
+			if err = ps.flushBatchFiles(ctx); err != nil {
+				return ps.state, err
 			}
+			err = ps.writeManifest(ps.filenameFunc(ctx, "", -1))
 		}
 		return
 	} else {
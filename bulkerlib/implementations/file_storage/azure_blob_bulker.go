@@ -0,0 +1,51 @@
+package file_storage
+
+import (
+	"errors"
+	"fmt"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	"github.com/jitsucom/bulker/bulkerlib/implementations"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+)
+
+const AzureBlobBulkerTypeId = "azure_blob"
+const AzureBlobAutocommitUnsupported = "Stream mode is not supported for Azure Blob. Please use 'batch' mode"
+
+func init() {
+	bulker.RegisterBulker(AzureBlobBulkerTypeId, NewAzureBlobBulker)
+}
+
+type AzureBlobBulker struct {
+	implementations.AzureBlob
+}
+
+func NewAzureBlobBulker(bulkerConfig bulker.Config) (bulker.Bulker, error) {
+	azureConfig := &implementations.AzureBlobConfig{}
+	if err := utils.ParseObject(bulkerConfig.DestinationConfig, azureConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse destination config: %v", err)
+	}
+	if icebergConfig, err := parseIcebergConfig(bulkerConfig.DestinationConfig, &azureConfig.FileConfig); err != nil {
+		return nil, err
+	} else if icebergConfig != nil {
+		return nil, fmt.Errorf("%s table format is not supported by %s bulker", TableFormatIceberg, AzureBlobBulkerTypeId)
+	}
+	azureAdapter, err := implementations.NewAzureBlob(azureConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &AzureBlobBulker{*azureAdapter}, nil
+}
+
+func (ab *AzureBlobBulker) CreateStream(id, tableName string, mode bulker.BulkMode, streamOptions ...bulker.StreamOption) (bulker.BulkerStream, error) {
+	switch mode {
+	case bulker.Stream:
+		return nil, errors.New(AzureBlobAutocommitUnsupported)
+	case bulker.Batch:
+		return NewTransactionalStream(id, ab, tableName, streamOptions...)
+	case bulker.ReplaceTable:
+		return NewReplaceTableStream(id, ab, tableName, streamOptions...)
+	case bulker.ReplacePartition:
+		return NewReplacePartitionStream(id, ab, tableName, streamOptions...)
+	}
+	return nil, fmt.Errorf("unsupported bulk mode: %s", mode)
+}
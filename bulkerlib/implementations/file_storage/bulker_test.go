@@ -3,22 +3,29 @@ package file_storage
 import (
 	"bufio"
 	"bytes"
-	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/memory"
+	"github.com/apache/arrow/go/v10/parquet/file"
+	"github.com/apache/arrow/go/v10/parquet/pqarrow"
 	bulker "github.com/jitsucom/bulker/bulkerlib"
 	"github.com/jitsucom/bulker/bulkerlib/implementations"
 	"github.com/jitsucom/bulker/bulkerlib/implementations/file_storage/testcontainers"
+	sqltestcontainers "github.com/jitsucom/bulker/bulkerlib/implementations/sql/testcontainers"
 	"github.com/jitsucom/bulker/bulkerlib/types"
 	"github.com/jitsucom/bulker/jitsubase/logging"
 	"github.com/jitsucom/bulker/jitsubase/timestamp"
 	"github.com/jitsucom/bulker/jitsubase/utils"
 	"github.com/jitsucom/bulker/jitsubase/uuid"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/linkedin/goavro/v2"
 	"github.com/stretchr/testify/require"
-	"io"
 	"os"
+	"path"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -29,6 +36,12 @@ var constantTime = timestamp.MustParseTime(time.RFC3339Nano, constantTimeStr)
 
 var allBulkerConfigs []string
 
+// icebergBulkerConfigs configs with iceberg table format. Not included into allBulkerConfigs because iceberg tables have own layout
+var icebergBulkerConfigs []string
+
+// versionPlaceholder placeholder of ReplaceTable stream version folder in expected file name
+const versionPlaceholder = "[VERSION]"
+
 type TestConfig struct {
 	//type of bulker destination
 	BulkerType string
@@ -41,16 +54,72 @@ type StepFunction func(testConfig bulkerTestConfig, mode bulker.BulkMode) error
 var configRegistry = map[string]any{}
 
 var minioContainer *testcontainers.MinioContainer
+var azuriteContainer *testcontainers.AzuriteContainer
 
 func init() {
+	//BULKER_TEST_CONFIGS env variable with comma separated list of bulker config ids. E.g. BULKER_TEST_CONFIGS=local,local_gzip runs tests without Docker
+	var testConfigs []string
+	if testConfigsEnv := os.Getenv("BULKER_TEST_CONFIGS"); testConfigsEnv != "" {
+		testConfigs = strings.Split(testConfigsEnv, ",")
+	}
 	gcsConfig := os.Getenv("BULKER_TEST_GCS")
 	if gcsConfig != "" {
 		configRegistry[GCSBulkerTypeId] = TestConfig{BulkerType: GCSBulkerTypeId, Config: gcsConfig}
 	}
+	localPath := filepath.Join(os.TempDir(), "bulker_file_storage_tests")
+	configRegistry[LocalBulkerTypeId] = TestConfig{BulkerType: LocalBulkerTypeId, Config: implementations.LocalConfig{
+		FileConfig: implementations.FileConfig{
+			Folder:      "tests/[DATE]",
+			Format:      types.FileFormatNDJSON,
+			Compression: types.FileCompressionNONE,
+		},
+		Path: localPath,
+	}}
+	configRegistry[LocalBulkerTypeId+"_gzip"] = TestConfig{BulkerType: LocalBulkerTypeId, Config: implementations.LocalConfig{
+		FileConfig: implementations.FileConfig{
+			Folder:      "tests",
+			Format:      types.FileFormatNDJSON,
+			Compression: types.FileCompressionGZIP,
+		},
+		Path: localPath,
+	}}
+	for _, compression := range []types.FileCompression{types.FileCompressionZSTD, types.FileCompressionLZ4} {
+		configRegistry[LocalBulkerTypeId+"_"+string(compression)] = TestConfig{BulkerType: LocalBulkerTypeId, Config: implementations.LocalConfig{
+			FileConfig: implementations.FileConfig{
+				Folder:      "tests",
+				Format:      types.FileFormatNDJSON,
+				Compression: compression,
+			},
+			Path: localPath,
+		}}
+	}
+	configRegistry[LocalBulkerTypeId+"_parquet"] = TestConfig{BulkerType: LocalBulkerTypeId, Config: implementations.LocalConfig{
+		FileConfig: implementations.FileConfig{
+			Folder:      "tests",
+			Format:      types.FileFormatPARQUET,
+			Compression: types.FileCompressionSNAPPY,
+		},
+		Path: localPath,
+	}}
+	configRegistry[LocalBulkerTypeId+"_avro"] = TestConfig{BulkerType: LocalBulkerTypeId, Config: implementations.LocalConfig{
+		FileConfig: implementations.FileConfig{
+			Folder:      "tests",
+			Format:      types.FileFormatAVRO,
+			Compression: types.FileCompressionGZIP,
+		},
+		Path: localPath,
+	}}
+	configRegistry[LocalBulkerTypeId+"_iceberg"] = TestConfig{BulkerType: LocalBulkerTypeId, Config: map[string]any{
+		"folder":      "iceberg",
+		"compression": string(types.FileCompressionZSTD),
+		"path":        localPath,
+		"tableFormat": TableFormatIceberg,
+	}}
 	s3Config := os.Getenv("BULKER_TEST_S3")
 	if s3Config != "" {
 		configRegistry[S3BulkerTypeId] = TestConfig{BulkerType: S3BulkerTypeId, Config: s3Config}
-	} else {
+	} else if len(testConfigs) == 0 || utils.ArrayContains(testConfigs, S3BulkerTypeId) || utils.ArrayContains(testConfigs, S3BulkerTypeId+"_gzip") ||
+		utils.ArrayContains(testConfigs, S3BulkerTypeId+"_iceberg") || utils.ArrayContains(testConfigs, S3BulkerTypeId+"_iceberg_jdbc") {
 		var err error
 		minioContainer, err = testcontainers.NewMinioContainer(context.Background(), "bulkertests")
 		if err != nil {
@@ -80,16 +149,75 @@ func init() {
 			AccessKey: minioContainer.AccessKey,
 			SecretKey: minioContainer.SecretKey,
 		}}
+		s3IcebergConfig := map[string]any{
+			"folder":          "iceberg",
+			"endpoint":        fmt.Sprintf("http://%s:%d", minioContainer.Host, minioContainer.Port),
+			"region":          "us-east-1",
+			"bucket":          "bulkertests",
+			"accessKeyId":     minioContainer.AccessKey,
+			"secretAccessKey": minioContainer.SecretKey,
+			"tableFormat":     TableFormatIceberg,
+		}
+		configRegistry[S3BulkerTypeId+"_iceberg"] = TestConfig{BulkerType: S3BulkerTypeId, Config: s3IcebergConfig}
+		if len(testConfigs) == 0 || utils.ArrayContains(testConfigs, S3BulkerTypeId+"_iceberg_jdbc") {
+			postgresContainer, err := sqltestcontainers.NewPostgresContainer(context.Background(), false)
+			if err != nil {
+				panic(err)
+			}
+			s3IcebergJDBCConfig := utils.MapPutAll(map[string]any{}, s3IcebergConfig)
+			s3IcebergJDBCConfig["folder"] = "iceberg_jdbc"
+			s3IcebergJDBCConfig["icebergCatalog"] = IcebergCatalogJDBC
+			s3IcebergJDBCConfig["icebergCatalogUrl"] = fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable",
+				postgresContainer.Username, postgresContainer.Password, postgresContainer.Host, postgresContainer.Port, postgresContainer.Database)
+			configRegistry[S3BulkerTypeId+"_iceberg_jdbc"] = TestConfig{BulkerType: S3BulkerTypeId, Config: s3IcebergJDBCConfig}
+		}
+	}
+
+	azureBlobConfig := os.Getenv("BULKER_TEST_AZURE_BLOB")
+	if azureBlobConfig != "" {
+		configRegistry[AzureBlobBulkerTypeId] = TestConfig{BulkerType: AzureBlobBulkerTypeId, Config: azureBlobConfig}
+	} else if len(testConfigs) == 0 || utils.ArrayContains(testConfigs, AzureBlobBulkerTypeId) || utils.ArrayContains(testConfigs, AzureBlobBulkerTypeId+"_gzip") {
+		var err error
+		azuriteContainer, err = testcontainers.NewAzuriteContainer(context.Background(), "bulkertests")
+		if err != nil {
+			panic(err)
+		}
+		configRegistry[AzureBlobBulkerTypeId+"_gzip"] = TestConfig{BulkerType: AzureBlobBulkerTypeId, Config: implementations.AzureBlobConfig{
+			FileConfig: implementations.FileConfig{
+				Folder:      "tests",
+				Format:      types.FileFormatNDJSON,
+				Compression: types.FileCompressionGZIP,
+			},
+			ConnectionString: azuriteContainer.ConnectionString,
+			Container:        "bulkertests",
+		}}
+		configRegistry[AzureBlobBulkerTypeId] = TestConfig{BulkerType: AzureBlobBulkerTypeId, Config: implementations.AzureBlobConfig{
+			FileConfig: implementations.FileConfig{
+				Folder:      "tests",
+				Format:      types.FileFormatNDJSON,
+				Compression: types.FileCompressionNONE,
+			},
+			ConnectionString: azuriteContainer.ConnectionString,
+			Container:        "bulkertests",
+		}}
 	}
 
 	allBulkerConfigs = make([]string, 0, len(configRegistry))
 	for k := range configRegistry {
-		allBulkerConfigs = append(allBulkerConfigs, k)
+		if strings.Contains(k, "_iceberg") {
+			icebergBulkerConfigs = append(icebergBulkerConfigs, k)
+		} else {
+			allBulkerConfigs = append(allBulkerConfigs, k)
+		}
+	}
+	if len(testConfigs) > 0 {
+		allBulkerConfigs = utils.ArrayIntersection(allBulkerConfigs, testConfigs)
+		icebergBulkerConfigs = utils.ArrayIntersection(icebergBulkerConfigs, testConfigs)
 	}
 	////uncomment to run test for single db only
 	//allBulkerConfigs = []string{S3BulkerTypeId}
 	//exceptBigquery = allBulkerConfigs
-	logging.Infof("Initialized bulker types: %v", allBulkerConfigs)
+	logging.Infof("Initialized bulker types: %v iceberg: %v", allBulkerConfigs, icebergBulkerConfigs)
 }
 
 type bulkerTestConfig struct {
@@ -111,6 +239,11 @@ type bulkerTestConfig struct {
 	expectedRows []map[string]any
 	//for configs that runs for multiple modes including bulker.ReplacePartition automatically adds WithPartition to streamOptions and takes into account partitionId in expected file name
 	expectPartitionId bool
+	//Hive-style partition of resulting file when WithPartitionBy option is used e.g. dt=2022-08-18
+	partition string
+	//expected number of rolled over part files when WithMaxRowsPerFile or WithMaxFileSizeBytes option is used.
+	//resulting file to check is the last part. Not supported for partitioned files
+	expectedFilesCount int
 	//map of expected errors by step name. May be error type or string. String is used for error message partial matching.
 	expectedErrors map[string]any
 	//map of function to run after each step by step name.
@@ -141,9 +274,15 @@ func (c *bulkerTestConfig) adaptConfig(mode bulker.BulkMode, fileAdapter impleme
 		ext = ".ndjson"
 	case types.FileFormatCSV:
 		ext = ".csv"
+	case types.FileFormatPARQUET:
+		ext = ".parquet"
+	case types.FileFormatAVRO:
+		ext = ".avro"
 	}
-	if fileAdapter.Compression() == types.FileCompressionGZIP {
-		ext = ext + ".gz"
+	if fileAdapter.Format() != types.FileFormatPARQUET && fileAdapter.Format() != types.FileFormatAVRO {
+		if codec := types.GetCompressionCodec(fileAdapter.Compression()); codec != nil {
+			ext = ext + codec.FileExtension()
+		}
 	}
 	switch mode {
 	case bulker.ReplacePartition:
@@ -153,10 +292,29 @@ func (c *bulkerTestConfig) adaptConfig(mode bulker.BulkMode, fileAdapter impleme
 			copy(newOptions, c.streamOptions)
 			newOptions = append(newOptions, bulker.WithPartition(partitionId))
 			c.streamOptions = newOptions
-			expectedFileName = fmt.Sprintf("%s/%s", expectedFileName, partitionId)
+			if c.partition != "" {
+				expectedFileName = fmt.Sprintf("%s/%s/part-%s", expectedFileName, c.partition, partitionId)
+			} else {
+				expectedFileName = fmt.Sprintf("%s/%s", expectedFileName, partitionId)
+			}
+		}
+	case bulker.ReplaceTable:
+		//version folder is resolved from _CURRENT pointer after stream completion
+		part := 0
+		if c.expectedFilesCount > 0 {
+			part = c.expectedFilesCount - 1
 		}
+		expectedFileName = path.Join(expectedFileName, versionPlaceholder, c.partition, fmt.Sprintf("part-%05d", part)) + ext
+		return
 	case bulker.Batch:
-		expectedFileName = fmt.Sprintf("%s_%s", expectedFileName, constantTime.Format(FilenameDate))
+		if c.partition != "" {
+			expectedFileName = fmt.Sprintf("%s/%s/part-%s", expectedFileName, c.partition, constantTime.Format(FilenameDate))
+		} else {
+			expectedFileName = fmt.Sprintf("%s_%s", expectedFileName, constantTime.Format(FilenameDate))
+		}
+	}
+	if c.expectedFilesCount > 0 {
+		expectedFileName = expectedFileName + partSuffix(c.expectedFilesCount-1)
 	}
 	expectedFileName = expectedFileName + ext
 	return
@@ -199,6 +357,62 @@ func TestBasics(t *testing.T) {
 			configIds:     allBulkerConfigs,
 			streamOptions: []bulker.StreamOption{bulker.WithPrimaryKey("id"), bulker.WithMergeRows()},
 		},
+		{
+			name:              "partitioned_no_pk",
+			modes:             []bulker.BulkMode{bulker.Batch, bulker.ReplaceTable, bulker.ReplacePartition},
+			dataFile:          "test_data/partitioned.ndjson",
+			expectPartitionId: true,
+			partition:         "dt=2022-08-18/hour=14",
+			expectedRows: []map[string]any{
+				{"_timestamp": constantTimeStr, "id": 1, "name": "test"},
+				{"_timestamp": constantTimeStr, "id": 2, "name": "test1"},
+				{"_timestamp": constantTimeStr, "id": 3, "name": "test3"},
+				{"_timestamp": constantTimeStr, "id": 5, "name": "test5"},
+			},
+			configIds:     allBulkerConfigs,
+			streamOptions: []bulker.StreamOption{bulker.WithTimestamp("_timestamp"), WithPartitionBy("dt=2006-01-02", "hour=15")},
+		},
+		{
+			name:              "partitioned_pk",
+			modes:             []bulker.BulkMode{bulker.Batch, bulker.ReplaceTable, bulker.ReplacePartition},
+			dataFile:          "test_data/partitioned.ndjson",
+			expectPartitionId: true,
+			partition:         "dt=2022-08-18/hour=14",
+			//object with id=1 is moved to dt=2022-08-19 partition by the last event
+			expectedRows: []map[string]any{
+				{"_timestamp": constantTimeStr, "id": 2, "name": "test1"},
+				{"_timestamp": constantTimeStr, "id": 3, "name": "test3"},
+				{"_timestamp": constantTimeStr, "id": 5, "name": "test5"},
+			},
+			configIds:     allBulkerConfigs,
+			streamOptions: []bulker.StreamOption{bulker.WithPrimaryKey("id"), bulker.WithMergeRows(), bulker.WithTimestamp("_timestamp"), WithPartitionBy("dt=2006-01-02", "hour=15")},
+		},
+		{
+			name:               "rollover",
+			modes:              []bulker.BulkMode{bulker.Batch, bulker.ReplaceTable, bulker.ReplacePartition},
+			dataFile:           "test_data/repeated_ids.ndjson",
+			expectPartitionId:  true,
+			expectedFilesCount: 3,
+			expectedRows: []map[string]any{
+				{"_timestamp": constantTimeStr, "id": 3, "name": "test6"},
+				{"_timestamp": constantTimeStr, "id": 1, "name": "test7"},
+			},
+			configIds:     allBulkerConfigs,
+			streamOptions: []bulker.StreamOption{bulker.WithMaxRowsPerFile(3)},
+		},
+		{
+			name:               "manifest",
+			modes:              []bulker.BulkMode{bulker.Batch, bulker.ReplaceTable},
+			dataFile:           "test_data/repeated_ids.ndjson",
+			expectedFilesCount: 3,
+			expectedRows: []map[string]any{
+				{"_timestamp": constantTimeStr, "id": 3, "name": "test6"},
+				{"_timestamp": constantTimeStr, "id": 1, "name": "test7"},
+			},
+			postStepFunctions: map[string]StepFunction{"stream_complete": checkManifest(8, 3)},
+			configIds:         allBulkerConfigs,
+			streamOptions:     []bulker.StreamOption{bulker.WithMaxRowsPerFile(3), bulker.WithTimestamp("_timestamp"), WithManifest()},
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
@@ -209,6 +423,490 @@ func TestBasics(t *testing.T) {
 	}
 }
 
+// TestReplaceTableVersions checks that ReplaceTable stream switches _CURRENT pointer to the new version and deletes files of the previous one
+func TestReplaceTableVersions(t *testing.T) {
+	timestamp.SetFreezeTime(constantTime)
+	timestamp.FreezeTime()
+	defer timestamp.UnfreezeTime()
+	tt := bulkerTestConfig{
+		name:          "replace_table_versions",
+		modes:         []bulker.BulkMode{bulker.ReplaceTable},
+		dataFile:      "test_data/partitioned.ndjson",
+		configIds:     allBulkerConfigs,
+		streamOptions: []bulker.StreamOption{bulker.WithMaxRowsPerFile(2), bulker.WithTimestamp("_timestamp"), WithPartitionBy("dt=2006-01-02")},
+	}
+	runTestConfig(t, tt, func(t *testing.T, testConfig bulkerTestConfig, mode bulker.BulkMode) {
+		reqr := require.New(t)
+		blk, err := bulker.CreateBulker(*testConfig.config)
+		reqr.NoError(err)
+		defer func() {
+			_ = blk.Close()
+		}()
+		fileAdapter := blk.(implementations.FileAdapter)
+		_, tableName, _ := testConfig.adaptConfig(mode, fileAdapter)
+		versions := make([]*CurrentVersion, 2)
+		for i := range versions {
+			stream, err := blk.CreateStream(tableName, tableName, mode, testConfig.streamOptions...)
+			reqr.NoError(err)
+			file, err := os.Open(testConfig.dataFile)
+			reqr.NoError(err)
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				obj := types.Object{}
+				decoder := jsoniter.NewDecoder(bytes.NewReader(scanner.Bytes()))
+				decoder.UseNumber()
+				reqr.NoError(decoder.Decode(&obj))
+				_, _, err = stream.Consume(context.Background(), obj)
+				reqr.NoError(err)
+			}
+			_ = file.Close()
+			_, err = stream.Complete(context.Background())
+			reqr.NoError(err)
+			versions[i], err = readCurrentVersion(fileAdapter, tableName)
+			reqr.NoError(err)
+			//dt=2022-08-18: 5 rows in 3 parts, dt=2022-08-19: 2 rows in 1 part
+			reqr.Len(versions[i].Files, 4)
+			for _, f := range versions[i].Files {
+				reqr.True(strings.HasPrefix(f, path.Join(tableName, versions[i].Version)+"/"), f)
+				_, err = fileAdapter.Download(f)
+				reqr.NoError(err)
+			}
+		}
+		reqr.NotEqual(versions[0].Version, versions[1].Version)
+		for _, f := range versions[0].Files {
+			_, err = fileAdapter.Download(f)
+			reqr.Error(err, "file of previous version wasn't deleted: %s", f)
+		}
+	})
+}
+
+// pointerReadErrorAdapter fails to download `_CURRENT` pointer with error other than 'not found'
+type pointerReadErrorAdapter struct {
+	implementations.FileAdapter
+}
+
+func (a pointerReadErrorAdapter) Download(fileName string) ([]byte, error) {
+	if path.Base(fileName) == currentPointer {
+		return nil, errors.New("permission denied")
+	}
+	return a.FileAdapter.Download(fileName)
+}
+
+// TestReplaceTablePointerReadError checks that ReplaceTable stream doesn't overwrite `_CURRENT` pointer that it failed to read
+func TestReplaceTablePointerReadError(t *testing.T) {
+	tt := bulkerTestConfig{
+		name:      "replace_table_pointer_error",
+		modes:     []bulker.BulkMode{bulker.ReplaceTable},
+		configIds: []string{LocalBulkerTypeId},
+	}
+	runTestConfig(t, tt, func(t *testing.T, testConfig bulkerTestConfig, mode bulker.BulkMode) {
+		reqr := require.New(t)
+		blk, err := bulker.CreateBulker(*testConfig.config)
+		reqr.NoError(err)
+		defer func() {
+			_ = blk.Close()
+		}()
+		fileAdapter := blk.(implementations.FileAdapter)
+		_, tableName, _ := testConfig.adaptConfig(mode, fileAdapter)
+		runStream := func(adapter implementations.FileAdapter) error {
+			stream, err := NewReplaceTableStream(tableName, adapter, tableName)
+			reqr.NoError(err)
+			_, _, err = stream.Consume(context.Background(), types.Object{"id": 1, "name": "test"})
+			reqr.NoError(err)
+			_, err = stream.Complete(context.Background())
+			return err
+		}
+		reqr.NoError(runStream(fileAdapter))
+		previous, err := readCurrentVersion(fileAdapter, tableName)
+		reqr.NoError(err)
+
+		reqr.ErrorContains(runStream(pointerReadErrorAdapter{fileAdapter}), "permission denied")
+		current, err := readCurrentVersion(fileAdapter, tableName)
+		reqr.NoError(err)
+		reqr.Equal(previous, current, "pointer must not be switched")
+		for _, f := range previous.Files {
+			_, err = fileAdapter.Download(f)
+			reqr.NoError(err, "file of current version was deleted: %s", f)
+		}
+	})
+}
+
+// replacePartitionRun single ReplacePartition stream of the same partition
+type replacePartitionRun struct {
+	//dates of objects from data file to consume. Empty - no objects
+	dates []string
+	//streamOptions options of this run in addition to test options
+	streamOptions []bulker.StreamOption
+	//dropPartitionFiles deletes list of partition files before the run. Emulates partition written before files were listed
+	dropPartitionFiles bool
+	//expectedFilesCount number of files of partition after stream completion
+	expectedFilesCount int
+}
+
+// TestReplacePartitionFiles checks that ReplacePartition stream deletes files of the previous stream of the same partition that it doesn't overwrite
+func TestReplacePartitionFiles(t *testing.T) {
+	timestamp.SetFreezeTime(constantTime)
+	timestamp.FreezeTime()
+	defer timestamp.UnfreezeTime()
+	tests := []struct {
+		name          string
+		streamOptions []bulker.StreamOption
+		//partitioned whether WithPartitionBy option is used
+		partitioned bool
+		runs        []replacePartitionRun
+	}{
+		{
+			name:          "replace_partition_hive",
+			streamOptions: []bulker.StreamOption{bulker.WithTimestamp("_timestamp"), WithPartitionBy("dt=2006-01-02")},
+			partitioned:   true,
+			runs: []replacePartitionRun{
+				{dates: []string{"2022-08-18", "2022-08-19"}, expectedFilesCount: 2},
+				//dt=2022-08-19 file must be deleted
+				{dates: []string{"2022-08-18"}, expectedFilesCount: 1},
+				//no empty file outside of Hive-style partitions
+				{expectedFilesCount: 0},
+			},
+		},
+		{
+			name:          "replace_partition_rollover",
+			streamOptions: []bulker.StreamOption{bulker.WithMaxRowsPerFile(2)},
+			runs: []replacePartitionRun{
+				{dates: []string{"2022-08-18", "2022-08-19"}, expectedFilesCount: 4},
+				//_00001.._00003 parts must be deleted
+				{dates: []string{"2022-08-19"}, expectedFilesCount: 1},
+				//empty _00000 part
+				{expectedFilesCount: 1},
+			},
+		},
+		{
+			name: "replace_partition_rollover_enabled",
+			runs: []replacePartitionRun{
+				{dates: []string{"2022-08-18", "2022-08-19"}, expectedFilesCount: 1},
+				//file without part suffix must be deleted
+				{dates: []string{"2022-08-18", "2022-08-19"}, streamOptions: []bulker.StreamOption{bulker.WithMaxRowsPerFile(3)}, expectedFilesCount: 3},
+				{dates: []string{"2022-08-19"}, expectedFilesCount: 1},
+				{dates: []string{"2022-08-18", "2022-08-19"}, streamOptions: []bulker.StreamOption{bulker.WithMaxRowsPerFile(5)}, expectedFilesCount: 2, dropPartitionFiles: true},
+			},
+		},
+	}
+	for _, tc := range tests {
+		tt := bulkerTestConfig{
+			name:              tc.name,
+			modes:             []bulker.BulkMode{bulker.ReplacePartition},
+			expectPartitionId: true,
+			dataFile:          "test_data/partitioned.ndjson",
+			configIds:         allBulkerConfigs,
+			streamOptions:     tc.streamOptions,
+		}
+		runs, partitioned := tc.runs, tc.partitioned
+		runTestConfig(t, tt, func(t *testing.T, testConfig bulkerTestConfig, mode bulker.BulkMode) {
+			reqr := require.New(t)
+			blk, err := bulker.CreateBulker(*testConfig.config)
+			reqr.NoError(err)
+			defer func() {
+				_ = blk.Close()
+			}()
+			fileAdapter := blk.(implementations.FileAdapter)
+			_, tableName, _ := testConfig.adaptConfig(mode, fileAdapter)
+			so := bulker.StreamOptions{}
+			for _, opt := range testConfig.streamOptions {
+				so.Add(opt)
+			}
+			partitionId := bulker.PartitionIdOption.Get(&so)
+			partitionFilesName := path.Join(tableName, partitionFilesFolder, partitionId)
+			var previousFiles []string
+			for i, run := range runs {
+				if run.dropPartitionFiles {
+					reqr.NoError(fileAdapter.DeleteObject(partitionFilesName))
+				}
+				streamOptions := append(append([]bulker.StreamOption{}, testConfig.streamOptions...), run.streamOptions...)
+				stream, err := blk.CreateStream(tableName, tableName, mode, streamOptions...)
+				reqr.NoError(err)
+				file, err := os.Open(testConfig.dataFile)
+				reqr.NoError(err)
+				scanner := bufio.NewScanner(file)
+				for scanner.Scan() {
+					obj := types.Object{}
+					decoder := jsoniter.NewDecoder(bytes.NewReader(scanner.Bytes()))
+					decoder.UseNumber()
+					reqr.NoError(decoder.Decode(&obj))
+					if !utils.ArrayContains(run.dates, fmt.Sprint(obj["_timestamp"])[:10]) {
+						continue
+					}
+					_, _, err = stream.Consume(context.Background(), obj)
+					reqr.NoError(err)
+				}
+				_ = file.Close()
+				_, err = stream.Complete(context.Background())
+				reqr.NoError(err)
+				partitionFilesBytes, err := fileAdapter.Download(partitionFilesName)
+				reqr.NoError(err)
+				partitionFiles := PartitionFiles{}
+				reqr.NoError(jsoniter.Unmarshal(partitionFilesBytes, &partitionFiles))
+				reqr.Len(partitionFiles.Files, run.expectedFilesCount, "run %d", i)
+				for _, f := range partitionFiles.Files {
+					_, err = fileAdapter.Download(f)
+					reqr.NoError(err, "run %d", i)
+				}
+				for _, f := range previousFiles {
+					if !utils.ArrayContains(partitionFiles.Files, f) {
+						_, err = fileAdapter.Download(f)
+						reqr.Error(err, "run %d: file of previous stream wasn't deleted: %s", i, f)
+					}
+				}
+				if run.expectedFilesCount == 0 && partitioned {
+					_, err = fileAdapter.Download(fileAdapter.AddFileExtension(path.Join(tableName, partitionId)))
+					reqr.Error(err, "run %d: empty file must not be written for Hive-style partitions", i)
+				}
+				previousFiles = partitionFiles.Files
+			}
+		})
+	}
+}
+
+// icebergBatch single stream of iceberg test
+type icebergBatch struct {
+	dataFile      string
+	partitionId   string
+	streamOptions []bulker.StreamOption
+	//expectedError error of stream creation or completion. Partial match
+	expectedError string
+}
+
+// TestIceberg commits several streams into iceberg table and checks rows of the current snapshot, schema evolution and snapshot history
+func TestIceberg(t *testing.T) {
+	timestamp.SetFreezeTime(constantTime)
+	timestamp.FreezeTime()
+	defer timestamp.UnfreezeTime()
+	tests := []struct {
+		name    string
+		mode    bulker.BulkMode
+		batches []icebergBatch
+		//expectedRows rows of the current snapshot in any order
+		expectedRows []map[string]any
+		//expectedSummaries operation and added/deleted data files of every snapshot
+		expectedSummaries []string
+	}{
+		{
+			name: "append",
+			mode: bulker.Batch,
+			batches: []icebergBatch{
+				{dataFile: "test_data/repeated_ids.ndjson", streamOptions: []bulker.StreamOption{bulker.WithMaxRowsPerFile(3)}},
+				{dataFile: "test_data/new_column.ndjson"},
+			},
+			expectedRows: []map[string]any{
+				{"_timestamp": constantTimeStr, "id": 1, "name": "test"},
+				{"_timestamp": constantTimeStr, "id": 2, "name": "test1"},
+				{"_timestamp": constantTimeStr, "id": 3, "name": "test2"},
+				{"_timestamp": constantTimeStr, "id": 3, "name": "test3"},
+				{"_timestamp": constantTimeStr, "id": 4, "name": "test4"},
+				{"_timestamp": constantTimeStr, "id": 4, "name": "test5"},
+				{"_timestamp": constantTimeStr, "id": 3, "name": "test6"},
+				{"_timestamp": constantTimeStr, "id": 1, "name": "test7"},
+				{"_timestamp": constantTimeStr, "id": 9, "name": "test9", "extra": true},
+				{"_timestamp": constantTimeStr, "id": 10, "name": "test10", "extra": false},
+			},
+			expectedSummaries: []string{"append +3 -0", "append +1 -0"},
+		},
+		{
+			name: "append_pk",
+			mode: bulker.Batch,
+			batches: []icebergBatch{
+				{dataFile: "test_data/repeated_ids.ndjson", streamOptions: []bulker.StreamOption{bulker.WithPrimaryKey("id"), bulker.WithMergeRows()}},
+				{dataFile: "test_data/repeated_ids.ndjson", partitionId: "p1", expectedError: "not partitioned"},
+			},
+			expectedRows: []map[string]any{
+				{"_timestamp": constantTimeStr, "id": 2, "name": "test1"},
+				{"_timestamp": constantTimeStr, "id": 4, "name": "test5"},
+				{"_timestamp": constantTimeStr, "id": 3, "name": "test6"},
+				{"_timestamp": constantTimeStr, "id": 1, "name": "test7"},
+			},
+			expectedSummaries: []string{"append +1 -0"},
+		},
+		{
+			name: "replace_table",
+			mode: bulker.ReplaceTable,
+			batches: []icebergBatch{
+				{dataFile: "test_data/repeated_ids.ndjson", streamOptions: []bulker.StreamOption{bulker.WithMaxRowsPerFile(3)}},
+				{dataFile: "test_data/new_column.ndjson"},
+			},
+			expectedRows: []map[string]any{
+				{"_timestamp": constantTimeStr, "id": 9, "name": "test9", "extra": true},
+				{"_timestamp": constantTimeStr, "id": 10, "name": "test10", "extra": false},
+			},
+			expectedSummaries: []string{"overwrite +3 -0", "overwrite +1 -3"},
+		},
+		{
+			name: "replace_partition",
+			mode: bulker.ReplacePartition,
+			batches: []icebergBatch{
+				{dataFile: "test_data/repeated_ids.ndjson", partitionId: "p1"},
+				{dataFile: "test_data/new_column.ndjson", partitionId: "p/2"},
+				{dataFile: "test_data/new_column.ndjson", partitionId: "p1"},
+			},
+			expectedRows: []map[string]any{
+				{"_timestamp": constantTimeStr, "id": 9, "name": "test9", "extra": true, icebergPartitionIdColumn: "p/2"},
+				{"_timestamp": constantTimeStr, "id": 10, "name": "test10", "extra": false, icebergPartitionIdColumn: "p/2"},
+				{"_timestamp": constantTimeStr, "id": 9, "name": "test9", "extra": true, icebergPartitionIdColumn: "p1"},
+				{"_timestamp": constantTimeStr, "id": 10, "name": "test10", "extra": false, icebergPartitionIdColumn: "p1"},
+			},
+			expectedSummaries: []string{"overwrite +1 -0", "overwrite +1 -0", "overwrite +1 -1"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			runTestConfig(t, bulkerTestConfig{name: tt.name, modes: []bulker.BulkMode{tt.mode}, configIds: icebergBulkerConfigs}, func(t *testing.T, testConfig bulkerTestConfig, mode bulker.BulkMode) {
+				reqr := require.New(t)
+				blk, err := bulker.CreateBulker(*testConfig.config)
+				reqr.NoError(err)
+				defer func() {
+					_ = blk.Close()
+				}()
+				warehouse := icebergWarehouseOf(blk)
+				reqr.NotNil(warehouse)
+				tableName := fmt.Sprintf("%s_%s", tt.name, uuid.NewLettersNumbers()[:8])
+				for _, batch := range tt.batches {
+					streamOptions := batch.streamOptions
+					batchMode := mode
+					if batch.partitionId != "" {
+						streamOptions = append(streamOptions, bulker.WithPartition(batch.partitionId))
+						batchMode = bulker.ReplacePartition
+					}
+					err = consumeIcebergBatch(blk, tableName, batchMode, batch.dataFile, streamOptions...)
+					if batch.expectedError != "" {
+						reqr.ErrorContains(err, batch.expectedError)
+					} else {
+						reqr.NoError(err)
+					}
+				}
+				md, rows, err := readIcebergRows(warehouse, tableName)
+				reqr.NoError(err)
+				reqr.ElementsMatch(tt.expectedRows, rows)
+				summaries := make([]string, len(md.Snapshots))
+				for i, snapshot := range md.Snapshots {
+					summaries[i] = fmt.Sprintf("%s +%s -%s", snapshot.Summary["operation"], snapshot.Summary["added-data-files"], snapshot.Summary["deleted-data-files"])
+					if i > 0 {
+						reqr.Equal(md.Snapshots[i-1].SnapshotId, *snapshot.ParentSnapshotId)
+					}
+				}
+				reqr.Equal(tt.expectedSummaries, summaries)
+				reqr.Equal(int64(len(md.Snapshots)), md.LastSequenceNumber)
+				reqr.Len(md.MetadataLog, len(md.Snapshots)-1)
+				//schema evolution: new columns are appended with new ids
+				schema := md.currentSchema()
+				columns := make([]string, len(schema.Fields))
+				for i, field := range schema.Fields {
+					columns[i] = fmt.Sprintf("%d:%s:%v", field.Id, field.Name, field.Type)
+				}
+				if _, ok := tt.expectedRows[0]["extra"]; ok {
+					reqr.Equal("extra", schema.Fields[len(schema.Fields)-1].Name, columns)
+					reqr.Equal(md.LastColumnId, schema.Fields[len(schema.Fields)-1].Id)
+				}
+				reqr.Contains(columns, fmt.Sprintf("%d:_timestamp:timestamptz", icebergFieldId(md, "_timestamp")))
+				reqr.Contains(columns, fmt.Sprintf("%d:id:long", icebergFieldId(md, "id")))
+			})
+		})
+	}
+}
+
+// consumeIcebergBatch consumes objects of data file with a single stream
+func consumeIcebergBatch(blk bulker.Bulker, tableName string, mode bulker.BulkMode, dataFile string, streamOptions ...bulker.StreamOption) error {
+	stream, err := blk.CreateStream(tableName, tableName, mode, streamOptions...)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(dataFile)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		obj := types.Object{}
+		decoder := jsoniter.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		decoder.UseNumber()
+		if err = decoder.Decode(&obj); err != nil {
+			return err
+		}
+		if _, _, err = stream.Consume(context.Background(), obj); err != nil {
+			_, _ = stream.Abort(context.Background())
+			return err
+		}
+	}
+	_, err = stream.Complete(context.Background())
+	return err
+}
+
+func icebergWarehouseOf(blk bulker.Bulker) *icebergWarehouse {
+	switch b := blk.(type) {
+	case *LocalBulker:
+		return b.iceberg
+	case *S3Bulker:
+		return b.iceberg
+	case *GCSBulker:
+		return b.iceberg
+	}
+	return nil
+}
+
+// readIcebergRows reads rows of live data files of the current snapshot of iceberg table.
+// Checks that parquet field ids of data files match ids of table schema
+func readIcebergRows(warehouse *icebergWarehouse, tableName string) (*icebergTableMetadata, []map[string]any, error) {
+	md, _, err := warehouse.loadTable(context.Background(), tableName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if md == nil {
+		return nil, nil, fmt.Errorf("table %s not found", tableName)
+	}
+	manifests, err := warehouse.manifests(md)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows := []map[string]any{}
+	for _, manifest := range manifests {
+		entries, err := warehouse.manifestEntries(manifest)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, entry := range entries {
+			if entry.Status == icebergStatusDeleted {
+				continue
+			}
+			data, err := warehouse.download(entry.DataFile.FilePath)
+			if err != nil {
+				return nil, nil, err
+			}
+			if int64(len(data)) != entry.DataFile.SizeBytes {
+				return nil, nil, fmt.Errorf("unexpected size of %s: %d expected: %d", entry.DataFile.FilePath, len(data), entry.DataFile.SizeBytes)
+			}
+			pqReader, err := file.NewParquetReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, nil, err
+			}
+			root := pqReader.MetaData().Schema.Root()
+			for i := 0; i < root.NumFields(); i++ {
+				if id := icebergFieldId(md, root.Field(i).Name()); int32(id) != root.Field(i).FieldID() {
+					return nil, nil, fmt.Errorf("unexpected field id of column %s: %d expected: %d", root.Field(i).Name(), root.Field(i).FieldID(), id)
+				}
+			}
+			_ = pqReader.Close()
+			fileRows, err := readParquetRows(data)
+			if err != nil {
+				return nil, nil, err
+			}
+			if int64(len(fileRows)) != entry.DataFile.RecordCount {
+				return nil, nil, fmt.Errorf("unexpected record count of %s: %d expected: %d", entry.DataFile.FilePath, len(fileRows), entry.DataFile.RecordCount)
+			}
+			rows = append(rows, fileRows...)
+		}
+	}
+	return md, rows, nil
+}
+
 func runTestConfig(t *testing.T, tt bulkerTestConfig, testFunc func(*testing.T, bulkerTestConfig, bulker.BulkMode)) {
 	if tt.config != nil {
 		for _, mode := range tt.modes {
@@ -219,7 +917,7 @@ func runTestConfig(t *testing.T, tt bulkerTestConfig, testFunc func(*testing.T,
 	} else {
 		for _, testConfigId := range tt.configIds {
 			newTd := tt
-			if !utils.ArrayContains(allBulkerConfigs, testConfigId) {
+			if !utils.ArrayContains(allBulkerConfigs, testConfigId) && !utils.ArrayContains(icebergBulkerConfigs, testConfigId) {
 				t.Skipf("Config '%s' is not selected for this test", testConfigId)
 			}
 			testConfigRaw, ok := configRegistry[testConfigId]
@@ -313,6 +1011,17 @@ func testStream(t *testing.T, testConfig bulkerTestConfig, mode bulker.BulkMode)
 	if testConfig.expectedState != nil {
 		reqr.Equal(*testConfig.expectedState, state)
 	}
+	if mode == bulker.ReplaceTable && err == nil {
+		current, err := readCurrentVersion(fileAdapter, tableName)
+		PostStep("read_current_version", testConfig, mode, reqr, err)
+		expectedFileName = strings.Replace(expectedFileName, versionPlaceholder, current.Version, 1)
+	}
+	if testConfig.expectedFilesCount > 0 && err == nil {
+		representation, ok := state.Representation.(RepresentationFiles)
+		reqr.True(ok)
+		reqr.Len(representation.Files, testConfig.expectedFilesCount)
+		reqr.Equal(fileAdapter.Path(expectedFileName), representation.Files[testConfig.expectedFilesCount-1])
+	}
 	if err != nil {
 		return
 	}
@@ -321,13 +1030,24 @@ func testStream(t *testing.T, testConfig bulkerTestConfig, mode bulker.BulkMode)
 		time.Sleep(1 * time.Second)
 		//Check rows count and rows data when provided
 		rowBytes, err := fileAdapter.Download(expectedFileName)
+		PostStep("download_result", testConfig, mode, reqr, err)
 		rows := []map[string]any{}
-		var reader io.Reader
-		if fileAdapter.Compression() == types.FileCompressionGZIP {
-			reader, _ = gzip.NewReader(bytes.NewReader(rowBytes))
-		} else {
-			reader = bytes.NewReader(rowBytes)
+		//binary formats with own reader
+		var readRows func([]byte) ([]map[string]any, error)
+		switch fileAdapter.Format() {
+		case types.FileFormatPARQUET:
+			readRows = readParquetRows
+		case types.FileFormatAVRO:
+			readRows = readAvroRows
 		}
+		if readRows != nil {
+			rows, err = readRows(rowBytes)
+			PostStep("select_result", testConfig, mode, reqr, err)
+			reqr.Equal(testConfig.expectedRows, rows)
+			return
+		}
+		reader, err := types.NewCompressionReader(fileAdapter.Compression(), bytes.NewReader(rowBytes))
+		PostStep("decompress_result", testConfig, mode, reqr, err)
 		//read rows from rowBytes using Scanner
 		scanner = bufio.NewScanner(reader)
 		for scanner.Scan() {
@@ -362,6 +1082,66 @@ func testStream(t *testing.T, testConfig bulkerTestConfig, mode bulker.BulkMode)
 	}
 }
 
+func readCurrentVersion(fileAdapter implementations.FileAdapter, tableName string) (*CurrentVersion, error) {
+	pointerBytes, err := fileAdapter.Download(path.Join(tableName, currentPointer))
+	if err != nil {
+		return nil, err
+	}
+	current := &CurrentVersion{}
+	err = jsoniter.Unmarshal(pointerBytes, current)
+	return current, err
+}
+
+// checkManifest returns StepFunction that checks manifest of completed batch. Supports bulker.Batch and bulker.ReplaceTable modes
+func checkManifest(expectedRowsCount, expectedFilesCount int) StepFunction {
+	return func(testConfig bulkerTestConfig, mode bulker.BulkMode) error {
+		blk, err := bulker.CreateBulker(*testConfig.config)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = blk.Close()
+		}()
+		fileAdapter := blk.(implementations.FileAdapter)
+		batch := testConfig.name + "_" + strings.ToLower(string(mode))
+		if mode == bulker.Batch {
+			batch = fmt.Sprintf("%s_%s", batch, constantTime.Format(FilenameDate))
+		} else {
+			current, err := readCurrentVersion(fileAdapter, batch)
+			if err != nil {
+				return err
+			}
+			if _, err = fileAdapter.Download(path.Join(batch, current.Version, successMarker)); err != nil {
+				return fmt.Errorf("failed to download %s marker: %v", successMarker, err)
+			}
+		}
+		manifestBytes, err := fileAdapter.Download(manifestFolder + "/" + batch + ".json")
+		if err != nil {
+			return fmt.Errorf("failed to download manifest: %v", err)
+		}
+		manifest := Manifest{}
+		if err = jsoniter.Unmarshal(manifestBytes, &manifest); err != nil {
+			return err
+		}
+		if manifest.RowsCount != expectedRowsCount || len(manifest.Files) != expectedFilesCount {
+			return fmt.Errorf("unexpected manifest rows count: %d or files count: %d", manifest.RowsCount, len(manifest.Files))
+		}
+		for _, f := range manifest.Files {
+			if f.SizeBytes == 0 || len(f.SHA256) != 64 {
+				return fmt.Errorf("unexpected manifest file: %+v", f)
+			}
+		}
+		if manifest.FirstEventTime == nil || !manifest.FirstEventTime.Equal(constantTime) || !manifest.LastEventTime.Equal(constantTime) {
+			return fmt.Errorf("unexpected manifest event time range: %v - %v", manifest.FirstEventTime, manifest.LastEventTime)
+		}
+		expectedSchema := []ManifestColumn{{Name: "_timestamp", Type: "TIMESTAMP"}, {Name: "id", Type: "INT64"}, {Name: "name", Type: "STRING"}}
+		if fmt.Sprint(manifest.Schema) != fmt.Sprint(expectedSchema) {
+			return fmt.Errorf("unexpected manifest schema: %v", manifest.Schema)
+		}
+		return nil
+	}
+}
+
 func PostStep(step string, testConfig bulkerTestConfig, mode bulker.BulkMode, reqr *require.Assertions, err error) {
 	bulkerType := testConfig.config.BulkerType
 	stepLookupKeys := []string{step + "_" + bulkerType + "_" + strings.ToLower(string(mode)), step + "_" + bulkerType, step}
@@ -398,3 +1178,96 @@ func PostStep(step string, testConfig bulkerTestConfig, mode bulker.BulkMode, re
 		panic(fmt.Sprintf("unexpected type of expected error: %T for step: %s", target, step))
 	}
 }
+
+// readParquetRows reads parquet file into rows. Timestamps are formatted as RFC3339 strings, null values are omitted
+func readParquetRows(data []byte) ([]map[string]any, error) {
+	pqReader, err := file.NewParquetReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer pqReader.Close()
+	reader, err := pqarrow.NewFileReader(pqReader, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	if err != nil {
+		return nil, err
+	}
+	table, err := reader.ReadTable(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer table.Release()
+	rows := make([]map[string]any, table.NumRows())
+	for i := range rows {
+		rows[i] = map[string]any{}
+	}
+	for c := 0; c < int(table.NumCols()); c++ {
+		column := table.Column(c)
+		rowIndex := 0
+		for _, chunk := range column.Data().Chunks() {
+			for j := 0; j < chunk.Len(); j, rowIndex = j+1, rowIndex+1 {
+				if chunk.IsNull(j) {
+					continue
+				}
+				var value any
+				switch arr := chunk.(type) {
+				case *array.String:
+					value = arr.Value(j)
+				case *array.Int64:
+					value = int(arr.Value(j))
+				case *array.Float64:
+					value = arr.Value(j)
+				case *array.Boolean:
+					value = arr.Value(j)
+				case *array.Timestamp:
+					value = time.UnixMicro(int64(arr.Value(j))).UTC().Format(time.RFC3339Nano)
+				default:
+					return nil, fmt.Errorf("unexpected parquet column type: %s", arr.DataType())
+				}
+				rows[rowIndex][column.Name()] = value
+			}
+		}
+	}
+	return rows, nil
+}
+
+// readAvroRows reads avro container file into rows. Union values are unwrapped, timestamps are formatted as RFC3339 strings, null values are omitted
+func readAvroRows(data []byte) ([]map[string]any, error) {
+	reader, err := goavro.NewOCFReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	rows := []map[string]any{}
+	for reader.Scan() {
+		datum, err := reader.Read()
+		if err != nil {
+			return nil, err
+		}
+		row := map[string]any{}
+		for name, union := range datum.(map[string]any) {
+			if union == nil {
+				continue
+			}
+			for _, value := range union.(map[string]any) {
+				switch v := value.(type) {
+				case int64:
+					row[name] = int(v)
+				case time.Time:
+					row[name] = v.UTC().Format(time.RFC3339Nano)
+				default:
+					row[name] = v
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, reader.Err()
+}
+
+// icebergFieldId returns id of column in the current schema of iceberg table. 0 if column not found
+func icebergFieldId(md *icebergTableMetadata, name string) int {
+	for _, field := range md.currentSchema().Fields {
+		if field.Name == name {
+			return field.Id
+		}
+	}
+	return 0
+}
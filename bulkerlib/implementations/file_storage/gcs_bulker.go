@@ -22,6 +22,7 @@ type GCSConfig struct {
 }
 type GCSBulker struct {
 	implementations2.GoogleCloudStorage
+	iceberg *icebergWarehouse
 }
 
 func NewGCSBulker(bulkerConfig bulker.Config) (bulker.Bulker, error) {
@@ -29,6 +30,10 @@ func NewGCSBulker(bulkerConfig bulker.Config) (bulker.Bulker, error) {
 	if err := utils.ParseObject(bulkerConfig.DestinationConfig, gcsConfig); err != nil {
 		return nil, fmt.Errorf("failed to parse destination config: %v", err)
 	}
+	icebergConfig, err := parseIcebergConfig(bulkerConfig.DestinationConfig, &gcsConfig.FileConfig)
+	if err != nil {
+		return nil, err
+	}
 	googleConfig := implementations2.GoogleConfig{
 		FileConfig: gcsConfig.FileConfig,
 		Bucket:     gcsConfig.Bucket,
@@ -39,10 +44,20 @@ func NewGCSBulker(bulkerConfig bulker.Config) (bulker.Bulker, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &GCSBulker{*gcsAdapter}, nil
+	gcs := &GCSBulker{GoogleCloudStorage: *gcsAdapter}
+	if icebergConfig != nil {
+		if gcs.iceberg, err = newIcebergWarehouse(icebergConfig, gcs, "gs://"+gcsConfig.Bucket); err != nil {
+			_ = gcsAdapter.Close()
+			return nil, err
+		}
+	}
+	return gcs, nil
 }
 
 func (gcs *GCSBulker) CreateStream(id, tableName string, mode bulker.BulkMode, streamOptions ...bulker.StreamOption) (bulker.BulkerStream, error) {
+	if gcs.iceberg != nil && mode != bulker.Stream {
+		return NewIcebergStream(id, gcs.iceberg, tableName, mode, streamOptions...)
+	}
 	switch mode {
 	case bulker.Stream:
 		return nil, errors.New(GCSAutocommitUnsupported)
@@ -55,3 +70,10 @@ func (gcs *GCSBulker) CreateStream(id, tableName string, mode bulker.BulkMode, s
 	}
 	return nil, fmt.Errorf("unsupported bulk mode: %s", mode)
 }
+
+func (gcs *GCSBulker) Close() error {
+	if gcs.iceberg != nil {
+		_ = gcs.iceberg.Close()
+	}
+	return gcs.GoogleCloudStorage.Close()
+}
@@ -0,0 +1,531 @@
+package file_storage
+
+import (
+	"bytes"
+	"fmt"
+	types2 "github.com/jitsucom/bulker/bulkerlib/types"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/linkedin/goavro/v2"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	icebergFormatVersion = 2
+	// icebergPartitionIdColumn column with partition id of ReplacePartition stream. Tables written by ReplacePartition stream
+	// are partitioned by identity of this column
+	icebergPartitionIdColumn = "__partition_id"
+	// icebergPartitionFieldId id of the first partition field. Partition field ids start from 1000 according to the spec
+	icebergPartitionFieldId = 1000
+
+	icebergStatusExisting = 0
+	icebergStatusAdded    = 1
+	icebergStatusDeleted  = 2
+
+	icebergContentData = 0
+)
+
+// icebergTableMetadata table metadata file according to Iceberg spec (format version 2)
+type icebergTableMetadata struct {
+	FormatVersion      int                           `json:"format-version"`
+	TableUUID          string                        `json:"table-uuid"`
+	Location           string                        `json:"location"`
+	LastSequenceNumber int64                         `json:"last-sequence-number"`
+	LastUpdatedMs      int64                         `json:"last-updated-ms"`
+	LastColumnId       int                           `json:"last-column-id"`
+	CurrentSchemaId    int                           `json:"current-schema-id"`
+	Schemas            []icebergSchema               `json:"schemas"`
+	DefaultSpecId      int                           `json:"default-spec-id"`
+	PartitionSpecs     []icebergPartitionSpec        `json:"partition-specs"`
+	LastPartitionId    int                           `json:"last-partition-id"`
+	DefaultSortOrderId int                           `json:"default-sort-order-id"`
+	SortOrders         []icebergSortOrder            `json:"sort-orders"`
+	Properties         map[string]string             `json:"properties"`
+	CurrentSnapshotId  *int64                        `json:"current-snapshot-id,omitempty"`
+	Refs               map[string]icebergSnapshotRef `json:"refs,omitempty"`
+	Snapshots          []icebergSnapshot             `json:"snapshots"`
+	SnapshotLog        []icebergSnapshotLogEntry     `json:"snapshot-log"`
+	MetadataLog        []icebergMetadataLogEntry     `json:"metadata-log"`
+}
+
+type icebergSchema struct {
+	Type               string         `json:"type"`
+	SchemaId           int            `json:"schema-id"`
+	IdentifierFieldIds []int          `json:"identifier-field-ids,omitempty"`
+	Fields             []icebergField `json:"fields"`
+}
+
+type icebergField struct {
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	Required bool   `json:"required"`
+	// Type name of primitive type. Nested types are kept as is but not supported for writing
+	Type any    `json:"type"`
+	Doc  string `json:"doc,omitempty"`
+}
+
+type icebergPartitionSpec struct {
+	SpecId int                     `json:"spec-id"`
+	Fields []icebergPartitionField `json:"fields"`
+}
+
+type icebergPartitionField struct {
+	SourceId  int    `json:"source-id"`
+	FieldId   int    `json:"field-id"`
+	Name      string `json:"name"`
+	Transform string `json:"transform"`
+}
+
+type icebergSortOrder struct {
+	OrderId int   `json:"order-id"`
+	Fields  []any `json:"fields"`
+}
+
+type icebergSnapshot struct {
+	SnapshotId       int64             `json:"snapshot-id"`
+	ParentSnapshotId *int64            `json:"parent-snapshot-id,omitempty"`
+	SequenceNumber   int64             `json:"sequence-number"`
+	TimestampMs      int64             `json:"timestamp-ms"`
+	ManifestList     string            `json:"manifest-list"`
+	Summary          map[string]string `json:"summary"`
+	SchemaId         *int              `json:"schema-id,omitempty"`
+}
+
+type icebergSnapshotRef struct {
+	SnapshotId int64  `json:"snapshot-id"`
+	Type       string `json:"type"`
+}
+
+type icebergSnapshotLogEntry struct {
+	SnapshotId  int64 `json:"snapshot-id"`
+	TimestampMs int64 `json:"timestamp-ms"`
+}
+
+type icebergMetadataLogEntry struct {
+	MetadataFile string `json:"metadata-file"`
+	TimestampMs  int64  `json:"timestamp-ms"`
+}
+
+func (md *icebergTableMetadata) currentSchema() *icebergSchema {
+	for i := range md.Schemas {
+		if md.Schemas[i].SchemaId == md.CurrentSchemaId {
+			return &md.Schemas[i]
+		}
+	}
+	return nil
+}
+
+func (md *icebergTableMetadata) defaultSpec() *icebergPartitionSpec {
+	for i := range md.PartitionSpecs {
+		if md.PartitionSpecs[i].SpecId == md.DefaultSpecId {
+			return &md.PartitionSpecs[i]
+		}
+	}
+	return nil
+}
+
+func (md *icebergTableMetadata) currentSnapshot() *icebergSnapshot {
+	if md.CurrentSnapshotId == nil {
+		return nil
+	}
+	for i := range md.Snapshots {
+		if md.Snapshots[i].SnapshotId == *md.CurrentSnapshotId {
+			return &md.Snapshots[i]
+		}
+	}
+	return nil
+}
+
+// validate checks that table may be written by bulker: format version 2, primitive column types that bulker writes
+// and partition spec that is either empty or identity of __partition_id column
+func (md *icebergTableMetadata) validate() error {
+	if md.FormatVersion != icebergFormatVersion {
+		return fmt.Errorf("unsupported iceberg format version: %d. Only version %d is supported", md.FormatVersion, icebergFormatVersion)
+	}
+	schema := md.currentSchema()
+	if schema == nil {
+		return fmt.Errorf("current schema %d not found in table metadata", md.CurrentSchemaId)
+	}
+	for _, field := range schema.Fields {
+		if _, err := icebergDataType(field); err != nil {
+			return err
+		}
+	}
+	spec := md.defaultSpec()
+	if spec == nil {
+		return fmt.Errorf("default partition spec %d not found in table metadata", md.DefaultSpecId)
+	}
+	if _, err := md.partitionIdSourced(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// partitionIdSourced returns true if default partition spec is identity of __partition_id column
+func (md *icebergTableMetadata) partitionIdSourced() (bool, error) {
+	spec := md.defaultSpec()
+	switch len(spec.Fields) {
+	case 0:
+		return false, nil
+	case 1:
+		field := spec.Fields[0]
+		if field.Transform == "identity" {
+			for _, f := range md.currentSchema().Fields {
+				if f.Id == field.SourceId && f.Name == icebergPartitionIdColumn {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, fmt.Errorf("unsupported partition spec: %+v. Only unpartitioned tables or tables partitioned by identity(%s) are supported", spec.Fields, icebergPartitionIdColumn)
+}
+
+// icebergType returns iceberg primitive type of bulker data type. Must match types written by ParquetMarshaller
+func icebergType(dataType types2.DataType) string {
+	switch dataType {
+	case types2.BOOL:
+		return "boolean"
+	case types2.INT64:
+		return "long"
+	case types2.FLOAT64:
+		return "double"
+	case types2.TIMESTAMP:
+		return "timestamptz"
+	default:
+		return "string"
+	}
+}
+
+// icebergDataType returns bulker data type of iceberg field. Only types that bulker writes are supported
+func icebergDataType(field icebergField) (types2.DataType, error) {
+	switch field.Type {
+	case "boolean":
+		return types2.BOOL, nil
+	case "long":
+		return types2.INT64, nil
+	case "double":
+		return types2.FLOAT64, nil
+	case "timestamptz":
+		return types2.TIMESTAMP, nil
+	case "string":
+		return types2.STRING, nil
+	default:
+		return types2.UNKNOWN, fmt.Errorf("unsupported type of iceberg column %s: %v", field.Name, field.Type)
+	}
+}
+
+// parseMetadataVersion returns version of metadata file: `v<N>.metadata.json` or `<N>-<uuid>.metadata.json`
+func parseMetadataVersion(metadataLocation string) int {
+	name := strings.TrimPrefix(path.Base(metadataLocation), "v")
+	end := 0
+	for end < len(name) && name[end] >= '0' && name[end] <= '9' {
+		end++
+	}
+	version, _ := strconv.Atoi(name[:end])
+	return version
+}
+
+// icebergDataFile data file record of manifest entry. Only required fields are written
+type icebergDataFile struct {
+	Content     int
+	FilePath    string
+	FileFormat  string
+	RecordCount int64
+	SizeBytes   int64
+	// PartitionId value of __partition_id partition field. nil for unpartitioned tables and null partition
+	PartitionId *string
+}
+
+// icebergManifestEntry entry of manifest file
+type icebergManifestEntry struct {
+	Status     int
+	SnapshotId *int64
+	// SequenceNumber data sequence number. nil means inherited from manifest file
+	SequenceNumber     *int64
+	FileSequenceNumber *int64
+	DataFile           icebergDataFile
+}
+
+// icebergManifestFile entry of manifest list
+type icebergManifestFile struct {
+	Path               string
+	Length             int64
+	PartitionSpecId    int
+	Content            int
+	SequenceNumber     int64
+	MinSequenceNumber  int64
+	AddedSnapshotId    int64
+	AddedFilesCount    int
+	ExistingFilesCount int
+	DeletedFilesCount  int
+	AddedRowsCount     int64
+	ExistingRowsCount  int64
+	DeletedRowsCount   int64
+}
+
+// inherit resolves snapshot id and sequence numbers that entry inherits from manifest file when they are null
+func (e *icebergManifestEntry) inherit(manifest icebergManifestFile) {
+	if e.SnapshotId == nil {
+		snapshotId := manifest.AddedSnapshotId
+		e.SnapshotId = &snapshotId
+	}
+	if e.SequenceNumber == nil {
+		sequenceNumber := manifest.SequenceNumber
+		e.SequenceNumber = &sequenceNumber
+	}
+	if e.FileSequenceNumber == nil {
+		sequenceNumber := manifest.SequenceNumber
+		e.FileSequenceNumber = &sequenceNumber
+	}
+}
+
+const icebergManifestListSchema = `{"type": "record", "name": "manifest_file", "fields": [
+	{"name": "manifest_path", "type": "string", "field-id": 500},
+	{"name": "manifest_length", "type": "long", "field-id": 501},
+	{"name": "partition_spec_id", "type": "int", "field-id": 502},
+	{"name": "content", "type": "int", "field-id": 517},
+	{"name": "sequence_number", "type": "long", "field-id": 515},
+	{"name": "min_sequence_number", "type": "long", "field-id": 516},
+	{"name": "added_snapshot_id", "type": "long", "field-id": 503},
+	{"name": "added_files_count", "type": "int", "field-id": 504},
+	{"name": "existing_files_count", "type": "int", "field-id": 505},
+	{"name": "deleted_files_count", "type": "int", "field-id": 506},
+	{"name": "added_rows_count", "type": "long", "field-id": 512},
+	{"name": "existing_rows_count", "type": "long", "field-id": 513},
+	{"name": "deleted_rows_count", "type": "long", "field-id": 514}
+]}`
+
+// icebergManifestSchema returns avro schema of manifest entries for partitioned by __partition_id or unpartitioned table
+func icebergManifestSchema(partitioned bool) string {
+	partitionFields := ""
+	if partitioned {
+		partitionFields = fmt.Sprintf(`{"name": %q, "type": ["null", "string"], "default": null, "field-id": %d}`, icebergPartitionIdColumn, icebergPartitionFieldId)
+	}
+	return `{"type": "record", "name": "manifest_entry", "fields": [
+	{"name": "status", "type": "int", "field-id": 0},
+	{"name": "snapshot_id", "type": ["null", "long"], "default": null, "field-id": 1},
+	{"name": "sequence_number", "type": ["null", "long"], "default": null, "field-id": 3},
+	{"name": "file_sequence_number", "type": ["null", "long"], "default": null, "field-id": 4},
+	{"name": "data_file", "type": {"type": "record", "name": "r2", "fields": [
+		{"name": "content", "type": "int", "field-id": 134},
+		{"name": "file_path", "type": "string", "field-id": 100},
+		{"name": "file_format", "type": "string", "field-id": 101},
+		{"name": "partition", "type": {"type": "record", "name": "r102", "fields": [` + partitionFields + `]}, "field-id": 102},
+		{"name": "record_count", "type": "long", "field-id": 103},
+		{"name": "file_size_in_bytes", "type": "long", "field-id": 104}
+	]}, "field-id": 2}
+]}`
+}
+
+// writeIcebergManifest encodes manifest entries into avro file. Table schema and partition spec are stored in file metadata as the spec requires
+func writeIcebergManifest(md *icebergTableMetadata, entries []icebergManifestEntry) ([]byte, error) {
+	partitioned, err := md.partitionIdSourced()
+	if err != nil {
+		return nil, err
+	}
+	schemaBytes, err := jsoniter.Marshal(md.currentSchema())
+	if err != nil {
+		return nil, err
+	}
+	specBytes, err := jsoniter.Marshal(md.defaultSpec().Fields)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]any, len(entries))
+	for i, e := range entries {
+		partition := map[string]any{}
+		if partitioned {
+			partition[icebergPartitionIdColumn] = avroUnion("string", e.DataFile.PartitionId)
+		}
+		records[i] = map[string]any{
+			"status":               int32(e.Status),
+			"snapshot_id":          avroUnion("long", e.SnapshotId),
+			"sequence_number":      avroUnion("long", e.SequenceNumber),
+			"file_sequence_number": avroUnion("long", e.FileSequenceNumber),
+			"data_file": map[string]any{
+				"content":            int32(e.DataFile.Content),
+				"file_path":          e.DataFile.FilePath,
+				"file_format":        e.DataFile.FileFormat,
+				"partition":          partition,
+				"record_count":       e.DataFile.RecordCount,
+				"file_size_in_bytes": e.DataFile.SizeBytes,
+			},
+		}
+	}
+	return writeAvro(icebergManifestSchema(partitioned), map[string][]byte{
+		"schema":            schemaBytes,
+		"schema-id":         []byte(strconv.Itoa(md.CurrentSchemaId)),
+		"partition-spec":    specBytes,
+		"partition-spec-id": []byte(strconv.Itoa(md.DefaultSpecId)),
+		"format-version":    []byte(strconv.Itoa(icebergFormatVersion)),
+		"content":           []byte("data"),
+	}, records)
+}
+
+// readIcebergManifest decodes entries of manifest file
+func readIcebergManifest(data []byte) ([]icebergManifestEntry, error) {
+	records, err := readAvro(data)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]icebergManifestEntry, len(records))
+	for i, record := range records {
+		dataFile, _ := record["data_file"].(map[string]any)
+		partition, _ := dataFile["partition"].(map[string]any)
+		entries[i] = icebergManifestEntry{
+			Status:             int(avroInt(record["status"])),
+			SnapshotId:         avroOptionalInt(record["snapshot_id"]),
+			SequenceNumber:     avroOptionalInt(record["sequence_number"]),
+			FileSequenceNumber: avroOptionalInt(record["file_sequence_number"]),
+			DataFile: icebergDataFile{
+				Content:     int(avroInt(dataFile["content"])),
+				FilePath:    avroString(dataFile["file_path"]),
+				FileFormat:  avroString(dataFile["file_format"]),
+				RecordCount: avroInt(dataFile["record_count"]),
+				SizeBytes:   avroInt(dataFile["file_size_in_bytes"]),
+			},
+		}
+		if partitionId, ok := avroUnwrap(partition[icebergPartitionIdColumn]).(string); ok {
+			entries[i].DataFile.PartitionId = &partitionId
+		}
+	}
+	return entries, nil
+}
+
+// writeIcebergManifestList encodes manifest list of snapshot into avro file
+func writeIcebergManifestList(snapshot *icebergSnapshot, manifests []icebergManifestFile) ([]byte, error) {
+	records := make([]any, len(manifests))
+	for i, m := range manifests {
+		records[i] = map[string]any{
+			"manifest_path":        m.Path,
+			"manifest_length":      m.Length,
+			"partition_spec_id":    int32(m.PartitionSpecId),
+			"content":              int32(m.Content),
+			"sequence_number":      m.SequenceNumber,
+			"min_sequence_number":  m.MinSequenceNumber,
+			"added_snapshot_id":    m.AddedSnapshotId,
+			"added_files_count":    int32(m.AddedFilesCount),
+			"existing_files_count": int32(m.ExistingFilesCount),
+			"deleted_files_count":  int32(m.DeletedFilesCount),
+			"added_rows_count":     m.AddedRowsCount,
+			"existing_rows_count":  m.ExistingRowsCount,
+			"deleted_rows_count":   m.DeletedRowsCount,
+		}
+	}
+	parentSnapshotId := "null"
+	if snapshot.ParentSnapshotId != nil {
+		parentSnapshotId = strconv.FormatInt(*snapshot.ParentSnapshotId, 10)
+	}
+	return writeAvro(icebergManifestListSchema, map[string][]byte{
+		"snapshot-id":        []byte(strconv.FormatInt(snapshot.SnapshotId, 10)),
+		"parent-snapshot-id": []byte(parentSnapshotId),
+		"sequence-number":    []byte(strconv.FormatInt(snapshot.SequenceNumber, 10)),
+		"format-version":     []byte(strconv.Itoa(icebergFormatVersion)),
+	}, records)
+}
+
+// readIcebergManifestList decodes manifest list of snapshot
+func readIcebergManifestList(data []byte) ([]icebergManifestFile, error) {
+	records, err := readAvro(data)
+	if err != nil {
+		return nil, err
+	}
+	manifests := make([]icebergManifestFile, len(records))
+	for i, record := range records {
+		manifests[i] = icebergManifestFile{
+			Path:               avroString(record["manifest_path"]),
+			Length:             avroInt(record["manifest_length"]),
+			PartitionSpecId:    int(avroInt(record["partition_spec_id"])),
+			Content:            int(avroInt(record["content"])),
+			SequenceNumber:     avroInt(record["sequence_number"]),
+			MinSequenceNumber:  avroInt(record["min_sequence_number"]),
+			AddedSnapshotId:    avroInt(record["added_snapshot_id"]),
+			AddedFilesCount:    int(avroInt(record["added_files_count"])),
+			ExistingFilesCount: int(avroInt(record["existing_files_count"])),
+			DeletedFilesCount:  int(avroInt(record["deleted_files_count"])),
+			AddedRowsCount:     avroInt(record["added_rows_count"]),
+			ExistingRowsCount:  avroInt(record["existing_rows_count"]),
+			DeletedRowsCount:   avroInt(record["deleted_rows_count"]),
+		}
+	}
+	return manifests, nil
+}
+
+func writeAvro(schema string, metadata map[string][]byte, records []any) ([]byte, error) {
+	buf := bytes.Buffer{}
+	writer, err := goavro.NewOCFWriter(goavro.OCFConfig{W: &buf, Schema: schema, CompressionName: goavro.CompressionDeflateLabel, MetaData: metadata})
+	if err != nil {
+		return nil, err
+	}
+	if len(records) > 0 {
+		if err = writer.Append(records); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func readAvro(data []byte) ([]map[string]any, error) {
+	reader, err := goavro.NewOCFReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var records []map[string]any
+	for reader.Scan() {
+		datum, err := reader.Read()
+		if err != nil {
+			return nil, err
+		}
+		record, ok := datum.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unexpected avro record type: %T", datum)
+		}
+		records = append(records, record)
+	}
+	return records, reader.Err()
+}
+
+// avroUnion returns goavro representation of optional value
+func avroUnion[T any](typeName string, value *T) any {
+	if value == nil {
+		return nil
+	}
+	return goavro.Union(typeName, *value)
+}
+
+// avroUnwrap returns value of goavro union or value itself
+func avroUnwrap(value any) any {
+	if union, ok := value.(map[string]any); ok && len(union) == 1 {
+		for _, v := range union {
+			return v
+		}
+	}
+	return value
+}
+
+func avroInt(value any) int64 {
+	switch v := avroUnwrap(value).(type) {
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	default:
+		return 0
+	}
+}
+
+func avroOptionalInt(value any) *int64 {
+	switch avroUnwrap(value).(type) {
+	case int32, int64:
+		v := avroInt(value)
+		return &v
+	default:
+		return nil
+	}
+}
+
+func avroString(value any) string {
+	s, _ := avroUnwrap(value).(string)
+	return s
+}
@@ -0,0 +1,424 @@
+package file_storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	types2 "github.com/jitsucom/bulker/bulkerlib/types"
+	"github.com/jitsucom/bulker/jitsubase/errorj"
+	"github.com/jitsucom/bulker/jitsubase/timestamp"
+	"github.com/jitsucom/bulker/jitsubase/uuid"
+	jsoniter "github.com/json-iterator/go"
+	"math/rand"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RepresentationIcebergSnapshot snapshot committed by IcebergStream
+type RepresentationIcebergSnapshot struct {
+	RepresentationFiles
+	MetadataLocation string `json:"metadataLocation"`
+	SnapshotId       int64  `json:"snapshotId"`
+}
+
+// IcebergStream writes Parquet data files of the batch and commits them into Apache Iceberg table as a single snapshot:
+// Batch - append, ReplaceTable - overwrite of the whole table, ReplacePartition - overwrite of identity(__partition_id) partition.
+// New columns are added to the table schema, types of existing columns are kept
+type IcebergStream struct {
+	AbstractFileStorageStream
+	warehouse   *icebergWarehouse
+	tableName   string
+	partitionId string
+	// writeId unique id of stream used in names of files it writes
+	writeId        string
+	manifestsCount int
+
+	loaded bool
+	// base table metadata stream is based on. nil if table doesn't exist yet
+	base         *icebergTableMetadata
+	baseLocation string
+	partitioned  bool
+	// fields of the table schema including new columns consumed by stream
+	fields       []icebergField
+	lastColumnId int
+}
+
+func NewIcebergStream(id string, warehouse *icebergWarehouse, tableName string, mode bulker.BulkMode, streamOptions ...bulker.StreamOption) (bulker.BulkerStream, error) {
+	ps := IcebergStream{warehouse: warehouse, tableName: tableName, writeId: uuid.New()}
+	var err error
+	ps.AbstractFileStorageStream, err = newAbstractFileStorageStream(id, warehouse.fileAdapter, func(ctx context.Context, partition string, part int) string {
+		if part < 0 {
+			part = 0
+		}
+		return path.Join(tableName, icebergDataFolder, partition, fmt.Sprintf("%05d-%s", part, ps.writeId))
+	}, mode, streamOptions...)
+	if err != nil {
+		return nil, err
+	}
+	if len(ps.partitions) > 0 {
+		return nil, errors.New("partitionBy option is not supported with iceberg table format")
+	}
+	if mode == bulker.ReplacePartition {
+		ps.partitionId = bulker.PartitionIdOption.Get(&ps.options)
+		if ps.partitionId == "" {
+			return nil, errors.New("WithPartition is required option for ReplacePartitionStream")
+		}
+	}
+	ps.columnTypeFunc = ps.columnType
+	ps.fieldIds = make(map[string]int)
+	return &ps, nil
+}
+
+// load loads current metadata of the table on first use
+func (ps *IcebergStream) load(ctx context.Context) error {
+	if ps.loaded {
+		return nil
+	}
+	md, metadataLocation, err := ps.warehouse.loadTable(ctx, ps.tableName)
+	if err != nil {
+		return err
+	}
+	if md != nil {
+		if err = md.validate(); err != nil {
+			return fmt.Errorf("iceberg table %s: %v", ps.tableName, err)
+		}
+		ps.partitioned, _ = md.partitionIdSourced()
+		ps.lastColumnId = md.LastColumnId
+		ps.fields = append(ps.fields, md.currentSchema().Fields...)
+		for _, field := range ps.fields {
+			ps.fieldIds[field.Name] = field.Id
+		}
+	} else if ps.mode == bulker.ReplacePartition {
+		//new table written by ReplacePartition stream is partitioned by partition id
+		ps.partitioned = true
+		ps.columnType(icebergPartitionIdColumn, types2.STRING)
+	}
+	if ps.mode == bulker.ReplacePartition && !ps.partitioned {
+		return fmt.Errorf("ReplacePartition mode requires iceberg table partitioned by identity(%s). Table %s is not partitioned", icebergPartitionIdColumn, ps.tableName)
+	}
+	if ps.partitioned {
+		ps.partitions = []hivePartition{{name: icebergPartitionIdColumn}}
+	}
+	ps.base, ps.baseLocation, ps.loaded = md, metadataLocation, true
+	return nil
+}
+
+// columnType returns type of column from the table schema. Adds new column to the schema
+func (ps *IcebergStream) columnType(name string, dataType types2.DataType) types2.DataType {
+	for _, field := range ps.fields {
+		if field.Name == name {
+			dataType, _ = icebergDataType(field)
+			return dataType
+		}
+	}
+	ps.lastColumnId++
+	ps.fields = append(ps.fields, icebergField{Id: ps.lastColumnId, Name: name, Type: icebergType(dataType)})
+	ps.fieldIds[name] = ps.lastColumnId
+	return dataType
+}
+
+func (ps *IcebergStream) Consume(ctx context.Context, object types2.Object) (state bulker.State, processedObjects []types2.Object, err error) {
+	if err = ps.load(ctx); err != nil {
+		return ps.state, nil, ps.postConsume(err)
+	}
+	if ps.partitionId != "" {
+		object[icebergPartitionIdColumn] = ps.partitionId
+	}
+	return ps.AbstractFileStorageStream.Consume(ctx, object)
+}
+
+func (ps *IcebergStream) Complete(ctx context.Context) (state bulker.State, err error) {
+	if ps.state.Status != bulker.Active {
+		return ps.state, errors.New("stream is not active")
+	}
+	defer func() {
+		state, err = ps.postComplete(err)
+	}()
+	if ps.state.LastError == nil {
+		if err = ps.load(ctx); err != nil {
+			return ps.state, err
+		}
+		//if at least one object was inserted
+		if ps.state.SuccessfulRows > 0 {
+			if err = ps.flushBatchFiles(ctx); err != nil {
+				return ps.state, err
+			}
+		} else if ps.mode == bulker.Batch {
+			//nothing to append. Overwrites commit empty snapshot that deletes existing data
+			return
+		}
+		err = ps.commit(ctx)
+		return
+	} else {
+		//if was any error - it will trigger transaction rollback in defer func
+		err = ps.state.LastError
+		return
+	}
+}
+
+// commit writes manifests, manifest list and metadata file of the new snapshot and switches catalog pointer to it.
+// Data files stay orphaned if commit fails
+func (ps *IcebergStream) commit(ctx context.Context) error {
+	now := timestamp.Now()
+	md := ps.newMetadata(now)
+	snapshot := icebergSnapshot{
+		SnapshotId:     rand.Int63(),
+		SequenceNumber: md.LastSequenceNumber + 1,
+		TimestampMs:    now.UnixMilli(),
+		SchemaId:       &md.CurrentSchemaId,
+	}
+	if current := md.currentSnapshot(); current != nil {
+		parentSnapshotId := current.SnapshotId
+		snapshot.ParentSnapshotId = &parentSnapshotId
+	}
+	manifests, err := ps.writeManifests(md, &snapshot)
+	if err != nil {
+		return err
+	}
+	manifestListBytes, err := writeIcebergManifestList(&snapshot, manifests)
+	if err != nil {
+		return errorj.Decorate(err, "failed to write iceberg manifest list")
+	}
+	manifestListName := path.Join(ps.tableName, icebergMetadataFolder, fmt.Sprintf("snap-%d-1-%s.avro", snapshot.SnapshotId, ps.writeId))
+	if err = ps.fileAdapter.UploadBytes(manifestListName, manifestListBytes); err != nil {
+		return errorj.Decorate(err, "failed to upload iceberg manifest list")
+	}
+	snapshot.ManifestList = ps.warehouse.uri(manifestListName)
+
+	md.Snapshots = append(md.Snapshots, snapshot)
+	md.CurrentSnapshotId = &snapshot.SnapshotId
+	if md.Refs == nil {
+		md.Refs = make(map[string]icebergSnapshotRef)
+	}
+	md.Refs["main"] = icebergSnapshotRef{SnapshotId: snapshot.SnapshotId, Type: "branch"}
+	md.SnapshotLog = append(md.SnapshotLog, icebergSnapshotLogEntry{SnapshotId: snapshot.SnapshotId, TimestampMs: snapshot.TimestampMs})
+	md.LastSequenceNumber = snapshot.SequenceNumber
+	metadataBytes, err := jsoniter.MarshalIndent(md, "", "  ")
+	if err != nil {
+		return errorj.Decorate(err, "failed to marshal iceberg table metadata")
+	}
+	metadataName := ps.warehouse.catalog.metadataFile(ps.tableName, parseMetadataVersion(ps.baseLocation)+1)
+	if err = ps.fileAdapter.UploadBytes(metadataName, metadataBytes); err != nil {
+		return errorj.Decorate(err, "failed to upload iceberg table metadata")
+	}
+	metadataLocation := ps.warehouse.uri(metadataName)
+	if err = ps.warehouse.catalog.commitTable(ctx, ps.tableName, ps.baseLocation, metadataLocation); err != nil {
+		return err
+	}
+	representation, _ := ps.state.Representation.(RepresentationFiles)
+	ps.state.Representation = RepresentationIcebergSnapshot{RepresentationFiles: representation, MetadataLocation: metadataLocation, SnapshotId: snapshot.SnapshotId}
+	return nil
+}
+
+// newMetadata returns copy of the base table metadata with schema evolved by new columns. Creates metadata of new table if it doesn't exist
+func (ps *IcebergStream) newMetadata(now time.Time) *icebergTableMetadata {
+	var md icebergTableMetadata
+	if ps.base != nil {
+		md = *ps.base
+		md.MetadataLog = append(md.MetadataLog, icebergMetadataLogEntry{MetadataFile: ps.baseLocation, TimestampMs: ps.base.LastUpdatedMs})
+	} else {
+		md = icebergTableMetadata{
+			FormatVersion:   icebergFormatVersion,
+			TableUUID:       uuid.New(),
+			Location:        ps.warehouse.uri(ps.tableName),
+			CurrentSchemaId: -1,
+			PartitionSpecs:  []icebergPartitionSpec{{SpecId: 0, Fields: []icebergPartitionField{}}},
+			LastPartitionId: icebergPartitionFieldId - 1,
+			SortOrders:      []icebergSortOrder{{OrderId: 0, Fields: []any{}}},
+			Properties:      map[string]string{"write.format.default": "parquet"},
+			Snapshots:       []icebergSnapshot{},
+			SnapshotLog:     []icebergSnapshotLogEntry{},
+			MetadataLog:     []icebergMetadataLogEntry{},
+		}
+		if ps.partitioned {
+			md.PartitionSpecs[0].Fields = []icebergPartitionField{{
+				SourceId:  ps.fieldIds[icebergPartitionIdColumn],
+				FieldId:   icebergPartitionFieldId,
+				Name:      icebergPartitionIdColumn,
+				Transform: "identity",
+			}}
+			md.LastPartitionId = icebergPartitionFieldId
+		}
+	}
+	if schema := md.currentSchema(); schema == nil || len(schema.Fields) != len(ps.fields) {
+		schemaId := 0
+		for _, s := range md.Schemas {
+			if s.SchemaId >= schemaId {
+				schemaId = s.SchemaId + 1
+			}
+		}
+		fields := make([]icebergField, len(ps.fields))
+		copy(fields, ps.fields)
+		md.Schemas = append(md.Schemas, icebergSchema{Type: "struct", SchemaId: schemaId, Fields: fields})
+		md.CurrentSchemaId = schemaId
+	}
+	md.LastColumnId = ps.lastColumnId
+	md.LastUpdatedMs = now.UnixMilli()
+	return &md
+}
+
+// writeManifests writes manifest of added data files and rewrites manifests of the base snapshot that have data files deleted by overwrite.
+// Returns manifest list of the new snapshot. Sets snapshot summary
+func (ps *IcebergStream) writeManifests(md *icebergTableMetadata, snapshot *icebergSnapshot) ([]icebergManifestFile, error) {
+	var manifests []icebergManifestFile
+	added := make([]icebergManifestEntry, 0, len(ps.uploadedFiles))
+	for _, f := range ps.uploadedFiles {
+		dataFile := icebergDataFile{
+			Content:     icebergContentData,
+			FilePath:    ps.warehouse.uri(f.name),
+			FileFormat:  "PARQUET",
+			RecordCount: int64(f.RowsCount),
+			SizeBytes:   f.SizeBytes,
+		}
+		if ps.partitioned {
+			dataFile.PartitionId = icebergPartitionValue(f.Partition)
+		}
+		added = append(added, icebergManifestEntry{Status: icebergStatusAdded, SnapshotId: &snapshot.SnapshotId, DataFile: dataFile})
+	}
+	if len(added) > 0 {
+		manifest, err := ps.writeManifest(md, snapshot, added)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, manifest)
+	}
+	var previous []icebergManifestFile
+	if ps.base != nil {
+		var err error
+		if previous, err = ps.warehouse.manifests(ps.base); err != nil {
+			return nil, err
+		}
+	}
+	for _, manifest := range previous {
+		//delete files are kept by overwrites too: they don't apply to data files with newer sequence numbers
+		if ps.mode == bulker.Batch || manifest.Content != icebergContentData {
+			manifests = append(manifests, manifest)
+			continue
+		}
+		entries, err := ps.warehouse.manifestEntries(manifest)
+		if err != nil {
+			return nil, err
+		}
+		rewritten := make([]icebergManifestEntry, 0, len(entries))
+		live, deleted := 0, 0
+		for _, e := range entries {
+			if e.Status == icebergStatusDeleted {
+				continue
+			}
+			if ps.mode == bulker.ReplaceTable || (e.DataFile.PartitionId != nil && *e.DataFile.PartitionId == ps.partitionId) {
+				e.Status = icebergStatusDeleted
+				e.SnapshotId = &snapshot.SnapshotId
+				deleted++
+			} else {
+				e.Status = icebergStatusExisting
+				live++
+			}
+			rewritten = append(rewritten, e)
+		}
+		if deleted == 0 {
+			if live > 0 {
+				manifests = append(manifests, manifest)
+			}
+			continue
+		}
+		manifest, err = ps.writeManifest(md, snapshot, rewritten)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, manifest)
+	}
+	snapshot.Summary = icebergSnapshotSummary(ps.mode, manifests, snapshot.SnapshotId)
+	return manifests, nil
+}
+
+// writeManifest uploads manifest file with entries and returns its manifest list entry
+func (ps *IcebergStream) writeManifest(md *icebergTableMetadata, snapshot *icebergSnapshot, entries []icebergManifestEntry) (icebergManifestFile, error) {
+	manifestBytes, err := writeIcebergManifest(md, entries)
+	if err != nil {
+		return icebergManifestFile{}, errorj.Decorate(err, "failed to write iceberg manifest")
+	}
+	manifestName := path.Join(ps.tableName, icebergMetadataFolder, fmt.Sprintf("%s-m%d.avro", ps.writeId, ps.manifestsCount))
+	ps.manifestsCount++
+	if err = ps.fileAdapter.UploadBytes(manifestName, manifestBytes); err != nil {
+		return icebergManifestFile{}, errorj.Decorate(err, "failed to upload iceberg manifest")
+	}
+	manifest := icebergManifestFile{
+		Path:              ps.warehouse.uri(manifestName),
+		Length:            int64(len(manifestBytes)),
+		PartitionSpecId:   md.DefaultSpecId,
+		Content:           icebergContentData,
+		SequenceNumber:    snapshot.SequenceNumber,
+		MinSequenceNumber: snapshot.SequenceNumber,
+		AddedSnapshotId:   snapshot.SnapshotId,
+	}
+	for _, e := range entries {
+		switch e.Status {
+		case icebergStatusAdded:
+			manifest.AddedFilesCount++
+			manifest.AddedRowsCount += e.DataFile.RecordCount
+		case icebergStatusExisting:
+			manifest.ExistingFilesCount++
+			manifest.ExistingRowsCount += e.DataFile.RecordCount
+		case icebergStatusDeleted:
+			manifest.DeletedFilesCount++
+			manifest.DeletedRowsCount += e.DataFile.RecordCount
+		}
+		if e.Status != icebergStatusDeleted && e.SequenceNumber != nil && *e.SequenceNumber < manifest.MinSequenceNumber {
+			manifest.MinSequenceNumber = *e.SequenceNumber
+		}
+	}
+	return manifest, nil
+}
+
+// icebergSnapshotSummary returns summary of snapshot with totals calculated from its manifest list
+func icebergSnapshotSummary(mode bulker.BulkMode, manifests []icebergManifestFile, snapshotId int64) map[string]string {
+	var addedFiles, deletedFiles, totalFiles, totalDeleteFiles int
+	var addedRecords, deletedRecords, totalRecords int64
+	for _, m := range manifests {
+		if m.Content != icebergContentData {
+			totalDeleteFiles += m.AddedFilesCount + m.ExistingFilesCount
+			continue
+		}
+		if m.AddedSnapshotId == snapshotId {
+			addedFiles += m.AddedFilesCount
+			addedRecords += m.AddedRowsCount
+			deletedFiles += m.DeletedFilesCount
+			deletedRecords += m.DeletedRowsCount
+		}
+		totalFiles += m.AddedFilesCount + m.ExistingFilesCount
+		totalRecords += m.AddedRowsCount + m.ExistingRowsCount
+	}
+	summary := map[string]string{
+		"operation":          "overwrite",
+		"added-data-files":   strconv.Itoa(addedFiles),
+		"added-records":      strconv.FormatInt(addedRecords, 10),
+		"deleted-data-files": strconv.Itoa(deletedFiles),
+		"deleted-records":    strconv.FormatInt(deletedRecords, 10),
+		"total-data-files":   strconv.Itoa(totalFiles),
+		"total-records":      strconv.FormatInt(totalRecords, 10),
+		"total-delete-files": strconv.Itoa(totalDeleteFiles),
+	}
+	switch mode {
+	case bulker.Batch:
+		summary["operation"] = "append"
+	case bulker.ReplacePartition:
+		summary["replace-partitions"] = "true"
+	}
+	return summary
+}
+
+// icebergPartitionValue returns value of __partition_id partition from Hive-style partition path of data file
+func icebergPartitionValue(partition string) *string {
+	value := strings.TrimPrefix(partition, icebergPartitionIdColumn+"=")
+	if value == hiveDefaultPartition {
+		return nil
+	}
+	if unescaped, err := url.PathUnescape(value); err == nil {
+		value = unescaped
+	}
+	return &value
+}
@@ -0,0 +1,72 @@
+package file_storage
+
+import (
+	"errors"
+	"fmt"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	"github.com/jitsucom/bulker/bulkerlib/implementations"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	"path/filepath"
+)
+
+const LocalBulkerTypeId = "local"
+const LocalAutocommitUnsupported = "Stream mode is not supported for local file storage. Please use 'batch' mode"
+
+func init() {
+	bulker.RegisterBulker(LocalBulkerTypeId, NewLocalBulker)
+}
+
+type LocalBulker struct {
+	implementations.Local
+	iceberg *icebergWarehouse
+}
+
+func NewLocalBulker(bulkerConfig bulker.Config) (bulker.Bulker, error) {
+	localConfig := &implementations.LocalConfig{}
+	if err := utils.ParseObject(bulkerConfig.DestinationConfig, localConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse destination config: %v", err)
+	}
+	icebergConfig, err := parseIcebergConfig(bulkerConfig.DestinationConfig, &localConfig.FileConfig)
+	if err != nil {
+		return nil, err
+	}
+	localAdapter, err := implementations.NewLocal(localConfig)
+	if err != nil {
+		return nil, err
+	}
+	l := &LocalBulker{Local: *localAdapter}
+	if icebergConfig != nil {
+		rootPath, err := filepath.Abs(localConfig.Path)
+		if err != nil {
+			return nil, err
+		}
+		if l.iceberg, err = newIcebergWarehouse(icebergConfig, l, "file://"+filepath.ToSlash(rootPath)); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func (l *LocalBulker) CreateStream(id, tableName string, mode bulker.BulkMode, streamOptions ...bulker.StreamOption) (bulker.BulkerStream, error) {
+	if l.iceberg != nil && mode != bulker.Stream {
+		return NewIcebergStream(id, l.iceberg, tableName, mode, streamOptions...)
+	}
+	switch mode {
+	case bulker.Stream:
+		return nil, errors.New(LocalAutocommitUnsupported)
+	case bulker.Batch:
+		return NewTransactionalStream(id, l, tableName, streamOptions...)
+	case bulker.ReplaceTable:
+		return NewReplaceTableStream(id, l, tableName, streamOptions...)
+	case bulker.ReplacePartition:
+		return NewReplacePartitionStream(id, l, tableName, streamOptions...)
+	}
+	return nil, fmt.Errorf("unsupported bulk mode: %s", mode)
+}
+
+func (l *LocalBulker) Close() error {
+	if l.iceberg != nil {
+		_ = l.iceberg.Close()
+	}
+	return l.Local.Close()
+}
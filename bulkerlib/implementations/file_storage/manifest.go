@@ -0,0 +1,121 @@
+package file_storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	types2 "github.com/jitsucom/bulker/bulkerlib/types"
+	"github.com/jitsucom/bulker/jitsubase/errorj"
+	"github.com/jitsucom/bulker/jitsubase/timestamp"
+	jsoniter "github.com/json-iterator/go"
+	"io"
+	"path"
+	"sort"
+	"time"
+)
+
+const (
+	// manifestFolder folder of batch manifests relative to the destination folder
+	manifestFolder = "_manifest"
+	// successMarker Spark-style marker of completed ReplaceTable output
+	successMarker = "_SUCCESS"
+)
+
+// Manifest describes completed batch of file storage stream.
+// Written after all data files of the batch so downstream loaders may rely on its presence
+type Manifest struct {
+	Batch          string                 `json:"batch"`
+	Mode           bulker.BulkMode        `json:"mode"`
+	Format         types2.FileFormat      `json:"format"`
+	Compression    types2.FileCompression `json:"compression,omitempty"`
+	CreatedAt      time.Time              `json:"createdAt"`
+	FirstEventTime *time.Time             `json:"firstEventTime,omitempty"`
+	LastEventTime  *time.Time             `json:"lastEventTime,omitempty"`
+	RowsCount      int                    `json:"rowsCount"`
+	Files          []ManifestFile         `json:"files"`
+	Schema         []ManifestColumn       `json:"schema"`
+}
+
+// ManifestFile data file of the batch
+type ManifestFile struct {
+	// name of file relative to the destination folder
+	name      string
+	Path      string `json:"path"`
+	Partition string `json:"partition,omitempty"`
+	RowsCount int    `json:"rowsCount"`
+	SizeBytes int64  `json:"sizeBytes"`
+	SHA256    string `json:"sha256,omitempty"`
+}
+
+// ManifestColumn column of the batch schema
+type ManifestColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// fileChecksum returns hex encoded SHA-256 of file content and rewinds file to the beginning
+func fileChecksum(file io.ReadSeeker) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// writeManifest uploads manifest of the batch to `_manifest/<batch>.json` if ManifestOption is enabled
+func (ps *AbstractFileStorageStream) writeManifest(batch string) error {
+	if !ps.manifest {
+		return nil
+	}
+	manifest := Manifest{
+		Batch:       batch,
+		Mode:        ps.mode,
+		Format:      ps.fileAdapter.Format(),
+		Compression: ps.fileAdapter.Compression(),
+		CreatedAt:   timestamp.Now(),
+		Files:       ps.uploadedFiles,
+	}
+	if manifest.Files == nil {
+		manifest.Files = []ManifestFile{}
+	}
+	if !ps.firstEventTime.IsZero() {
+		firstEventTime, lastEventTime := ps.firstEventTime.UTC(), ps.lastEventTime.UTC()
+		manifest.FirstEventTime, manifest.LastEventTime = &firstEventTime, &lastEventTime
+	}
+	for _, file := range ps.uploadedFiles {
+		manifest.RowsCount += file.RowsCount
+	}
+	manifest.Schema = make([]ManifestColumn, 0, len(ps.columnTypes))
+	for name, dataType := range ps.columnTypes {
+		manifest.Schema = append(manifest.Schema, ManifestColumn{Name: name, Type: dataType.String()})
+	}
+	sort.Slice(manifest.Schema, func(i, j int) bool {
+		return manifest.Schema[i].Name < manifest.Schema[j].Name
+	})
+	manifestBytes, err := jsoniter.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return errorj.Decorate(err, "failed to marshal manifest")
+	}
+	manifestName := path.Join(manifestFolder, batch+".json")
+	if err = ps.fileAdapter.UploadBytes(manifestName, manifestBytes); err != nil {
+		return errorj.Decorate(err, "failed to upload manifest")
+	}
+	representation, _ := ps.state.Representation.(RepresentationFiles)
+	representation.Manifest = ps.fileAdapter.Path(manifestName)
+	ps.state.Representation = representation
+	return nil
+}
+
+// writeSuccessMarker uploads empty `_SUCCESS` object into the output folder if ManifestOption is enabled
+func (ps *AbstractFileStorageStream) writeSuccessMarker(folder string) error {
+	if !ps.manifest {
+		return nil
+	}
+	if err := ps.fileAdapter.UploadBytes(path.Join(folder, successMarker), []byte{}); err != nil {
+		return errorj.Decorate(err, "failed to upload _SUCCESS marker")
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package file_storage
+
+import (
+	"fmt"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	types2 "github.com/jitsucom/bulker/bulkerlib/types"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	"strings"
+	"time"
+)
+
+const hiveDefaultPartition = "__HIVE_DEFAULT_PARTITION__"
+
+var (
+	// PartitionByOption list of Hive-style partitions of uploaded files. Each element is either:
+	//
+	//	"<name>=<go time layout>" – partition by event time (TimestampOption column or processing time in UTC) e.g. "dt=2006-01-02" or "hour=15"
+	//	"<column>" – partition by value of column e.g. "country"
+	//
+	// Produces file paths like: table/dt=2024-01-01/hour=13/part-xxx.ndjson.gz
+	PartitionByOption = bulker.ImplementationOption[[]string]{
+		Key: "partitionBy",
+		AdvancedParseFunc: func(o *bulker.ImplementationOption[[]string], serializedValue any) (bulker.StreamOption, error) {
+			switch v := serializedValue.(type) {
+			case []string:
+				return withPartitionBy(o, v...), nil
+			case []any:
+				partitions := make([]string, len(v))
+				for i, p := range v {
+					s, ok := p.(string)
+					if !ok {
+						return nil, fmt.Errorf("failed to parse 'partitionBy' option: %v incorrect element type: %T expected string", p, p)
+					}
+					partitions[i] = s
+				}
+				return withPartitionBy(o, partitions...), nil
+			case string:
+				if v == "" {
+					return func(options *bulker.StreamOptions) {}, nil
+				}
+				return withPartitionBy(o, strings.Split(v, ",")...), nil
+			default:
+				return nil, fmt.Errorf("failed to parse 'partitionBy' option: %v incorrect type: %T expected string or []string", v, v)
+			}
+		},
+	}
+
+	// ManifestOption - write manifest object `_manifest/<batch>.json` with list of data files, row counts, sizes, checksums,
+	// schema and event time range after all data files of the batch are uploaded. ReplaceTable stream also writes `_SUCCESS` marker
+	ManifestOption = bulker.ImplementationOption[bool]{
+		Key:       "manifest",
+		ParseFunc: utils.ParseBool,
+	}
+)
+
+func init() {
+	bulker.RegisterOption(&PartitionByOption)
+	bulker.RegisterOption(&ManifestOption)
+}
+
+func withPartitionBy(o *bulker.ImplementationOption[[]string], partitions ...string) bulker.StreamOption {
+	return func(options *bulker.StreamOptions) {
+		o.Set(options, partitions)
+	}
+}
+
+// WithPartitionBy - Hive-style partitions of uploaded files. See PartitionByOption
+func WithPartitionBy(partitions ...string) bulker.StreamOption {
+	return withPartitionBy(&PartitionByOption, partitions...)
+}
+
+// hivePartition single level of Hive-style partitioning
+type hivePartition struct {
+	name string
+	//timeLayout go time layout for partitions by event time. Empty for partitions by column value
+	timeLayout string
+}
+
+func parsePartitionBy(partitionBy []string) ([]hivePartition, error) {
+	partitions := make([]hivePartition, 0, len(partitionBy))
+	for _, p := range partitionBy {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		name, layout, isTime := strings.Cut(p, "=")
+		if name == "" || (isTime && layout == "") {
+			return nil, fmt.Errorf("invalid partition: %q. Expected <column> or <name>=<time layout>", p)
+		}
+		partitions = append(partitions, hivePartition{name: name, timeLayout: layout})
+	}
+	return partitions, nil
+}
+
+// hivePartitionPath returns Hive-style partition path for object e.g. dt=2024-01-01/hour=13
+func hivePartitionPath(partitions []hivePartition, object types2.Object, eventTime time.Time) string {
+	if len(partitions) == 0 {
+		return ""
+	}
+	parts := make([]string, len(partitions))
+	for i, p := range partitions {
+		var value string
+		if p.timeLayout != "" {
+			value = eventTime.UTC().Format(p.timeLayout)
+		} else if v, ok := object[p.name]; ok && v != nil {
+			value = fmt.Sprint(v)
+		}
+		if value == "" {
+			value = hiveDefaultPartition
+		}
+		parts[i] = hiveEscape(p.name) + "=" + hiveEscape(value)
+	}
+	return strings.Join(parts, "/")
+}
+
+// hiveEscape escapes characters that are not allowed in Hive partition path the same way as Hive does
+func hiveEscape(s string) string {
+	var sb strings.Builder
+	for _, c := range []byte(s) {
+		if c < 0x20 || c == 0x7F || strings.IndexByte("\"#%'*/:=?\\{[]^", c) >= 0 {
+			sb.WriteString(fmt.Sprintf("%%%02X", c))
+		} else {
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+func withManifest(o *bulker.ImplementationOption[bool], b bool) bulker.StreamOption {
+	return func(options *bulker.StreamOptions) {
+		o.Set(options, b)
+	}
+}
+
+// WithManifest - write manifest of the batch after all data files. See ManifestOption
+func WithManifest() bulker.StreamOption {
+	return withManifest(&ManifestOption, true)
+}
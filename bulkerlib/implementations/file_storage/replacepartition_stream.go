@@ -6,14 +6,33 @@ import (
 	"fmt"
 	bulker "github.com/jitsucom/bulker/bulkerlib"
 	"github.com/jitsucom/bulker/bulkerlib/implementations"
+	"github.com/jitsucom/bulker/jitsubase/errorj"
+	"github.com/jitsucom/bulker/jitsubase/logging"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	jsoniter "github.com/json-iterator/go"
+	"path"
 )
 
+// partitionFilesFolder folder inside table folder with lists of files written by ReplacePartition streams
+const partitionFilesFolder = "_partitions"
+
+// PartitionFiles content of `<table>/_partitions/<partitionId>` object.
+// Lists files written by the last ReplacePartition stream of the partition,
+// so the next stream can delete files that it doesn't overwrite
+type PartitionFiles struct {
+	PartitionId string `json:"partitionId"`
+	// Files names of data files of the partition relative to the destination folder
+	Files []string `json:"files"`
+}
+
 type ReplacePartitionStream struct {
 	AbstractFileStorageStream
+	tableName   string
+	partitionId string
 }
 
 func NewReplacePartitionStream(id string, p implementations.FileAdapter, tableName string, streamOptions ...bulker.StreamOption) (bulker.BulkerStream, error) {
-	ps := ReplacePartitionStream{}
+	ps := ReplacePartitionStream{tableName: tableName}
 	so := bulker.StreamOptions{}
 	for _, opt := range streamOptions {
 		so.Add(opt)
@@ -22,9 +41,13 @@ func NewReplacePartitionStream(id string, p implementations.FileAdapter, tableNa
 	if partitionId == "" {
 		return nil, errors.New("WithPartition is required option for ReplacePartitionStream")
 	}
+	ps.partitionId = partitionId
 	var err error
-	filenameFunc := func(ctx context.Context) string {
-		return fmt.Sprintf("%s/%s", tableName, partitionId)
+	filenameFunc := func(ctx context.Context, partition string, part int) string {
+		if partition != "" {
+			return fmt.Sprintf("%s/%s/part-%s%s", tableName, partition, partitionId, partSuffix(part))
+		}
+		return fmt.Sprintf("%s/%s%s", tableName, partitionId, partSuffix(part))
 	}
 	ps.AbstractFileStorageStream, err = newAbstractFileStorageStream(id, p, filenameFunc, bulker.ReplacePartition, streamOptions...)
 	if err != nil {
@@ -43,17 +66,27 @@ func (ps *ReplacePartitionStream) Complete(ctx context.Context) (state bulker.St
 	if ps.state.LastError == nil {
 		//if at least one object was inserted
 		if ps.state.SuccessfulRows > 0 {
-			if ps.batchFile != nil {
-				if err = ps.flushBatchFile(ctx); err != nil {
-					return ps.state, err
-				}
+			if err = ps.flushBatchFiles(ctx); err != nil {
+				return ps.state, err
 			}
-		} else {
-			//for ReplacePartitionStream  we should replace existing file with empty one
-			if err = ps.fileAdapter.UploadBytes(ps.filenameFunc(ctx), []byte{}); err != nil {
+		} else if len(ps.partitions) == 0 {
+			//for ReplacePartitionStream  we should replace existing file with empty one.
+			//Hive-style partitioned files of previous stream are just deleted below
+			if err = ps.uploadEmptyFile(ctx); err != nil {
 				return ps.state, err
 			}
 		}
+		var previous *PartitionFiles
+		if previous, err = ps.readPartitionFiles(); err != nil {
+			return ps.state, err
+		}
+		if err = ps.deleteStaleFiles(ctx, previous); err != nil {
+			return ps.state, err
+		}
+		if err = ps.writePartitionFiles(); err != nil {
+			return ps.state, err
+		}
+		err = ps.writeManifest(ps.filenameFunc(ctx, "", -1))
 		return
 	} else {
 		//if was any error - it will trigger transaction rollback in defer func
@@ -61,3 +94,68 @@ func (ps *ReplacePartitionStream) Complete(ctx context.Context) (state bulker.St
 		return
 	}
 }
+
+// partitionFilesName name of the object with list of files of the partition
+func (ps *ReplacePartitionStream) partitionFilesName() string {
+	return path.Join(ps.tableName, partitionFilesFolder, ps.partitionId)
+}
+
+// readPartitionFiles returns list of files written by the previous stream of the partition or nil if there was no such stream
+func (ps *ReplacePartitionStream) readPartitionFiles() (*PartitionFiles, error) {
+	partitionFilesName := ps.partitionFilesName()
+	partitionFilesBytes, err := ps.fileAdapter.Download(partitionFilesName)
+	if err != nil {
+		if implementations.IsObjectNotFound(err) {
+			return nil, nil
+		}
+		return nil, errorj.Decorate(err, "failed to read list of partition files")
+	}
+	previous := &PartitionFiles{}
+	if err = jsoniter.Unmarshal(partitionFilesBytes, previous); err != nil {
+		logging.Warnf("[%s] failed to parse list of partition files %s: %v", ps.id, partitionFilesName, err)
+		return nil, nil
+	}
+	return previous, nil
+}
+
+// deleteStaleFiles deletes files of the previous stream of the partition that weren't overwritten by this stream,
+// e.g. parts left after rollover of bigger batch. When there is no list of partition files yet - deletes single file
+// written by ReplacePartition stream before rollover and Hive-style partitioning were introduced.
+// Readers list table folder so partition is not replaced until stale files are deleted
+func (ps *ReplacePartitionStream) deleteStaleFiles(ctx context.Context, previous *PartitionFiles) error {
+	var staleFiles []string
+	if previous == nil {
+		staleFiles = []string{ps.fileAdapter.AddFileExtension(ps.filenameFunc(ctx, "", -1))}
+	} else {
+		staleFiles = previous.Files
+	}
+	written := utils.NewSet[string]()
+	for _, f := range ps.uploadedFiles {
+		written.Put(f.name)
+	}
+	for _, f := range staleFiles {
+		if written.Contains(f) {
+			continue
+		}
+		if err := ps.fileAdapter.DeleteObject(f); err != nil && !implementations.IsObjectNotFound(err) {
+			return errorj.Decorate(err, "failed to delete file %s of previous stream of partition", f)
+		}
+	}
+	return nil
+}
+
+// writePartitionFiles overwrites list of partition files with files written by this stream
+func (ps *ReplacePartitionStream) writePartitionFiles() error {
+	current := PartitionFiles{PartitionId: ps.partitionId, Files: make([]string, 0, len(ps.uploadedFiles))}
+	for _, f := range ps.uploadedFiles {
+		current.Files = append(current.Files, f.name)
+	}
+	partitionFilesBytes, err := jsoniter.Marshal(current)
+	if err != nil {
+		return errorj.Decorate(err, "failed to marshal list of partition files")
+	}
+	if err = ps.fileAdapter.UploadBytes(ps.partitionFilesName(), partitionFilesBytes); err != nil {
+		return errorj.Decorate(err, "failed to write list of partition files")
+	}
+	return nil
+}
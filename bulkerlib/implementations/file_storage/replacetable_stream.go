@@ -3,20 +3,45 @@ package file_storage
 import (
 	"context"
 	"errors"
+	"fmt"
 	bulker "github.com/jitsucom/bulker/bulkerlib"
 	"github.com/jitsucom/bulker/bulkerlib/implementations"
+	"github.com/jitsucom/bulker/jitsubase/errorj"
+	"github.com/jitsucom/bulker/jitsubase/logging"
+	"github.com/jitsucom/bulker/jitsubase/timestamp"
+	"github.com/jitsucom/bulker/jitsubase/uuid"
+	jsoniter "github.com/json-iterator/go"
+	"path"
 )
 
+// currentPointer name of the object that points to the current version of ReplaceTable stream output
+const currentPointer = "_CURRENT"
+
+// CurrentVersion content of `<table>/_CURRENT` pointer object.
+// Readers should read files of the version listed in the pointer instead of listing `<table>/` folder
+type CurrentVersion struct {
+	Version string `json:"version"`
+	// Files names of data files of the version relative to the destination folder
+	Files []string `json:"files"`
+}
+
+// ReplaceTableStream writes every replacement into the new versioned prefix `<table>/<version>/`
+// and atomically switches `<table>/_CURRENT` pointer to it. Files of the previous version are deleted afterwards
 type ReplaceTableStream struct {
 	AbstractFileStorageStream
+	tableName string
+	version   string
 }
 
 func NewReplaceTableStream(id string, p implementations.FileAdapter, tableName string, streamOptions ...bulker.StreamOption) (bulker.BulkerStream, error) {
-	ps := ReplaceTableStream{}
-
+	ps := ReplaceTableStream{tableName: tableName}
+	ps.version = fmt.Sprintf("v%s_%s", timestamp.Now().Format("20060102T150405"), uuid.NewLettersNumbers()[:8])
 	var err error
-	ps.AbstractFileStorageStream, err = newAbstractFileStorageStream(id, p, func(ctx context.Context) string {
-		return tableName
+	ps.AbstractFileStorageStream, err = newAbstractFileStorageStream(id, p, func(ctx context.Context, partition string, part int) string {
+		if part < 0 {
+			part = 0
+		}
+		return path.Join(ps.versionFolder(), partition, fmt.Sprintf("part-%05d", part))
 	}, bulker.ReplaceTable, streamOptions...)
 	if err != nil {
 		return nil, err
@@ -24,6 +49,11 @@ func NewReplaceTableStream(id string, p implementations.FileAdapter, tableName s
 	return &ps, nil
 }
 
+// versionFolder folder of the version written by this stream
+func (ps *ReplaceTableStream) versionFolder() string {
+	return path.Join(ps.tableName, ps.version)
+}
+
 func (ps *ReplaceTableStream) Complete(ctx context.Context) (state bulker.State, err error) {
 	if ps.state.Status != bulker.Active {
 		return ps.state, errors.New("stream is not active")
@@ -32,19 +62,23 @@ func (ps *ReplaceTableStream) Complete(ctx context.Context) (state bulker.State,
 		state, err = ps.postComplete(err)
 	}()
 	if ps.state.LastError == nil {
-		//if at least one object was inserted
+		//if at least one object was inserted. Otherwise, new version is empty
 		if ps.state.SuccessfulRows > 0 {
-			if ps.batchFile != nil {
-				if err = ps.flushBatchFile(ctx); err != nil {
-					return ps.state, err
-				}
-			}
-		} else {
-			//for ReplaceTable stream we should replace existing file with empty one
-			if err = ps.fileAdapter.UploadBytes(ps.filenameFunc(ctx), []byte{}); err != nil {
+			if err = ps.flushBatchFiles(ctx); err != nil {
 				return ps.state, err
 			}
 		}
+		if err = ps.writeSuccessMarker(ps.versionFolder()); err != nil {
+			return ps.state, err
+		}
+		var previous *CurrentVersion
+		if previous, err = ps.switchCurrentVersion(); err != nil {
+			return ps.state, err
+		}
+		if err = ps.writeManifest(ps.tableName); err != nil {
+			return ps.state, err
+		}
+		ps.cleanupPreviousVersion(previous)
 		return
 	} else {
 		//if was any error - it will trigger transaction rollback in defer func
@@ -52,3 +86,49 @@ func (ps *ReplaceTableStream) Complete(ctx context.Context) (state bulker.State,
 		return
 	}
 }
+
+// switchCurrentVersion overwrites `_CURRENT` pointer with the version written by this stream.
+// Returns previous version or nil if there was no pointer yet
+func (ps *ReplaceTableStream) switchCurrentVersion() (*CurrentVersion, error) {
+	pointerName := path.Join(ps.tableName, currentPointer)
+	var previous *CurrentVersion
+	if pointerBytes, err := ps.fileAdapter.Download(pointerName); err == nil {
+		previous = &CurrentVersion{}
+		if err = jsoniter.Unmarshal(pointerBytes, previous); err != nil {
+			logging.Warnf("[%s] failed to parse previous %s pointer: %v", ps.id, pointerName, err)
+			previous = nil
+		}
+	} else if !implementations.IsObjectNotFound(err) {
+		//pointer must not be overwritten: files of previous version would never be deleted
+		return nil, errorj.Decorate(err, "failed to read current version pointer")
+	}
+	current := CurrentVersion{Version: ps.version, Files: make([]string, 0, len(ps.uploadedFiles))}
+	for _, f := range ps.uploadedFiles {
+		current.Files = append(current.Files, f.name)
+	}
+	pointerBytes, err := jsoniter.Marshal(current)
+	if err != nil {
+		return nil, errorj.Decorate(err, "failed to marshal current version pointer")
+	}
+	if err = ps.fileAdapter.UploadBytes(pointerName, pointerBytes); err != nil {
+		return nil, errorj.Decorate(err, "failed to switch current version pointer")
+	}
+	return previous, nil
+}
+
+// cleanupPreviousVersion deletes files of the previous version. When there was no pointer yet - deletes single file
+// written by ReplaceTable stream before versioning was introduced.
+// Replacement is already committed at this point so errors are only logged
+func (ps *ReplaceTableStream) cleanupPreviousVersion(previous *CurrentVersion) {
+	var staleFiles []string
+	if previous == nil {
+		staleFiles = []string{ps.fileAdapter.AddFileExtension(ps.tableName)}
+	} else if previous.Version != ps.version {
+		staleFiles = append(previous.Files, path.Join(ps.tableName, previous.Version, successMarker))
+	}
+	for _, f := range staleFiles {
+		if err := ps.fileAdapter.DeleteObject(f); err != nil && previous != nil {
+			logging.Warnf("[%s] failed to delete file %s of previous version: %v", ps.id, f, err)
+		}
+	}
+}
@@ -17,6 +17,7 @@ func init() {
 
 type S3Bulker struct {
 	implementations.S3
+	iceberg *icebergWarehouse
 }
 
 func NewS3Bulker(bulkerConfig bulker.Config) (bulker.Bulker, error) {
@@ -24,14 +25,28 @@ func NewS3Bulker(bulkerConfig bulker.Config) (bulker.Bulker, error) {
 	if err := utils.ParseObject(bulkerConfig.DestinationConfig, s3Config); err != nil {
 		return nil, fmt.Errorf("failed to parse destination config: %v", err)
 	}
+	icebergConfig, err := parseIcebergConfig(bulkerConfig.DestinationConfig, &s3Config.FileConfig)
+	if err != nil {
+		return nil, err
+	}
 	s3adapter, err := implementations.NewS3(s3Config)
 	if err != nil {
 		return nil, err
 	}
-	return &S3Bulker{*s3adapter}, nil
+	s3 := &S3Bulker{S3: *s3adapter}
+	if icebergConfig != nil {
+		if s3.iceberg, err = newIcebergWarehouse(icebergConfig, s3, "s3://"+s3Config.Bucket); err != nil {
+			_ = s3adapter.Close()
+			return nil, err
+		}
+	}
+	return s3, nil
 }
 
 func (s3 *S3Bulker) CreateStream(id, tableName string, mode bulker.BulkMode, streamOptions ...bulker.StreamOption) (bulker.BulkerStream, error) {
+	if s3.iceberg != nil && mode != bulker.Stream {
+		return NewIcebergStream(id, s3.iceberg, tableName, mode, streamOptions...)
+	}
 	switch mode {
 	case bulker.Stream:
 		return nil, errors.New(S3AutocommitUnsupported)
@@ -44,3 +59,10 @@ func (s3 *S3Bulker) CreateStream(id, tableName string, mode bulker.BulkMode, str
 	}
 	return nil, fmt.Errorf("unsupported bulk mode: %s", mode)
 }
+
+func (s3 *S3Bulker) Close() error {
+	if s3.iceberg != nil {
+		_ = s3.iceberg.Close()
+	}
+	return s3.S3.Close()
+}
@@ -0,0 +1,99 @@
+package testcontainers
+
+import (
+	"context"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/jitsucom/bulker/jitsubase/logging"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	"github.com/testcontainers/testcontainers-go"
+	tcWait "github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	// well-known Azurite development storage account credentials
+	azuriteAccountName = "devstoreaccount1"
+	azuriteAccountKey  = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+// AzuriteContainer is an Azurite (Azure Storage emulator) testcontainer
+type AzuriteContainer struct {
+	Container        testcontainers.Container
+	Context          context.Context
+	Host             string
+	Port             int
+	ConnectionString string
+}
+
+// NewAzuriteContainer creates new Azurite test container with blob service and creates container with provided name
+func NewAzuriteContainer(ctx context.Context, containerName string) (*AzuriteContainer, error) {
+	exposedPort := fmt.Sprintf("%d:%d", utils.GetPort(), 10000)
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "mcr.microsoft.com/azure-storage/azurite:latest",
+			Cmd:          []string{"azurite-blob", "--blobHost", "0.0.0.0", "--blobPort", "10000", "--skipApiVersionCheck"},
+			ExposedPorts: []string{exposedPort},
+			WaitingFor:   tcWait.ForListeningPort("10000"),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		container.Terminate(ctx)
+		return nil, err
+	}
+
+	port, err := container.MappedPort(ctx, "10000")
+	if err != nil {
+		container.Terminate(ctx)
+		return nil, err
+	}
+	ac := AzuriteContainer{
+		Container: container,
+		Context:   ctx,
+		Host:      host,
+		Port:      port.Int(),
+		ConnectionString: fmt.Sprintf("DefaultEndpointsProtocol=http;AccountName=%s;AccountKey=%s;BlobEndpoint=http://%s:%d/%s;",
+			azuriteAccountName, azuriteAccountKey, host, port.Int(), azuriteAccountName),
+	}
+	err = ac.createContainer(containerName)
+	if err != nil {
+		_ = ac.Close()
+		return nil, err
+	}
+	return &ac, nil
+}
+
+func (ac *AzuriteContainer) createContainer(containerName string) error {
+	client, err := azblob.NewClientFromConnectionString(ac.ConnectionString, nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.CreateContainer(ac.Context, containerName, nil)
+	return err
+}
+
+// Close terminates underlying azurite docker container
+func (ac *AzuriteContainer) Close() error {
+	if ac.Container != nil {
+		err := ac.Container.Terminate(ac.Context)
+		if err != nil {
+			logging.Errorf("Failed to stop Azurite container: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (ac *AzuriteContainer) Stop() error {
+	return ac.Container.Stop(context.Background(), nil)
+}
+
+func (ac *AzuriteContainer) Start() error {
+	return ac.Container.Start(context.Background())
+}
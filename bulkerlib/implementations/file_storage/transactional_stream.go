@@ -18,13 +18,16 @@ func NewTransactionalStream(id string, p implementations.FileAdapter, tableName
 	ps := TransactionalStream{}
 	var err error
 	streamStartDate := timestamp.Now()
-	filenameFunc := func(ctx context.Context) string {
+	filenameFunc := func(ctx context.Context, partition string, part int) string {
 		batchNumStr := ""
 		batchNum, ok := ctx.Value(bulker.BatchNumberCtxKey).(int)
 		if ok {
 			batchNumStr = fmt.Sprintf("_%d", batchNum)
 		}
-		return fmt.Sprintf("%s_%s%s", tableName, streamStartDate.Format(FilenameDate), batchNumStr)
+		if partition != "" {
+			return fmt.Sprintf("%s/%s/part-%s%s%s", tableName, partition, streamStartDate.Format(FilenameDate), batchNumStr, partSuffix(part))
+		}
+		return fmt.Sprintf("%s_%s%s%s", tableName, streamStartDate.Format(FilenameDate), batchNumStr, partSuffix(part))
 	}
 	ps.AbstractFileStorageStream, err = newAbstractFileStorageStream(id, p, filenameFunc, bulker.Batch, streamOptions...)
 	if err != nil {
@@ -148,8 +148,12 @@ func (gcs *GoogleCloudStorage) Upload(fileName string, fileReader io.ReadSeeker)
 			})
 	}
 	metadata := storage.ObjectAttrsToUpdate{}
-	if gcs.config.Compression == types2.FileCompressionGZIP {
-		metadata.ContentType = "application/gzip"
+	if gcs.config.Format == types2.FileFormatPARQUET {
+		metadata.ContentType = "application/vnd.apache.parquet"
+	} else if gcs.config.Format == types2.FileFormatAVRO {
+		metadata.ContentType = "application/avro"
+	} else if codec := types2.GetCompressionCodec(gcs.config.Compression); codec != nil {
+		metadata.ContentType = codec.ContentType()
 	} else {
 		if gcs.config.Format == types2.FileFormatCSV {
 			metadata.ContentType = "text/csv"
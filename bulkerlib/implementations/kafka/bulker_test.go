@@ -0,0 +1,229 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	"github.com/jitsucom/bulker/bulkerlib/implementations/kafka/testcontainers"
+	"github.com/jitsucom/bulker/bulkerlib/types"
+	"github.com/jitsucom/bulker/jitsubase/logging"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	"github.com/jitsucom/bulker/jitsubase/uuid"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// kafkaBootstrapServers bootstrap servers of Kafka cluster used by tests. Empty when Kafka tests are not selected
+var kafkaBootstrapServers string
+
+var kafkaContainer *testcontainers.KafkaContainer
+
+func init() {
+	//BULKER_TEST_CONFIGS env variable with comma separated list of bulker config ids. Kafka tests run only if it is empty or contains 'kafka'
+	//BULKER_TEST_KAFKA env variable with bootstrap servers of existing Kafka cluster to run tests without Docker
+	if testConfigsEnv := os.Getenv("BULKER_TEST_CONFIGS"); testConfigsEnv != "" && !utils.ArrayContains(strings.Split(testConfigsEnv, ","), KafkaBulkerTypeId) {
+		return
+	}
+	kafkaBootstrapServers = os.Getenv("BULKER_TEST_KAFKA")
+	if kafkaBootstrapServers == "" {
+		var err error
+		kafkaContainer, err = testcontainers.NewKafkaContainer(context.Background())
+		if err != nil {
+			panic(err)
+		}
+		kafkaBootstrapServers = testcontainers.KafkaBootstrapServers
+	}
+	logging.Infof("Kafka tests bootstrap servers: %s", kafkaBootstrapServers)
+}
+
+type kafkaTestConfig struct {
+	//name of the test
+	name string
+	//bulker stream mode
+	mode bulker.BulkMode
+	//objects to consume
+	objects []types.Object
+	//abort stream instead of completing it
+	abort bool
+	//bulker stream options
+	streamOptions []bulker.StreamOption
+	//expected messages visible to read_committed consumer in order of production
+	expectedMessages []kafkaTestMessage
+}
+
+type kafkaTestMessage struct {
+	key     string
+	value   map[string]any
+	headers map[string]string
+}
+
+func TestKafka(t *testing.T) {
+	if kafkaBootstrapServers == "" {
+		t.Skip("Kafka tests are not selected")
+	}
+	if kafkaContainer != nil {
+		t.Cleanup(func() {
+			_ = kafkaContainer.Close()
+		})
+	}
+	objects := []types.Object{
+		{"id": 1, "name": "test", "nested": map[string]any{"a": 1}},
+		{"id": 2, "name": "test2", "nested": map[string]any{"a": 2}},
+		{"id": 3, "name": "test3"},
+	}
+	tests := []kafkaTestConfig{
+		{
+			name:          "stream",
+			mode:          bulker.Stream,
+			objects:       objects,
+			streamOptions: []bulker.StreamOption{bulker.WithPrimaryKey("id"), WithHeaders(map[string]string{"stream": "s1"})},
+			expectedMessages: []kafkaTestMessage{
+				{key: "1", value: map[string]any{"id": 1, "name": "test", "nested": map[string]any{"a": 1}}, headers: map[string]string{"source": "bulker", "stream": "s1"}},
+				{key: "2", value: map[string]any{"id": 2, "name": "test2", "nested": map[string]any{"a": 2}}, headers: map[string]string{"source": "bulker", "stream": "s1"}},
+				{key: "3", value: map[string]any{"id": 3, "name": "test3"}, headers: map[string]string{"source": "bulker", "stream": "s1"}},
+			},
+		},
+		{
+			name:          "batch_flatten",
+			mode:          bulker.Batch,
+			objects:       objects,
+			streamOptions: []bulker.StreamOption{bulker.WithPrimaryKey("id"), WithKeyFields("name", "id"), WithFlatten()},
+			expectedMessages: []kafkaTestMessage{
+				{key: `["test",1]`, value: map[string]any{"id": 1, "name": "test", "nested_a": 1}, headers: map[string]string{"source": "bulker"}},
+				{key: `["test2",2]`, value: map[string]any{"id": 2, "name": "test2", "nested_a": 2}, headers: map[string]string{"source": "bulker"}},
+				{key: `["test3",3]`, value: map[string]any{"id": 3, "name": "test3"}, headers: map[string]string{"source": "bulker"}},
+			},
+		},
+		{
+			name:             "batch_abort",
+			mode:             bulker.Batch,
+			objects:          objects,
+			abort:            true,
+			expectedMessages: []kafkaTestMessage{},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			testKafkaStream(t, tt)
+		})
+	}
+}
+
+func testKafkaStream(t *testing.T, tt kafkaTestConfig) {
+	reqr := require.New(t)
+	ctx := context.Background()
+	blk, err := bulker.CreateBulker(bulker.Config{Id: "kafka_" + tt.name, BulkerType: KafkaBulkerTypeId, DestinationConfig: map[string]any{
+		"bootstrapServers": kafkaBootstrapServers,
+		"topicPrefix":      "bulker_test.",
+		"compression":      "zstd",
+		"headers":          map[string]string{"source": "bulker"},
+	}})
+	reqr.NoError(err)
+	defer func() {
+		_ = blk.Close()
+	}()
+	tableName := fmt.Sprintf("%s %s", tt.name, uuid.New())
+	topic := "bulker_test." + strings.ReplaceAll(tableName, " ", "_")
+
+	stream, err := blk.CreateStream(tt.name, tableName, tt.mode, tt.streamOptions...)
+	reqr.NoError(err)
+	for _, object := range tt.objects {
+		_, _, err = stream.Consume(ctx, object)
+		reqr.NoError(err)
+	}
+	var state bulker.State
+	if tt.abort {
+		state, err = stream.Abort(ctx)
+		reqr.NoError(err)
+		reqr.Equal(bulker.Aborted, state.Status)
+		//produce and commit one more batch so aborted messages are followed by committed ones in the topic
+		stream, err = blk.CreateStream(tt.name, tableName, tt.mode, tt.streamOptions...)
+		reqr.NoError(err)
+		_, _, err = stream.Consume(ctx, types.Object{"id": 4})
+		reqr.NoError(err)
+		tt.expectedMessages = append(tt.expectedMessages, kafkaTestMessage{value: map[string]any{"id": 4}, headers: map[string]string{"source": "bulker"}})
+	}
+	state, err = stream.Complete(ctx)
+	reqr.NoError(err)
+	reqr.Equal(bulker.Completed, state.Status)
+	reqr.Equal(RepresentationTopic{Topic: topic}, state.Representation)
+
+	messages, err := readTopic(topic, len(tt.expectedMessages))
+	reqr.NoError(err)
+	reqr.Len(messages, len(tt.expectedMessages))
+	for i, expected := range tt.expectedMessages {
+		message := messages[i]
+		reqr.Equal(expected.key, string(message.Key), "key of message %d", i)
+		expectedValue, _ := jsoniter.Marshal(expected.value)
+		reqr.JSONEq(string(expectedValue), string(message.Value), "value of message %d", i)
+		headers := map[string]string{}
+		for _, h := range message.Headers {
+			headers[h.Key] = string(h.Value)
+		}
+		reqr.Equal(expected.headers, headers, "headers of message %d", i)
+	}
+}
+
+// readTopic reads committed messages from the beginning of topic until expected number of messages is read or timeout
+func readTopic(topic string, expectedCount int) ([]*kafka.Message, error) {
+	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers": kafkaBootstrapServers,
+		"group.id":          uuid.New(),
+		"auto.offset.reset": "earliest",
+		"isolation.level":   "read_committed",
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = consumer.Close()
+	}()
+	if err = consumer.Subscribe(topic, nil); err != nil {
+		return nil, err
+	}
+	messages := make([]*kafka.Message, 0, expectedCount)
+	deadline := time.Now().Add(30 * time.Second)
+	for len(messages) < expectedCount && time.Now().Before(deadline) {
+		message, err := consumer.ReadMessage(time.Second)
+		if err != nil {
+			if kafkaErr, ok := err.(kafka.Error); ok && (kafkaErr.IsTimeout() || !kafkaErr.IsFatal()) {
+				continue
+			}
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	//make sure there are no unexpected messages
+	if message, err := consumer.ReadMessage(3 * time.Second); err == nil {
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func TestTopicName(t *testing.T) {
+	reqr := require.New(t)
+	blk, err := NewKafkaBulker(bulker.Config{BulkerType: KafkaBulkerTypeId, DestinationConfig: map[string]any{
+		"bootstrapServers": "localhost:9092",
+		"topicPrefix":      "jitsu.",
+	}})
+	reqr.NoError(err)
+	kb := blk.(*KafkaBulker)
+	reqr.Equal("jitsu.events", kb.topicName("events"))
+	reqr.Equal("jitsu.my_events__2024_", kb.topicName("my events (2024)"))
+	reqr.Len(kb.topicName(strings.Repeat("a", 300)), maxTopicNameLength)
+
+	_, err = kb.CreateStream("replace", "events", bulker.ReplaceTable)
+	reqr.EqualError(err, KafkaReplaceUnsupported)
+
+	_, err = NewKafkaBulker(bulker.Config{BulkerType: KafkaBulkerTypeId, DestinationConfig: map[string]any{
+		"bootstrapServers": "localhost:9092",
+		"compression":      "brotli",
+	}})
+	reqr.ErrorContains(err, "unsupported Kafka compression")
+}
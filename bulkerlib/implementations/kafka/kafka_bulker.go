@@ -0,0 +1,181 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	"github.com/jitsucom/bulker/jitsubase/logging"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const KafkaBulkerTypeId = "kafka"
+
+const KafkaReplaceUnsupported = "Kafka topics can't be replaced. Please use 'stream' or 'batch' mode"
+
+// maxTopicNameLength max length of Kafka topic name
+const maxTopicNameLength = 249
+
+var (
+	illegalTopicCharacters = regexp.MustCompile(`[^a-zA-Z0-9._\-]`)
+	compressionTypes       = []string{"none", "gzip", "snappy", "lz4", "zstd"}
+)
+
+func init() {
+	bulker.RegisterBulker(KafkaBulkerTypeId, NewKafkaBulker)
+}
+
+// KafkaConfig is a dto for config deserialization
+type KafkaConfig struct {
+	// BootstrapServers list of Kafka brokers separated by comma. Each broker should be in format host:port
+	BootstrapServers string `mapstructure:"bootstrapServers,omitempty" json:"bootstrapServers,omitempty" yaml:"bootstrapServers,omitempty"`
+	SSL              bool   `mapstructure:"ssl,omitempty" json:"ssl,omitempty" yaml:"ssl,omitempty"`
+	SSLSkipVerify    bool   `mapstructure:"sslSkipVerify,omitempty" json:"sslSkipVerify,omitempty" yaml:"sslSkipVerify,omitempty"`
+	// SASL authorization e.g. {"mechanism": "SCRAM-SHA-256|PLAIN", "username": "user", "password": "password"}
+	SASL map[string]string `mapstructure:"sasl,omitempty" json:"sasl,omitempty" yaml:"sasl,omitempty"`
+	// TopicPrefix prefix of topic names. Topic name is prefix + table name with characters not allowed by Kafka replaced by '_'
+	TopicPrefix string `mapstructure:"topicPrefix,omitempty" json:"topicPrefix,omitempty" yaml:"topicPrefix,omitempty"`
+	// Compression of produced messages: none, gzip, snappy, lz4 or zstd
+	Compression string `mapstructure:"compression,omitempty" json:"compression,omitempty" yaml:"compression,omitempty"`
+	// Headers static headers added to every produced message. See also HeadersOption
+	Headers map[string]string `mapstructure:"headers,omitempty" json:"headers,omitempty" yaml:"headers,omitempty"`
+	// TransactionalIdPrefix prefix of transactional.id of producers of batch streams. Stream id is appended to it.
+	// Default: bulker
+	TransactionalIdPrefix string `mapstructure:"transactionalIdPrefix,omitempty" json:"transactionalIdPrefix,omitempty" yaml:"transactionalIdPrefix,omitempty"`
+	// Parameters additional librdkafka producer properties e.g. {"linger.ms": "50"}
+	Parameters map[string]string `mapstructure:"parameters,omitempty" json:"parameters,omitempty" yaml:"parameters,omitempty"`
+}
+
+// Validate returns err if invalid
+func (kc *KafkaConfig) Validate() error {
+	if kc == nil {
+		return errors.New("Kafka config is required")
+	}
+	if kc.BootstrapServers == "" {
+		return errors.New("Kafka bootstrapServers is required parameter")
+	}
+	if kc.Compression != "" && !utils.ArrayContains(compressionTypes, kc.Compression) {
+		return fmt.Errorf("unsupported Kafka compression: %s. Supported: %s", kc.Compression, strings.Join(compressionTypes, ", "))
+	}
+	return nil
+}
+
+// KafkaBulker produces objects to Kafka topics. Topic name is derived from table name.
+//
+// Stream mode produces each object and waits for delivery. Batch mode uses transactional producer:
+// objects become visible to read_committed consumers only after stream Complete call.
+type KafkaBulker struct {
+	config         *KafkaConfig
+	producerConfig kafka.ConfigMap
+
+	// producer shared non-transactional producer of Stream mode streams. Created on first use
+	producer      *kafka.Producer
+	producerMutex sync.Mutex
+}
+
+func NewKafkaBulker(bulkerConfig bulker.Config) (bulker.Bulker, error) {
+	kafkaConfig := &KafkaConfig{}
+	if err := utils.ParseObject(bulkerConfig.DestinationConfig, kafkaConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse destination config: %v", err)
+	}
+	if err := kafkaConfig.Validate(); err != nil {
+		return nil, err
+	}
+	producerConfig := kafka.ConfigMap{
+		"client.id":                utils.NvlString(bulkerConfig.Id, "bulker"),
+		"bootstrap.servers":        kafkaConfig.BootstrapServers,
+		"reconnect.backoff.ms":     1000,
+		"reconnect.backoff.max.ms": 10000,
+		"compression.type":         utils.NvlString(kafkaConfig.Compression, "none"),
+	}
+	if kafkaConfig.SSL {
+		if len(kafkaConfig.SASL) > 0 {
+			_ = producerConfig.SetKey("security.protocol", "SASL_SSL")
+		} else {
+			_ = producerConfig.SetKey("security.protocol", "SSL")
+		}
+		if kafkaConfig.SSLSkipVerify {
+			_ = producerConfig.SetKey("enable.ssl.certificate.verification", false)
+		}
+	} else if len(kafkaConfig.SASL) > 0 {
+		_ = producerConfig.SetKey("security.protocol", "SASL_PLAINTEXT")
+	}
+	for k, v := range kafkaConfig.SASL {
+		_ = producerConfig.SetKey("sasl."+k, v)
+	}
+	for k, v := range kafkaConfig.Parameters {
+		_ = producerConfig.SetKey(k, v)
+	}
+	return &KafkaBulker{config: kafkaConfig, producerConfig: producerConfig}, nil
+}
+
+func (kb *KafkaBulker) CreateStream(id, tableName string, mode bulker.BulkMode, streamOptions ...bulker.StreamOption) (bulker.BulkerStream, error) {
+	switch mode {
+	case bulker.Stream, bulker.Batch:
+		return newKafkaStream(id, kb, kb.topicName(tableName), mode, streamOptions...)
+	case bulker.ReplaceTable, bulker.ReplacePartition:
+		return nil, errors.New(KafkaReplaceUnsupported)
+	}
+	return nil, fmt.Errorf("unsupported bulk mode: %s", mode)
+}
+
+// topicName returns topic name for table name
+func (kb *KafkaBulker) topicName(tableName string) string {
+	topic := illegalTopicCharacters.ReplaceAllString(kb.config.TopicPrefix+tableName, "_")
+	if len(topic) > maxTopicNameLength {
+		topic = topic[:maxTopicNameLength]
+	}
+	return topic
+}
+
+// sharedProducer returns non-transactional producer shared by Stream mode streams
+func (kb *KafkaBulker) sharedProducer() (*kafka.Producer, error) {
+	kb.producerMutex.Lock()
+	defer kb.producerMutex.Unlock()
+	if kb.producer != nil {
+		return kb.producer, nil
+	}
+	producer, err := kb.newProducer(kafka.ConfigMap{
+		"enable.idempotence": true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	kb.producer = producer
+	return producer, nil
+}
+
+// newProducer creates producer with bulker config and provided specific properties
+func (kb *KafkaBulker) newProducer(properties kafka.ConfigMap) (*kafka.Producer, error) {
+	producerConfig := kafka.ConfigMap(utils.MapPutAll(utils.MapPutAll(kafka.ConfigMap{}, kb.producerConfig), properties))
+	producer, err := kafka.NewProducer(&producerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating kafka producer: %v", err)
+	}
+	// delivery reports are received with per message delivery channels.
+	// Drain remaining events so the events channel never blocks producer
+	go func() {
+		for e := range producer.Events() {
+			if kafkaErr, ok := e.(kafka.Error); ok {
+				logging.Errorf("[%s] kafka producer error: %v", producerConfig["client.id"], kafkaErr)
+			}
+		}
+	}()
+	return producer, nil
+}
+
+func (kb *KafkaBulker) Close() error {
+	kb.producerMutex.Lock()
+	defer kb.producerMutex.Unlock()
+	if kb.producer != nil {
+		if notProduced := kb.producer.Flush(3000); notProduced > 0 {
+			logging.Errorf("%d messages left unsent in kafka producer queue", notProduced)
+		}
+		kb.producer.Close()
+		kb.producer = nil
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	"github.com/jitsucom/bulker/bulkerlib/implementations"
+	"github.com/jitsucom/bulker/bulkerlib/types"
+	"github.com/jitsucom/bulker/jitsubase/errorj"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	jsoniter "github.com/json-iterator/go"
+	"sort"
+	"time"
+)
+
+// transactionTimeout timeout of transactional producer operations: init, commit and abort
+const transactionTimeout = 60 * time.Second
+
+// commitRetries number of attempts to commit transaction on retriable errors
+const commitRetries = 3
+
+// RepresentationTopic topic where stream produces messages
+type RepresentationTopic struct {
+	Topic string `json:"topic"`
+}
+
+// KafkaStream produces consumed objects to a topic.
+// In Stream mode each message is produced with shared producer and stream waits for its delivery.
+// In Batch mode messages are produced in transaction of stream own transactional producer
+// that is committed on Complete and aborted on Abort call.
+type KafkaStream struct {
+	id      string
+	mode    bulker.BulkMode
+	bulker  *KafkaBulker
+	topic   string
+	options bulker.StreamOptions
+
+	keyFields []string
+	headers   []kafka.Header
+	flatten   bool
+
+	producer *kafka.Producer
+
+	state  bulker.State
+	inited bool
+}
+
+func newKafkaStream(id string, kb *KafkaBulker, topic string, mode bulker.BulkMode, streamOptions ...bulker.StreamOption) (*KafkaStream, error) {
+	ps := KafkaStream{id: id, bulker: kb, topic: topic, mode: mode}
+	ps.options = bulker.StreamOptions{}
+	for _, option := range streamOptions {
+		ps.options.Add(option)
+	}
+	ps.keyFields = KeyFieldsOption.Get(&ps.options)
+	if len(ps.keyFields) == 0 {
+		// primary key is a set. Sort fields to get the same key for the same object in every stream
+		ps.keyFields = bulker.PrimaryKeyOption.Get(&ps.options).ToSlice()
+		sort.Strings(ps.keyFields)
+	}
+	headers := utils.MapPutAll(utils.MapPutAll(map[string]string{}, kb.config.Headers), HeadersOption.Get(&ps.options))
+	headerNames := make([]string, 0, len(headers))
+	for name := range headers {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+	for _, name := range headerNames {
+		ps.headers = append(ps.headers, kafka.Header{Key: name, Value: []byte(headers[name])})
+	}
+	ps.flatten = FlattenOption.Get(&ps.options)
+	ps.state = bulker.State{Status: bulker.Active, Representation: RepresentationTopic{Topic: topic}}
+	return &ps, nil
+}
+
+func (ps *KafkaStream) init(ctx context.Context) error {
+	if ps.inited {
+		return nil
+	}
+	if ps.mode == bulker.Stream {
+		producer, err := ps.bulker.sharedProducer()
+		if err != nil {
+			return err
+		}
+		ps.producer = producer
+	} else {
+		producer, err := ps.bulker.newProducer(kafka.ConfigMap{
+			"transactional.id": utils.NvlString(ps.bulker.config.TransactionalIdPrefix, "bulker") + "-" + ps.id,
+			// produce errors are reported by CommitTransaction
+			"go.delivery.reports": false,
+		})
+		if err != nil {
+			return err
+		}
+		initCtx, cancel := context.WithTimeout(ctx, transactionTimeout)
+		defer cancel()
+		if err = producer.InitTransactions(initCtx); err != nil {
+			producer.Close()
+			return errorj.ProducerTransactionError.Wrap(err, "failed to init transactions").
+				WithProperty(errorj.DBInfo, &types.ErrorPayload{Table: ps.topic})
+		}
+		if err = producer.BeginTransaction(); err != nil {
+			producer.Close()
+			return errorj.ProducerTransactionError.Wrap(err, "failed to begin transaction").
+				WithProperty(errorj.DBInfo, &types.ErrorPayload{Table: ps.topic})
+		}
+		ps.producer = producer
+	}
+	ps.inited = true
+	return nil
+}
+
+func (ps *KafkaStream) Consume(ctx context.Context, object types.Object) (state bulker.State, processedObjects []types.Object, err error) {
+	defer func() {
+		if err != nil {
+			ps.state.ErrorRowIndex = ps.state.ProcessedRows
+			ps.state.SetError(err)
+		} else {
+			ps.state.SuccessfulRows++
+		}
+		state = ps.state
+	}()
+	if err = ps.init(ctx); err != nil {
+		return
+	}
+	ps.state.ProcessedRows++
+	message, err := ps.message(object)
+	if err != nil {
+		return
+	}
+	if err = ps.produce(ctx, message); err != nil {
+		err = errorj.ProduceError.Wrap(err, "failed to produce message").
+			WithProperty(errorj.DBInfo, &types.ErrorPayload{Table: ps.topic})
+	}
+	return
+}
+
+// message builds Kafka message from object
+func (ps *KafkaStream) message(object types.Object) (*kafka.Message, error) {
+	var err error
+	if ps.flatten {
+		object, err = implementations.DefaultFlattener.FlattenObject(object, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	value, err := jsoniter.Marshal(object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize object: %v", err)
+	}
+	key, err := ps.messageKey(object)
+	if err != nil {
+		return nil, err
+	}
+	return &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &ps.topic, Partition: kafka.PartitionAny},
+		Key:            key,
+		Value:          value,
+		Headers:        ps.headers,
+	}, nil
+}
+
+// messageKey returns value of single key field or JSON array of values of multiple key fields. nil if no key fields are present in object
+func (ps *KafkaStream) messageKey(object types.Object) ([]byte, error) {
+	if len(ps.keyFields) == 0 {
+		return nil, nil
+	}
+	values := make([]any, len(ps.keyFields))
+	found := false
+	for i, field := range ps.keyFields {
+		value, ok := object[field]
+		if ok && value != nil {
+			found = true
+		}
+		values[i] = value
+	}
+	if !found {
+		return nil, nil
+	}
+	if len(values) == 1 {
+		if s, ok := values[0].(string); ok {
+			return []byte(s), nil
+		}
+		return jsoniter.Marshal(values[0])
+	}
+	return jsoniter.Marshal(values)
+}
+
+// produce puts message to producer queue. In Stream mode waits for message delivery
+func (ps *KafkaStream) produce(ctx context.Context, message *kafka.Message) error {
+	var deliveryChan chan kafka.Event
+	if ps.mode == bulker.Stream {
+		// new channel for each message so a late delivery report of cancelled call can't be taken for the next message report
+		deliveryChan = make(chan kafka.Event, 1)
+	}
+	for {
+		err := ps.producer.Produce(message, deliveryChan)
+		if err == nil {
+			break
+		}
+		var kafkaErr kafka.Error
+		if !errors.As(err, &kafkaErr) || kafkaErr.Code() != kafka.ErrQueueFull {
+			return err
+		}
+		// wait for some messages to be delivered to free up space in the queue
+		ps.producer.Flush(100)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	if deliveryChan == nil {
+		return nil
+	}
+	select {
+	case e := <-deliveryChan:
+		m, ok := e.(*kafka.Message)
+		if !ok {
+			return fmt.Errorf("unexpected delivery event: %v", e)
+		}
+		return m.TopicPartition.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (ps *KafkaStream) Complete(ctx context.Context) (state bulker.State, err error) {
+	if ps.mode == bulker.Stream {
+		// messages are already delivered by Consume calls
+		ps.state.Status = bulker.Completed
+		return ps.state, nil
+	}
+	if ps.state.LastError == nil {
+		err = ps.commit(ctx)
+	} else {
+		err = ps.state.LastError
+		ps.abort(ctx)
+	}
+	if err != nil {
+		ps.state.SetError(err)
+		ps.state.Status = bulker.Failed
+	} else {
+		ps.state.Status = bulker.Completed
+	}
+	return ps.state, err
+}
+
+func (ps *KafkaStream) Abort(ctx context.Context) (state bulker.State, err error) {
+	ps.abort(ctx)
+	ps.state.Status = bulker.Aborted
+	return ps.state, nil
+}
+
+// commit commits transaction of Batch mode stream and closes its producer
+func (ps *KafkaStream) commit(ctx context.Context) error {
+	if ps.mode == bulker.Stream || ps.producer == nil {
+		return nil
+	}
+	defer ps.closeProducer()
+	var err error
+	for attempt := 0; attempt < commitRetries; attempt++ {
+		commitCtx, cancel := context.WithTimeout(ctx, transactionTimeout)
+		err = ps.producer.CommitTransaction(commitCtx)
+		cancel()
+		var kafkaErr kafka.Error
+		if err == nil || !errors.As(err, &kafkaErr) || !kafkaErr.IsRetriable() {
+			break
+		}
+	}
+	if err != nil {
+		ps.abort(ctx)
+		return errorj.ProducerTransactionError.Wrap(err, "failed to commit transaction").
+			WithProperty(errorj.DBInfo, &types.ErrorPayload{Table: ps.topic, TotalObjects: ps.state.SuccessfulRows})
+	}
+	return nil
+}
+
+// abort aborts transaction of Batch mode stream and closes its producer
+func (ps *KafkaStream) abort(ctx context.Context) {
+	if ps.mode == bulker.Stream || ps.producer == nil {
+		return
+	}
+	defer ps.closeProducer()
+	abortCtx, cancel := context.WithTimeout(ctx, transactionTimeout)
+	defer cancel()
+	_ = ps.producer.AbortTransaction(abortCtx)
+}
+
+func (ps *KafkaStream) closeProducer() {
+	if ps.producer != nil {
+		ps.producer.Close()
+		ps.producer = nil
+	}
+}
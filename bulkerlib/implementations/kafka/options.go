@@ -0,0 +1,116 @@
+package kafka
+
+import (
+	"fmt"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	jsoniter "github.com/json-iterator/go"
+	"strings"
+)
+
+var (
+	// KeyFieldsOption list of object fields which values form message key. Default: primary key fields (bulker.PrimaryKeyOption).
+	// Single field value is used as is. Values of multiple fields are serialized as JSON array.
+	// Messages without key are distributed between partitions by producer partitioner
+	KeyFieldsOption = bulker.ImplementationOption[[]string]{
+		Key: "keyFields",
+		AdvancedParseFunc: func(o *bulker.ImplementationOption[[]string], serializedValue any) (bulker.StreamOption, error) {
+			switch v := serializedValue.(type) {
+			case []string:
+				return withKeyFields(o, v...), nil
+			case []any:
+				fields := make([]string, len(v))
+				for i, f := range v {
+					s, ok := f.(string)
+					if !ok {
+						return nil, fmt.Errorf("failed to parse 'keyFields' option: %v incorrect element type: %T expected string", f, f)
+					}
+					fields[i] = s
+				}
+				return withKeyFields(o, fields...), nil
+			case string:
+				if v == "" {
+					return func(options *bulker.StreamOptions) {}, nil
+				}
+				return withKeyFields(o, strings.Split(v, ",")...), nil
+			default:
+				return nil, fmt.Errorf("failed to parse 'keyFields' option: %v incorrect type: %T expected string or []string", v, v)
+			}
+		},
+	}
+
+	// HeadersOption headers added to every message produced by stream. Override headers with the same name from destination config
+	HeadersOption = bulker.ImplementationOption[map[string]string]{
+		Key:       "headers",
+		ParseFunc: parseHeaders,
+	}
+
+	// FlattenOption - produce flattened objects e.g. {"a_b": 1} instead of {"a": {"b": 1}}. Default: raw objects are produced
+	FlattenOption = bulker.ImplementationOption[bool]{
+		Key:       "flatten",
+		ParseFunc: utils.ParseBool,
+	}
+)
+
+func init() {
+	bulker.RegisterOption(&KeyFieldsOption)
+	bulker.RegisterOption(&HeadersOption)
+	bulker.RegisterOption(&FlattenOption)
+}
+
+func parseHeaders(serialized any) (map[string]string, error) {
+	switch v := serialized.(type) {
+	case map[string]string:
+		return v, nil
+	case map[string]any:
+		headers := make(map[string]string, len(v))
+		for name, value := range v {
+			headers[name] = fmt.Sprint(value)
+		}
+		return headers, nil
+	case string:
+		headers := map[string]string{}
+		if v == "" {
+			return headers, nil
+		}
+		if err := jsoniter.Unmarshal([]byte(v), &headers); err != nil {
+			return nil, fmt.Errorf("failed to parse headers JSON object: %v", err)
+		}
+		return headers, nil
+	default:
+		return nil, fmt.Errorf("incorrect type: %T expected map or JSON object string", v)
+	}
+}
+
+func withKeyFields(o *bulker.ImplementationOption[[]string], fields ...string) bulker.StreamOption {
+	return func(options *bulker.StreamOptions) {
+		o.Set(options, fields)
+	}
+}
+
+// WithKeyFields - fields which values form message key. See KeyFieldsOption
+func WithKeyFields(fields ...string) bulker.StreamOption {
+	return withKeyFields(&KeyFieldsOption, fields...)
+}
+
+func withHeaders(o *bulker.ImplementationOption[map[string]string], headers map[string]string) bulker.StreamOption {
+	return func(options *bulker.StreamOptions) {
+		o.Set(options, headers)
+	}
+}
+
+// WithHeaders - headers added to every produced message. See HeadersOption
+func WithHeaders(headers map[string]string) bulker.StreamOption {
+	return withHeaders(&HeadersOption, headers)
+}
+
+func withFlatten(o *bulker.ImplementationOption[bool], b bool) bulker.StreamOption {
+	return func(options *bulker.StreamOptions) {
+		o.Set(options, b)
+	}
+}
+
+// WithFlatten - produce flattened objects. See FlattenOption
+func WithFlatten() bulker.StreamOption {
+	return withFlatten(&FlattenOption, true)
+}
@@ -1,4 +1,4 @@
-package kafka
+package testcontainers
 
 import (
 	"context"
@@ -6,15 +6,23 @@ import (
 	"github.com/jitsucom/bulker/jitsubase/logging"
 	"github.com/testcontainers/testcontainers-go"
 	tcWait "github.com/testcontainers/testcontainers-go/wait"
+	"path/filepath"
+	"runtime"
 )
 
+const KafkaBootstrapServers = "localhost:19092"
+
+// KafkaContainer single broker Kafka cluster with zookeeper run by docker compose.
+// Broker is available at KafkaBootstrapServers address
 type KafkaContainer struct {
 	Compose *testcontainers.LocalDockerCompose
 	Context context.Context
 }
 
 func NewKafkaContainer(ctx context.Context) (*KafkaContainer, error) {
-	composeFilePaths := []string{"testcontainers/kafka/docker-compose.yml"}
+	//docker-compose.yml is located next to this file. Resolve its path so container can be started from tests of any package
+	_, currentFile, _, _ := runtime.Caller(0)
+	composeFilePaths := []string{filepath.Join(filepath.Dir(currentFile), "docker-compose.yml")}
 	identifier := "bulker_kafka_compose"
 
 	compose := testcontainers.NewLocalDockerCompose(composeFilePaths, identifier)
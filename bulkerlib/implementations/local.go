@@ -0,0 +1,189 @@
+package implementations
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	types2 "github.com/jitsucom/bulker/bulkerlib/types"
+	"github.com/jitsucom/bulker/jitsubase/errorj"
+	"github.com/jitsucom/bulker/jitsubase/logging"
+	"github.com/jitsucom/bulker/jitsubase/timestamp"
+	"go.uber.org/atomic"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalConfig is a dto for config deserialization
+type LocalConfig struct {
+	FileConfig `mapstructure:",squash" json:",inline" yaml:",inline"`
+	// Path root directory for all files. May be NFS mount
+	Path string `mapstructure:"path,omitempty" json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+// Validate returns err if invalid
+func (lc *LocalConfig) Validate() error {
+	if lc == nil {
+		return errors.New("Local file storage config is required")
+	}
+	if lc.Path == "" {
+		return errors.New("path is required parameter")
+	}
+	return nil
+}
+
+// Local is a local filesystem adapter for uploading/deleting files.
+// Files are written to temporary file first and then atomically renamed to the target name
+type Local struct {
+	AbstractFileAdapter
+	config *LocalConfig
+
+	closed *atomic.Bool
+}
+
+// NewLocal returns configured Local file storage adapter
+func NewLocal(localConfig *LocalConfig) (*Local, error) {
+	if err := localConfig.Validate(); err != nil {
+		return nil, err
+	}
+	if localConfig.Format == "" {
+		localConfig.Format = types2.FileFormatNDJSON
+	}
+	if err := os.MkdirAll(localConfig.Path, 0o755); err != nil {
+		return nil, errorj.SaveOnStageError.Wrap(err, "failed to create root directory").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Statement: fmt.Sprintf("path: %s", localConfig.Path),
+			})
+	}
+	return &Local{AbstractFileAdapter: AbstractFileAdapter{config: &localConfig.FileConfig}, config: localConfig, closed: atomic.NewBool(false)}, nil
+}
+
+func (a *Local) UploadBytes(fileName string, fileBytes []byte) error {
+	return a.Upload(fileName, bytes.NewReader(fileBytes))
+}
+
+// Upload writes payload to the temporary file in the target directory and renames it to the target file name
+func (a *Local) Upload(fileName string, fileReader io.ReadSeeker) (err error) {
+	filePath, err := a.localPath(fileName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			err = errorj.SaveOnStageError.Wrap(err, "failed to write file to local file system").
+				WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+					Statement: fmt.Sprintf("file: %s", filePath),
+				})
+		}
+	}()
+	if a.closed.Load() {
+		return fmt.Errorf("attempt to use closed Local file storage instance")
+	}
+	if err = os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return err
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmpFile.Close()
+			_ = os.Remove(tmpFile.Name())
+		}
+	}()
+	if _, err = io.Copy(tmpFile, fileReader); err != nil {
+		return err
+	}
+	if err = tmpFile.Sync(); err != nil {
+		return err
+	}
+	if err = tmpFile.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmpFile.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), filePath)
+}
+
+// Download reads file from local file system
+func (a *Local) Download(fileName string) ([]byte, error) {
+	filePath, err := a.localPath(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if a.closed.Load() {
+		return nil, fmt.Errorf("attempt to use closed Local file storage instance")
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, errorj.SaveOnStageError.Wrap(err, "failed to read file from local file system").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Statement: fmt.Sprintf("file: %s", filePath),
+			})
+	}
+	return data, nil
+}
+
+// DeleteObject deletes file from local file system. Missing file is not an error.
+// Parent directories left empty are removed too the same way as folders disappear in object storages
+func (a *Local) DeleteObject(key string) error {
+	filePath, err := a.localPath(key)
+	if err != nil {
+		return err
+	}
+	if a.closed.Load() {
+		return fmt.Errorf("attempt to use closed Local file storage instance")
+	}
+	if err = os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return errorj.SaveOnStageError.Wrap(err, "failed to delete from local file system").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Statement: fmt.Sprintf("file: %s", filePath),
+			})
+	}
+	root := filepath.Clean(a.config.Path)
+	for dir := filepath.Dir(filePath); dir != root && len(dir) > len(root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			//not empty
+			break
+		}
+	}
+	return nil
+}
+
+// localPath returns path of file in local file system. Doesn't allow paths outside of root directory
+func (a *Local) localPath(fileName string) (string, error) {
+	filePath := filepath.Join(a.config.Path, filepath.FromSlash(a.Path(fileName)))
+	rel, err := filepath.Rel(a.config.Path, filePath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errorj.SaveOnStageError.New("file path is outside of root directory").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Statement: fmt.Sprintf("file: %s", fileName),
+			})
+	}
+	return filePath, nil
+}
+
+// ValidateWritePermission tries to create temporary file and remove it.
+// returns nil if file creation was successful.
+func (a *Local) ValidateWritePermission() error {
+	filename := fmt.Sprintf("test_%v", timestamp.NowUTC())
+
+	if err := a.UploadBytes(filename, []byte{}); err != nil {
+		return err
+	}
+
+	if err := a.DeleteObject(filename); err != nil {
+		logging.Warnf("Cannot remove file %q from local file system: %v", filename, err)
+	}
+
+	return nil
+}
+
+// Close returns nil
+func (a *Local) Close() error {
+	a.closed.Store(true)
+	return nil
+}
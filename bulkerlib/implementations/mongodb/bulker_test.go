@@ -0,0 +1,303 @@
+package mongodb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	"github.com/jitsucom/bulker/bulkerlib/implementations/mongodb/testcontainers"
+	"github.com/jitsucom/bulker/bulkerlib/types"
+	"github.com/jitsucom/bulker/jitsubase/errorj"
+	"github.com/jitsucom/bulker/jitsubase/logging"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	"github.com/jitsucom/bulker/jitsubase/uuid"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testDatabase = "bulker_test"
+
+// mongoDBURL connection string of MongoDB used by tests. Empty when MongoDB tests are not selected
+var mongoDBURL string
+
+var mongoDBContainer *testcontainers.MongoDBContainer
+
+func init() {
+	//BULKER_TEST_CONFIGS env variable with comma separated list of bulker config ids. MongoDB tests run only if it is empty or contains 'mongodb'
+	//BULKER_TEST_MONGODB env variable with connection string of existing MongoDB replica set to run tests without Docker
+	if testConfigsEnv := os.Getenv("BULKER_TEST_CONFIGS"); testConfigsEnv != "" && !utils.ArrayContains(strings.Split(testConfigsEnv, ","), MongoDBBulkerTypeId) {
+		return
+	}
+	mongoDBURL = os.Getenv("BULKER_TEST_MONGODB")
+	if mongoDBURL == "" {
+		var err error
+		mongoDBContainer, err = testcontainers.NewMongoDBContainer(context.Background())
+		if err != nil {
+			panic(err)
+		}
+		mongoDBURL = mongoDBContainer.URL()
+	}
+	logging.Infof("MongoDB tests url: %s", mongoDBURL)
+}
+
+type mongoDBTestConfig struct {
+	//name of the test
+	name string
+	//bulker stream mode
+	mode bulker.BulkMode
+	//objects consumed by consecutive streams. Each stream is completed before the next one is created
+	batches [][]types.Object
+	//bulker stream options
+	streamOptions []bulker.StreamOption
+	//expected documents in collection sorted by '_id'. Compared as extended JSON
+	expectedDocuments []string
+	//error expected from Consume or Complete call of the last stream. String is used for error message partial matching
+	expectedError string
+	//expected number of successfully written objects of the last stream
+	expectedSuccessfulRows int
+}
+
+func TestMongoDB(t *testing.T) {
+	if mongoDBURL == "" {
+		t.Skip("MongoDB tests are not selected")
+	}
+	if mongoDBContainer != nil {
+		t.Cleanup(func() {
+			_ = mongoDBContainer.Close()
+		})
+	}
+	tests := []mongoDBTestConfig{
+		{
+			name: "stream",
+			mode: bulker.Stream,
+			batches: [][]types.Object{{
+				{"id": 1, "name": "a", "nested": map[string]any{"value": json.Number("1.5"), "list": []any{1, "b"}}},
+				{"id": 2, "created": "2023-01-01T00:00:00Z"},
+			}},
+			streamOptions: []bulker.StreamOption{bulker.WithPrimaryKey("id")},
+			expectedDocuments: []string{
+				`{"_id":1,"id":1,"name":"a","nested":{"list":[1,"b"],"value":1.5}}`,
+				`{"_id":2,"created":{"$date":"2023-01-01T00:00:00Z"},"id":2}`,
+			},
+			expectedSuccessfulRows: 2,
+		},
+		{
+			name:                   "batch_duplicate_id",
+			mode:                   bulker.Batch,
+			batches:                [][]types.Object{{{"id": 1, "name": "a"}, {"id": 2, "name": "c"}, {"id": 1, "name": "b"}}},
+			streamOptions:          []bulker.StreamOption{bulker.WithPrimaryKey("id")},
+			expectedDocuments:      []string{`{"_id":1,"id":1,"name":"a"}`, `{"_id":2,"id":2,"name":"c"}`},
+			expectedError:          "1 of 1 documents failed",
+			expectedSuccessfulRows: 2,
+		},
+		{
+			name: "batch_deduplicate",
+			mode: bulker.Batch,
+			batches: [][]types.Object{
+				{{"id": 1, "name": "a"}},
+				{{"id": 1, "name": "b"}, {"id": 2, "name": "c"}},
+			},
+			streamOptions:          []bulker.StreamOption{bulker.WithPrimaryKey("id"), bulker.WithMergeRows()},
+			expectedDocuments:      []string{`{"_id":1,"id":1,"name":"b"}`, `{"_id":2,"id":2,"name":"c"}`},
+			expectedSuccessfulRows: 2,
+		},
+		{
+			//duplicates inside one batch are spread across bulkWrite commands of 'bulkSize' operations
+			name: "batch_deduplicate_same_batch",
+			mode: bulker.Batch,
+			batches: [][]types.Object{
+				{{"id": 1, "name": "a"}, {"id": 2, "name": "c"}, {"id": 1, "name": "b"}, {"id": 3, "name": "e"}, {"id": 1, "name": "d"}},
+			},
+			streamOptions:          []bulker.StreamOption{bulker.WithPrimaryKey("id"), bulker.WithMergeRows()},
+			expectedDocuments:      []string{`{"_id":1,"id":1,"name":"d"}`, `{"_id":2,"id":2,"name":"c"}`, `{"_id":3,"id":3,"name":"e"}`},
+			expectedSuccessfulRows: 5,
+		},
+		{
+			name:                   "composite_primary_key",
+			mode:                   bulker.Batch,
+			batches:                [][]types.Object{{{"b": "x", "a": 1}}},
+			streamOptions:          []bulker.StreamOption{bulker.WithPrimaryKey("b", "a"), bulker.WithMergeRows()},
+			expectedDocuments:      []string{`{"_id":{"a":1,"b":"x"},"a":1,"b":"x"}`},
+			expectedSuccessfulRows: 1,
+		},
+		{
+			name: "replace_table",
+			mode: bulker.ReplaceTable,
+			batches: [][]types.Object{
+				{{"id": 1}, {"id": 2}},
+				{{"id": 3}},
+			},
+			streamOptions:          []bulker.StreamOption{bulker.WithPrimaryKey("id")},
+			expectedDocuments:      []string{`{"_id":3,"id":3}`},
+			expectedSuccessfulRows: 1,
+		},
+		{
+			name: "replace_partition",
+			mode: bulker.ReplacePartition,
+			batches: [][]types.Object{
+				{{"id": 1}, {"id": 2}},
+				{{"id": 3}},
+			},
+			streamOptions:          []bulker.StreamOption{bulker.WithPrimaryKey("id"), bulker.WithPartition("p1")},
+			expectedDocuments:      []string{`{"_id":3,"__partition_id":"p1","id":3}`},
+			expectedSuccessfulRows: 1,
+		},
+		{
+			name: "replace_partition_rollback",
+			mode: bulker.ReplacePartition,
+			batches: [][]types.Object{
+				{{"id": 1}, {"id": 2}},
+				{{"id": 3}, {"id": 3}},
+			},
+			streamOptions:     []bulker.StreamOption{bulker.WithPrimaryKey("id"), bulker.WithPartition("p1")},
+			expectedDocuments: []string{`{"_id":1,"__partition_id":"p1","id":1}`, `{"_id":2,"__partition_id":"p1","id":2}`},
+			expectedError:     "1 of 2 documents failed",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			testMongoDBStream(t, tt)
+		})
+	}
+}
+
+func testMongoDBStream(t *testing.T, tt mongoDBTestConfig) {
+	reqr := require.New(t)
+	ctx := context.Background()
+	blk, err := bulker.CreateBulker(bulker.Config{Id: "mongodb_" + tt.name, BulkerType: MongoDBBulkerTypeId, DestinationConfig: map[string]any{
+		"url":      mongoDBURL,
+		"database": testDatabase,
+		"bulkSize": 2,
+	}})
+	reqr.NoError(err)
+	defer func() {
+		_ = blk.Close()
+	}()
+	mb := blk.(*MongoDBBulker)
+	collection := fmt.Sprintf("%s_%s", tt.name, uuid.NewLettersNumbers()[:8])
+	defer func() {
+		_ = mb.database.Collection(collection).Drop(ctx)
+	}()
+
+	var state bulker.State
+	for i, batch := range tt.batches {
+		last := i == len(tt.batches)-1
+		stream, err := blk.CreateStream(tt.name, collection, tt.mode, tt.streamOptions...)
+		reqr.NoError(err)
+		for _, object := range batch {
+			if _, _, err = stream.Consume(ctx, object); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			state, err = stream.Complete(ctx)
+		}
+		if last && tt.expectedError != "" {
+			reqr.ErrorContains(err, tt.expectedError)
+			reqr.True(errorj.IsNonRetryableError(err))
+		} else {
+			reqr.NoError(err)
+			reqr.Equal(bulker.Completed, state.Status)
+			reqr.Equal(RepresentationCollection{Database: testDatabase, Collection: collection}, state.Representation)
+		}
+	}
+	reqr.Equal(tt.expectedSuccessfulRows, state.SuccessfulRows)
+
+	cursor, err := mb.database.Collection(collection).Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	reqr.NoError(err)
+	var documents []bson.M
+	reqr.NoError(cursor.All(ctx, &documents))
+	reqr.Len(documents, len(tt.expectedDocuments))
+	for i, expected := range tt.expectedDocuments {
+		actual, err := bson.MarshalExtJSON(documents[i], false, false)
+		reqr.NoError(err)
+		reqr.JSONEq(expected, string(actual), "document %d", i)
+	}
+}
+
+func TestMongoDBStreamConfig(t *testing.T) {
+	reqr := require.New(t)
+	_, err := NewMongoDBBulker(bulker.Config{BulkerType: MongoDBBulkerTypeId, DestinationConfig: map[string]any{"url": "localhost:27017", "database": "db"}})
+	reqr.ErrorContains(err, "must start with mongodb://")
+	_, err = NewMongoDBBulker(bulker.Config{BulkerType: MongoDBBulkerTypeId, DestinationConfig: map[string]any{"url": "mongodb://localhost:27017"}})
+	reqr.ErrorContains(err, "database is required")
+
+	blk, err := NewMongoDBBulker(bulker.Config{BulkerType: MongoDBBulkerTypeId, DestinationConfig: map[string]any{
+		"url":              "mongodb://localhost:27017",
+		"database":         "db",
+		"collectionPrefix": "jitsu_",
+	}})
+	reqr.NoError(err)
+	defer func() {
+		_ = blk.Close()
+	}()
+	mb := blk.(*MongoDBBulker)
+	reqr.Equal("jitsu_events", mb.collectionName("events"))
+	reqr.Equal("jitsu_my_events", mb.collectionName("my$events"))
+	reqr.Len(mb.collectionName(strings.Repeat("a", 300)), maxCollectionNameLength)
+	mb.config.CollectionPrefix = ""
+	reqr.Equal("_system.events", mb.collectionName("system.events"))
+
+	_, err = blk.CreateStream("dedup", "events", bulker.Batch, bulker.WithMergeRows())
+	reqr.ErrorContains(err, "deduplicate option requires primaryKey option")
+	_, err = blk.CreateStream("partition", "events", bulker.ReplacePartition)
+	reqr.ErrorContains(err, "partitionId option is required")
+
+	stream, err := newMongoDBStream("doc", mb, "events", bulker.Batch, bulker.WithPrimaryKey("id"), bulker.WithPartition("p1"))
+	reqr.NoError(err)
+	object := types.Object{"id": json.Number("1"), "ts": "2023-01-01T00:00:00Z", "nested": map[string]any{"n": json.Number("2.5")}}
+	document, err := stream.document(object)
+	reqr.NoError(err)
+	reqr.Equal(bson.M{
+		"_id":              int64(1),
+		"id":               int64(1),
+		"ts":               time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		"nested":           map[string]any{"n": 2.5},
+		PartitionIdKeyword: "p1",
+	}, document)
+	//consumed object must not be modified
+	reqr.Len(object, 3)
+	_, err = stream.document(types.Object{"name": "a"})
+	reqr.ErrorContains(err, "primary key field 'id' is missing")
+}
+
+// TestMongoDBBufferModel checks that with deduplicate option buffer keeps only the last write model of each _id
+func TestMongoDBBufferModel(t *testing.T) {
+	reqr := require.New(t)
+	mb := &MongoDBBulker{config: &MongoDBConfig{Database: "db"}}
+	stream, err := newMongoDBStream("dedup", mb, "events", bulker.Batch, bulker.WithPrimaryKey("b", "a"), bulker.WithMergeRows())
+	reqr.NoError(err)
+	objects := []types.Object{
+		{"a": 1, "b": "x", "name": "first"},
+		{"a": 2, "b": "x", "name": "second"},
+		{"a": 1, "b": "x", "name": "third"},
+		{"a": "1", "b": "x", "name": "fourth"},
+	}
+	for _, object := range objects {
+		_, _, err = stream.Consume(context.Background(), object)
+		reqr.NoError(err)
+	}
+	reqr.Len(stream.buffer, 3)
+	reqr.Equal(1, stream.superseded)
+	names := make([]any, len(stream.buffer))
+	for i, model := range stream.buffer {
+		names[i] = model.(*mongo.ReplaceOneModel).Replacement.(bson.M)["name"]
+	}
+	reqr.Equal([]any{"third", "second", "fourth"}, names)
+
+	stream, err = newMongoDBStream("insert", mb, "events", bulker.Batch, bulker.WithPrimaryKey("a"))
+	reqr.NoError(err)
+	for _, object := range objects {
+		_, _, err = stream.Consume(context.Background(), object)
+		reqr.NoError(err)
+	}
+	reqr.Len(stream.buffer, 4)
+	reqr.Equal(0, stream.superseded)
+}
@@ -0,0 +1,150 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
+	"time"
+)
+
+const MongoDBBulkerTypeId = "mongodb"
+
+const (
+	connectTimeout = 30 * time.Second
+	// maxCollectionNameLength max length of collection name in bytes. Namespace (database + '.' + collection) is limited by 255 bytes
+	maxCollectionNameLength = 200
+	// namespaceExistsErrorCode error code returned by 'create' command when collection already exists
+	namespaceExistsErrorCode = 48
+)
+
+var illegalCollectionNameCharacters = strings.NewReplacer("$", "_", "\x00", "_")
+
+func init() {
+	bulker.RegisterBulker(MongoDBBulkerTypeId, NewMongoDBBulker)
+}
+
+// MongoDBConfig is a dto for config deserialization
+type MongoDBConfig struct {
+	// URL connection string e.g. mongodb://host1:27017,host2:27017/?replicaSet=rs0 or mongodb+srv://cluster.example.com
+	URL      string `mapstructure:"url,omitempty" json:"url,omitempty" yaml:"url,omitempty"`
+	Database string `mapstructure:"database,omitempty" json:"database,omitempty" yaml:"database,omitempty"`
+	// Username and Password override credentials of connection string
+	Username string `mapstructure:"username,omitempty" json:"username,omitempty" yaml:"username,omitempty"`
+	Password string `mapstructure:"password,omitempty" json:"password,omitempty" yaml:"password,omitempty"`
+	// AuthSource database that stores user credentials. Default: admin
+	AuthSource string `mapstructure:"authSource,omitempty" json:"authSource,omitempty" yaml:"authSource,omitempty"`
+	// CollectionPrefix prefix of collection names. Collection name is prefix + table name
+	CollectionPrefix string `mapstructure:"collectionPrefix,omitempty" json:"collectionPrefix,omitempty" yaml:"collectionPrefix,omitempty"`
+	// BulkSize max number of operations in a single bulkWrite command. Default: 1000
+	BulkSize int `mapstructure:"bulkSize,omitempty" json:"bulkSize,omitempty" yaml:"bulkSize,omitempty"`
+}
+
+// Validate returns err if invalid
+func (mc *MongoDBConfig) Validate() error {
+	if mc == nil {
+		return errors.New("MongoDB config is required")
+	}
+	if !strings.HasPrefix(mc.URL, "mongodb://") && !strings.HasPrefix(mc.URL, "mongodb+srv://") {
+		return errors.New("MongoDB url is required parameter and must start with mongodb:// or mongodb+srv://")
+	}
+	if mc.Database == "" {
+		return errors.New("MongoDB database is required parameter")
+	}
+	return nil
+}
+
+// MongoDBBulker writes objects to MongoDB collections.
+// Table name maps to collection name, primary key – to document '_id'
+type MongoDBBulker struct {
+	config   *MongoDBConfig
+	client   *mongo.Client
+	database *mongo.Database
+}
+
+func NewMongoDBBulker(bulkerConfig bulker.Config) (bulker.Bulker, error) {
+	mongoConfig := &MongoDBConfig{}
+	if err := utils.ParseObject(bulkerConfig.DestinationConfig, mongoConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse destination config: %v", err)
+	}
+	if err := mongoConfig.Validate(); err != nil {
+		return nil, err
+	}
+	if mongoConfig.BulkSize <= 0 {
+		mongoConfig.BulkSize = 1000
+	}
+	clientOptions := options.Client().ApplyURI(mongoConfig.URL).SetConnectTimeout(connectTimeout)
+	if mongoConfig.Username != "" {
+		clientOptions.SetAuth(options.Credential{
+			Username:   mongoConfig.Username,
+			Password:   mongoConfig.Password,
+			AuthSource: utils.NvlString(mongoConfig.AuthSource, "admin"),
+		})
+	}
+	// Connect doesn't block: connection is established on the first operation
+	client, err := mongo.Connect(context.Background(), clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MongoDB client: %v", err)
+	}
+	return &MongoDBBulker{
+		config:   mongoConfig,
+		client:   client,
+		database: client.Database(mongoConfig.Database),
+	}, nil
+}
+
+func (mb *MongoDBBulker) CreateStream(id, tableName string, mode bulker.BulkMode, streamOptions ...bulker.StreamOption) (bulker.BulkerStream, error) {
+	switch mode {
+	case bulker.Stream, bulker.Batch, bulker.ReplaceTable, bulker.ReplacePartition:
+		return newMongoDBStream(id, mb, mb.collectionName(tableName), mode, streamOptions...)
+	default:
+		return nil, fmt.Errorf("unsupported bulk mode: %s", mode)
+	}
+}
+
+// collectionName returns collection name for table name
+func (mb *MongoDBBulker) collectionName(tableName string) string {
+	name := illegalCollectionNameCharacters.Replace(mb.config.CollectionPrefix + tableName)
+	if name == "" {
+		name = "unnamed"
+	}
+	if strings.HasPrefix(name, "system.") {
+		// 'system.' prefix is reserved for internal collections
+		name = "_" + name
+	}
+	if len(name) > maxCollectionNameLength {
+		name = name[:maxCollectionNameLength]
+	}
+	return name
+}
+
+// ensureCollection creates collection if it doesn't exist.
+// Collections can't be created implicitly inside transactions on MongoDB versions prior to 4.4
+func (mb *MongoDBBulker) ensureCollection(ctx context.Context, collection string) error {
+	err := mb.database.CreateCollection(ctx, collection)
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) && commandErr.Code == namespaceExistsErrorCode {
+		return nil
+	}
+	return err
+}
+
+// renameCollection atomically replaces target collection with source collection
+func (mb *MongoDBBulker) renameCollection(ctx context.Context, source, target string) error {
+	return mb.client.Database("admin").RunCommand(ctx, bson.D{
+		{Key: "renameCollection", Value: mb.config.Database + "." + source},
+		{Key: "to", Value: mb.config.Database + "." + target},
+		{Key: "dropTarget", Value: true},
+	}).Err()
+}
+
+func (mb *MongoDBBulker) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	return mb.client.Disconnect(ctx)
+}
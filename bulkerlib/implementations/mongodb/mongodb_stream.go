@@ -0,0 +1,334 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	"github.com/jitsucom/bulker/bulkerlib/implementations"
+	"github.com/jitsucom/bulker/bulkerlib/types"
+	"github.com/jitsucom/bulker/jitsubase/errorj"
+	"github.com/jitsucom/bulker/jitsubase/logging"
+	"github.com/jitsucom/bulker/jitsubase/timestamp"
+	"github.com/jitsucom/bulker/jitsubase/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
+)
+
+// PartitionIdKeyword field that stores partition id of documents written in ReplacePartition mode
+const PartitionIdKeyword = "__partition_id"
+
+// RepresentationCollection collection where stream writes documents
+type RepresentationCollection struct {
+	Database   string `json:"database"`
+	Collection string `json:"collection"`
+}
+
+// MongoDBStream writes consumed objects to collection. Nested objects are stored as is without flattening.
+// Primary key becomes document '_id': value of single primary key field or subdocument of composite primary key fields.
+// With 'deduplicate' option documents are upserted by '_id' and only the last object of each '_id' in a batch is written,
+// otherwise they are inserted and documents with existing '_id' are rejected.
+// In Stream mode each object is written on Consume.
+// In Batch mode objects are buffered and written on Complete with bulkWrite commands of up to 'bulkSize' operations.
+// In ReplaceTable mode objects are written to a temporary collection that replaces target collection with renameCollection command.
+// In ReplacePartition mode documents of the partition are deleted and new ones are written in a single transaction.
+// Transactions require replica set or sharded cluster
+type MongoDBStream struct {
+	id         string
+	mode       bulker.BulkMode
+	bulker     *MongoDBBulker
+	collection string
+	options    bulker.StreamOptions
+
+	flattener   implementations.Flattener
+	pkFields    []string
+	deduplicate bool
+	partitionId string
+
+	// buffer write models of Batch, ReplaceTable and ReplacePartition mode streams
+	buffer []mongo.WriteModel
+	// bufferIndex positions of buffered write models by '_id' with 'deduplicate' option.
+	// Buffer keeps only the last model of each '_id' because unordered bulkWrite doesn't guarantee order of operations
+	bufferIndex map[string]int
+	// superseded number of buffered objects replaced by later objects with the same '_id'
+	superseded int
+
+	state bulker.State
+}
+
+func newMongoDBStream(id string, mb *MongoDBBulker, collection string, mode bulker.BulkMode, streamOptions ...bulker.StreamOption) (*MongoDBStream, error) {
+	ps := MongoDBStream{id: id, bulker: mb, collection: collection, mode: mode}
+	ps.options = bulker.StreamOptions{}
+	for _, option := range streamOptions {
+		ps.options.Add(option)
+	}
+	ps.flattener = implementations.NewDummyFlattener()
+	// primary key is a set. Sort fields to get the same _id for the same object in every stream
+	ps.pkFields = bulker.PrimaryKeyOption.Get(&ps.options).ToSlice()
+	sort.Strings(ps.pkFields)
+	ps.deduplicate = bulker.MergeRowsOption.Get(&ps.options)
+	if ps.deduplicate && len(ps.pkFields) == 0 {
+		return nil, fmt.Errorf("couldn't start MongoDBStream: %s option requires %s option", bulker.MergeRowsOption.Key, bulker.PrimaryKeyOption.Key)
+	}
+	if ps.deduplicate {
+		ps.bufferIndex = map[string]int{}
+	}
+	ps.partitionId = bulker.PartitionIdOption.Get(&ps.options)
+	if mode == bulker.ReplacePartition && ps.partitionId == "" {
+		return nil, fmt.Errorf("couldn't start MongoDBStream: %s option is required in ReplacePartition mode", bulker.PartitionIdOption.Key)
+	}
+	ps.state = bulker.State{Status: bulker.Active, Representation: RepresentationCollection{Database: mb.config.Database, Collection: collection}}
+	return &ps, nil
+}
+
+func (ps *MongoDBStream) Consume(ctx context.Context, object types.Object) (state bulker.State, processedObjects []types.Object, err error) {
+	defer func() {
+		if err != nil {
+			ps.state.ErrorRowIndex = ps.state.ProcessedRows - 1
+			ps.state.SetError(err)
+		}
+		state = ps.state
+	}()
+	ps.state.ProcessedRows++
+	document, err := ps.document(object)
+	if err != nil {
+		return
+	}
+	model := ps.writeModel(document)
+	if ps.mode != bulker.Stream {
+		err = ps.bufferModel(document, model)
+		return
+	}
+	written, err := ps.bulkWrite(ctx, ps.collection, []mongo.WriteModel{model})
+	ps.state.SuccessfulRows += written
+	return
+}
+
+// document converts object to document: values are reformatted to BSON friendly types,
+// '_id' and partition id fields are added
+func (ps *MongoDBStream) document(object types.Object) (bson.M, error) {
+	object, err := ps.flattener.FlattenObject(object, nil)
+	if err != nil {
+		return nil, err
+	}
+	document := make(bson.M, len(object)+2)
+	for name, value := range object {
+		document[name] = reformatValue(value)
+	}
+	if ps.partitionId != "" {
+		document[PartitionIdKeyword] = ps.partitionId
+	}
+	if len(ps.pkFields) > 0 {
+		id, err := ps.documentId(document)
+		if err != nil {
+			return nil, err
+		}
+		document["_id"] = id
+	}
+	return document, nil
+}
+
+// documentId returns _id of document: value of single primary key field or subdocument of composite primary key fields
+func (ps *MongoDBStream) documentId(document bson.M) (any, error) {
+	id := make(bson.D, len(ps.pkFields))
+	for i, field := range ps.pkFields {
+		value, ok := document[field]
+		if !ok || value == nil {
+			return nil, fmt.Errorf("primary key field '%s' is missing in object", field)
+		}
+		id[i] = bson.E{Key: field, Value: value}
+	}
+	if len(id) == 1 {
+		return id[0].Value, nil
+	}
+	return id, nil
+}
+
+// reformatValue converts json numbers and timestamp strings of value and nested values to int64, float64 and time.Time
+func reformatValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		reformatted := make(map[string]any, len(v))
+		for name, nested := range v {
+			reformatted[name] = reformatValue(nested)
+		}
+		return reformatted
+	case []any:
+		reformatted := make([]any, len(v))
+		for i, nested := range v {
+			reformatted[i] = reformatValue(nested)
+		}
+		return reformatted
+	default:
+		return types.ReformatValue(value)
+	}
+}
+
+func (ps *MongoDBStream) writeModel(document bson.M) mongo.WriteModel {
+	if ps.deduplicate {
+		return mongo.NewReplaceOneModel().SetFilter(bson.D{{Key: "_id", Value: document["_id"]}}).SetReplacement(document).SetUpsert(true)
+	}
+	return mongo.NewInsertOneModel().SetDocument(document)
+}
+
+// bufferModel appends write model to buffer. With 'deduplicate' option replaces buffered model of document with the same '_id'
+func (ps *MongoDBStream) bufferModel(document bson.M, model mongo.WriteModel) error {
+	if ps.deduplicate {
+		id, err := bson.Marshal(bson.D{{Key: "_id", Value: document["_id"]}})
+		if err != nil {
+			return err
+		}
+		if i, ok := ps.bufferIndex[string(id)]; ok {
+			ps.buffer[i] = model
+			ps.superseded++
+			return nil
+		}
+		ps.bufferIndex[string(id)] = len(ps.buffer)
+	}
+	ps.buffer = append(ps.buffer, model)
+	return nil
+}
+
+func (ps *MongoDBStream) Complete(ctx context.Context) (state bulker.State, err error) {
+	defer func() {
+		ps.buffer = nil
+		ps.bufferIndex = nil
+		if err != nil {
+			ps.state.SetError(err)
+			ps.state.Status = bulker.Failed
+		} else {
+			//superseded objects are written as part of the last object with the same '_id'
+			ps.state.SuccessfulRows += ps.superseded
+			ps.state.Status = bulker.Completed
+		}
+		state = ps.state
+	}()
+	if ps.mode == bulker.Stream {
+		return
+	}
+	if ps.state.LastError != nil {
+		err = ps.state.LastError
+		return
+	}
+	switch ps.mode {
+	case bulker.ReplaceTable:
+		err = ps.replaceTable(ctx)
+	case bulker.ReplacePartition:
+		err = ps.replacePartition(ctx)
+	default:
+		var written int
+		written, err = ps.bulkWrite(ctx, ps.collection, ps.buffer)
+		ps.state.SuccessfulRows += written
+	}
+	return
+}
+
+// replaceTable writes documents to a temporary collection and atomically replaces target collection with it
+func (ps *MongoDBStream) replaceTable(ctx context.Context) (err error) {
+	tmpCollection := ps.bulker.collectionName(fmt.Sprintf("%s_tmp_%s_%s", ps.collection, timestamp.Now().Format("20060102T150405"), uuid.NewLettersNumbers()[:8]))
+	if err = ps.bulker.ensureCollection(ctx, tmpCollection); err != nil {
+		return errorj.CreateTableError.Wrap(err, "failed to create temporary collection").
+			WithProperty(errorj.DBInfo, &types.ErrorPayload{Database: ps.bulker.config.Database, Table: tmpCollection})
+	}
+	defer func() {
+		if err != nil {
+			if dropErr := ps.bulker.database.Collection(tmpCollection).Drop(context.Background()); dropErr != nil {
+				logging.Errorf("[%s] failed to drop temporary collection %s: %v", ps.id, tmpCollection, dropErr)
+			}
+		}
+	}()
+	written, err := ps.bulkWrite(ctx, tmpCollection, ps.buffer)
+	if err != nil {
+		return err
+	}
+	if err = ps.bulker.renameCollection(ctx, tmpCollection, ps.collection); err != nil {
+		return errorj.RenameError.Wrap(err, "failed to replace collection").
+			WithProperty(errorj.DBInfo, &types.ErrorPayload{Database: ps.bulker.config.Database, Table: ps.collection})
+	}
+	ps.state.SuccessfulRows = written
+	return nil
+}
+
+// replacePartition deletes documents of the partition and writes new ones in a single transaction
+func (ps *MongoDBStream) replacePartition(ctx context.Context) error {
+	if err := ps.bulker.ensureCollection(ctx, ps.collection); err != nil {
+		return errorj.CreateTableError.Wrap(err, "failed to create collection").
+			WithProperty(errorj.DBInfo, &types.ErrorPayload{Database: ps.bulker.config.Database, Table: ps.collection})
+	}
+	session, err := ps.bulker.client.StartSession()
+	if err != nil {
+		return errorj.BeginTransactionError.Wrap(err, "failed to start session").
+			WithProperty(errorj.DBInfo, &types.ErrorPayload{Database: ps.bulker.config.Database, Table: ps.collection})
+	}
+	defer session.EndSession(context.Background())
+	// callback may be called multiple times when transaction is retried on transient errors
+	var written int
+	var callbackErr error
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (any, error) {
+		written, callbackErr = 0, nil
+		_, err := ps.bulker.database.Collection(ps.collection).DeleteMany(sessionCtx, bson.D{{Key: PartitionIdKeyword, Value: ps.partitionId}})
+		if err != nil {
+			callbackErr = errorj.DeleteFromTableError.Wrap(err, "failed to delete partition").
+				WithProperty(errorj.DBInfo, &types.ErrorPayload{Database: ps.bulker.config.Database, Table: ps.collection, Partition: ps.partitionId})
+			return nil, callbackErr
+		}
+		written, callbackErr = ps.bulkWrite(sessionCtx, ps.collection, ps.buffer)
+		return nil, callbackErr
+	})
+	if callbackErr != nil {
+		return callbackErr
+	}
+	if err != nil {
+		return errorj.CommitTransactionError.Wrap(err, "failed to commit transaction").
+			WithProperty(errorj.DBInfo, &types.ErrorPayload{Database: ps.bulker.config.Database, Table: ps.collection, Partition: ps.partitionId})
+	}
+	ps.state.SuccessfulRows = written
+	return nil
+}
+
+// bulkWrite writes models with unordered bulkWrite commands of up to 'bulkSize' operations.
+// Stops on the first command that fails. Returns number of written documents
+func (ps *MongoDBStream) bulkWrite(ctx context.Context, collection string, models []mongo.WriteModel) (written int, err error) {
+	coll := ps.bulker.database.Collection(collection)
+	bulkSize := ps.bulker.config.BulkSize
+	for start := 0; start < len(models); start += bulkSize {
+		end := start + bulkSize
+		if end > len(models) {
+			end = len(models)
+		}
+		_, err = coll.BulkWrite(ctx, models[start:end], options.BulkWrite().SetOrdered(false))
+		if err == nil {
+			written += end - start
+			continue
+		}
+		errorPayload := &types.ErrorPayload{Database: ps.bulker.config.Database, Table: collection, PrimaryKeys: ps.pkFields, TotalObjects: end - start}
+		var bulkErr mongo.BulkWriteException
+		if !errors.As(err, &bulkErr) || len(bulkErr.WriteErrors) == 0 {
+			return written, errorj.BulkWriteError.Wrap(err, "failed to write documents").
+				WithProperty(errorj.DBInfo, errorPayload)
+		}
+		// unordered bulkWrite attempts all operations despite of failed ones
+		written += end - start - len(bulkErr.WriteErrors)
+		if ps.mode != bulker.Stream {
+			ps.state.ErrorRowIndex = start + bulkErr.WriteErrors[0].Index
+		}
+		writeErr := fmt.Errorf("%d of %d documents failed. First error: code: %d message: %s",
+			len(bulkErr.WriteErrors), end-start, bulkErr.WriteErrors[0].Code, bulkErr.WriteErrors[0].Message)
+		errorType := errorj.BulkWriteRejectedError
+		if bulkErr.WriteConcernError != nil {
+			errorType = errorj.BulkWriteError
+		}
+		return written, errorType.Wrap(writeErr, "failed to write documents").
+			WithProperty(errorj.DBInfo, errorPayload)
+	}
+	return written, nil
+}
+
+func (ps *MongoDBStream) Abort(ctx context.Context) (state bulker.State, err error) {
+	ps.buffer = nil
+	ps.bufferIndex = nil
+	ps.state.Status = bulker.Aborted
+	return ps.state, nil
+}
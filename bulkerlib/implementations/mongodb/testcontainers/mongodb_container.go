@@ -0,0 +1,87 @@
+package testcontainers
+
+import (
+	"context"
+	"fmt"
+	"github.com/jitsucom/bulker/jitsubase/logging"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	"github.com/testcontainers/testcontainers-go"
+	tcWait "github.com/testcontainers/testcontainers-go/wait"
+	"io"
+	"time"
+)
+
+const replicaSetName = "rs0"
+
+// MongoDBContainer is a MongoDB testcontainer running single node replica set. Replica set is required for transactions
+type MongoDBContainer struct {
+	Container testcontainers.Container
+	Context   context.Context
+	Host      string
+	Port      int
+}
+
+// NewMongoDBContainer creates new MongoDB test container and initiates replica set
+func NewMongoDBContainer(ctx context.Context) (*MongoDBContainer, error) {
+	exposedPort := fmt.Sprintf("%d:%d", utils.GetPort(), 27017)
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "mongo:6.0",
+			ExposedPorts: []string{exposedPort},
+			Cmd:          []string{"--replSet", replicaSetName, "--bind_ip_all"},
+			WaitingFor:   tcWait.ForLog("Waiting for connections").WithStartupTimeout(time.Second * 120),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exitCode, output, err := container.Exec(ctx, []string{"mongosh", "--quiet", "--eval",
+		fmt.Sprintf("rs.initiate({_id: '%s', members: [{_id: 0, host: 'localhost:27017'}]})", replicaSetName)})
+	if err == nil && exitCode != 0 {
+		message, _ := io.ReadAll(output)
+		err = fmt.Errorf("exit code: %d output: %s", exitCode, message)
+	}
+	if err != nil {
+		container.Terminate(ctx)
+		return nil, fmt.Errorf("failed to initiate replica set: %v", err)
+	}
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		container.Terminate(ctx)
+		return nil, err
+	}
+
+	port, err := container.MappedPort(ctx, "27017")
+	if err != nil {
+		container.Terminate(ctx)
+		return nil, err
+	}
+	return &MongoDBContainer{
+		Container: container,
+		Context:   ctx,
+		Host:      host,
+		Port:      port.Int(),
+	}, nil
+}
+
+// URL returns connection string of MongoDB node. Direct connection is used because replica set member
+// is registered with address inside container
+func (mc *MongoDBContainer) URL() string {
+	return fmt.Sprintf("mongodb://%s:%d/?directConnection=true", mc.Host, mc.Port)
+}
+
+// Close terminates underlying docker container
+func (mc *MongoDBContainer) Close() error {
+	if mc.Container != nil {
+		err := mc.Container.Terminate(mc.Context)
+		if err != nil {
+			logging.Errorf("Failed to stop MongoDB container: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -94,8 +94,12 @@ func (a *S3) Upload(fileName string, fileReader io.ReadSeeker) error {
 	params := &s3.PutObjectInput{
 		Bucket: aws.String(a.config.Bucket),
 	}
-	if a.config.Compression == types2.FileCompressionGZIP {
-		params.ContentType = aws.String("application/gzip")
+	if a.config.Format == types2.FileFormatPARQUET {
+		params.ContentType = aws.String("application/vnd.apache.parquet")
+	} else if a.config.Format == types2.FileFormatAVRO {
+		params.ContentType = aws.String("application/avro")
+	} else if codec := types2.GetCompressionCodec(a.config.Compression); codec != nil {
+		params.ContentType = aws.String(codec.ContentType())
 	} else {
 		switch a.config.Format {
 		case types2.FileFormatCSV:
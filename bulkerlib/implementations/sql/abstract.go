@@ -8,6 +8,7 @@ import (
 	"github.com/jitsucom/bulker/jitsubase/logging"
 	"github.com/jitsucom/bulker/jitsubase/utils"
 	jsoniter "github.com/json-iterator/go"
+	"strings"
 )
 
 // TODO: check whether COPY is transactional ?
@@ -26,9 +27,15 @@ type AbstractSQLStream struct {
 	state  bulker.State
 	inited bool
 
-	customTypes     types.SQLTypes
-	pkColumns       []string
-	timestampColumn string
+	customTypes          types.SQLTypes
+	pkColumns            []string
+	timestampColumn      string
+	partitionGranularity Granularity
+	// allJsonColumns keep all nested objects of the top level in columns of JSON type
+	allJsonColumns bool
+	jsonColumnType types.SQLColumn
+	// jsonArrays keep nested arrays in columns of JSON type too
+	jsonArrays bool
 }
 
 func newAbstractStream(id string, p SQLAdapter, tableName string, mode bulker.BulkMode, streamOptions ...bulker.StreamOption) (AbstractSQLStream, error) {
@@ -45,10 +52,29 @@ func newAbstractStream(id string, p SQLAdapter, tableName string, mode bulker.Bu
 	var customFields = ColumnTypesOption.Get(&ps.options)
 	ps.pkColumns = pkColumns.ToSlice()
 	ps.timestampColumn = bulker.TimestampOption.Get(&ps.options)
+	ps.partitionGranularity = PartitionGranularityOption.Get(&ps.options)
 
 	//TODO: max column?
 	ps.state = bulker.State{Status: bulker.Active}
 	ps.customTypes = customFields
+	if jsonColumns := jsonColumnsOption.Get(&ps.options); len(jsonColumns) > 0 {
+		jsonSQLType := jsonColumnTypeOption.Get(&ps.options)
+		ps.jsonArrays = jsonSQLType == ""
+		if ps.jsonArrays {
+			jsonSQLType, _ = p.GetSQLType(types.JSON)
+		}
+		ps.jsonColumnType = types.SQLColumn{Type: jsonSQLType, DataType: types.JSON, Override: true}
+		ps.customTypes = utils.MapCopy(customFields)
+		for _, column := range jsonColumns {
+			column = strings.TrimSpace(column)
+			if column == "*" {
+				ps.allJsonColumns = true
+			} else if column != "" {
+				//explicitly configured column types take precedence
+				utils.MapPutIfAbsent(ps.customTypes, column, ps.jsonColumnType)
+			}
+		}
+	}
 	return ps, nil
 }
 
@@ -56,11 +82,21 @@ func (ps *AbstractSQLStream) preprocess(object types.Object) (*Table, types.Obje
 	if ps.state.Status != bulker.Active {
 		return nil, nil, fmt.Errorf("stream is not active. Status: %s", ps.state.Status)
 	}
-	batchHeader, processedObject, err := ProcessEvents(ps.tableName, object, ps.customTypes)
+	customTypes := ps.customTypes
+	if ps.allJsonColumns {
+		customTypes = ps.jsonColumnTypes(object)
+	}
+	batchHeader, processedObject, err := ProcessEvents(ps.tableName, object, customTypes)
 	if err != nil {
 		return nil, nil, err
 	}
 	table, processedObject := ps.sqlAdapter.TableHelper().MapTableSchema(ps.sqlAdapter, batchHeader, processedObject, ps.pkColumns, ps.timestampColumn)
+	if ps.partitionGranularity != "" && table.TimestampColumn != "" {
+		table.Partition = DatePartition{Field: table.TimestampColumn, Granularity: ps.partitionGranularity}
+	}
+	if ps.merge {
+		table.VersionColumn = table.TimestampColumn
+	}
 	ps.state.ProcessedRows++
 	return table, processedObject, nil
 }
@@ -173,6 +209,25 @@ func (ps *AbstractSQLStream) adjustTableColumnTypes(existingTable, desiredTable
 	return columnsAdded
 }
 
+// jsonColumnTypes returns custom column types with JSON type for all nested objects and arrays of the top level of the object
+func (ps *AbstractSQLStream) jsonColumnTypes(object types.Object) types.SQLTypes {
+	customTypes := types.SQLTypes{}
+	for key, value := range object {
+		if strings.HasPrefix(key, SqlTypePrefix) {
+			continue
+		}
+		switch value.(type) {
+		case map[string]any:
+			customTypes[key] = ps.jsonColumnType
+		case []any:
+			if ps.jsonArrays {
+				customTypes[key] = ps.jsonColumnType
+			}
+		}
+	}
+	return utils.MapPutAll(customTypes, ps.customTypes)
+}
+
 func (ps *AbstractSQLStream) updateRepresentationTable(table *Table) {
 	if ps.state.Representation == nil ||
 		ps.state.Representation.(RepresentationTable).Name != table.Name ||
@@ -12,6 +12,7 @@ import (
 	"github.com/jitsucom/bulker/jitsubase/errorj"
 	"github.com/jitsucom/bulker/jitsubase/logging"
 	"github.com/jitsucom/bulker/jitsubase/utils"
+	"github.com/jitsucom/bulker/jitsubase/uuid"
 	jsoniter "github.com/json-iterator/go"
 	"os"
 	"path"
@@ -33,6 +34,12 @@ type AbstractTransactionalSQLStream struct {
 	s3                 *implementations.S3
 	batchFileLinesByPK map[string]int
 	batchFileSkipLines utils.Set[int]
+	maxFileSize        int
+	maxRowsPerFile     int
+	//number of batch file parts already loaded into tmp table
+	loadedParts int
+	//schema of tmp table in database. nil if tmp table isn't created yet
+	dbTmpTable *Table
 }
 
 func newAbstractTransactionalStream(id string, p SQLAdapter, tableName string, mode bulker.BulkMode, streamOptions ...bulker.StreamOption) (AbstractTransactionalSQLStream, error) {
@@ -42,6 +49,11 @@ func newAbstractTransactionalStream(id string, p SQLAdapter, tableName string, m
 		return ps, err
 	}
 	ps.AbstractSQLStream = abs
+	ps.maxFileSize = bulker.MaxFileSizeBytesOption.Get(&ps.options)
+	ps.maxRowsPerFile = bulker.MaxRowsPerFileOption.Get(&ps.options)
+	if ps.maxFileSize < 0 || ps.maxRowsPerFile < 0 {
+		return ps, fmt.Errorf("maxFileSizeBytes and maxRowsPerFile options must not be negative")
+	}
 	if ps.merge {
 		ps.batchFileLinesByPK = make(map[string]int)
 		ps.batchFileSkipLines = utils.NewSet[int]()
@@ -61,21 +73,10 @@ func (ps *AbstractTransactionalSQLStream) init(ctx context.Context) (err error)
 			return fmt.Errorf("failed to setup s3 client: %v", err)
 		}
 	}
-	localBatchFile := localBatchFileOption.Get(&ps.options)
-	if localBatchFile != "" && ps.batchFile == nil {
-		ps.batchFile, err = os.CreateTemp("", localBatchFile)
-		if err != nil {
+	if ps.batchFile == nil {
+		if err = ps.newBatchFile(); err != nil {
 			return err
 		}
-		ps.marshaller, _ = types.NewMarshaller(types.FileFormatNDJSON, types.FileCompressionNONE)
-		ps.targetMarshaller, err = types.NewMarshaller(ps.sqlAdapter.GetBatchFileFormat(), ps.sqlAdapter.GetBatchFileCompression())
-		if err != nil {
-			return err
-		}
-		if !ps.merge && ps.sqlAdapter.GetBatchFileFormat() == types.FileFormatNDJSON {
-			//without merge we can write file with compression - no need to convert
-			ps.marshaller, _ = types.NewMarshaller(ps.sqlAdapter.GetBatchFileFormat(), ps.sqlAdapter.GetBatchFileCompression())
-		}
 	}
 	err = ps.AbstractSQLStream.init(ctx)
 	if err != nil {
@@ -91,6 +92,52 @@ func (ps *AbstractTransactionalSQLStream) init(ctx context.Context) (err error)
 	return nil
 }
 
+// newBatchFile creates new local batch file if localBatchFileOption is set
+func (ps *AbstractTransactionalSQLStream) newBatchFile() (err error) {
+	localBatchFile := localBatchFileOption.Get(&ps.options)
+	if localBatchFile == "" {
+		return nil
+	}
+	ps.batchFile, err = os.CreateTemp("", localBatchFile)
+	if err != nil {
+		return err
+	}
+	ps.eventsInBatch = 0
+	ps.marshaller, _ = types.NewMarshaller(types.FileFormatNDJSON, types.FileCompressionNONE)
+	ps.targetMarshaller, err = types.NewMarshaller(ps.sqlAdapter.GetBatchFileFormat(), ps.sqlAdapter.GetBatchFileCompression())
+	if err != nil {
+		return err
+	}
+	if !ps.merge && ps.sqlAdapter.GetBatchFileFormat() == types.FileFormatNDJSON {
+		//without merge we can write file with compression - no need to convert
+		ps.marshaller, _ = types.NewMarshaller(ps.sqlAdapter.GetBatchFileFormat(), ps.sqlAdapter.GetBatchFileCompression())
+	}
+	return nil
+}
+
+// needRollover returns true when batch file has reached one of maxFileSizeBytes or maxRowsPerFile thresholds
+func (ps *AbstractTransactionalSQLStream) needRollover() (bool, error) {
+	if ps.maxRowsPerFile > 0 && ps.eventsInBatch >= ps.maxRowsPerFile {
+		return true, nil
+	}
+	if ps.maxFileSize > 0 {
+		fi, err := ps.batchFile.Stat()
+		if err != nil {
+			return false, errorj.Decorate(err, "failed to get batch file size")
+		}
+		return fi.Size() >= int64(ps.maxFileSize), nil
+	}
+	return false, nil
+}
+
+// rollover loads finished part of batch file into tmp table right away to bound local disk usage and starts new batch file
+func (ps *AbstractTransactionalSQLStream) rollover(ctx context.Context) error {
+	if err := ps.flushBatchFile(ctx); err != nil {
+		return err
+	}
+	return ps.newBatchFile()
+}
+
 func (ps *AbstractTransactionalSQLStream) postComplete(ctx context.Context, err error) (bulker.State, error) {
 	if ps.batchFile != nil {
 		_ = ps.batchFile.Close()
@@ -117,16 +164,18 @@ func (ps *AbstractTransactionalSQLStream) postComplete(ctx context.Context, err
 }
 
 func (ps *AbstractTransactionalSQLStream) flushBatchFile(ctx context.Context) (err error) {
-	existingTable, _ := ps.tx.GetTableSchema(ctx, ps.tableName)
-	if existingTable.Exists() {
-		//we need to respect types of existing columns when we create tmp table
-		ps.tmpTable.Columns = utils.MapPutAll(ps.tmpTable.Columns, existingTable.Columns)
+	if ps.dbTmpTable == nil {
+		existingTable, _ := ps.tx.GetTableSchema(ctx, ps.tableName)
+		if existingTable.Exists() {
+			//we need to respect types of existing columns when we create tmp table
+			ps.tmpTable.Columns = utils.MapPutAll(ps.tmpTable.Columns, existingTable.Columns)
+		}
 	}
-	table := ps.tmpTable
-	err = ps.tx.CreateTable(ctx, table)
-	if err != nil {
-		return errorj.Decorate(err, "failed to create table")
+	//tmp table may be already created by previous part of batch file. New columns may have appeared since then
+	if err = ps.ensureTmpTable(ctx); err != nil {
+		return err
 	}
+	table := ps.tmpTable
 	columns := table.SortedColumnNames()
 	defer func() {
 		if ps.merge {
@@ -137,6 +186,7 @@ func (ps *AbstractTransactionalSQLStream) flushBatchFile(ctx context.Context) (e
 		_ = os.Remove(ps.batchFile.Name())
 	}()
 	if ps.eventsInBatch > 0 {
+		err = ps.marshaller.Flush()
 		if err != nil {
 			return errorj.Decorate(err, "failed to flush marshaller")
 		}
@@ -160,6 +210,13 @@ func (ps *AbstractTransactionalSQLStream) flushBatchFile(ctx context.Context) (e
 				_ = os.Remove(workingFile.Name())
 			}()
 			if needToConvert {
+				if sm, ok := ps.targetMarshaller.(types.SchemaMarshaller); ok {
+					dataTypes := make(map[string]types.DataType, len(table.Columns))
+					for name, column := range table.Columns {
+						dataTypes[name] = column.DataType
+					}
+					sm.SetDataTypes(dataTypes)
+				}
 				err = ps.targetMarshaller.Init(workingFile, columns)
 				if err != nil {
 					return errorj.Decorate(err, "failed to write header for converted batch file")
@@ -182,7 +239,10 @@ func (ps *AbstractTransactionalSQLStream) flushBatchFile(ctx context.Context) (e
 						if err != nil {
 							return errorj.Decorate(err, "failed to decode json object from batch filer")
 						}
-						ps.targetMarshaller.Marshal(obj)
+						err = ps.targetMarshaller.Marshal(obj)
+						if err != nil {
+							return errorj.Decorate(err, "failed to marshal object into converted batch file")
+						}
 					} else {
 						_, err = workingFile.Write(scanner.Bytes())
 						if err != nil {
@@ -196,12 +256,37 @@ func (ps *AbstractTransactionalSQLStream) flushBatchFile(ctx context.Context) (e
 			if err = scanner.Err(); err != nil {
 				return errorj.Decorate(err, "failed to read batch file")
 			}
-			ps.targetMarshaller.Flush()
+			if needToConvert {
+				err = ps.targetMarshaller.Flush()
+				if err != nil {
+					return errorj.Decorate(err, "failed to flush converted batch file")
+				}
+			}
 			workingFile.Sync()
 		}
 		if needToConvert {
 			logging.Infof("[%s] Converted batch file from %s to %s in %s", ps.id, ps.marshaller.Format(), ps.targetMarshaller.Format(), time.Now().Sub(convertStart))
 		}
+		loadTable := table
+		if ps.merge && ps.loadedParts > 0 {
+			//tmp table already contains objects of previous parts. Load part into staging table and merge it into tmp table
+			stagingTableName := fmt.Sprintf("jitsu_tmp_%s", uuid.NewLettersNumbers()[:8])
+			loadTable = &Table{
+				Name:            stagingTableName,
+				Columns:         table.Columns,
+				PKFields:        table.PKFields,
+				PrimaryKeyName:  BuildConstraintName(stagingTableName),
+				Temporary:       true,
+				TimestampColumn: table.TimestampColumn,
+			}
+			err = ps.tx.CreateTable(ctx, loadTable)
+			if err != nil {
+				return errorj.Decorate(err, "failed to create staging table")
+			}
+			defer func() {
+				_ = ps.tx.Drop(ctx, loadTable, true)
+			}()
+		}
 		if ps.s3 != nil {
 			s3Config := s3BatchFileOption.Get(&ps.options)
 			rFile, err := os.Open(workingFile.Name())
@@ -217,17 +302,24 @@ func (ps *AbstractTransactionalSQLStream) flushBatchFile(ctx context.Context) (e
 				return errorj.Decorate(err, "failed to upload file to s3")
 			}
 			defer ps.s3.DeleteObject(s3FileName)
-			err = ps.tx.LoadTable(ctx, table, &LoadSource{Type: AmazonS3, Path: s3FileName, Format: ps.sqlAdapter.GetBatchFileFormat(), S3Config: s3Config})
+			err = ps.tx.LoadTable(ctx, loadTable, &LoadSource{Type: AmazonS3, Path: s3FileName, Format: ps.sqlAdapter.GetBatchFileFormat(), Compression: ps.sqlAdapter.GetBatchFileCompression(), S3Config: s3Config})
 			if err != nil {
 				return errorj.Decorate(err, "failed to flush tmp file to the warehouse")
 			}
 		} else {
-			err = ps.tx.LoadTable(ctx, table, &LoadSource{Type: LocalFile, Path: workingFile.Name(), Format: ps.sqlAdapter.GetBatchFileFormat()})
+			err = ps.tx.LoadTable(ctx, loadTable, &LoadSource{Type: LocalFile, Path: workingFile.Name(), Format: ps.sqlAdapter.GetBatchFileFormat(), Compression: ps.sqlAdapter.GetBatchFileCompression()})
 			if err != nil {
 				return errorj.Decorate(err, "failed to flush tmp file to the warehouse")
 			}
 		}
+		if loadTable != table {
+			err = ps.tx.CopyTables(ctx, table, loadTable, true)
+			if err != nil {
+				return errorj.Decorate(err, "failed to merge staging table into tmp table")
+			}
+		}
 	}
+	ps.loadedParts++
 	return nil
 }
 
@@ -298,13 +390,33 @@ func (ps *AbstractTransactionalSQLStream) writeToBatchFile(ctx context.Context,
 func (ps *AbstractTransactionalSQLStream) insert(ctx context.Context, targetTable *Table, processedObject types.Object) (err error) {
 	ps.adjustTables(ctx, targetTable, processedObject)
 	ps.updateRepresentationTable(ps.tmpTable)
-	ps.tmpTable, err = ps.sqlAdapter.TableHelper().EnsureTableWithoutCaching(ctx, ps.tx, ps.id, ps.tmpTable)
-	if err != nil {
-		return errorj.Decorate(err, "failed to ensure table")
+	if err = ps.ensureTmpTable(ctx); err != nil {
+		return err
 	}
 	return ps.tx.Insert(ctx, ps.tmpTable, ps.merge, processedObject)
 }
 
+// ensureTmpTable creates tmp table or adds new columns to it.
+// Tmp table is visible only to the stream transaction, so it is managed without table lock and schema cache
+func (ps *AbstractTransactionalSQLStream) ensureTmpTable(ctx context.Context) error {
+	if ps.dbTmpTable == nil {
+		if err := ps.tx.CreateTable(ctx, ps.tmpTable); err != nil {
+			return errorj.Decorate(err, "failed to create table")
+		}
+		ps.dbTmpTable = ps.tmpTable.Clone()
+		return nil
+	}
+	diff := ps.dbTmpTable.Diff(ps.tmpTable)
+	if !diff.Exists() {
+		return nil
+	}
+	if err := ps.tx.PatchTableSchema(ctx, diff); err != nil {
+		return errorj.Decorate(err, "failed to patch table")
+	}
+	ps.dbTmpTable = ps.tmpTable.Clone()
+	return nil
+}
+
 func (ps *AbstractTransactionalSQLStream) adjustTables(ctx context.Context, targetTable *Table, processedObject types.Object) {
 	if ps.tmpTable == nil {
 		//targetTable contains desired name and primary key setup
@@ -332,7 +444,13 @@ func (ps *AbstractTransactionalSQLStream) Consume(ctx context.Context, object ty
 	}
 	batchFile := ps.batchFile != nil
 	if batchFile {
-		err = ps.writeToBatchFile(ctx, tableForObject, processedObject)
+		if err = ps.writeToBatchFile(ctx, tableForObject, processedObject); err != nil {
+			return
+		}
+		var rollover bool
+		if rollover, err = ps.needRollover(); err == nil && rollover {
+			err = ps.rollover(ctx)
+		}
 	} else {
 		err = ps.insert(ctx, tableForObject, processedObject)
 	}
@@ -1,6 +1,7 @@
 package sql
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	bulker "github.com/jitsucom/bulker/bulkerlib"
@@ -8,6 +9,7 @@ import (
 	"github.com/jitsucom/bulker/jitsubase/logging"
 	"github.com/jitsucom/bulker/jitsubase/timestamp"
 	"github.com/jitsucom/bulker/jitsubase/utils"
+	jsoniter "github.com/json-iterator/go"
 	"github.com/stretchr/testify/require"
 	"os"
 	"strings"
@@ -173,3 +175,123 @@ func testLotOfEvents(t *testing.T, testConfig bulkerTestConfig, mode bulker.Bulk
 		reqr.Equal(testConfig.expectedRowsCount, count)
 	}
 }
+
+const loadTableBenchmarkRows = 100_000
+
+// BenchmarkPostgresLoadTable compares streaming of CSV batch file to COPY FROM STDIN
+// with executing prepared COPY statement for every line of NDJSON batch file.
+// Run: BULKER_TEST_CONFIGS=postgres go test -run=^$ -bench=PostgresLoadTable ./implementations/sql/
+func BenchmarkPostgresLoadTable(b *testing.B) {
+	if !utils.ArrayContains(allBulkerConfigs, PostgresBulkerTypeId) {
+		b.Skip("Postgres is not among configured configs")
+	}
+	reqr := require.New(b)
+	testConfig := configRegistry[PostgresBulkerTypeId].(TestConfig)
+	blk, err := bulker.CreateBulker(bulker.Config{Id: "load_table_benchmark", BulkerType: PostgresBulkerTypeId, DestinationConfig: testConfig.Config})
+	reqr.NoError(err)
+	defer func() {
+		_ = blk.Close()
+	}()
+	p := blk.(*Postgres)
+	ctx := context.Background()
+	table := &Table{Name: "load_table_benchmark", Columns: Columns{
+		"_timestamp": types.SQLColumn{Type: "timestamp with time zone", DataType: types.TIMESTAMP},
+		"id":         types.SQLColumn{Type: "bigint", DataType: types.INT64},
+		"name":       types.SQLColumn{Type: "text", DataType: types.STRING},
+	}}
+	ndjsonFile := writeBenchmarkBatchFile(b, types.FileFormatNDJSON, table.SortedColumnNames())
+	csvFile := writeBenchmarkBatchFile(b, types.FileFormatCSV, table.SortedColumnNames())
+
+	//COPY is only allowed inside transaction
+	runLoad := func(b *testing.B, load func(tx *TxSQLAdapter) error) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			_ = p.DropTable(ctx, table.Name, true)
+			reqr.NoError(p.CreateTable(ctx, table))
+			b.StartTimer()
+			tx, err := p.OpenTx(ctx)
+			reqr.NoError(err)
+			if err = load(tx); err != nil {
+				_ = tx.Rollback()
+				reqr.NoError(err)
+			}
+			reqr.NoError(tx.Commit())
+		}
+		b.StopTimer()
+		_ = p.DropTable(ctx, table.Name, true)
+		b.ReportMetric(float64(loadTableBenchmarkRows*b.N)/b.Elapsed().Seconds(), "rows/s")
+	}
+	b.Run("copy_stream", func(b *testing.B) {
+		runLoad(b, func(tx *TxSQLAdapter) error {
+			return tx.LoadTable(ctx, table, &LoadSource{Type: LocalFile, Path: csvFile, Format: types.FileFormatCSV, Compression: types.FileCompressionNONE})
+		})
+	})
+	b.Run("exec_per_row", func(b *testing.B) {
+		runLoad(b, func(tx *TxSQLAdapter) error {
+			return loadTableExecPerRow(ctx, p, tx, table, ndjsonFile)
+		})
+	})
+}
+
+// writeBenchmarkBatchFile writes loadTableBenchmarkRows objects to batch file of provided format
+func writeBenchmarkBatchFile(b *testing.B, format types.FileFormat, columns []string) string {
+	reqr := require.New(b)
+	file, err := os.CreateTemp(b.TempDir(), "load_table_benchmark")
+	reqr.NoError(err)
+	defer func() {
+		_ = file.Close()
+	}()
+	marshaller, err := types.NewMarshaller(format, types.FileCompressionNONE)
+	reqr.NoError(err)
+	reqr.NoError(marshaller.Init(file, columns))
+	for i := 0; i < loadTableBenchmarkRows; i++ {
+		reqr.NoError(marshaller.Marshal(types.Object{"_timestamp": constantTime, "id": i, "name": "test"}))
+	}
+	reqr.NoError(marshaller.Flush())
+	return file.Name()
+}
+
+// loadTableExecPerRow loads NDJSON file with prepared COPY statement executed for every line within provided transaction
+func loadTableExecPerRow(ctx context.Context, p *Postgres, tx *TxSQLAdapter, table *Table, path string) error {
+	columns := table.SortedColumnNames()
+	columnNames := make([]string, len(columns))
+	for i, name := range columns {
+		columnNames[i] = p.quotedColumnName(name)
+	}
+	stmt, err := tx.tx.PrepareContext(ctx, fmt.Sprintf("COPY %s(%s) FROM STDIN", p.quotedTableName(table.Name), strings.Join(columnNames, ", ")))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*100), 1024*1024*10)
+	for scanner.Scan() {
+		object := map[string]any{}
+		decoder := jsoniter.ConfigDefault.NewDecoder(strings.NewReader(scanner.Text()))
+		decoder.UseNumber()
+		if err = decoder.Decode(&object); err != nil {
+			return err
+		}
+		args := make([]any, len(columns))
+		for i, v := range columns {
+			args[i] = types.ReformatValue(object[v])
+		}
+		if _, err = stmt.ExecContext(ctx, args...); err != nil {
+			return err
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	_, err = stmt.ExecContext(ctx)
+	return err
+}
@@ -118,7 +118,7 @@ func NewBigquery(bulkerConfig bulker.Config) (bulker.Bulker, error) {
 	b := &BigQuery{
 		Service: appbase.NewServiceBase(bulkerConfig.Id),
 		client:  client, config: config, queryLogger: queryLogger}
-	b.tableHelper = NewTableHelper(1024, '`')
+	b.tableHelper = NewTableHelper(bulkerConfig.Id, 1024, '`')
 	b.tableHelper.columnNameFunc = columnNameFunc
 	b.tableHelper.tableNameFunc = tableNameFunc
 	return b, err
@@ -334,6 +334,8 @@ func (bq *BigQuery) CreateTable(ctx context.Context, table *Table) (err error) {
 	if table.Partition.Field != "" && table.Partition.Granularity != ALL {
 		var partitioningType bigquery.TimePartitioningType
 		switch table.Partition.Granularity {
+		case HOUR:
+			partitioningType = bigquery.HourPartitioningType
 		case DAY, WEEK:
 			partitioningType = bigquery.DayPartitioningType
 		case MONTH, QUARTER:
@@ -546,6 +548,16 @@ func (bq *BigQuery) LoadTable(ctx context.Context, targetTable *Table, loadSourc
 	if err != nil {
 		return err
 	}
+	defer func() {
+		_ = file.Close()
+	}()
+	reader, err := types2.NewCompressionReader(loadSource.Compression, file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
 	bqTable := bq.client.Dataset(bq.config.Dataset).Table(tableName)
 	meta, err := bqTable.Metadata(ctx)
 
@@ -561,7 +573,7 @@ func (bq *BigQuery) LoadTable(ctx context.Context, targetTable *Table, loadSourc
 		meta.Schema[i] = mp[field]
 	}
 
-	source := bigquery.NewReaderSource(file)
+	source := bigquery.NewReaderSource(reader)
 	source.Schema = meta.Schema
 
 	if loadSource.Format == types2.FileFormatCSV {
@@ -723,6 +735,7 @@ func (bq *BigQuery) logQuery(messageTemplate string, entity any, err error) {
 }
 
 func (bq *BigQuery) Close() error {
+	bq.tableHelper.Close()
 	if bq.client != nil {
 		return bq.client.Close()
 	}
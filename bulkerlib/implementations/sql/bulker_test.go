@@ -17,7 +17,9 @@ import (
 	jsoniter "github.com/json-iterator/go"
 	"github.com/stretchr/testify/require"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -27,7 +29,7 @@ var constantTime = timestamp.MustParseTime(time.RFC3339Nano, "2022-08-18T14:17:2
 const forceLeaveResultingTables = false
 
 var allBulkerConfigs = []string{BigqueryBulkerTypeId, RedshiftBulkerTypeId, RedshiftBulkerTypeId + "_serverless", SnowflakeBulkerTypeId, PostgresBulkerTypeId,
-	MySQLBulkerTypeId, ClickHouseBulkerTypeId, ClickHouseBulkerTypeId + "_cluster", ClickHouseBulkerTypeId + "_cluster_noshards"}
+	MySQLBulkerTypeId, MySQLBulkerTypeId + "_no_infile", MSSQLBulkerTypeId, ClickHouseBulkerTypeId, ClickHouseBulkerTypeId + "_cluster", ClickHouseBulkerTypeId + "_cluster_noshards", DuckDBBulkerTypeId, SQLiteBulkerTypeId}
 
 var exceptBigquery []string
 
@@ -50,6 +52,8 @@ type ExpectedTable struct {
 
 var postgresContainer *testcontainers2.PostgresContainer
 var mysqlContainer *testcontainers2.MySQLContainer
+var mysqlNoInfileContainer *testcontainers2.MySQLContainer
+var mssqlContainer *testcontainers2.MSSQLContainer
 var clickhouseContainer *testcontainers2.ClickHouseContainer
 var clickhouseClusterContainer *clickhouse.ClickHouseClusterContainer
 var clickhouseClusterContainerNoShards *clickhouse_noshards.ClickHouseClusterContainerNoShards
@@ -57,6 +61,10 @@ var clickhouseClusterContainerNoShards *clickhouse_noshards.ClickHouseClusterCon
 func init() {
 	//uncomment to run tests locally with just one bulker type
 	//allBulkerConfigs = []string{PostgresBulkerTypeId}
+	//or set BULKER_TEST_CONFIGS env variable with comma separated list of bulker config ids. E.g. BULKER_TEST_CONFIGS=duckdb,sqlite runs tests without Docker
+	if testConfigs := os.Getenv("BULKER_TEST_CONFIGS"); testConfigs != "" {
+		allBulkerConfigs = utils.ArrayIntersection(allBulkerConfigs, strings.Split(testConfigs, ","))
+	}
 
 	if utils.ArrayContains(allBulkerConfigs, BigqueryBulkerTypeId) {
 		bigqueryConfig := os.Getenv("BULKER_TEST_BIGQUERY")
@@ -113,17 +121,54 @@ func init() {
 	}
 
 	if utils.ArrayContains(allBulkerConfigs, MySQLBulkerTypeId) {
-		mysqlContainer, err = testcontainers2.NewMySQLContainer(context.Background())
+		mysqlContainer, err = testcontainers2.NewMySQLContainer(context.Background(), true)
 		if err != nil {
 			panic(err)
 		}
-		configRegistry[MySQLBulkerTypeId] = TestConfig{BulkerType: MySQLBulkerTypeId, Config: DataSourceConfig{
-			Host:       mysqlContainer.Host,
-			Port:       mysqlContainer.Port,
-			Username:   mysqlContainer.Username,
-			Password:   mysqlContainer.Password,
-			Db:         mysqlContainer.Database,
-			Parameters: map[string]string{"tls": "false", "parseTime": "true"},
+		configRegistry[MySQLBulkerTypeId] = TestConfig{BulkerType: MySQLBulkerTypeId, Config: MySQLConfig{
+			DataSourceConfig: DataSourceConfig{
+				Host:       mysqlContainer.Host,
+				Port:       mysqlContainer.Port,
+				Username:   mysqlContainer.Username,
+				Password:   mysqlContainer.Password,
+				Db:         mysqlContainer.Database,
+				Parameters: map[string]string{"tls": "false", "parseTime": "true"},
+			},
+			LoadDataLocalInfile: true,
+		}}
+	}
+
+	if utils.ArrayContains(allBulkerConfigs, MySQLBulkerTypeId+"_no_infile") {
+		//server rejects LOAD DATA LOCAL INFILE so bulker falls back to insert statements
+		mysqlNoInfileContainer, err = testcontainers2.NewMySQLContainer(context.Background(), false)
+		if err != nil {
+			panic(err)
+		}
+		configRegistry[MySQLBulkerTypeId+"_no_infile"] = TestConfig{BulkerType: MySQLBulkerTypeId, Config: MySQLConfig{
+			DataSourceConfig: DataSourceConfig{
+				Host:       mysqlNoInfileContainer.Host,
+				Port:       mysqlNoInfileContainer.Port,
+				Username:   mysqlNoInfileContainer.Username,
+				Password:   mysqlNoInfileContainer.Password,
+				Db:         mysqlNoInfileContainer.Database,
+				Parameters: map[string]string{"tls": "false", "parseTime": "true"},
+			},
+			LoadDataLocalInfile: true,
+		}}
+	}
+
+	if utils.ArrayContains(allBulkerConfigs, MSSQLBulkerTypeId) {
+		mssqlContainer, err = testcontainers2.NewMSSQLContainer(context.Background())
+		if err != nil {
+			panic(err)
+		}
+		configRegistry[MSSQLBulkerTypeId] = TestConfig{BulkerType: MSSQLBulkerTypeId, Config: DataSourceConfig{
+			Host:       mssqlContainer.Host,
+			Port:       mssqlContainer.Port,
+			Username:   mssqlContainer.Username,
+			Password:   mssqlContainer.Password,
+			Db:         mssqlContainer.Database,
+			Parameters: map[string]string{"encrypt": "disable"},
 		}}
 	}
 
@@ -166,6 +211,19 @@ func init() {
 		}}
 	}
 
+	if utils.ArrayContains(allBulkerConfigs, DuckDBBulkerTypeId) {
+		configRegistry[DuckDBBulkerTypeId] = TestConfig{BulkerType: DuckDBBulkerTypeId, Config: DuckDBConfig{
+			Path:   filepath.Join(os.TempDir(), "bulker_test.duckdb"),
+			Schema: "bulker",
+		}}
+	}
+
+	if utils.ArrayContains(allBulkerConfigs, SQLiteBulkerTypeId) {
+		configRegistry[SQLiteBulkerTypeId] = TestConfig{BulkerType: SQLiteBulkerTypeId, Config: SQLiteConfig{
+			Path: filepath.Join(os.TempDir(), "bulker_test.sqlite"),
+		}}
+	}
+
 	exceptBigquery = utils.ArrayExcluding(allBulkerConfigs, BigqueryBulkerTypeId)
 
 	logging.Infof("Initialized bulker types: %v", allBulkerConfigs)
@@ -181,6 +239,9 @@ func init() {
 	//	if mysqlContainer != nil {
 	//		_ = mysqlContainer.Close()
 	//	}
+	//	if mssqlContainer != nil {
+	//		_ = mssqlContainer.Close()
+	//	}
 	//	if clickhouseContainer != nil {
 	//		_ = clickhouseContainer.Close()
 	//	}
@@ -364,6 +425,44 @@ func TestBasics(t *testing.T) {
 			configIds:      allBulkerConfigs,
 			streamOptions:  []bulker.StreamOption{bulker.WithTimestamp("_timestamp")},
 		},
+		{
+			name:              "rollover_added_columns",
+			modes:             []bulker.BulkMode{bulker.Batch, bulker.ReplaceTable, bulker.ReplacePartition},
+			expectPartitionId: true,
+			dataFile:          "test_data/columns_added.ndjson",
+			expectedTable: ExpectedTable{
+				Columns: justColumns("_timestamp", "column1", "column2", "column3", "id", "name"),
+			},
+			expectedRows: []map[string]any{
+				{"_timestamp": constantTime, "id": 1, "name": "test", "column1": nil, "column2": nil, "column3": nil},
+				{"_timestamp": constantTime, "id": 2, "name": "test2", "column1": "data", "column2": nil, "column3": nil},
+				{"_timestamp": constantTime, "id": 3, "name": "test3", "column1": "data", "column2": "data", "column3": nil},
+				{"_timestamp": constantTime, "id": 4, "name": "test2", "column1": "data", "column2": nil, "column3": nil},
+				{"_timestamp": constantTime, "id": 5, "name": "test", "column1": nil, "column2": nil, "column3": nil},
+				{"_timestamp": constantTime, "id": 6, "name": "test4", "column1": "data", "column2": "data", "column3": "data"},
+			},
+			configIds:     allBulkerConfigs,
+			streamOptions: []bulker.StreamOption{bulker.WithMaxRowsPerFile(2)},
+		},
+		{
+			name:              "rollover_pk",
+			modes:             []bulker.BulkMode{bulker.Batch, bulker.ReplaceTable, bulker.ReplacePartition},
+			expectPartitionId: true,
+			dataFile:          "test_data/repeated_ids.ndjson",
+			expectedTable: ExpectedTable{
+				PKFields: utils.NewSet("id"),
+				Columns:  justColumns("_timestamp", "id", "name"),
+			},
+			//duplicates are spread across parts of batch file
+			expectedRows: []map[string]any{
+				{"_timestamp": constantTime, "id": 1, "name": "test7"},
+				{"_timestamp": constantTime, "id": 2, "name": "test1"},
+				{"_timestamp": constantTime, "id": 3, "name": "test6"},
+				{"_timestamp": constantTime, "id": 4, "name": "test5"},
+			},
+			configIds:     allBulkerConfigs,
+			streamOptions: []bulker.StreamOption{bulker.WithPrimaryKey("id"), bulker.WithMergeRows(), bulker.WithMaxRowsPerFile(3)},
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
@@ -374,6 +473,195 @@ func TestBasics(t *testing.T) {
 	}
 }
 
+// TestPostgresPartitions sequentially loads data to tables partitioned by month of _timestamp
+func TestPostgresPartitions(t *testing.T) {
+	t.Parallel()
+	if !utils.ArrayContains(allBulkerConfigs, PostgresBulkerTypeId) {
+		t.Skipf("Config '%s' is not selected for this test", PostgresBulkerTypeId)
+	}
+	month := func(m time.Month) time.Time {
+		return time.Date(2023, m, 1, 0, 0, 0, 0, time.UTC)
+	}
+	ts := func(value string) time.Time {
+		return timestamp.MustParseTime(time.RFC3339Nano, value)
+	}
+	partitionOptions := []bulker.StreamOption{bulker.WithTimestamp("_timestamp"), WithPartitionGranularity(MONTH)}
+	tests := []bulkerTestConfig{
+		{
+			//delete any table leftovers from previous tests
+			name:          "dummy_test_table_cleanup",
+			tableName:     "pg_partitions_test",
+			modes:         []bulker.BulkMode{bulker.Batch, bulker.Stream, bulker.ReplaceTable},
+			dataFile:      "test_data/empty.ndjson",
+			streamOptions: partitionOptions,
+			configIds:     []string{PostgresBulkerTypeId},
+		},
+		{
+			name:                "create_partitioned_table",
+			tableName:           "pg_partitions_test",
+			modes:               []bulker.BulkMode{bulker.Batch, bulker.Stream, bulker.ReplaceTable},
+			leaveResultingTable: true,
+			dataFile:            "test_data/date_partitions.ndjson",
+			expectedRows: []map[string]any{
+				{"_timestamp": ts("2023-01-15T10:00:00Z"), "id": 1, "name": "test"},
+				{"_timestamp": ts("2023-01-31T23:59:59Z"), "id": 2, "name": "test2"},
+				{"_timestamp": ts("2023-02-01T00:00:00Z"), "id": 3, "name": "test3"},
+				{"_timestamp": nil, "id": 4, "name": "test4"},
+			},
+			postStepFunctions: map[string]StepFunction{
+				"stream_complete": pgPartitionsStep(month(1), month(2)),
+			},
+			streamOptions: partitionOptions,
+			configIds:     []string{PostgresBulkerTypeId},
+		},
+		{
+			name:                "load_new_partitions",
+			tableName:           "pg_partitions_test",
+			modes:               []bulker.BulkMode{bulker.Batch, bulker.Stream},
+			leaveResultingTable: true,
+			dataFile:            "test_data/date_partitions2.ndjson",
+			expectedRows: []map[string]any{
+				{"_timestamp": ts("2023-01-15T10:00:00Z"), "id": 1, "name": "test"},
+				{"_timestamp": ts("2023-01-31T23:59:59Z"), "id": 2, "name": "test2"},
+				{"_timestamp": ts("2023-02-01T00:00:00Z"), "id": 3, "name": "test3"},
+				{"_timestamp": nil, "id": 4, "name": "test4"},
+				{"_timestamp": ts("2023-02-10T10:00:00Z"), "id": 5, "name": "test5"},
+				{"_timestamp": ts("2023-03-05T10:00:00Z"), "id": 6, "name": "test6"},
+			},
+			postStepFunctions: map[string]StepFunction{
+				"stream_complete": pgPartitionsStep(month(1), month(2), month(3)),
+			},
+			streamOptions: partitionOptions,
+			configIds:     []string{PostgresBulkerTypeId},
+		},
+		{
+			name:          "dummy_test_table_cleanup",
+			tableName:     "pg_partitions_test",
+			modes:         []bulker.BulkMode{bulker.Batch, bulker.Stream, bulker.ReplaceTable},
+			dataFile:      "test_data/empty.ndjson",
+			streamOptions: partitionOptions,
+			configIds:     []string{PostgresBulkerTypeId},
+		},
+		{
+			//delete any table leftovers from previous tests
+			name:          "dummy_test_table_cleanup",
+			tableName:     "pg_date_partition_test",
+			modes:         []bulker.BulkMode{bulker.ReplacePartition},
+			dataFile:      "test_data/empty.ndjson",
+			streamOptions: append([]bulker.StreamOption{bulker.WithPartition("MONTH/2023-01-01T00:00:00Z")}, partitionOptions...),
+			configIds:     []string{PostgresBulkerTypeId},
+		},
+		{
+			name:                "first_date_partition",
+			tableName:           "pg_date_partition_test",
+			modes:               []bulker.BulkMode{bulker.ReplacePartition},
+			leaveResultingTable: true,
+			dataFile:            "test_data/date_partition1.ndjson",
+			expectedRows: []map[string]any{
+				{"_timestamp": ts("2023-01-15T10:00:00Z"), "id": 1, "name": "test", "__partition_id": "MONTH/2023-01-01T00:00:00Z"},
+				{"_timestamp": ts("2023-01-20T10:00:00Z"), "id": 2, "name": "test2", "__partition_id": "MONTH/2023-01-01T00:00:00Z"},
+			},
+			postStepFunctions: map[string]StepFunction{
+				"stream_complete": pgPartitionsStep(month(1)),
+			},
+			streamOptions: append([]bulker.StreamOption{bulker.WithPartition("MONTH/2023-01-01T00:00:00Z")}, partitionOptions...),
+			configIds:     []string{PostgresBulkerTypeId},
+		},
+		{
+			//regular partition id with rows in both january and february partitions
+			name:                "second_partition",
+			tableName:           "pg_date_partition_test",
+			modes:               []bulker.BulkMode{bulker.ReplacePartition},
+			leaveResultingTable: true,
+			dataFile:            "test_data/date_partition2.ndjson",
+			expectedRows: []map[string]any{
+				{"_timestamp": ts("2023-01-15T10:00:00Z"), "id": 1, "name": "test", "__partition_id": "MONTH/2023-01-01T00:00:00Z"},
+				{"_timestamp": ts("2023-01-20T10:00:00Z"), "id": 2, "name": "test2", "__partition_id": "MONTH/2023-01-01T00:00:00Z"},
+				{"_timestamp": ts("2023-01-25T10:00:00Z"), "id": 3, "name": "test3", "__partition_id": "2"},
+				{"_timestamp": ts("2023-02-10T10:00:00Z"), "id": 4, "name": "test4", "__partition_id": "2"},
+			},
+			postStepFunctions: map[string]StepFunction{
+				"stream_complete": pgPartitionsStep(month(1), month(2)),
+			},
+			streamOptions: append([]bulker.StreamOption{bulker.WithPartition("2")}, partitionOptions...),
+			configIds:     []string{PostgresBulkerTypeId},
+		},
+		{
+			//january child partition is truncated: rows of other partition ids within january are replaced too
+			name:                "first_date_partition_reprocess",
+			tableName:           "pg_date_partition_test",
+			modes:               []bulker.BulkMode{bulker.ReplacePartition},
+			leaveResultingTable: true,
+			dataFile:            "test_data/date_partition1_1.ndjson",
+			expectedRows: []map[string]any{
+				{"_timestamp": ts("2023-02-10T10:00:00Z"), "id": 4, "name": "test4", "__partition_id": "2"},
+				{"_timestamp": ts("2023-01-05T10:00:00Z"), "id": 5, "name": "test5", "__partition_id": "MONTH/2023-01-01T00:00:00Z"},
+			},
+			postStepFunctions: map[string]StepFunction{
+				"stream_complete": pgPartitionsStep(month(1), month(2)),
+			},
+			streamOptions: append([]bulker.StreamOption{bulker.WithPartition("MONTH/2023-01-01T00:00:00Z")}, partitionOptions...),
+			configIds:     []string{PostgresBulkerTypeId},
+		},
+		{
+			name:          "dummy_test_table_cleanup",
+			tableName:     "pg_date_partition_test",
+			modes:         []bulker.BulkMode{bulker.ReplacePartition},
+			dataFile:      "test_data/empty.ndjson",
+			streamOptions: append([]bulker.StreamOption{bulker.WithPartition("MONTH/2023-01-01T00:00:00Z")}, partitionOptions...),
+			configIds:     []string{PostgresBulkerTypeId},
+		},
+	}
+	sequentialGroup := sync.WaitGroup{}
+	sequentialGroup.Add(1)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runTestConfig(t, tt, testStream)
+			sequentialGroup.Done()
+		})
+		sequentialGroup.Wait()
+		sequentialGroup.Add(1)
+	}
+}
+
+// pgPartitionsStep returns StepFunction that checks that table is partitioned by month of _timestamp
+// and has child partitions exactly for provided lower bounds
+func pgPartitionsStep(lowerBounds ...time.Time) StepFunction {
+	return func(testConfig bulkerTestConfig, mode bulker.BulkMode) error {
+		blk, err := bulker.CreateBulker(*testConfig.config)
+		if err != nil {
+			return err
+		}
+		defer blk.Close()
+		p, ok := blk.(*Postgres)
+		if !ok {
+			return fmt.Errorf("unexpected bulker type: %T", blk)
+		}
+		ctx := context.Background()
+		_, tableName := testConfig.getIdAndTableName(mode)
+		table, err := p.GetTableSchema(ctx, tableName)
+		if err != nil {
+			return err
+		}
+		if table.Partition.Field != "_timestamp" || table.Partition.Granularity != MONTH {
+			return fmt.Errorf("table %s is not partitioned by month of _timestamp: %+v", tableName, table.Partition)
+		}
+		partitions, err := p.getPartitions(ctx, table)
+		if err != nil {
+			return err
+		}
+		if len(partitions) != len(lowerBounds) {
+			return fmt.Errorf("table %s has %d partitions: %v. expected: %d", tableName, len(partitions), partitions, len(lowerBounds))
+		}
+		for _, lower := range lowerBounds {
+			if _, ok := partitions[lower.Unix()]; !ok {
+				return fmt.Errorf("table %s has no partition for %s: %v", tableName, lower.Format(time.RFC3339), partitions)
+			}
+		}
+		return nil
+	}
+}
+
 func runTestConfig(t *testing.T, tt bulkerTestConfig, testFunc func(*testing.T, bulkerTestConfig, bulker.BulkMode)) {
 	if tt.config != nil {
 		for _, mode := range tt.modes {
@@ -7,13 +7,15 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	_ "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/jitsucom/bulker/bulkerlib"
 	"github.com/jitsucom/bulker/bulkerlib/types"
 	"github.com/jitsucom/bulker/jitsubase/errorj"
 	"github.com/jitsucom/bulker/jitsubase/logging"
 	"github.com/jitsucom/bulker/jitsubase/utils"
+	"github.com/jitsucom/bulker/jitsubase/uuid"
 	jsoniter "github.com/json-iterator/go"
+	"math"
 	"os"
 	"regexp"
 	"strconv"
@@ -39,6 +41,7 @@ const (
 
 	chTableSchemaQuery       = `SELECT name, type, is_in_primary_key FROM system.columns WHERE database = ? and table = ? and default_kind not in ('MATERIALIZED', 'ALIAS', 'EPHEMERAL')`
 	chCreateDatabaseTemplate = `CREATE DATABASE IF NOT EXISTS "%s" %s`
+	chTableEngineQuery       = `SELECT partition_key, engine_full FROM system.tables WHERE database = ? and name = ?`
 
 	chOnClusterClauseTemplate = " ON CLUSTER `%s` "
 	chNullableColumnTemplate  = ` Nullable(%s) `
@@ -56,8 +59,20 @@ const (
 	chExchangeTableTemplate = `EXCHANGE TABLES %s AND %s %s`
 	chRenameTableTemplate   = `RENAME TABLE %s TO %s %s`
 
+	chCreateTableAsTemplate    = `CREATE TABLE %s %s AS %s ENGINE = %s`
+	chReplacePartitionTemplate = `ALTER TABLE %s %s REPLACE PARTITION %s FROM %s`
+	chDropPartitionTemplate    = `ALTER TABLE %s %s DROP PARTITION %s`
+	chCreateFinalViewTemplate  = `CREATE OR REPLACE VIEW %s %s AS SELECT * FROM %s FINAL`
+	chFinalViewSuffix          = "_final"
+	chReplacingMergeTreeEngine = "ReplacingMergeTree"
+	chReplicatedEnginePrefix   = "Replicated"
+
 	chSelectFinalStatement = `SELECT %s FROM %s FINAL %s%s`
 	chLoadStatement        = `INSERT INTO %s (%s) VALUES %s`
+	chBatchInsertStatement = `INSERT INTO %s (%s)`
+
+	// chDefaultLoadChunkSize default number of rows sent in a single INSERT by LoadTable
+	chDefaultLoadChunkSize = 10000
 )
 
 var (
@@ -133,6 +148,12 @@ type ClickHouseConfig struct {
 	Cluster    string             `mapstructure:"cluster,omitempty" json:"cluster,omitempty" yaml:"cluster,omitempty"`
 	TLS        map[string]string  `mapstructure:"tls,omitempty" json:"tls,omitempty" yaml:"tls,omitempty"`
 	Engine     *EngineConfig      `mapstructure:"engine,omitempty" json:"engine,omitempty" yaml:"engine,omitempty"`
+	// LoadChunkSize max number of rows sent in a single INSERT when batch file is loaded. Default: 10000
+	LoadChunkSize int `mapstructure:"loadChunkSize,omitempty" json:"loadChunkSize,omitempty" yaml:"loadChunkSize,omitempty"`
+	// AsyncInsert enables asynchronous inserts on server side (async_insert=1). Bulker waits for data to be flushed (wait_for_async_insert=1)
+	AsyncInsert bool `mapstructure:"asyncInsert,omitempty" json:"asyncInsert,omitempty" yaml:"asyncInsert,omitempty"`
+	// FinalView creates <table>_final view that selects deduplicated rows (with FINAL modifier) of tables with primary key
+	FinalView bool `mapstructure:"finalView,omitempty" json:"finalView,omitempty" yaml:"finalView,omitempty"`
 }
 
 // EngineConfig dto for deserialized clickhouse engine config
@@ -142,6 +163,9 @@ type EngineConfig struct {
 	PartitionFields []FieldConfig `mapstructure:"partitionFields,omitempty" json:"partitionFields,omitempty" yaml:"partitionFields,omitempty"`
 	OrderFields     []FieldConfig `mapstructure:"orderFields,omitempty" json:"orderFields,omitempty" yaml:"orderFields,omitempty"`
 	PrimaryKeys     []string      `mapstructure:"primaryKeys,omitempty" json:"primaryKeys,omitempty" yaml:"primaryKeys,omitempty"`
+	// NestedType ClickHouse type of columns that keep nested objects of the top level instead of flattening them:
+	// Map(String, String) or JSON. Nested objects are flattened if empty
+	NestedType string `mapstructure:"nestedType,omitempty" json:"nestedType,omitempty" yaml:"nestedType,omitempty"`
 }
 
 // FieldConfig dto for deserialized clickhouse engine fields
@@ -156,6 +180,7 @@ type ClickHouse struct {
 	httpMode              bool
 	distributed           bool
 	tableStatementFactory *TableStatementFactory
+	nullableFields        []string
 }
 
 // NewClickHouse returns configured ClickHouse adapter instance
@@ -183,6 +208,9 @@ func NewClickHouse(bulkerConfig bulkerlib.Config) (bulkerlib.Bulker, error) {
 	utils.MapPutIfAbsent(config.Parameters, "mutations_sync", "2")
 	utils.MapPutIfAbsent(config.Parameters, "dial_timeout", "60s")
 	utils.MapPutIfAbsent(config.Parameters, "read_timeout", "60s")
+	if config.LoadChunkSize <= 0 {
+		config.LoadChunkSize = chDefaultLoadChunkSize
+	}
 
 	dbConnectFunction := func(config *ClickHouseConfig) (*sql.DB, error) {
 		dsn := clickhouseDriverConnectionString(config)
@@ -224,8 +252,9 @@ func NewClickHouse(bulkerConfig bulkerlib.Config) (bulkerlib.Bulker, error) {
 		SQLAdapterBase:        sqlAdapterBase,
 		tableStatementFactory: tableStatementFactory,
 		httpMode:              httpMode,
+		nullableFields:        nullableFields,
 	}
-	c.tableHelper = NewTableHelper(63, '`')
+	c.tableHelper = NewTableHelper(bulkerConfig.Id, 63, '`')
 	return c, err
 }
 
@@ -273,24 +302,53 @@ func clickhouseDriverConnectionString(config *ClickHouseConfig) string {
 
 func (ch *ClickHouse) CreateStream(id, tableName string, mode bulkerlib.BulkMode, streamOptions ...bulkerlib.StreamOption) (bulkerlib.BulkerStream, error) {
 	streamOptions = append(streamOptions, withLocalBatchFile(fmt.Sprintf("bulker_%s", utils.SanitizeString(id))))
+	so := bulkerlib.StreamOptions{}
+	for _, opt := range streamOptions {
+		so.Add(opt)
+	}
+	adapter := ch
+	engine := ch.config.Engine
+	if streamEngine := ClickHouseEngineOption.Get(&so); streamEngine != nil {
+		engine = streamEngine
+		adapter = ch.withEngine(streamEngine)
+	}
+	if engine != nil && engine.NestedType != "" {
+		streamOptions = append(streamOptions, withJsonColumns(&jsonColumnsOption, "*"), withJsonColumnType(engine.NestedType))
+	}
 
 	switch mode {
 	case bulkerlib.Stream:
-		return newAutoCommitStream(id, ch, tableName, streamOptions...)
+		return newAutoCommitStream(id, adapter, tableName, streamOptions...)
 	case bulkerlib.Batch:
-		return newTransactionalStream(id, ch, tableName, streamOptions...)
+		return newTransactionalStream(id, adapter, tableName, streamOptions...)
 	case bulkerlib.ReplaceTable:
-		return newReplaceTableStream(id, ch, tableName, streamOptions...)
+		return newReplaceTableStream(id, adapter, tableName, streamOptions...)
 	case bulkerlib.ReplacePartition:
-		return newReplacePartitionStream(id, ch, tableName, streamOptions...)
+		return newReplacePartitionStream(id, adapter, tableName, streamOptions...)
 	}
 	return nil, fmt.Errorf("unsupported bulk mode: %s", mode)
 }
 
+// withEngine returns copy of adapter that creates tables with provided engine settings
+func (ch *ClickHouse) withEngine(engine *EngineConfig) *ClickHouse {
+	config := *ch.config
+	config.Engine = engine
+	streamAdapter := *ch
+	streamAdapter.tableStatementFactory = &TableStatementFactory{config: &config, onClusterClause: ch.tableStatementFactory.onClusterClause}
+	streamAdapter.nullableFields = engine.NullableFields
+	return &streamAdapter
+}
+
 func (ch *ClickHouse) Type() string {
 	return ClickHouseBulkerTypeId
 }
 
+// columnDDL returns column DDL with nullable fields of adapter's engine settings
+func (ch *ClickHouse) columnDDL(name string, table *Table) string {
+	quoted, unquoted := ch.tableHelper.adaptColumnName(name)
+	return chColumnDDL(quoted, unquoted, table, ch.nullableFields)
+}
+
 // OpenTx opens underline sql transaction and return wrapped instance
 func (ch *ClickHouse) OpenTx(ctx context.Context) (*TxSQLAdapter, error) {
 	//return ch.openTx(ctx, ch)
@@ -334,7 +392,9 @@ func (ch *ClickHouse) InitDatabase(ctx context.Context) error {
 }
 
 // CreateTable create database table with name,columns provided in Table representation
-// New tables will have MergeTree() or ReplicatedMergeTree() engine depends on config.cluster empty or not
+// New tables will have MergeTree() or ReplicatedMergeTree() engine depends on config.cluster empty or not.
+// Tables with primary key have ReplacingMergeTree engine that deduplicates rows by primary key
+// keeping the row with the latest value of table.VersionColumn (or the last inserted row)
 func (ch *ClickHouse) CreateTable(ctx context.Context, table *Table) error {
 	if table.Temporary {
 		table := table.Clone()
@@ -377,9 +437,14 @@ func (ch *ClickHouse) CreateTable(ctx context.Context, table *Table) error {
 
 	//create distributed table
 	if ch.distributed {
-		return ch.createDistributedTableInTransaction(ctx, table)
+		if err := ch.createDistributedTableInTransaction(ctx, table); err != nil {
+			return err
+		}
 	}
 
+	if len(table.PKFields) > 0 {
+		return ch.createFinalView(ctx, table.Name)
+	}
 	return nil
 }
 
@@ -473,11 +538,23 @@ func (ch *ClickHouse) PatchTableSchema(ctx context.Context, patchSchema *Table)
 			ch.Errorf("Error altering distributed table for [%s] with statement [%s]: %v", patchSchema.Name, query, err)
 			// fallback for older clickhouse versions: drop and create distributed table if ReplicatedMergeTree engine
 			ch.dropTable(ctx, ch.quotedTableName(patchSchema.Name), ch.getOnClusterClause(), true)
-			return ch.createDistributedTableInTransaction(ctx, patchSchema)
+			if err = ch.createDistributedTableInTransaction(ctx, patchSchema); err != nil {
+				return err
+			}
 		}
 
 	}
 
+	if ch.config.FinalView {
+		//view has columns of the table at the moment of creation
+		table, err := ch.GetTableSchema(ctx, patchSchema.Name)
+		if err != nil {
+			return err
+		}
+		if len(table.PKFields) > 0 {
+			return ch.createFinalView(ctx, patchSchema.Name)
+		}
+	}
 	return nil
 }
 
@@ -518,11 +595,13 @@ func (ch *ClickHouse) Count(ctx context.Context, tableName string, whenCondition
 	return strconv.Atoi(fmt.Sprint(scnt))
 }
 
+// Insert inserts objects to the table. Rows with the same primary key are deduplicated by ReplacingMergeTree engine of the table
+// and only the latest row is returned by Select and by <table>_final view
 func (ch *ClickHouse) Insert(ctx context.Context, table *Table, _ bool, objects ...types.Object) (err error) {
-	return ch.insert(ctx, table, objects)
+	return ch.insert(ch.insertContext(ctx), table, objects)
 }
 
-// LoadTable transfer data from local file to ClickHouse table
+// LoadTable transfer data from local file to ClickHouse table in chunks of config.LoadChunkSize rows
 func (ch *ClickHouse) LoadTable(ctx context.Context, targetTable *Table, loadSource *LoadSource) (err error) {
 	if loadSource.Type != LocalFile {
 		return fmt.Errorf("LoadTable: only local file is supported")
@@ -530,16 +609,7 @@ func (ch *ClickHouse) LoadTable(ctx context.Context, targetTable *Table, loadSou
 	if loadSource.Format != ch.batchFileFormat {
 		return fmt.Errorf("LoadTable: only %s format is supported", ch.batchFileFormat)
 	}
-	tableName := ch.quotedTableName(targetTable.Name)
-
-	columns := targetTable.SortedColumnNames()
-	columnNames := make([]string, len(columns))
-	for i, name := range columns {
-		columnNames[i] = ch.quotedColumnName(name)
-	}
-	var placeholdersBuilder strings.Builder
-	args := make([]any, 0, len(columns))
-	var copyStatement string
+	var statement string
 	defer func() {
 		if err != nil {
 			err = errorj.LoadError.Wrap(err, "failed to load table").
@@ -548,7 +618,7 @@ func (ch *ClickHouse) LoadTable(ctx context.Context, targetTable *Table, loadSou
 					Cluster:     ch.config.Cluster,
 					Table:       targetTable.Name,
 					PrimaryKeys: targetTable.GetPKFields(),
-					Statement:   copyStatement,
+					Statement:   statement,
 				})
 		}
 	}()
@@ -557,7 +627,19 @@ func (ch *ClickHouse) LoadTable(ctx context.Context, targetTable *Table, loadSou
 	if err != nil {
 		return err
 	}
-	scanner := bufio.NewScanner(file)
+	defer func() {
+		_ = file.Close()
+	}()
+	reader, err := types.NewCompressionReader(loadSource.Compression, file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	ctx = ch.insertContext(ctx)
+	chunk := make([]map[string]any, 0, ch.config.LoadChunkSize)
+	scanner := bufio.NewScanner(reader)
 	scanner.Buffer(make([]byte, 1024*100), 1024*1024*10)
 	for scanner.Scan() {
 		object := map[string]any{}
@@ -567,34 +649,104 @@ func (ch *ClickHouse) LoadTable(ctx context.Context, targetTable *Table, loadSou
 		if err != nil {
 			return err
 		}
-		placeholdersBuilder.WriteString(",(")
-		for i, v := range columns {
-			column := targetTable.Columns[v]
-			l, err := convertType(object[v], column)
-			if err != nil {
+		chunk = append(chunk, object)
+		if len(chunk) >= ch.config.LoadChunkSize {
+			if statement, err = ch.insertChunk(ctx, targetTable, chunk); err != nil {
 				return err
 			}
-			//ch.Infof("%s: %v (%T) was %v", v, l, l, object[v])
-			if i > 0 {
-				placeholdersBuilder.WriteString(",")
-			}
-			placeholdersBuilder.WriteString(ch.typecastFunc(ch.parameterPlaceholder(i, ch.quotedColumnName(v)), column))
-			args = append(args, l)
+			chunk = chunk[:0]
 		}
-		placeholdersBuilder.WriteString(")")
 	}
 	if err = scanner.Err(); err != nil {
 		return fmt.Errorf("LoadTable: failed to read file: %v", err)
 	}
-	if len(args) > 0 {
-		copyStatement = fmt.Sprintf(chLoadStatement, tableName, strings.Join(columnNames, ", "), placeholdersBuilder.String()[1:])
-		if _, err := ch.txOrDb(ctx).ExecContext(ctx, copyStatement, args...); err != nil {
-			return checkErr(err)
+	if len(chunk) > 0 {
+		statement, err = ch.insertChunk(ctx, targetTable, chunk)
+	}
+	return err
+}
+
+// insertChunk inserts objects with a single INSERT statement.
+// Native protocol uses batch API of the driver. HTTP protocol uses INSERT ... VALUES statement with placeholders
+func (ch *ClickHouse) insertChunk(ctx context.Context, table *Table, chunk []map[string]any) (statement string, err error) {
+	tableName := ch.quotedTableName(table.Name)
+	columns := table.SortedColumnNames()
+	columnNames := make([]string, len(columns))
+	for i, name := range columns {
+		columnNames[i] = ch.quotedColumnName(name)
+	}
+	if ch.httpMode {
+		var placeholdersBuilder strings.Builder
+		args := make([]any, 0, len(columns)*len(chunk))
+		for _, object := range chunk {
+			placeholdersBuilder.WriteString(",(")
+			for i, v := range columns {
+				column := table.Columns[v]
+				l, err := convertType(object[v], column)
+				if err != nil {
+					return "", err
+				}
+				if i > 0 {
+					placeholdersBuilder.WriteString(",")
+				}
+				placeholdersBuilder.WriteString(ch.typecastFunc(ch.parameterPlaceholder(i, ch.quotedColumnName(v)), column))
+				args = append(args, l)
+			}
+			placeholdersBuilder.WriteString(")")
 		}
+		statement = fmt.Sprintf(chLoadStatement, tableName, strings.Join(columnNames, ", "), placeholdersBuilder.String()[1:])
+		if _, err := ch.txOrDb(ctx).ExecContext(ctx, statement, args...); err != nil {
+			return statement, checkErr(err)
+		}
+		return statement, nil
 	}
-	return nil
+
+	statement = fmt.Sprintf(chBatchInsertStatement, tableName, strings.Join(columnNames, ", "))
+	//driver sends rows appended to prepared INSERT statement on transaction commit
+	sqlTx, err := ch.dataSource.BeginTx(ctx, nil)
+	if err != nil {
+		return statement, err
+	}
+	tx := NewTxWrapper(ch.Type(), sqlTx, ch.queryLogger, ch.checkErrFunc)
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	stmt, err := tx.PrepareContext(ctx, statement)
+	if err != nil {
+		return statement, err
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+	values := make([]any, len(columns))
+	for _, object := range chunk {
+		for i, name := range columns {
+			nullable := utils.ArrayContains(ch.nullableFields, name)
+			if values[i], err = chBatchValue(object[name], table.Columns[name].Type, nullable); err != nil {
+				return statement, fmt.Errorf("failed to convert value of column %s: %v", name, err)
+			}
+		}
+		if _, err = stmt.ExecContext(ctx, values...); err != nil {
+			return statement, err
+		}
+	}
+	return statement, tx.Commit()
+}
+
+// insertContext returns context with settings of INSERT queries
+func (ch *ClickHouse) insertContext(ctx context.Context) context.Context {
+	if !ch.config.AsyncInsert {
+		return ctx
+	}
+	return clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{
+		"async_insert":          1,
+		"wait_for_async_insert": 1,
+	}))
 }
 
+// CopyTables copies rows of sourceTable to targetTable. Rows with the same primary key are deduplicated by ReplacingMergeTree engine of the table
 func (ch *ClickHouse) CopyTables(ctx context.Context, targetTable *Table, sourceTable *Table, _ bool) (err error) {
 	return ch.copy(ctx, targetTable, sourceTable)
 }
@@ -632,6 +784,11 @@ func (ch *ClickHouse) TruncateTable(ctx context.Context, tableName string) error
 }
 
 func (ch *ClickHouse) DropTable(ctx context.Context, tableName string, ifExists bool) error {
+	if ch.config.FinalView {
+		if err := ch.dropTable(ctx, ch.quotedFinalViewName(tableName), ch.getOnClusterClause(), true); err != nil {
+			return err
+		}
+	}
 	err := ch.dropTable(ctx, ch.quotedTableName(tableName), ch.getOnClusterClause(), ifExists)
 	if err != nil {
 		return err
@@ -689,11 +846,20 @@ func (ch *ClickHouse) ReplaceTable(ctx context.Context, targetTableName string,
 		if _, err := ch.txOrDb(ctx).ExecContext(ctx, query); err != nil {
 			return fmt.Errorf("error renaming [%s] table: %v", replacementTable.Name, err)
 		}
+		targetTable := replacementTable.Clone()
+		targetTable.Name = targetTableName
 		//on cluster we also need to create distributed table for newly create target table
 		if ch.distributed {
-			targetTable := replacementTable.Clone()
-			targetTable.Name = targetTableName
-			return ch.createDistributedTableInTransaction(ctx, targetTable)
+			if err := ch.createDistributedTableInTransaction(ctx, targetTable); err != nil {
+				return err
+			}
+		}
+		//view of replacement table refers to the table name that doesn't exist anymore
+		if ch.config.FinalView && len(targetTable.PKFields) > 0 {
+			if err := ch.dropTable(ctx, ch.quotedFinalViewName(replacementTable.Name), ch.getOnClusterClause(), true); err != nil {
+				return err
+			}
+			return ch.createFinalView(ctx, targetTableName)
 		}
 		return nil
 	}
@@ -731,6 +897,102 @@ func (ch *ClickHouse) createDistributedTableInTransaction(ctx context.Context, o
 	return nil
 }
 
+// createFinalView creates or replaces <table>_final view that selects table with FINAL modifier if config.FinalView is enabled
+func (ch *ClickHouse) createFinalView(ctx context.Context, tableName string) error {
+	if !ch.config.FinalView {
+		return nil
+	}
+	statement := fmt.Sprintf(chCreateFinalViewTemplate, ch.quotedFinalViewName(tableName), ch.getOnClusterClause(), ch.quotedTableName(tableName))
+	if _, err := ch.txOrDb(ctx).ExecContext(ctx, statement); err != nil {
+		return errorj.CreateTableError.Wrap(err, "failed to create final view").
+			WithProperty(errorj.DBInfo, &types.ErrorPayload{
+				Database:  ch.config.Database,
+				Cluster:   ch.config.Cluster,
+				Table:     tableName,
+				Statement: statement,
+			})
+	}
+	return nil
+}
+
+func (ch *ClickHouse) quotedFinalViewName(tableName string) string {
+	return ch.quotedTableName(tableName + chFinalViewSuffix)
+}
+
+// ReplacePartition replaces partition of the table partitioned by __partition_id column
+// with ALTER TABLE ... REPLACE PARTITION from staging table that has the same engine as target table.
+// Partition is dropped if there is no new data
+func (ch *ClickHouse) ReplacePartition(ctx context.Context, targetTable *Table, sourceTable *Table, partitionId string) (replaced bool, err error) {
+	var partitionKey, engine, statement string
+	defer func() {
+		if err != nil {
+			err = errorj.AlterTableError.Wrap(err, "failed to replace partition").
+				WithProperty(errorj.DBInfo, &types.ErrorPayload{
+					Database:  ch.config.Database,
+					Cluster:   ch.config.Cluster,
+					Table:     targetTable.Name,
+					Partition: partitionId,
+					Statement: statement,
+				})
+		}
+	}()
+	localTableName := ch.localTableName(ch.TableName(targetTable.Name))
+	err = ch.txOrDb(ctx).QueryRowContext(ctx, chTableEngineQuery, ch.config.Database, localTableName).Scan(&partitionKey, &engine)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if strings.Trim(partitionKey, "` ") != PartitonIdKeyword {
+		return false, nil
+	}
+	partition := chStringLiteral(partitionId)
+	if sourceTable == nil {
+		statement = fmt.Sprintf(chDropPartitionTemplate, ch.quotedLocalTableName(targetTable.Name), ch.getOnClusterClause(), partition)
+		if _, err = ch.txOrDb(ctx).ExecContext(ctx, statement); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	stagingTable := &Table{
+		Name:     fmt.Sprintf("jitsu_stg_%s", uuid.NewLettersNumbers()[:8]),
+		Columns:  targetTable.Columns,
+		PKFields: targetTable.PKFields,
+	}
+	if strings.HasPrefix(engine, chReplicatedEnginePrefix) {
+		//replicated staging table must have its own path in keeper
+		targetKeeperPath := chKeeperPath(ch.TableName(targetTable.Name))
+		if !strings.Contains(engine, targetKeeperPath) {
+			return false, nil
+		}
+		engine = strings.Replace(engine, targetKeeperPath, chKeeperPath(ch.TableName(stagingTable.Name)), 1)
+	}
+	statement = fmt.Sprintf(chCreateTableAsTemplate, ch.quotedLocalTableName(stagingTable.Name), ch.getOnClusterClause(), ch.quotedLocalTableName(targetTable.Name), engine)
+	if _, err = ch.txOrDb(ctx).ExecContext(ctx, statement); err != nil {
+		return false, err
+	}
+	defer func() {
+		if dropErr := ch.DropTable(ctx, stagingTable.Name, true); dropErr != nil {
+			ch.Errorf("failed to drop staging table %s: %v", stagingTable.Name, dropErr)
+		}
+	}()
+	if ch.distributed {
+		if err = ch.createDistributedTableInTransaction(ctx, stagingTable); err != nil {
+			return false, err
+		}
+	}
+	//rows must reach local staging tables on all shards before partition is replaced
+	insertCtx := clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{"insert_distributed_sync": 1}))
+	if err = ch.copy(insertCtx, stagingTable, sourceTable); err != nil {
+		return false, err
+	}
+	statement = fmt.Sprintf(chReplacePartitionTemplate, ch.quotedLocalTableName(targetTable.Name), ch.getOnClusterClause(), partition, ch.quotedLocalTableName(stagingTable.Name))
+	if _, err = ch.txOrDb(ctx).ExecContext(ctx, statement); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (ch *ClickHouse) quotedLocalTableName(tableName string) string {
 	return ch.quotedTableName(ch.localTableName(tableName))
 }
@@ -744,6 +1006,9 @@ func (ch *ClickHouse) localTableName(tableName string) string {
 }
 
 func convertType(value any, column types.SQLColumn) (any, error) {
+	if chIsMapType(column.Type) || chIsJSONType(column.Type) {
+		return chNestedValue(value, column.Type)
+	}
 	v := types.ReformatValue(value)
 	//ch.Infof("%v (%T) was %v (%T)", v, v, value, value)
 
@@ -810,6 +1075,264 @@ func convertType(value any, column types.SQLColumn) (any, error) {
 	return v, nil
 }
 
+// chBatchValue converts value to go type required by column of native batch: int64 for Int64, float64 for Float64, time.Time for DateTime64 etc.
+// Missing values of non-nullable columns are replaced with default values of column type
+func chBatchValue(value any, sqlType string, nullable bool) (any, error) {
+	baseType := strings.ToLower(strings.TrimSpace(sqlType))
+	for {
+		if inner, ok := chUnwrapType(baseType, "nullable"); ok {
+			nullable = true
+			baseType = inner
+		} else if inner, ok := chUnwrapType(baseType, "lowcardinality"); ok {
+			baseType = inner
+		} else {
+			break
+		}
+	}
+	if strings.HasPrefix(baseType, "map(") {
+		return chMapValue(value)
+	}
+	if chIsJSONType(baseType) {
+		//native JSON column accepts objects only
+		if value == nil {
+			return map[string]any{}, nil
+		}
+		if s, ok := value.(string); ok {
+			object := map[string]any{}
+			if err := jsoniter.UnmarshalFromString(s, &object); err != nil {
+				return nil, fmt.Errorf("can't convert %q to %s: %v", s, sqlType, err)
+			}
+			return object, nil
+		}
+		return value, nil
+	}
+	v := types.ReformatValue(value)
+	if v == nil {
+		if nullable {
+			return nil, nil
+		}
+		if strings.HasPrefix(baseType, "date") {
+			return time.Unix(0, 0).UTC(), nil
+		}
+		if v = defaultValues[baseType]; v == nil {
+			return nil, nil
+		}
+	}
+	switch baseType {
+	case "int8", "int16", "int32", "int64":
+		n, err := chInt64(v)
+		if err != nil {
+			return nil, err
+		}
+		switch baseType {
+		case "int8":
+			return int8(n), nil
+		case "int16":
+			return int16(n), nil
+		case "int32":
+			return int32(n), nil
+		default:
+			return n, nil
+		}
+	case "uint8", "uint16", "uint32", "uint64":
+		if b, ok := v.(bool); ok && baseType == "uint8" {
+			return b, nil
+		}
+		n, err := chInt64(v)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("can't convert negative value %d to %s", n, sqlType)
+		}
+		switch baseType {
+		case "uint8":
+			return uint8(n), nil
+		case "uint16":
+			return uint16(n), nil
+		case "uint32":
+			return uint32(n), nil
+		default:
+			return uint64(n), nil
+		}
+	case "float32", "float64":
+		var f float64
+		switch n := v.(type) {
+		case float64:
+			f = n
+		case int64:
+			f = float64(n)
+		case int:
+			f = float64(n)
+		case string:
+			var err error
+			if f, err = strconv.ParseFloat(strings.TrimSpace(n), 64); err != nil {
+				return nil, fmt.Errorf("error converting string to float64: %v", err)
+			}
+		default:
+			return nil, fmt.Errorf("can't convert %v (%T) to %s", v, v, sqlType)
+		}
+		if baseType == "float32" {
+			return float32(f), nil
+		}
+		return f, nil
+	case "bool":
+		switch n := v.(type) {
+		case bool:
+			return n, nil
+		case int64:
+			return n != 0, nil
+		case string:
+			b, err := strconv.ParseBool(n)
+			if err != nil {
+				return nil, fmt.Errorf("error converting string to bool: %v", err)
+			}
+			return b, nil
+		}
+		return nil, fmt.Errorf("can't convert %v (%T) to %s", v, v, sqlType)
+	case "string":
+		switch n := v.(type) {
+		case string:
+			return n, nil
+		case time.Time:
+			return n.Format("2006-01-02 15:04:05Z"), nil
+		case int64:
+			return strconv.FormatInt(n, 10), nil
+		case float64, int:
+			return fmt.Sprint(n), nil
+		case bool:
+			return strconv.FormatBool(n), nil
+		default:
+			b, err := jsoniter.Marshal(n)
+			if err != nil {
+				return nil, err
+			}
+			return string(b), nil
+		}
+	}
+	if strings.HasPrefix(baseType, "date") {
+		switch n := v.(type) {
+		case time.Time:
+			return n, nil
+		case int64:
+			return time.Unix(n, 0).UTC(), nil
+		}
+		return nil, fmt.Errorf("can't convert %v (%T) to %s", v, v, sqlType)
+	}
+	return v, nil
+}
+
+// chIsMapType returns true for Map(K, V) type
+func chIsMapType(sqlType string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(sqlType)), "map(")
+}
+
+// chIsJSONType returns true for JSON and Object('json') types
+func chIsJSONType(sqlType string) bool {
+	t := strings.ToLower(strings.TrimSpace(sqlType))
+	return t == "json" || strings.HasPrefix(t, "json(") || strings.HasPrefix(t, "object(")
+}
+
+// chNestedValue converts nested object (or its json representation) to value of Map or JSON column bound to query parameter:
+// map[string]string for Map and json string for JSON
+func chNestedValue(value any, sqlType string) (any, error) {
+	if chIsMapType(sqlType) {
+		return chMapValue(value)
+	}
+	switch v := value.(type) {
+	case nil:
+		return "{}", nil
+	case string:
+		return v, nil
+	default:
+		return jsoniter.MarshalToString(v)
+	}
+}
+
+// chMapValue converts nested object (or its json representation) to map[string]string.
+// Values that aren't strings are kept as json
+func chMapValue(value any) (map[string]string, error) {
+	var object map[string]any
+	switch v := value.(type) {
+	case nil:
+		return map[string]string{}, nil
+	case map[string]any:
+		object = v
+	case string:
+		decoder := jsoniter.NewDecoder(strings.NewReader(v))
+		decoder.UseNumber()
+		if err := decoder.Decode(&object); err != nil {
+			return nil, fmt.Errorf("can't convert %q to Map: %v", v, err)
+		}
+	default:
+		return nil, fmt.Errorf("can't convert %v (%T) to Map", v, v)
+	}
+	result := make(map[string]string, len(object))
+	for key, val := range object {
+		switch s := val.(type) {
+		case string:
+			result[key] = s
+		case nil:
+			result[key] = ""
+		default:
+			b, err := jsoniter.Marshal(s)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = string(b)
+		}
+	}
+	return result, nil
+}
+
+// chUnwrapType returns type wrapped with provided type modifier e.g. string for nullable(string)
+func chUnwrapType(sqlType, modifier string) (string, bool) {
+	if strings.HasPrefix(sqlType, modifier+"(") && strings.HasSuffix(sqlType, ")") {
+		return strings.TrimSpace(sqlType[len(modifier)+1 : len(sqlType)-1]), true
+	}
+	return sqlType, false
+}
+
+// chInt64 converts numeric, boolean or string value to int64
+func chInt64(v any) (int64, error) {
+	switch n := v.(type) {
+	case int64:
+		return n, nil
+	case int:
+		return int64(n), nil
+	case float64:
+		if n != math.Trunc(n) {
+			return 0, fmt.Errorf("error converting float to int64: %f", n)
+		}
+		return int64(n), nil
+	case bool:
+		if n {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("error converting string to int: %v", err)
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("can't convert %v (%T) to int64", v, v)
+}
+
+// chStringLiteral returns value as quoted ClickHouse string literal
+func chStringLiteral(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// chKeeperPath returns path of replicated table in keeper
+func chKeeperPath(tableName string) string {
+	//clear table path from non-letter symbols
+	keeperPath := strings.ToLower(tableName)
+	keeperPath = nonLettersCharacters.ReplaceAllString(keeperPath, "_")
+	return fmt.Sprintf("%s_%x", keeperPath, utils.HashString(tableName))
+}
+
 // chColumnDDL returns column DDL (column name, mapped sql type)
 func chColumnDDL(quotedName, name string, table *Table, nullableFields []string) string {
 	//get sql type
@@ -818,7 +1341,8 @@ func chColumnDDL(quotedName, name string, table *Table, nullableFields []string)
 
 	//get nullable or plain
 	var columnTypeDDL string
-	if utils.ArrayContains(nullableFields, name) {
+	//Map and JSON types can't be nullable
+	if utils.ArrayContains(nullableFields, name) && !chIsMapType(columnSQLType) && !chIsJSONType(columnSQLType) {
 		columnTypeDDL = fmt.Sprintf(chNullableColumnTemplate, columnSQLType)
 	} else {
 		columnTypeDDL = columnSQLType
@@ -837,6 +1361,12 @@ func chTypecastFunc(placeholder string, column types.SQLColumn) string {
 
 // return nil if column type is nullable or default value for input type
 func chGetDefaultValue(sqlType string) any {
+	if chIsMapType(sqlType) {
+		return map[string]string{}
+	}
+	if chIsJSONType(sqlType) {
+		return "{}"
+	}
 	if !strings.Contains(strings.ToLower(sqlType), "nullable") {
 		//get default value based on type
 		dv, ok := defaultValues[strings.ToLower(sqlType)]
@@ -856,6 +1386,12 @@ func chReformatValue(value any, valuePresent bool, sqlColumn types.SQLColumn) an
 	if !valuePresent {
 		return chGetDefaultValue(sqlColumn.Type)
 	}
+	if chIsMapType(sqlColumn.Type) || chIsJSONType(sqlColumn.Type) {
+		if v, err := chNestedValue(value, sqlColumn.Type); err == nil {
+			return v
+		}
+		return value
+	}
 	//reformat boolean
 	booleanValue, ok := value.(bool)
 	if ok {
@@ -904,6 +1440,18 @@ func (chc *ClickHouseConfig) Validate() error {
 		return errors.New("database is required parameter")
 	}
 
+	if chc.Engine != nil {
+		return chc.Engine.Validate()
+	}
+
+	return nil
+}
+
+// Validate engine settings
+func (ec *EngineConfig) Validate() error {
+	if ec.NestedType != "" && !chIsMapType(ec.NestedType) && !chIsJSONType(ec.NestedType) {
+		return fmt.Errorf("unsupported nestedType: %s. Supported types: Map(String, String), JSON", ec.NestedType)
+	}
 	return nil
 }
 
@@ -942,7 +1490,7 @@ func (tsf TableStatementFactory) CreateTableStatement(quotedTableName, tableName
 	primaryKeyClause := ""
 	partitionClause := ""
 
-	baseEngine := "ReplacingMergeTree"
+	baseEngine := chReplacingMergeTreeEngine
 	pkFields := table.PKFields
 	if tsf.config.Engine != nil && len(tsf.config.Engine.OrderFields) > 0 {
 		orderByClause = "ORDER BY (" + extractStatement(tsf.config.Engine.OrderFields) + ")"
@@ -957,25 +1505,38 @@ func (tsf TableStatementFactory) CreateTableStatement(quotedTableName, tableName
 	}
 	if tsf.config.Engine != nil && len(tsf.config.Engine.PartitionFields) > 0 {
 		partitionClause = "PARTITION BY (" + extractStatement(tsf.config.Engine.PartitionFields) + ")"
+	} else if _, ok := table.Columns[PartitonIdKeyword]; ok {
+		//allows ReplacePartitionStream to replace data with REPLACE PARTITION
+		partitionClause = "PARTITION BY `" + PartitonIdKeyword + "`"
 	} else if table.TimestampColumn != "" {
 		partitionClause = "PARTITION BY toYYYYMM(`" + table.TimestampColumn + "`)"
 	}
 
+	//version column defines which of the rows with the same primary key is kept by ReplacingMergeTree
+	versionColumn := ""
+	if baseEngine == chReplacingMergeTreeEngine && table.VersionColumn != "" && len(pkFields) > 0 {
+		_, hasColumn := table.Columns[table.VersionColumn]
+		//version column can't be nullable
+		if hasColumn && (tsf.config.Engine == nil || !utils.ArrayContains(tsf.config.Engine.NullableFields, table.VersionColumn)) {
+			versionColumn = "`" + table.VersionColumn + "`"
+		}
+	}
+
 	if tsf.config.Cluster != "" {
 		//create engine statement with ReplicatedReplacingMergeTree() engine. We need to replace %s with tableName on creating statement
-		engineStatement = `ENGINE = Replicated` + baseEngine + `('/clickhouse/tables/{shard}/` + tsf.config.Database + `/%s', '{replica}')`
+		replicatedArgs := `'/clickhouse/tables/{shard}/` + tsf.config.Database + `/%s', '{replica}'`
+		if versionColumn != "" {
+			replicatedArgs += ", " + versionColumn
+		}
+		engineStatement = `ENGINE = ` + chReplicatedEnginePrefix + baseEngine + `(` + replicatedArgs + `)`
 		engineStatementFormat = true
 	} else {
 		//create table template with ReplacingMergeTree() engine
-		engineStatement = `ENGINE = ` + baseEngine + `()`
+		engineStatement = `ENGINE = ` + baseEngine + `(` + versionColumn + `)`
 	}
 
 	if engineStatementFormat {
-		//clear table path from non-letter symbols
-		keeperPath := strings.ToLower(tableName)
-		keeperPath = nonLettersCharacters.ReplaceAllString(keeperPath, "_")
-		keeperPath = fmt.Sprintf("%s_%x", keeperPath, utils.HashString(tableName))
-		engineStatement = fmt.Sprintf(engineStatement, keeperPath)
+		engineStatement = fmt.Sprintf(engineStatement, chKeeperPath(tableName))
 	}
 	return fmt.Sprintf(chCreateTableTemplate, quotedTableName, tsf.onClusterClause, columnsClause, engineStatement,
 		partitionClause, orderByClause, primaryKeyClause)
@@ -0,0 +1,119 @@
+package sql
+
+import (
+	"encoding/json"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	"github.com/jitsucom/bulker/bulkerlib/types"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestChBatchValue(t *testing.T) {
+	reqr := require.New(t)
+	ts := time.Date(2023, 3, 5, 10, 20, 30, 0, time.UTC)
+	tests := []struct {
+		name     string
+		value    any
+		sqlType  string
+		nullable bool
+		expected any
+		err      bool
+	}{
+		{name: "int64_from_number", value: json.Number("42"), sqlType: "Int64", expected: int64(42)},
+		{name: "int64_from_float", value: 42.0, sqlType: "Int64", expected: int64(42)},
+		{name: "int64_from_fraction", value: 42.5, sqlType: "Int64", err: true},
+		{name: "int32", value: json.Number("7"), sqlType: "Int32", expected: int32(7)},
+		{name: "int64_default", value: nil, sqlType: "Int64", expected: int64(0)},
+		{name: "int64_nullable", value: nil, sqlType: "Int64", nullable: true, expected: nil},
+		{name: "nullable_type", value: nil, sqlType: "Nullable(String)", expected: nil},
+		{name: "low_cardinality", value: "a", sqlType: "LowCardinality(Nullable(String))", expected: "a"},
+		{name: "uint8_bool", value: true, sqlType: "UInt8", expected: true},
+		{name: "uint8_default", value: nil, sqlType: "UInt8", expected: false},
+		{name: "uint64_negative", value: json.Number("-1"), sqlType: "UInt64", err: true},
+		{name: "float64_from_int", value: json.Number("3"), sqlType: "Float64", expected: float64(3)},
+		{name: "string_from_number", value: json.Number("3"), sqlType: "String", expected: "3"},
+		{name: "string_from_bool", value: false, sqlType: "String", expected: "false"},
+		{name: "string_from_object", value: map[string]any{"a": "b"}, sqlType: "String", expected: `{"a":"b"}`},
+		{name: "datetime", value: "2023-03-05T10:20:30Z", sqlType: "DateTime64(6)", expected: ts},
+		{name: "datetime_default", value: nil, sqlType: "DateTime64(6)", expected: time.Unix(0, 0).UTC()},
+		{name: "datetime_invalid", value: true, sqlType: "DateTime", err: true},
+		{name: "map_from_json", value: `{"a":"b","n":1,"o":{"c":true}}`, sqlType: "Map(String, String)", expected: map[string]string{"a": "b", "n": "1", "o": `{"c":true}`}},
+		{name: "map_default", value: nil, sqlType: "Map(String, String)", expected: map[string]string{}},
+		{name: "map_invalid", value: `[1,2]`, sqlType: "Map(String, String)", err: true},
+		{name: "json_from_string", value: `{"a":"b"}`, sqlType: "JSON", expected: map[string]any{"a": "b"}},
+		{name: "json_default", value: nil, sqlType: "Object('json')", expected: map[string]any{}},
+	}
+	for _, tt := range tests {
+		actual, err := chBatchValue(tt.value, tt.sqlType, tt.nullable)
+		if tt.err {
+			reqr.Error(err, tt.name)
+			continue
+		}
+		reqr.NoError(err, tt.name)
+		reqr.Equal(tt.expected, actual, tt.name)
+	}
+}
+
+func TestChCreateTableStatement(t *testing.T) {
+	reqr := require.New(t)
+	table := &Table{
+		Name:            "events",
+		Columns:         Columns{"id": {Type: "String"}, "_timestamp": {Type: "DateTime64(6)"}},
+		PKFields:        utils.NewSet("id"),
+		TimestampColumn: "_timestamp",
+	}
+	tsf, err := NewTableStatementFactory(&ClickHouseConfig{Database: "db"})
+	reqr.NoError(err)
+	reqr.Contains(tsf.CreateTableStatement("`events`", "events", "", table), "ENGINE = ReplacingMergeTree() PARTITION BY toYYYYMM(`_timestamp`)")
+
+	table.VersionColumn = "_timestamp"
+	reqr.Contains(tsf.CreateTableStatement("`events`", "events", "", table), "ENGINE = ReplacingMergeTree(`_timestamp`)")
+
+	clusterTsf, err := NewTableStatementFactory(&ClickHouseConfig{Database: "db", Cluster: "cl"})
+	reqr.NoError(err)
+	reqr.Contains(clusterTsf.CreateTableStatement("`events`", "events", "", table), "ENGINE = ReplicatedReplacingMergeTree('/clickhouse/tables/{shard}/db/"+chKeeperPath("events")+"', '{replica}', `_timestamp`)")
+
+	nullableTsf, err := NewTableStatementFactory(&ClickHouseConfig{Database: "db", Engine: &EngineConfig{NullableFields: []string{"_timestamp"}}})
+	reqr.NoError(err)
+	reqr.Contains(nullableTsf.CreateTableStatement("`events`", "events", "", table), "ENGINE = ReplacingMergeTree()")
+
+	table.Columns[PartitonIdKeyword] = types.SQLColumn{Type: "String"}
+	reqr.Contains(tsf.CreateTableStatement("`events`", "events", "", table), "PARTITION BY `"+PartitonIdKeyword+"`")
+}
+
+func TestChStringLiteral(t *testing.T) {
+	reqr := require.New(t)
+	reqr.Equal(`'sync_1'`, chStringLiteral("sync_1"))
+	reqr.Equal(`'it\'s \\ id'`, chStringLiteral(`it's \ id`))
+}
+
+func TestChEngineOption(t *testing.T) {
+	reqr := require.New(t)
+	so := bulker.StreamOptions{}
+	opt, err := bulker.ParseOption(ClickHouseEngineOption.Key, map[string]any{
+		"orderFields":    []any{map[string]any{"field": "id"}},
+		"nullableFields": []any{"name"},
+		"nestedType":     "Map(String, String)",
+	})
+	reqr.NoError(err)
+	so.Add(opt)
+	engine := ClickHouseEngineOption.Get(&so)
+	reqr.Equal([]FieldConfig{{Field: "id"}}, engine.OrderFields)
+	reqr.Equal("Map(String, String)", engine.NestedType)
+
+	_, err = bulker.ParseOption(ClickHouseEngineOption.Key, `{"nestedType": "Array(String)"}`)
+	reqr.Error(err)
+
+	config := &ClickHouseConfig{Database: "db", Engine: &EngineConfig{OrderFields: []FieldConfig{{Field: "ts"}}}}
+	tsf, err := NewTableStatementFactory(config)
+	reqr.NoError(err)
+	ch := &ClickHouse{SQLAdapterBase: &SQLAdapterBase[ClickHouseConfig]{config: config}, tableStatementFactory: tsf}
+	streamAdapter := ch.withEngine(engine)
+	table := &Table{Name: "events", Columns: Columns{"id": {Type: "String"}, "name": {Type: "String"}}}
+	reqr.Contains(ch.tableStatementFactory.CreateTableStatement("`events`", "events", "", table), "ORDER BY (ts)")
+	reqr.Contains(streamAdapter.tableStatementFactory.CreateTableStatement("`events`", "events", "", table), "ORDER BY (id)")
+	reqr.Equal([]string{"name"}, streamAdapter.nullableFields)
+	reqr.Equal([]FieldConfig{{Field: "ts"}}, config.Engine.OrderFields)
+}
@@ -24,6 +24,24 @@ type DatePartition struct {
 	Granularity Granularity
 }
 
+// DatePartitionFromId parses partition id in format: GRANULARITY/2006-01-02T15:04:05Z
+// returns false if partition id isn't date partition id
+func DatePartitionFromId(partitionId string) (*DatePartition, bool) {
+	match := BigQueryPartitonIdRegex.FindStringSubmatch(partitionId)
+	if match == nil || match[0] != partitionId {
+		return nil, false
+	}
+	granularity, err := ParseGranularity(match[1])
+	if err != nil || granularity == ALL {
+		return nil, false
+	}
+	value, err := time.Parse(time.RFC3339, match[2])
+	if err != nil {
+		return nil, false
+	}
+	return &DatePartition{Value: value, Granularity: granularity}, true
+}
+
 // WhenConditions is a dto for multiple WhenCondition instances with Joiner
 type WhenConditions struct {
 	Conditions    []WhenCondition
@@ -0,0 +1,543 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	types2 "github.com/jitsucom/bulker/bulkerlib/types"
+	"github.com/jitsucom/bulker/jitsubase/errorj"
+	"github.com/jitsucom/bulker/jitsubase/logging"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	"github.com/marcboeker/go-duckdb"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+func init() {
+	bulker.RegisterBulker(DuckDBBulkerTypeId, NewDuckDB)
+}
+
+const (
+	DuckDBBulkerTypeId = "duckdb"
+
+	duckDBDefaultSchema = "main"
+
+	duckDBTableSchemaQuery      = `SELECT column_name, data_type FROM information_schema.columns WHERE table_schema = $1 AND table_name = $2`
+	duckDBPrimaryKeyFieldsQuery = `SELECT unnest(constraint_column_names) FROM duckdb_constraints() WHERE schema_name = $1 AND table_name = $2 AND constraint_type = 'PRIMARY KEY'`
+
+	duckDBCreateSchemaIfNotExistsTemplate = `CREATE SCHEMA IF NOT EXISTS "%s"`
+	duckDBSetSchemaTemplate               = `SET schema = '%s'`
+	duckDBLoadJSONExtension               = `LOAD json`
+	duckDBCreateTableWithPKTemplate       = `CREATE %s TABLE %s (%s, PRIMARY KEY (%s))`
+
+	duckDBLoadJSONTemplate = `INSERT INTO %s(%s) SELECT %s FROM read_json('%s', format='newline_delimited', compression='%s', columns={%s})`
+	duckDBLoadCSVTemplate  = `COPY %s(%s) FROM '%s' (FORMAT CSV, HEADER TRUE, NULL '\N', COMPRESSION '%s')`
+
+	//DuckDB requires explicit conflict target and doesn't allow updating primary key columns. So these templates are completed for each table
+	duckDBMergeQuery     = `INSERT INTO {{.TableName}}({{.Columns}}) VALUES ({{.Placeholders}}) ON CONFLICT (%s) DO %s`
+	duckDBBulkMergeQuery = `INSERT INTO {{.TableTo}}({{.Columns}}) SELECT {{.Columns}} FROM {{.TableFrom}} ON CONFLICT (%s) DO %s`
+)
+
+var (
+	// DuckDB database can be opened only once per process. All bulker instances that use the same database share sql.DB
+	duckDBInstancesMu sync.Mutex
+	duckDBInstances   = map[string]*duckDBInstance{}
+
+	duckDBTypes = map[types2.DataType][]string{
+		types2.STRING:    {"VARCHAR", "TEXT", "STRING"},
+		types2.INT64:     {"BIGINT", "INTEGER", "SMALLINT", "TINYINT", "HUGEINT"},
+		types2.FLOAT64:   {"DOUBLE", "FLOAT", "REAL", "DECIMAL"},
+		types2.TIMESTAMP: {"TIMESTAMP", "TIMESTAMP WITH TIME ZONE", "DATETIME"},
+		types2.BOOL:      {"BOOLEAN"},
+		types2.JSON:      {"VARCHAR"},
+		types2.UNKNOWN:   {"VARCHAR"},
+	}
+)
+
+// DuckDBConfig dto for deserialized DuckDB config
+type DuckDBConfig struct {
+	// Path to database file. Empty value means in-memory database. Use `md:<database>` to connect to MotherDuck
+	Path   string `mapstructure:"path,omitempty" json:"path,omitempty" yaml:"path,omitempty"`
+	Schema string `mapstructure:"defaultSchema,omitempty" json:"defaultSchema,omitempty" yaml:"defaultSchema,omitempty"`
+	// MotherDuckToken is required when Path starts with `md:`
+	MotherDuckToken string `mapstructure:"motherduckToken,omitempty" json:"motherduckToken,omitempty" yaml:"motherduckToken,omitempty"`
+	// Parameters DuckDB configuration options e.g. threads, memory_limit, access_mode
+	Parameters map[string]string `mapstructure:"parameters,omitempty" json:"parameters,omitempty" yaml:"parameters,omitempty"`
+}
+
+// Validate required fields in DuckDBConfig
+func (dc *DuckDBConfig) Validate() error {
+	if dc == nil {
+		return errors.New("DuckDB config is required")
+	}
+	if strings.HasPrefix(dc.Path, "md:") && dc.MotherDuckToken == "" {
+		return errors.New("motherduckToken is required parameter for MotherDuck database")
+	}
+	if dc.Schema == "" {
+		dc.Schema = duckDBDefaultSchema
+	}
+	if dc.Parameters == nil {
+		dc.Parameters = map[string]string{}
+	}
+	return nil
+}
+
+type duckDBInstance struct {
+	dataSource *sql.DB
+	leases     int
+}
+
+// leaseDuckDB returns shared sql.DB for provided key. Opens new one if there is no open instance yet
+func leaseDuckDB(key string, open func() (*sql.DB, error)) (*sql.DB, error) {
+	duckDBInstancesMu.Lock()
+	defer duckDBInstancesMu.Unlock()
+	instance, ok := duckDBInstances[key]
+	if !ok {
+		dataSource, err := open()
+		if err != nil {
+			return nil, err
+		}
+		instance = &duckDBInstance{dataSource: dataSource}
+		duckDBInstances[key] = instance
+	}
+	instance.leases++
+	return instance.dataSource, nil
+}
+
+// releaseDuckDB closes shared sql.DB when no bulker instances use it anymore
+func releaseDuckDB(key string) error {
+	duckDBInstancesMu.Lock()
+	defer duckDBInstancesMu.Unlock()
+	instance, ok := duckDBInstances[key]
+	if !ok {
+		return nil
+	}
+	instance.leases--
+	if instance.leases > 0 {
+		return nil
+	}
+	delete(duckDBInstances, key)
+	return instance.dataSource.Close()
+}
+
+// DuckDB is adapter for creating, patching (schema or table), inserting data to DuckDB database file or MotherDuck
+type DuckDB struct {
+	*SQLAdapterBase[DuckDBConfig]
+	// instanceKey key of shared sql.DB in duckDBInstances
+	instanceKey string
+	// jsonExtension whether 'json' extension is available. Without it batch files are loaded as CSV
+	jsonExtension bool
+}
+
+// NewDuckDB returns configured DuckDB adapter instance
+func NewDuckDB(bulkerConfig bulker.Config) (bulker.Bulker, error) {
+	config := &DuckDBConfig{}
+	if err := utils.ParseObject(bulkerConfig.DestinationConfig, config); err != nil {
+		return nil, fmt.Errorf("failed to parse destination config: %v", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.MotherDuckToken != "" {
+		utils.MapPutIfAbsent(config.Parameters, "motherduck_token", config.MotherDuckToken)
+	}
+
+	dsn := config.Path
+	if len(config.Parameters) > 0 {
+		params := url.Values{}
+		for k, v := range config.Parameters {
+			params.Set(k, v)
+		}
+		dsn += "?" + params.Encode()
+	}
+	instanceKey := dsn + "#" + config.Schema
+	dbConnectFunction := func(cfg *DuckDBConfig) (*sql.DB, error) {
+		return leaseDuckDB(instanceKey, func() (*sql.DB, error) {
+			logging.Infof("[%s] opening: %s", bulkerConfig.Id, cfg.Path)
+			connector, err := duckdb.NewConnector(dsn, func(execer driver.ExecerContext) error {
+				//each connection has its own search path
+				for _, query := range []string{fmt.Sprintf(duckDBCreateSchemaIfNotExistsTemplate, cfg.Schema), fmt.Sprintf(duckDBSetSchemaTemplate, cfg.Schema)} {
+					if _, err := execer.ExecContext(context.Background(), query, nil); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			dataSource := sql.OpenDB(connector)
+			if err := dataSource.Ping(); err != nil {
+				_ = dataSource.Close()
+				return nil, err
+			}
+			dataSource.SetConnMaxIdleTime(3 * time.Minute)
+			dataSource.SetMaxIdleConns(10)
+			return dataSource, nil
+		})
+	}
+	typecastFunc := func(placeholder string, column types2.SQLColumn) string {
+		if column.Override {
+			return placeholder + "::" + column.Type
+		}
+		return placeholder
+	}
+	var queryLogger *logging.QueryLogger
+	if bulkerConfig.LogLevel == bulker.Verbose {
+		queryLogger = logging.NewQueryLogger(bulkerConfig.Id, os.Stderr, os.Stderr)
+	}
+	sqlAdapterBase, err := newSQLAdapterBase(bulkerConfig.Id, DuckDBBulkerTypeId, config, dbConnectFunction, duckDBTypes, queryLogger, typecastFunc, IndexParameterPlaceholder, duckDBColumnDDL, unmappedValue, checkErr)
+	d := &DuckDB{SQLAdapterBase: sqlAdapterBase, instanceKey: instanceKey}
+	d.batchFileFormat = types2.FileFormatCSV
+	if err == nil {
+		d.initJSONExtension()
+	}
+	d.tableHelper = NewTableHelper(bulkerConfig.Id, 63, '"')
+	return d, err
+}
+
+// initJSONExtension loads 'json' extension that allows loading NDJSON batch files natively and provides JSON type
+func (d *DuckDB) initJSONExtension() {
+	if _, err := d.dataSource.Exec(duckDBLoadJSONExtension); err != nil {
+		d.Infof("'json' extension is not available. Batch files will be loaded as CSV: %v", err)
+		return
+	}
+	d.jsonExtension = true
+	d.batchFileFormat = types2.FileFormatNDJSON
+	d.typesMapping[types2.JSON] = "JSON"
+}
+
+// Ping checks connection to DuckDB. Shared sql.DB is never reopened here
+func (d *DuckDB) Ping(ctx context.Context) error {
+	if d.dataSource == nil {
+		return d.SQLAdapterBase.Ping(ctx)
+	}
+	return d.dataSource.PingContext(ctx)
+}
+
+// Close releases shared sql.DB
+func (d *DuckDB) Close() error {
+	d.tableHelper.Close()
+	if d.dataSource != nil {
+		return releaseDuckDB(d.instanceKey)
+	}
+	return nil
+}
+
+func (d *DuckDB) CreateStream(id, tableName string, mode bulker.BulkMode, streamOptions ...bulker.StreamOption) (bulker.BulkerStream, error) {
+	streamOptions = append(streamOptions, withLocalBatchFile(fmt.Sprintf("bulker_%s", utils.SanitizeString(id))))
+	if err := d.validateOptions(streamOptions); err != nil {
+		return nil, err
+	}
+	switch mode {
+	case bulker.Stream:
+		return newAutoCommitStream(id, d, tableName, streamOptions...)
+	case bulker.Batch:
+		return newTransactionalStream(id, d, tableName, streamOptions...)
+	case bulker.ReplaceTable:
+		return newReplaceTableStream(id, d, tableName, streamOptions...)
+	case bulker.ReplacePartition:
+		return newReplacePartitionStream(id, d, tableName, streamOptions...)
+	}
+	return nil, fmt.Errorf("unsupported bulk mode: %s", mode)
+}
+
+func (d *DuckDB) validateOptions(streamOptions []bulker.StreamOption) error {
+	options := &bulker.StreamOptions{}
+	for _, option := range streamOptions {
+		options.Add(option)
+	}
+	return nil
+}
+
+// OpenTx opens underline sql transaction and return wrapped instance
+func (d *DuckDB) OpenTx(ctx context.Context) (*TxSQLAdapter, error) {
+	return d.openTx(ctx, d)
+}
+
+// InitDatabase creates database schema instance if doesn't exist
+func (d *DuckDB) InitDatabase(ctx context.Context) error {
+	query := fmt.Sprintf(duckDBCreateSchemaIfNotExistsTemplate, d.config.Schema)
+
+	if _, err := d.txOrDb(ctx).ExecContext(ctx, query); err != nil {
+		return errorj.CreateSchemaError.Wrap(err, "failed to create db schema").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Schema:    d.config.Schema,
+				Statement: query,
+			})
+	}
+
+	return nil
+}
+
+// GetTableSchema returns table (name,columns with name and types) representation wrapped in Table struct
+func (d *DuckDB) GetTableSchema(ctx context.Context, tableName string) (*Table, error) {
+	table, err := d.getTable(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	//don't select primary keys of non-existent table
+	if len(table.Columns) == 0 {
+		return table, nil
+	}
+
+	pkFields, err := d.getPrimaryKeys(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	table.PKFields = pkFields
+	if len(pkFields) > 0 {
+		//DuckDB doesn't keep names of primary key constraints
+		table.PrimaryKeyName = BuildConstraintName(table.Name)
+	}
+	return table, nil
+}
+
+func (d *DuckDB) getTable(ctx context.Context, tableName string) (*Table, error) {
+	tableName = d.TableName(tableName)
+	table := &Table{Name: tableName, Columns: Columns{}, PKFields: utils.NewSet[string]()}
+	rows, err := d.txOrDb(ctx).QueryContext(ctx, duckDBTableSchemaQuery, d.config.Schema, tableName)
+	if err != nil {
+		return nil, errorj.GetTableError.Wrap(err, "failed to get table columns").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Schema:      d.config.Schema,
+				Table:       tableName,
+				PrimaryKeys: table.GetPKFields(),
+				Statement:   duckDBTableSchemaQuery,
+				Values:      []any{d.config.Schema, tableName},
+			})
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var columnName, columnType string
+		if err := rows.Scan(&columnName, &columnType); err != nil {
+			return nil, errorj.GetTableError.Wrap(err, "failed to scan result").
+				WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+					Schema:      d.config.Schema,
+					Table:       tableName,
+					PrimaryKeys: table.GetPKFields(),
+					Statement:   duckDBTableSchemaQuery,
+					Values:      []any{d.config.Schema, tableName},
+				})
+		}
+		dt, _ := d.GetDataType(columnType)
+		table.Columns[columnName] = types2.SQLColumn{Type: columnType, DataType: dt}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errorj.GetTableError.Wrap(err, "failed read last row").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Schema:      d.config.Schema,
+				Table:       tableName,
+				PrimaryKeys: table.GetPKFields(),
+				Statement:   duckDBTableSchemaQuery,
+				Values:      []any{d.config.Schema, tableName},
+			})
+	}
+
+	return table, nil
+}
+
+func (d *DuckDB) getPrimaryKeys(ctx context.Context, tableName string) (utils.Set[string], error) {
+	tableName = d.TableName(tableName)
+	pkFieldsRows, err := d.txOrDb(ctx).QueryContext(ctx, duckDBPrimaryKeyFieldsQuery, d.config.Schema, tableName)
+	if err != nil {
+		return nil, errorj.GetPrimaryKeysError.Wrap(err, "failed to get primary key").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Schema:    d.config.Schema,
+				Table:     tableName,
+				Statement: duckDBPrimaryKeyFieldsQuery,
+				Values:    []any{d.config.Schema, tableName},
+			})
+	}
+
+	defer pkFieldsRows.Close()
+
+	pkFields := utils.NewSet[string]()
+	for pkFieldsRows.Next() {
+		var fieldName string
+		if err := pkFieldsRows.Scan(&fieldName); err != nil {
+			return nil, errorj.GetPrimaryKeysError.Wrap(err, "failed to scan result").
+				WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+					Schema:    d.config.Schema,
+					Table:     tableName,
+					Statement: duckDBPrimaryKeyFieldsQuery,
+					Values:    []any{d.config.Schema, tableName},
+				})
+		}
+		pkFields.Put(fieldName)
+	}
+	if err := pkFieldsRows.Err(); err != nil {
+		return nil, errorj.GetPrimaryKeysError.Wrap(err, "failed read last row").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Schema:    d.config.Schema,
+				Table:     tableName,
+				Statement: duckDBPrimaryKeyFieldsQuery,
+				Values:    []any{d.config.Schema, tableName},
+			})
+	}
+
+	return pkFields, nil
+}
+
+// CreateTable creates table with columns and primary key.
+// DuckDB doesn't support adding primary key to existing table so it is created inline
+func (d *DuckDB) CreateTable(ctx context.Context, schemaToCreate *Table) error {
+	if len(schemaToCreate.PKFields) == 0 {
+		return d.SQLAdapterBase.CreateTable(ctx, schemaToCreate)
+	}
+	quotedTableName := d.quotedTableName(schemaToCreate.Name)
+
+	columns := schemaToCreate.SortedColumnNames()
+	columnsDDL := make([]string, len(columns))
+	for i, columnName := range columns {
+		columnsDDL[i] = d.columnDDL(columnName, schemaToCreate)
+	}
+	pkFields := schemaToCreate.GetPKFields()
+	quotedPKFields := make([]string, len(pkFields))
+	for i, pkField := range pkFields {
+		quotedPKFields[i] = d.quotedColumnName(pkField)
+	}
+
+	query := fmt.Sprintf(duckDBCreateTableWithPKTemplate, "", quotedTableName, strings.Join(columnsDDL, ", "), strings.Join(quotedPKFields, ", "))
+
+	if _, err := d.txOrDb(ctx).ExecContext(ctx, query); err != nil {
+		return errorj.CreateTableError.Wrap(err, "failed to create table").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Table:       quotedTableName,
+				PrimaryKeys: pkFields,
+				Statement:   query,
+			})
+	}
+
+	return nil
+}
+
+// PatchTableSchema adds new columns to the table.
+// DuckDB doesn't support altering primary key of existing table
+func (d *DuckDB) PatchTableSchema(ctx context.Context, patchTable *Table) error {
+	if patchTable.DeletePkFields || len(patchTable.PKFields) > 0 {
+		return errorj.PatchTableError.Wrap(errors.New("DuckDB doesn't support changing primary key of existing table"), "failed to patch table").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Table:       d.quotedTableName(patchTable.Name),
+				PrimaryKeys: patchTable.GetPKFields(),
+			})
+	}
+	return d.SQLAdapterBase.PatchTableSchema(ctx, patchTable)
+}
+
+func (d *DuckDB) Insert(ctx context.Context, table *Table, merge bool, objects ...types2.Object) error {
+	if !merge || len(table.PKFields) == 0 {
+		return d.insert(ctx, table, objects)
+	}
+	mergeQueryTemplate, err := d.mergeQueryTemplate(duckDBMergeQuery, table, table)
+	if err != nil {
+		return errorj.ExecuteInsertError.Wrap(err, "failed to build query from template")
+	}
+	return d.insertOrMerge(ctx, table, objects, mergeQueryTemplate)
+}
+
+func (d *DuckDB) CopyTables(ctx context.Context, targetTable *Table, sourceTable *Table, merge bool) error {
+	if !merge || len(targetTable.PKFields) == 0 {
+		return d.copy(ctx, targetTable, sourceTable)
+	}
+	mergeQueryTemplate, err := d.mergeQueryTemplate(duckDBBulkMergeQuery, targetTable, sourceTable)
+	if err != nil {
+		return errorj.BulkMergeError.Wrap(err, "failed to build query from template")
+	}
+	return d.copyOrMerge(ctx, targetTable, sourceTable, mergeQueryTemplate, "")
+}
+
+// mergeQueryTemplate completes merge query with conflict target (primary key columns) and update of the rest of columns
+func (d *DuckDB) mergeQueryTemplate(query string, targetTable *Table, sourceTable *Table) (*template.Template, error) {
+	pkFields := targetTable.GetPKFields()
+	conflictColumns := make([]string, len(pkFields))
+	for i, pkField := range pkFields {
+		conflictColumns[i] = d.quotedColumnName(pkField)
+	}
+	var updateColumns []string
+	for _, name := range sourceTable.SortedColumnNames() {
+		if !targetTable.PKFields.Contains(name) {
+			quotedName := d.quotedColumnName(name)
+			updateColumns = append(updateColumns, fmt.Sprintf("%s=excluded.%s", quotedName, quotedName))
+		}
+	}
+	action := "NOTHING"
+	if len(updateColumns) > 0 {
+		action = "UPDATE SET " + strings.Join(updateColumns, ", ")
+	}
+	return template.New("duckdbMergeQuery").Parse(fmt.Sprintf(query, strings.Join(conflictColumns, ", "), action))
+}
+
+// LoadTable loads local batch file with DuckDB native readers: read_json for NDJSON or COPY for CSV
+func (d *DuckDB) LoadTable(ctx context.Context, targetTable *Table, loadSource *LoadSource) (err error) {
+	quotedTableName := d.quotedTableName(targetTable.Name)
+	if loadSource.Type != LocalFile {
+		return fmt.Errorf("LoadTable: only local file is supported")
+	}
+	if loadSource.Format != d.batchFileFormat {
+		return fmt.Errorf("LoadTable: only %s format is supported", d.batchFileFormat)
+	}
+	columns := targetTable.SortedColumnNames()
+	columnNames := make([]string, len(columns))
+	jsonColumns := make([]string, len(columns))
+	for i, name := range columns {
+		columnNames[i] = d.quotedColumnName(name)
+		jsonColumns[i] = fmt.Sprintf("'%s': '%s'", escapeSingleQuotes(name), targetTable.Columns[name].GetDDLType())
+	}
+	filePath := escapeSingleQuotes(loadSource.Path)
+	compression, err := duckDBCompression(loadSource.Compression)
+	if err != nil {
+		return err
+	}
+	var loadStatement string
+	if d.batchFileFormat == types2.FileFormatNDJSON {
+		loadStatement = fmt.Sprintf(duckDBLoadJSONTemplate, quotedTableName, strings.Join(columnNames, ", "), strings.Join(columnNames, ", "), filePath, compression, strings.Join(jsonColumns, ", "))
+	} else {
+		loadStatement = fmt.Sprintf(duckDBLoadCSVTemplate, quotedTableName, strings.Join(columnNames, ", "), filePath, compression)
+	}
+	if _, err := d.txOrDb(ctx).ExecContext(ctx, loadStatement); err != nil {
+		return errorj.LoadError.Wrap(err, "failed to load table").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Schema:      d.config.Schema,
+				Table:       quotedTableName,
+				PrimaryKeys: targetTable.GetPKFields(),
+				Statement:   loadStatement,
+			})
+	}
+	return nil
+}
+
+// duckDBCompression returns compression parameter of DuckDB file readers for batch file compression
+func duckDBCompression(compression types2.FileCompression) (string, error) {
+	switch compression {
+	case types2.FileCompressionGZIP:
+		return "gzip", nil
+	case types2.FileCompressionZSTD:
+		return "zstd", nil
+	case types2.FileCompressionNONE, types2.FileCompressionUNKNOWN:
+		return "none", nil
+	default:
+		return "", fmt.Errorf("LoadTable: compression %s is not supported", compression)
+	}
+}
+
+// duckDBColumnDDL returns column DDL (quoted column name, mapped sql type)
+func duckDBColumnDDL(quotedName, name string, table *Table) string {
+	column := table.Columns[name]
+	return fmt.Sprintf(`%s %s`, quotedName, column.GetDDLType())
+}
+
+func escapeSingleQuotes(value string) string {
+	return strings.ReplaceAll(value, "'", "''")
+}
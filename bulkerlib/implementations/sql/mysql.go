@@ -4,10 +4,12 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/go-sql-driver/mysql"
-	_ "github.com/go-sql-driver/mysql"
 	bulker "github.com/jitsucom/bulker/bulkerlib"
 	types2 "github.com/jitsucom/bulker/bulkerlib/types"
 	"github.com/jitsucom/bulker/jitsubase/errorj"
@@ -15,8 +17,11 @@ import (
 	"github.com/jitsucom/bulker/jitsubase/timestamp"
 	"github.com/jitsucom/bulker/jitsubase/utils"
 	jsoniter "github.com/json-iterator/go"
+	"io"
 	"os"
+	"path"
 	"strings"
+	"sync/atomic"
 	"text/template"
 	"time"
 )
@@ -40,7 +45,7 @@ const (
 	mySQLCreateDBIfNotExistsTemplate = "CREATE DATABASE IF NOT EXISTS %s"
 	mySQLAllowLocalFile              = "SET GLOBAL local_infile = 1"
 	mySQLIndexTemplate               = `CREATE INDEX %s ON %s (%s);`
-	mySQLLoadTemplate                = `LOAD DATA LOCAL INFILE '%s' INTO TABLE %s FIELDS TERMINATED BY ',' ENCLOSED BY '"' LINES TERMINATED BY '\n' IGNORE 1 LINES (%s)`
+	mySQLLoadTemplate                = `LOAD DATA LOCAL INFILE 'Reader::%s' INTO TABLE %s FIELDS TERMINATED BY ',' ENCLOSED BY '"' LINES TERMINATED BY '\n' IGNORE 1 LINES (%s)`
 	mySQLMergeQuery                  = `INSERT INTO {{.TableName}}({{.Columns}}) VALUES ({{.Placeholders}}) ON DUPLICATE KEY UPDATE {{.UpdateSet}}`
 	mySQLBulkMergeQuery              = "INSERT INTO {{.TableTo}}({{.Columns}}) SELECT * FROM (SELECT {{.Columns}} FROM {{.TableFrom}}) AS S ON DUPLICATE KEY UPDATE {{.UpdateSet}}"
 )
@@ -63,17 +68,31 @@ var (
 	mySQLPrimaryKeyTypesMapping = map[string]string{
 		"text": "varchar(255)",
 	}
+
+	//mySQLLocalInfileDisabledErrors errors returned when LOAD DATA LOCAL INFILE is disabled on server side:
+	//ER_NOT_ALLOWED_COMMAND and ER_CLIENT_LOCAL_FILES_DISABLED
+	mySQLLocalInfileDisabledErrors = []uint16{1148, 3948}
 )
 
+// MySQLConfig dto for deserialized MySQL destination config
+type MySQLConfig struct {
+	DataSourceConfig `mapstructure:",squash"`
+	SSLConfig        `mapstructure:",squash"`
+	// LoadDataLocalInfile loads batch files with LOAD DATA LOCAL INFILE statement. Requires local_infile enabled on server.
+	// Batch files are loaded with prepared insert statements if server doesn't allow it
+	LoadDataLocalInfile bool `mapstructure:"loadDataLocalInfile,omitempty" json:"loadDataLocalInfile,omitempty" yaml:"loadDataLocalInfile,omitempty"`
+}
+
 // MySQL is adapter for creating, patching (schema or table), inserting data to mySQL database
 type MySQL struct {
-	*SQLAdapterBase[DataSourceConfig]
-	infileEnabled bool
+	*SQLAdapterBase[MySQLConfig]
+	infileEnabled atomic.Bool
+	tlsConfigName string
 }
 
 // NewMySQL returns configured MySQL adapter instance
 func NewMySQL(bulkerConfig bulker.Config) (bulker.Bulker, error) {
-	config := &DataSourceConfig{}
+	config := &MySQLConfig{}
 	if err := utils.ParseObject(bulkerConfig.DestinationConfig, config); err != nil {
 		return nil, fmt.Errorf("failed to parse destination config: %v", err)
 	}
@@ -81,14 +100,22 @@ func NewMySQL(bulkerConfig bulker.Config) (bulker.Bulker, error) {
 	if config.Parameters == nil {
 		config.Parameters = map[string]string{}
 	}
+	tlsConfigName := "bulker_" + utils.SanitizeString(bulkerConfig.Id)
+	registered, err := mySQLProcessSSL(tlsConfigName, config)
+	if err != nil {
+		return nil, err
+	}
+	if !registered {
+		tlsConfigName = ""
+	}
 	utils.MapPutIfAbsent(config.Parameters, "tls", "preferred")
 
 	utils.MapPutIfAbsent(config.Parameters, "timeout", "60s")
 	utils.MapPutIfAbsent(config.Parameters, "writeTimeout", "60s")
 	utils.MapPutIfAbsent(config.Parameters, "readTimeout", "60s")
 
-	dbConnectFunction := func(cfg *DataSourceConfig) (*sql.DB, error) {
-		connectionString := mySQLDriverConnectionString(config)
+	dbConnectFunction := func(cfg *MySQLConfig) (*sql.DB, error) {
+		connectionString := mySQLDriverConnectionString(&config.DataSourceConfig)
 		dataSource, err := sql.Open("mysql", connectionString)
 		if err != nil {
 			return nil, err
@@ -125,19 +152,15 @@ func NewMySQL(bulkerConfig bulker.Config) (bulker.Bulker, error) {
 	if bulkerConfig.LogLevel == bulker.Verbose {
 		queryLogger = logging.NewQueryLogger(bulkerConfig.Id, os.Stderr, os.Stderr)
 	}
-	// disable infile support for convenience
-	infileEnabled := false
 	sqlAdapterBase, err := newSQLAdapterBase(bulkerConfig.Id, MySQLBulkerTypeId, config, dbConnectFunction, mysqlTypes, queryLogger, typecastFunc, QuestionMarkParameterPlaceholder, mySQLColumnDDL, mySQLMapColumnValue, checkErr)
 	m := &MySQL{
 		SQLAdapterBase: sqlAdapterBase,
-		infileEnabled:  infileEnabled,
+		tlsConfigName:  tlsConfigName,
 	}
-	if infileEnabled {
-		m.batchFileFormat = types2.FileFormatCSV
-	} else {
-		m.batchFileFormat = types2.FileFormatNDJSON
-	}
-	m.tableHelper = NewTableHelper(63, '`')
+	//batch file is converted to csv on the fly for LOAD DATA LOCAL INFILE. So prepared statements remain available as fallback
+	m.infileEnabled.Store(config.LoadDataLocalInfile)
+	m.batchFileFormat = types2.FileFormatNDJSON
+	m.tableHelper = NewTableHelper(bulkerConfig.Id, 63, '`')
 	return m, err
 }
 
@@ -210,99 +233,211 @@ func (m *MySQL) LoadTable(ctx context.Context, targetTable *Table, loadSource *L
 		return fmt.Errorf("LoadTable: only local file is supported")
 	}
 	if loadSource.Format != m.batchFileFormat {
-		mode := "LOCAL INFILE"
-		if !m.infileEnabled {
-			mode = "prepared statement"
-		}
-		return fmt.Errorf("LoadTable: only %s format is supported in %s mode", m.batchFileFormat, mode)
+		return fmt.Errorf("LoadTable: only %s format is supported", m.batchFileFormat)
 	}
-	if m.infileEnabled {
-		mysql.RegisterLocalFile(loadSource.Path)
-		defer mysql.DeregisterLocalFile(loadSource.Path)
-
-		columns := targetTable.SortedColumnNames()
-		header := make([]string, len(columns))
-		for i, name := range columns {
-			header[i] = m.quotedColumnName(name)
+	if m.infileEnabled.Load() {
+		err = m.loadLocalInfile(ctx, targetTable, loadSource)
+		if !mySQLLocalInfileDisabled(err) {
+			return err
 		}
-		loadStatement := fmt.Sprintf(mySQLLoadTemplate, loadSource.Path, quotedTableName, strings.Join(header, ", "))
-		if _, err := m.txOrDb(ctx).ExecContext(ctx, loadStatement); err != nil {
-			return errorj.LoadError.Wrap(err, "failed to load data from local file system").
+		m.infileEnabled.Store(false)
+		m.Warnf("Loading tables with LOAD DATA LOCAL INFILE is disabled on server: %v. Bulk loading will fallback to insert statements. To enable it set local_infile=1 in server config", err)
+	}
+	columns := targetTable.SortedColumnNames()
+	columnNames := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	for i, name := range columns {
+		columnNames[i] = m.quotedColumnName(name)
+		placeholders[i] = m.typecastFunc(m.parameterPlaceholder(i+1, name), targetTable.Columns[name])
+	}
+	insertPayload := QueryPayload{
+		TableName:      quotedTableName,
+		Columns:        strings.Join(columnNames, ", "),
+		Placeholders:   strings.Join(placeholders, ", "),
+		PrimaryKeyName: targetTable.PrimaryKeyName,
+	}
+	buf := strings.Builder{}
+	err = insertQueryTemplate.Execute(&buf, insertPayload)
+	if err != nil {
+		return errorj.ExecuteInsertError.Wrap(err, "failed to build query from template")
+	}
+	statement := buf.String()
+	defer func() {
+		if err != nil {
+			err = errorj.LoadError.Wrap(err, "failed to load table").
 				WithProperty(errorj.DBInfo, &types2.ErrorPayload{
-					Database:  m.config.Db,
+					Schema:    m.config.Schema,
 					Table:     quotedTableName,
-					Statement: loadStatement,
+					Statement: statement,
 				})
 		}
-		return nil
-	} else {
-		columns := targetTable.SortedColumnNames()
-		columnNames := make([]string, len(columns))
-		placeholders := make([]string, len(columns))
-		for i, name := range columns {
-			columnNames[i] = m.quotedColumnName(name)
-			placeholders[i] = m.typecastFunc(m.parameterPlaceholder(i+1, name), targetTable.Columns[name])
+	}()
+
+	stmt, err := m.txOrDb(ctx).PrepareContext(ctx, statement)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+	//f, err := os.ReadFile(loadSource.Path)
+	//m.Infof("FILE: %s", f)
+
+	file, err := os.Open(loadSource.Path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	reader, err := types2.NewCompressionReader(loadSource.Compression, file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 1024*100), 1024*1024*10)
+	for scanner.Scan() {
+		object := map[string]any{}
+		decoder := jsoniter.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		decoder.UseNumber()
+		err = decoder.Decode(&object)
+		if err != nil {
+			return err
 		}
-		insertPayload := QueryPayload{
-			TableName:      quotedTableName,
-			Columns:        strings.Join(columnNames, ", "),
-			Placeholders:   strings.Join(placeholders, ", "),
-			PrimaryKeyName: targetTable.PrimaryKeyName,
+		args := make([]any, len(columns))
+		for i, v := range columns {
+			l := types2.ReformatValue(object[v])
+			args[i] = l
 		}
-		buf := strings.Builder{}
-		err := insertQueryTemplate.Execute(&buf, insertPayload)
-		if err != nil {
-			return errorj.ExecuteInsertError.Wrap(err, "failed to build query from template")
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return checkErr(err)
 		}
-		statement := buf.String()
-		defer func() {
-			if err != nil {
-				err = errorj.LoadError.Wrap(err, "failed to load table").
-					WithProperty(errorj.DBInfo, &types2.ErrorPayload{
-						Schema:    m.config.Schema,
-						Table:     quotedTableName,
-						Statement: statement,
-					})
-			}
-		}()
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("LoadTable: failed to read file: %v", err)
+	}
+	return nil
+}
 
-		stmt, err := m.txOrDb(ctx).PrepareContext(ctx, statement)
-		if err != nil {
+// loadLocalInfile loads NDJSON batch file with LOAD DATA LOCAL INFILE statement.
+// File is converted to CSV on the fly and passed to the driver with reader handler
+func (m *MySQL) loadLocalInfile(ctx context.Context, targetTable *Table, loadSource *LoadSource) (err error) {
+	quotedTableName := m.quotedTableName(targetTable.Name)
+	file, err := os.Open(loadSource.Path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	reader, err := types2.NewCompressionReader(loadSource.Compression, file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	columns := targetTable.SortedColumnNames()
+	header := make([]string, len(columns))
+	for i, name := range columns {
+		header[i] = m.quotedColumnName(name)
+	}
+	pipeReader, pipeWriter := io.Pipe()
+	converted := make(chan error, 1)
+	go func() {
+		err := mySQLWriteCSV(reader, pipeWriter, columns)
+		_ = pipeWriter.CloseWithError(err)
+		converted <- err
+	}()
+	handlerName := fmt.Sprintf("bulker_%s", utils.SanitizeString(loadSource.Path))
+	mysql.RegisterReaderHandler(handlerName, func() io.Reader {
+		return pipeReader
+	})
+	defer mysql.DeregisterReaderHandler(handlerName)
+
+	loadStatement := fmt.Sprintf(mySQLLoadTemplate, handlerName, quotedTableName, strings.Join(header, ", "))
+	_, err = m.txOrDb(ctx).ExecContext(ctx, loadStatement)
+	//unblock conversion if driver stopped reading
+	_ = pipeReader.CloseWithError(io.ErrClosedPipe)
+	if convertErr := <-converted; err == nil && convertErr != nil {
+		err = fmt.Errorf("failed to convert batch file to csv: %v", convertErr)
+	}
+	if err != nil {
+		if mySQLLocalInfileDisabled(err) {
+			//keep driver error as is so caller can fall back to insert statements
 			return err
 		}
-		defer func() {
-			_ = stmt.Close()
-		}()
-		//f, err := os.ReadFile(loadSource.Path)
-		//m.Infof("FILE: %s", f)
+		return errorj.LoadError.Wrap(err, "failed to load data from local file system").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Database:  m.config.Db,
+				Table:     quotedTableName,
+				Statement: loadStatement,
+			})
+	}
+	return nil
+}
 
-		file, err := os.Open(loadSource.Path)
-		if err != nil {
+// mySQLLocalInfileDisabled checks if driver error means that LOAD DATA LOCAL INFILE is disabled on server
+func mySQLLocalInfileDisabled(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && utils.ArrayContains(mySQLLocalInfileDisabledErrors, mysqlErr.Number)
+}
+
+// mySQLWriteCSV converts NDJSON to CSV suitable for LOAD DATA statement with default escape character: '\\'
+func mySQLWriteCSV(reader io.Reader, writer io.Writer, columns []string) error {
+	marshaller, err := types2.NewMarshaller(types2.FileFormatCSV, types2.FileCompressionNONE)
+	if err != nil {
+		return err
+	}
+	if err = marshaller.Init(writer, columns); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 1024*100), 1024*1024*10)
+	for scanner.Scan() {
+		object := map[string]any{}
+		decoder := jsoniter.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		decoder.UseNumber()
+		if err = decoder.Decode(&object); err != nil {
 			return err
 		}
-		scanner := bufio.NewScanner(file)
-		scanner.Buffer(make([]byte, 1024*100), 1024*1024*10)
-		for scanner.Scan() {
-			object := map[string]any{}
-			decoder := jsoniter.NewDecoder(bytes.NewReader(scanner.Bytes()))
-			decoder.UseNumber()
-			err = decoder.Decode(&object)
+		row := make(types2.Object, len(columns))
+		for _, column := range columns {
+			row[column], err = mySQLCSVValue(types2.ReformatValue(object[column]))
 			if err != nil {
 				return err
 			}
-			args := make([]any, len(columns))
-			for i, v := range columns {
-				l := types2.ReformatValue(object[v])
-				args[i] = l
-			}
-			if _, err := stmt.ExecContext(ctx, args...); err != nil {
-				return checkErr(err)
-			}
 		}
-		if err = scanner.Err(); err != nil {
-			return fmt.Errorf("LoadTable: failed to read file: %v", err)
+		if err = marshaller.Marshal(row); err != nil {
+			return err
 		}
-		return nil
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	return marshaller.Flush()
+}
+
+// mySQLCSVValue formats timestamps the way MySQL parses them
+// and escapes backslashes in strings because LOAD DATA treats them as escape characters
+func mySQLCSVValue(value any) (any, error) {
+	switch v := value.(type) {
+	case nil, bool, int64, float64:
+		return v, nil
+	case string:
+		return strings.ReplaceAll(v, `\`, `\\`), nil
+	case time.Time:
+		return v.UTC().Format("2006-01-02 15:04:05.999999"), nil
+	default:
+		b, err := jsoniter.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return strings.ReplaceAll(string(b), `\`, `\\`), nil
 	}
 }
 
@@ -528,3 +663,108 @@ func (m *MySQL) createIndex(ctx context.Context, table *Table) error {
 
 	return nil
 }
+
+// Close underlying sql.DB
+func (m *MySQL) Close() error {
+	if m.tlsConfigName != "" {
+		mysql.DeregisterTLSConfig(m.tlsConfigName)
+	}
+	return m.SQLAdapterBase.Close()
+}
+
+// mySQLProcessSSL sets 'tls' connection parameter according to SSL config.
+// For 'verify-ca' and 'verify-full' modes registers custom TLS config with tlsConfigName name in the driver.
+// Returns true if TLS config was registered
+func mySQLProcessSSL(tlsConfigName string, config *MySQLConfig) (bool, error) {
+	switch config.SSLMode {
+	case SSLModeNotProvided:
+		//default driver tls mode
+		return false, nil
+	case SSLModeDisable:
+		config.Parameters["tls"] = "false"
+		return false, nil
+	case SSLModeRequire:
+		//encrypted connection without server certificate verification
+		config.Parameters["tls"] = "skip-verify"
+		return false, nil
+	case SSLModeVerifyCA, SSLModeVerifyFull:
+		tlsConfig, err := mySQLTLSConfig(config)
+		if err != nil {
+			return false, err
+		}
+		if err = mysql.RegisterTLSConfig(tlsConfigName, tlsConfig); err != nil {
+			return false, err
+		}
+		config.Parameters["tls"] = tlsConfigName
+		return true, nil
+	default:
+		return false, fmt.Errorf("unsupported ssl mode: %s", config.SSLMode)
+	}
+}
+
+// mySQLTLSConfig returns TLS config that verifies server certificate with sslServerCA
+// and the host name for 'verify-full' mode. Client certificate is optional
+func mySQLTLSConfig(config *MySQLConfig) (*tls.Config, error) {
+	if config.SSLServerCA == "" {
+		return nil, fmt.Errorf("'sslServerCA' is required parameter for sslMode '%s'", config.SSLMode)
+	}
+	if (config.SSLClientCert == "") != (config.SSLClientKey == "") {
+		return nil, fmt.Errorf("'sslClientCert' and 'sslClientKey' must be provided together")
+	}
+	serverCA, err := readSSLPayload(config.SSLServerCA)
+	if err != nil {
+		return nil, fmt.Errorf("error reading sslServerCA: %v", err)
+	}
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(serverCA) {
+		return nil, fmt.Errorf("failed to parse sslServerCA: no PEM certificates found")
+	}
+	tlsConfig := &tls.Config{RootCAs: rootCAs, ServerName: config.Host}
+	if config.SSLClientCert != "" {
+		clientCert, err := readSSLPayload(config.SSLClientCert)
+		if err != nil {
+			return nil, fmt.Errorf("error reading sslClientCert: %v", err)
+		}
+		clientKey, err := readSSLPayload(config.SSLClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("error reading sslClientKey: %v", err)
+		}
+		certificate, err := tls.X509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	if config.SSLMode == SSLModeVerifyCA {
+		//verify certificate chain only. Standard verification also checks host name
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return fmt.Errorf("server didn't provide certificate")
+			}
+			certs := make([]*x509.Certificate, len(rawCerts))
+			for i, raw := range rawCerts {
+				cert, err := x509.ParseCertificate(raw)
+				if err != nil {
+					return err
+				}
+				certs[i] = cert
+			}
+			intermediates := x509.NewCertPool()
+			for _, cert := range certs[1:] {
+				intermediates.AddCert(cert)
+			}
+			_, err := certs[0].Verify(x509.VerifyOptions{Roots: rootCAs, Intermediates: intermediates})
+			return err
+		}
+	}
+	return tlsConfig, nil
+}
+
+// readSSLPayload returns content of file if payload is absolute file path. Otherwise, returns payload itself
+func readSSLPayload(payload string) ([]byte, error) {
+	if path.IsAbs(payload) {
+		return os.ReadFile(payload)
+	}
+	return []byte(payload), nil
+}
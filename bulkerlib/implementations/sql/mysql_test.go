@@ -0,0 +1,108 @@
+package sql
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	types2 "github.com/jitsucom/bulker/bulkerlib/types"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestMySQLWriteCSV(t *testing.T) {
+	reqr := require.New(t)
+	ndjson := `{"id":1,"name":"a,b","path":"c:\\dir","flag":true,"ts":"2023-01-02T03:04:05.123Z","obj":{"k":"v\\"}}
+{"id":2,"name":"say \"hi\"","score":1.5}
+`
+	buf := &bytes.Buffer{}
+	reqr.NoError(mySQLWriteCSV(strings.NewReader(ndjson), buf, []string{"id", "name", "path", "flag", "ts", "obj", "score"}))
+	reqr.Equal(`id,name,path,flag,ts,obj,score
+1,"a,b",c:\\dir,1,2023-01-02 03:04:05.123,"{""k"":""v\\\\""}",\N
+2,"say ""hi""",\N,\N,\N,\N,1.5
+`, buf.String())
+}
+
+func TestMySQLProcessSSL(t *testing.T) {
+	reqr := require.New(t)
+	tests := []struct {
+		name          string
+		ssl           SSLConfig
+		expectedTLS   string
+		expectedError string
+	}{
+		{name: "not_provided"},
+		{name: "disable", ssl: SSLConfig{SSLMode: SSLModeDisable}, expectedTLS: "false"},
+		{name: "require", ssl: SSLConfig{SSLMode: SSLModeRequire}, expectedTLS: "skip-verify"},
+		{name: "verify_ca_no_ca", ssl: SSLConfig{SSLMode: SSLModeVerifyCA}, expectedError: "'sslServerCA' is required"},
+		{name: "verify_full_cert_without_key", ssl: SSLConfig{SSLMode: SSLModeVerifyFull, SSLServerCA: "ca", SSLClientCert: "cert"}, expectedError: "must be provided together"},
+		{name: "verify_full_bad_ca", ssl: SSLConfig{SSLMode: SSLModeVerifyFull, SSLServerCA: "ca"}, expectedError: "failed to parse sslServerCA"},
+		{name: "unsupported", ssl: SSLConfig{SSLMode: "allow"}, expectedError: "unsupported ssl mode"},
+	}
+	for _, tt := range tests {
+		config := &MySQLConfig{DataSourceConfig: DataSourceConfig{Parameters: map[string]string{}}, SSLConfig: tt.ssl}
+		registered, err := mySQLProcessSSL("bulker_test", config)
+		if tt.expectedError != "" {
+			reqr.ErrorContains(err, tt.expectedError, tt.name)
+			continue
+		}
+		reqr.NoError(err, tt.name)
+		reqr.False(registered, tt.name)
+		tls, ok := config.Parameters["tls"]
+		reqr.Equal(tt.expectedTLS != "", ok, tt.name)
+		reqr.Equal(tt.expectedTLS, tls, tt.name)
+	}
+}
+
+func TestMySQLLocalInfileDisabled(t *testing.T) {
+	reqr := require.New(t)
+	reqr.True(mySQLLocalInfileDisabled(&mysql.MySQLError{Number: 3948}))
+	reqr.True(mySQLLocalInfileDisabled(fmt.Errorf("load: %w", &mysql.MySQLError{Number: 1148})))
+	reqr.False(mySQLLocalInfileDisabled(&mysql.MySQLError{Number: 1146}))
+	reqr.False(mySQLLocalInfileDisabled(errors.New("Error 3948")))
+	reqr.False(mySQLLocalInfileDisabled(nil))
+}
+
+// TestMySQLLocalInfileFallback loads batch into server with local_infile disabled
+func TestMySQLLocalInfileFallback(t *testing.T) {
+	configId := MySQLBulkerTypeId + "_no_infile"
+	if !utils.ArrayContains(allBulkerConfigs, configId) {
+		t.Skipf("Config '%s' is not selected for this test", configId)
+	}
+	reqr := require.New(t)
+	testConfig := configRegistry[configId].(TestConfig)
+	blk, err := bulker.CreateBulker(bulker.Config{Id: configId, BulkerType: testConfig.BulkerType, DestinationConfig: testConfig.Config, LogLevel: bulker.Verbose})
+	reqr.NoError(err)
+	defer func() {
+		_ = blk.Close()
+	}()
+	m, ok := blk.(*MySQL)
+	reqr.True(ok)
+	reqr.True(m.infileEnabled.Load())
+
+	ctx := context.Background()
+	tableName := "local_infile_fallback"
+	reqr.NoError(m.InitDatabase(ctx))
+	reqr.NoError(m.DropTable(ctx, tableName, true))
+	defer func() {
+		_ = m.DropTable(ctx, tableName, true)
+	}()
+	stream, err := blk.CreateStream(configId+"_"+tableName, tableName, bulker.Batch)
+	reqr.NoError(err)
+	for i := 1; i <= 3; i++ {
+		_, _, err = stream.Consume(ctx, types2.Object{"id": i, "name": fmt.Sprintf("test%d", i)})
+		reqr.NoError(err)
+	}
+	state, err := stream.Complete(ctx)
+	reqr.NoError(err)
+	reqr.Equal(3, state.SuccessfulRows)
+	reqr.False(m.infileEnabled.Load(), "LOAD DATA LOCAL INFILE must be disabled after server rejected it")
+
+	rows, err := m.Select(ctx, tableName, nil, []string{"id"})
+	reqr.NoError(err)
+	reqr.Len(rows, 3)
+}
@@ -21,7 +21,7 @@ func TestNaming(t *testing.T) {
 			expectedTable: ExpectedTable{
 				Columns: justColumns("id", "name", "column_12b241e808ae6c964a5bb9f1c012e63d", "1test_name", "2", "column_c16da609b86c01f16a2c609eac4ccb0c", "Test Name", "Test Name DROP DATABASE public SELECT 1 from DUAL", "Université Français", "_timestamp", "_unnamed", "lorem_ipsum_dolor_sit_amet_consectetur_adipiscing_elit_sed_do_e", "Странное Имя", "秒速センチメートル", "camelCase", "int", "user", "select", "__ROOT__", "hash"),
 			},
-			configIds: utils.ArrayExcluding(allBulkerConfigs, RedshiftBulkerTypeId+"_serverless", RedshiftBulkerTypeId, SnowflakeBulkerTypeId, BigqueryBulkerTypeId),
+			configIds: utils.ArrayExcluding(allBulkerConfigs, RedshiftBulkerTypeId+"_serverless", RedshiftBulkerTypeId, SnowflakeBulkerTypeId, BigqueryBulkerTypeId, MSSQLBulkerTypeId),
 		},
 		{
 			name:              "naming_test1_case_redshift",
@@ -40,6 +40,20 @@ func TestNaming(t *testing.T) {
 			},
 			configIds: []string{RedshiftBulkerTypeId + "_serverless", RedshiftBulkerTypeId},
 		},
+		{
+			name:                      "naming_test1_mssql",
+			tableName:                 "Strange Table Name; DROP DATABASE public;",
+			modes:                     []bulker.BulkMode{bulker.Batch, bulker.Stream, bulker.ReplaceTable, bulker.ReplacePartition},
+			dataFile:                  "test_data/identifiers.ndjson",
+			expectPartitionId:         true,
+			expectedTableCaseChecking: true,
+			expectedRowsCount:         1,
+			expectedTable: ExpectedTable{
+				// SQL Server identifiers are limited by 128 characters
+				Columns: justColumns("id", "name", "column_12b241e808ae6c964a5bb9f1c012e63d", "1test_name", "2", "column_c16da609b86c01f16a2c609eac4ccb0c", "Test Name", "Test Name DROP DATABASE public SELECT 1 from DUAL", "Université Français", "_timestamp", "_unnamed", "lorem_ipsum_dolor_sit_amet_consectetur_adipiscing_elit_sed_do_eiusmod_tempor_incididunt_ut_labore_et_dolore_magna_aliqua_ut_enim", "Странное Имя", "秒速センチメートル", "camelCase", "int", "user", "select", "__ROOT__", "hash"),
+			},
+			configIds: []string{MSSQLBulkerTypeId},
+		},
 		{
 			name:                      "naming_test1_case_snowflake",
 			tableName:                 "Strange Table Name; DROP DATABASE public;",
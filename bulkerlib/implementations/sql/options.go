@@ -5,6 +5,7 @@ import (
 	bulker "github.com/jitsucom/bulker/bulkerlib"
 	"github.com/jitsucom/bulker/bulkerlib/types"
 	"github.com/jitsucom/bulker/jitsubase/utils"
+	"strings"
 )
 
 var (
@@ -36,13 +37,83 @@ var (
 		},
 	}
 
+	// PartitionGranularityOption granularity of native date partitions of destination table by timestampColumn.
+	// Supported by Postgres and BigQuery. Partitions are created only when timestampColumn option is set
+	PartitionGranularityOption = bulker.ImplementationOption[Granularity]{
+		Key: "partitionGranularity",
+		ParseFunc: func(serialized any) (Granularity, error) {
+			switch v := serialized.(type) {
+			case string:
+				return ParseGranularity(strings.ToUpper(v))
+			case Granularity:
+				return v, nil
+			default:
+				return "", fmt.Errorf("invalid value type of partitionGranularity option: %T", v)
+			}
+		},
+	}
+
+	// JsonbColumnsOption Postgres only. Nested objects and arrays that are kept in jsonb columns instead of being flattened.
+	// Elements are names of nested objects in flattened form, e.g. "context_page" for {"context": {"page": {...}}}.
+	// "*" keeps all nested objects and arrays of the top level in jsonb columns
+	JsonbColumnsOption = bulker.ImplementationOption[[]string]{
+		Key: "jsonbColumns",
+		AdvancedParseFunc: func(o *bulker.ImplementationOption[[]string], serializedValue any) (bulker.StreamOption, error) {
+			switch v := serializedValue.(type) {
+			case []string:
+				return withJsonColumns(o, v...), nil
+			case []any:
+				columns := make([]string, len(v))
+				for i, c := range v {
+					s, ok := c.(string)
+					if !ok {
+						return nil, fmt.Errorf("failed to parse 'jsonbColumns' option: %v incorrect element type: %T expected string", c, c)
+					}
+					columns[i] = s
+				}
+				return withJsonColumns(o, columns...), nil
+			case string:
+				if v == "" {
+					return func(options *bulker.StreamOptions) {}, nil
+				}
+				return withJsonColumns(o, strings.Split(v, ",")...), nil
+			default:
+				return nil, fmt.Errorf("failed to parse 'jsonbColumns' option: %v incorrect type: %T expected string or []string", v, v)
+			}
+		},
+	}
+
+	// ClickHouseEngineOption ClickHouse only. Engine settings of tables created by the stream. Overrides 'engine' of destination config
+	ClickHouseEngineOption = bulker.ImplementationOption[*EngineConfig]{
+		Key: "clickhouseEngine",
+		ParseFunc: func(serialized any) (*EngineConfig, error) {
+			engine := &EngineConfig{}
+			if err := utils.ParseObject(serialized, engine); err != nil {
+				return nil, fmt.Errorf("failed to parse 'clickhouseEngine' option: %v", err)
+			}
+			if err := engine.Validate(); err != nil {
+				return nil, fmt.Errorf("failed to parse 'clickhouseEngine' option: %v", err)
+			}
+			return engine, nil
+		},
+	}
+
 	localBatchFileOption = bulker.ImplementationOption[string]{Key: "BULKER_OPTION_LOCAL_BATCH_FILE"}
 
+	// jsonColumnsOption nested objects that are kept in columns of JSON type. Set by adapters that support it
+	jsonColumnsOption = bulker.ImplementationOption[[]string]{Key: "BULKER_OPTION_JSON_COLUMNS"}
+	// jsonColumnTypeOption sql type of columns of jsonColumnsOption if it differs from the type mapped to types.JSON.
+	// Such type holds nested objects only, arrays keep default type
+	jsonColumnTypeOption = bulker.ImplementationOption[string]{Key: "BULKER_OPTION_JSON_COLUMN_TYPE"}
+
 	s3BatchFileOption = bulker.ImplementationOption[*S3OptionConfig]{Key: "BULKER_OPTION_S3_BATCH_FILE"}
 )
 
 func init() {
 	bulker.RegisterOption(&ColumnTypesOption)
+	bulker.RegisterOption(&PartitionGranularityOption)
+	bulker.RegisterOption(&JsonbColumnsOption)
+	bulker.RegisterOption(&ClickHouseEngineOption)
 }
 
 type S3OptionConfig struct {
@@ -91,6 +162,37 @@ func WithColumnTypeDDL(columnName, sqlType, ddlType string) bulker.StreamOption
 	return withColumnTypes(&ColumnTypesOption, types.SQLTypes{}.WithDDL(columnName, sqlType, ddlType))
 }
 
+// WithPartitionGranularity sets granularity of native date partitions of destination table. Requires WithTimestamp option
+func WithPartitionGranularity(granularity Granularity) bulker.StreamOption {
+	return func(options *bulker.StreamOptions) {
+		PartitionGranularityOption.Set(options, granularity)
+	}
+}
+
+func withJsonColumns(o *bulker.ImplementationOption[[]string], columns ...string) bulker.StreamOption {
+	return func(options *bulker.StreamOptions) {
+		o.Set(options, columns)
+	}
+}
+
+// WithJsonbColumns keeps provided nested objects in jsonb columns instead of flattening them. See JsonbColumnsOption
+func WithJsonbColumns(columns ...string) bulker.StreamOption {
+	return withJsonColumns(&JsonbColumnsOption, columns...)
+}
+
+// WithClickHouseEngine sets engine settings of tables created by the stream. See ClickHouseEngineOption
+func WithClickHouseEngine(engine *EngineConfig) bulker.StreamOption {
+	return func(options *bulker.StreamOptions) {
+		ClickHouseEngineOption.Set(options, engine)
+	}
+}
+
+func withJsonColumnType(sqlType string) bulker.StreamOption {
+	return func(options *bulker.StreamOptions) {
+		jsonColumnTypeOption.Set(options, sqlType)
+	}
+}
+
 // WithLocalBatchFile setting for all modes except bulker.Stream
 // Not every database solution supports this option
 // fileName - name of tmp file that will be used to collection event batches before sending them to destination
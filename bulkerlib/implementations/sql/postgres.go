@@ -2,21 +2,26 @@ package sql
 
 import (
 	"bufio"
-	"bytes"
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/csv"
+	"errors"
 	"fmt"
 	bulker "github.com/jitsucom/bulker/bulkerlib"
 	types2 "github.com/jitsucom/bulker/bulkerlib/types"
 	"github.com/jitsucom/bulker/jitsubase/errorj"
 	"github.com/jitsucom/bulker/jitsubase/logging"
 	"github.com/jitsucom/bulker/jitsubase/utils"
-	jsoniter "github.com/json-iterator/go"
+	"io"
 	"os"
 	"path"
+	"sort"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
+	"unicode/utf8"
 
 	_ "github.com/Kount/pq-timeouts"
 )
@@ -36,7 +41,7 @@ const (
          					LEFT JOIN pg_attrdef pg_attrdef ON pg_attrdef.adrelid = pg_class.oid AND pg_attrdef.adnum = pg_attribute.attnum
          					LEFT JOIN pg_namespace ON pg_namespace.oid = pg_class.relnamespace
          					LEFT JOIN pg_constraint ON pg_constraint.conrelid = pg_class.oid AND pg_attribute.attnum = ANY (pg_constraint.conkey)
-						WHERE pg_class.relkind = 'r'::char
+						WHERE pg_class.relkind IN ('r'::char, 'p'::char)
   							AND  pg_namespace.nspname = $1
   							AND pg_class.relname = $2
   							AND pg_attribute.attnum > 0`
@@ -50,12 +55,40 @@ FROM information_schema.table_constraints tco
 WHERE tco.constraint_type = 'PRIMARY KEY' AND 
       kcu.table_schema = $1 AND
       kcu.table_name = $2`
+	pgPartitionKeyQuery = `SELECT pg_attribute.attname, coalesce(obj_description(pg_class.oid, 'pg_class'), '')
+						FROM pg_partitioned_table
+							JOIN pg_class ON pg_class.oid = pg_partitioned_table.partrelid
+							JOIN pg_namespace ON pg_namespace.oid = pg_class.relnamespace
+							JOIN pg_attribute ON pg_attribute.attrelid = pg_class.oid AND pg_attribute.attnum = pg_partitioned_table.partattrs[0]
+						WHERE pg_partitioned_table.partstrat = 'r'
+							AND pg_namespace.nspname = $1
+							AND pg_class.relname = $2`
+	// pgPartitionsQuery selects child partitions with lower bound of range as UTC timestamp. Lower bound of default partition is null
+	pgPartitionsQuery = `SELECT child.relname, (regexp_match(pg_get_expr(child.relpartbound, child.oid), 'FROM \(''([^'']+)''\)'))[1]%s
+						FROM pg_inherits
+							JOIN pg_class child ON child.oid = pg_inherits.inhrelid
+							JOIN pg_class parent ON parent.oid = pg_inherits.inhparent
+							JOIN pg_namespace ON pg_namespace.oid = parent.relnamespace
+						WHERE pg_namespace.nspname = $1
+							AND parent.relname = $2`
+	pgPartitionTimesQuery = `SELECT DISTINCT date_trunc('%s', %s) FROM %s WHERE %s IS NOT NULL`
+
 	pgCreateDbSchemaIfNotExistsTemplate = `CREATE SCHEMA IF NOT EXISTS "%s"`
 	pgCreateIndexTemplate               = `CREATE INDEX ON %s (%s);`
+	pgCreateGinIndexTemplate            = `CREATE INDEX ON %s USING GIN (%s);`
+
+	pgCreatePartitionedTableTemplate = `CREATE TABLE %s (%s) PARTITION BY RANGE (%s)`
+	pgCreateDefaultPartitionTemplate = `CREATE TABLE %s PARTITION OF %s DEFAULT`
+	pgCreatePartitionTemplate        = `CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')`
+	pgCommentTableTemplate           = `COMMENT ON TABLE %s IS '%s'`
+	// pgPartitionGranularityComment prefix of partitioned table comment that keeps granularity of partitions
+	pgPartitionGranularityComment = "bulker:partitionGranularity="
 
 	pgMergeQuery = `INSERT INTO {{.TableName}}({{.Columns}}) VALUES ({{.Placeholders}}) ON CONFLICT ON CONSTRAINT {{.PrimaryKeyName}} DO UPDATE set {{.UpdateSet}}`
 
-	pgCopyTemplate = `COPY %s(%s) FROM STDIN`
+	pgCopyTemplate = `COPY %s(%s) FROM STDIN (FORMAT CSV, HEADER TRUE, NULL '\N')`
+	// pgCopyChunkSize size of data chunks sent to COPY FROM STDIN
+	pgCopyChunkSize = 1024 * 1024
 
 	pgBulkMergeQuery       = `INSERT INTO {{.TableTo}}({{.Columns}}) SELECT {{.Columns}} FROM {{.TableFrom}} ON CONFLICT ON CONSTRAINT {{.PrimaryKeyName}} DO UPDATE SET {{.UpdateSet}}`
 	pgBulkMergeSourceAlias = `excluded`
@@ -79,6 +112,8 @@ var (
 type PostgresConfig struct {
 	DataSourceConfig `mapstructure:",squash"`
 	SSLConfig        `mapstructure:",squash"`
+	// JsonbGinIndex create GIN indexes on jsonb columns created by bulker
+	JsonbGinIndex bool `mapstructure:"jsonbGinIndex,omitempty" json:"jsonbGinIndex,omitempty" yaml:"jsonbGinIndex,omitempty"`
 }
 
 // Postgres is adapter for creating,patching (schema or table), inserting data to postgres
@@ -86,6 +121,8 @@ type Postgres struct {
 	*SQLAdapterBase[PostgresConfig]
 	dbConnectFunction DbConnectFunction[PostgresConfig]
 	tmpDir            string
+	// partitionsCache known partitions of tables. Allows to skip partitions lookup on every insert
+	partitionsCache *pgPartitionsCache
 }
 
 // NewPostgres return configured Postgres bulker.Bulker instance
@@ -156,8 +193,9 @@ func NewPostgres(bulkerConfig bulker.Config) (bulker.Bulker, error) {
 		return dataSource, nil
 	}
 	sqlAdapterBase, err := newSQLAdapterBase(bulkerConfig.Id, PostgresBulkerTypeId, config, dbConnectFunction, postgresDataTypes, queryLogger, typecastFunc, IndexParameterPlaceholder, pgColumnDDL, valueMappingFunc, checkErr)
-	p := &Postgres{sqlAdapterBase, dbConnectFunction, tmpDir}
-	p.tableHelper = NewTableHelper(63, '"')
+	p := &Postgres{sqlAdapterBase, dbConnectFunction, tmpDir, newPgPartitionsCache()}
+	p.batchFileFormat = types2.FileFormatCSV
+	p.tableHelper = NewTableHelper(bulkerConfig.Id, 63, '"')
 	return p, err
 }
 
@@ -167,6 +205,13 @@ func (p *Postgres) CreateStream(id, tableName string, mode bulker.BulkMode, stre
 	if err := p.validateOptions(streamOptions); err != nil {
 		return nil, err
 	}
+	options := &bulker.StreamOptions{}
+	for _, option := range streamOptions {
+		options.Add(option)
+	}
+	if jsonbColumns := JsonbColumnsOption.Get(options); len(jsonbColumns) > 0 {
+		streamOptions = append(streamOptions, withJsonColumns(&jsonColumnsOption, jsonbColumns...))
+	}
 	switch mode {
 	case bulker.Stream:
 		return newAutoCommitStream(id, p, tableName, streamOptions...)
@@ -190,7 +235,7 @@ func (p *Postgres) validateOptions(streamOptions []bulker.StreamOption) error {
 
 // OpenTx opens underline sql transaction and return wrapped instance
 func (p *Postgres) OpenTx(ctx context.Context) (*TxSQLAdapter, error) {
-	return p.openTx(ctx, p)
+	return p.openConnTx(ctx, p)
 }
 
 // InitDatabase creates database schema instance if doesn't exist
@@ -281,10 +326,49 @@ func (p *Postgres) getTable(ctx context.Context, tableName string) (*Table, erro
 			})
 	}
 
+	if len(table.Columns) > 0 {
+		if err := p.getPartitionKey(ctx, table); err != nil {
+			return nil, err
+		}
+	}
+
 	return table, nil
 }
 
+// getPartitionKey fills table partition field and granularity if table is partitioned by range
+func (p *Postgres) getPartitionKey(ctx context.Context, table *Table) error {
+	var field, comment string
+	err := p.txOrDb(ctx).QueryRowContext(ctx, pgPartitionKeyQuery, p.config.Schema, table.Name).Scan(&field, &comment)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return errorj.GetTableError.Wrap(err, "failed to get table partition key").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Schema:    p.config.Schema,
+				Table:     table.Name,
+				Statement: pgPartitionKeyQuery,
+				Values:    []any{p.config.Schema, table.Name},
+			})
+	}
+	table.Partition.Field = field
+	if granularity, ok := strings.CutPrefix(comment, pgPartitionGranularityComment); ok {
+		//tables partitioned outside of bulker have no granularity. their partitions are not managed
+		table.Partition.Granularity, _ = ParseGranularity(granularity)
+	}
+	return nil
+}
+
 func (p *Postgres) Insert(ctx context.Context, table *Table, merge bool, objects ...types2.Object) error {
+	if pgPartitioned(table) {
+		bounds := newPgPartitionBounds(table.Partition.Granularity)
+		for _, object := range objects {
+			bounds.add(object[table.Partition.Field])
+		}
+		if err := p.ensurePartitions(ctx, table, bounds); err != nil {
+			return err
+		}
+	}
 	if !merge {
 		return p.insert(ctx, table, objects)
 	} else {
@@ -293,6 +377,15 @@ func (p *Postgres) Insert(ctx context.Context, table *Table, merge bool, objects
 }
 
 func (p *Postgres) CopyTables(ctx context.Context, targetTable *Table, sourceTable *Table, merge bool) error {
+	if pgPartitioned(targetTable) {
+		bounds, err := p.selectPartitionBounds(ctx, targetTable, sourceTable)
+		if err != nil {
+			return err
+		}
+		if err = p.ensurePartitions(ctx, targetTable, bounds); err != nil {
+			return err
+		}
+	}
 	if !merge {
 		return p.copy(ctx, targetTable, sourceTable)
 	} else {
@@ -300,6 +393,7 @@ func (p *Postgres) CopyTables(ctx context.Context, targetTable *Table, sourceTab
 	}
 }
 
+// LoadTable streams local CSV batch file to COPY FROM STDIN without parsing it
 func (p *Postgres) LoadTable(ctx context.Context, targetTable *Table, loadSource *LoadSource) (err error) {
 	quotedTableName := p.quotedTableName(targetTable.Name)
 	if loadSource.Type != LocalFile {
@@ -326,47 +420,92 @@ func (p *Postgres) LoadTable(ctx context.Context, targetTable *Table, loadSource
 		}
 	}()
 
-	stmt, err := p.txOrDb(ctx).PrepareContext(ctx, copyStatement)
+	if pgPartitioned(targetTable) {
+		bounds, err := readPartitionBounds(targetTable, loadSource)
+		if err != nil {
+			return err
+		}
+		if err = p.ensurePartitions(ctx, targetTable, bounds); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.Open(loadSource.Path)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		_ = stmt.Close()
+		_ = file.Close()
 	}()
-	//f, err := os.ReadFile(loadSource.Path)
-	//p.Infof("FILE: %s", f)
-
-	file, err := os.Open(loadSource.Path)
+	reader, err := types2.NewCompressionReader(loadSource.Compression, file)
 	if err != nil {
 		return err
 	}
-	scanner := bufio.NewScanner(file)
-	scanner.Buffer(make([]byte, 1024*100), 1024*1024*10)
-	for scanner.Scan() {
-		object := map[string]any{}
-		decoder := jsoniter.NewDecoder(bytes.NewReader(scanner.Bytes()))
-		decoder.UseNumber()
-		err = decoder.Decode(&object)
+	defer func() {
+		_ = reader.Close()
+	}()
+	txOrDb, ok := p.txOrDb(ctx).(*TxWrapper)
+	if !ok {
+		return fmt.Errorf("LoadTable: unsupported connection type: %T", p.txOrDb(ctx))
+	}
+	//COPY must run on the connection of current transaction. database/sql doesn't support streaming COPY data, so driver connection is used directly
+	return txOrDb.Raw(func(driverConn any) error {
+		conn, ok := driverConn.(driver.ConnPrepareContext)
+		if !ok {
+			return fmt.Errorf("LoadTable: unsupported driver connection: %T", driverConn)
+		}
+		stmt, err := conn.PrepareContext(ctx, copyStatement)
 		if err != nil {
 			return err
 		}
-		args := make([]any, len(columns))
-		for i, v := range columns {
-			l := types2.ReformatValue(object[v])
-			args[i] = l
+		defer func() {
+			_ = stmt.Close()
+		}()
+		copier, ok := stmt.(pgCopier)
+		if !ok {
+			return fmt.Errorf("LoadTable: driver statement doesn't support COPY data: %T", stmt)
 		}
-		if _, err := stmt.ExecContext(ctx, args...); err != nil {
-			return checkErr(err)
+		if err = pgCopyData(ctx, copier, reader); err != nil {
+			return err
+		}
+		//Exec without values completes COPY and returns errors of pending data
+		_, err = stmt.Exec(nil)
+		return err
+	})
+}
+
+// pgCopier is implemented by lib/pq COPY FROM STDIN statement
+type pgCopier interface {
+	CopyData(ctx context.Context, line string) (driver.Result, error)
+}
+
+// pgCopyData sends data to COPY statement in chunks of pgCopyChunkSize.
+// CopyData messages don't need to be aligned with rows, but pq appends new line to each chunk, so chunks are cut at line ends
+func pgCopyData(ctx context.Context, copier pgCopier, reader io.Reader) error {
+	bufReader := bufio.NewReaderSize(reader, pgCopyChunkSize)
+	chunk := make([]byte, 0, 2*pgCopyChunkSize)
+	for {
+		line, err := bufReader.ReadSlice('\n')
+		chunk = append(chunk, line...)
+		if err == io.EOF {
+			break
+		} else if err == bufio.ErrBufferFull {
+			//line is longer than buffer. continue reading the same line
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to read file: %v", err)
+		}
+		if len(chunk) >= pgCopyChunkSize {
+			if _, err = copier.CopyData(ctx, string(chunk[:len(chunk)-1])); err != nil {
+				return err
+			}
+			chunk = chunk[:0]
 		}
 	}
-	if err = scanner.Err(); err != nil {
-		return fmt.Errorf("LoadTable: failed to read file: %v", err)
-	}
-	_, err = stmt.ExecContext(ctx)
-	if err != nil {
-		return checkErr(err)
+	if len(chunk) > 0 {
+		_, err := copier.CopyData(ctx, strings.TrimSuffix(string(chunk), "\n"))
+		return err
 	}
-
 	return nil
 }
 
@@ -451,7 +590,13 @@ func (p *Postgres) getPrimaryKey(ctx context.Context, tableName string) (string,
 }
 
 func (p *Postgres) CreateTable(ctx context.Context, schemaToCreate *Table) error {
-	err := p.SQLAdapterBase.CreateTable(ctx, schemaToCreate)
+	p.partitionsCache.clear(schemaToCreate.Name)
+	var err error
+	if !schemaToCreate.Temporary && pgPartitioned(schemaToCreate) {
+		err = p.createPartitionedTable(ctx, schemaToCreate)
+	} else {
+		err = p.SQLAdapterBase.CreateTable(ctx, schemaToCreate)
+	}
 	if err != nil {
 		return err
 	}
@@ -462,6 +607,60 @@ func (p *Postgres) CreateTable(ctx context.Context, schemaToCreate *Table) error
 			return fmt.Errorf("failed to create sort key: %v", err)
 		}
 	}
+	if !schemaToCreate.Temporary {
+		err = p.createGinIndexes(ctx, schemaToCreate)
+		if err != nil {
+			p.DropTable(ctx, schemaToCreate.Name, true)
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Postgres) DropTable(ctx context.Context, tableName string, ifExists bool) error {
+	p.partitionsCache.clear(tableName)
+	return p.SQLAdapterBase.DropTable(ctx, tableName, ifExists)
+}
+
+func (p *Postgres) Drop(ctx context.Context, table *Table, ifExists bool) error {
+	return p.DropTable(ctx, table.Name, ifExists)
+}
+
+// ReplaceTable replaces target table with replacement table. Partitions of the replacement table become partitions of the target table
+func (p *Postgres) ReplaceTable(ctx context.Context, targetTableName string, replacementTable *Table, dropOldTable bool) error {
+	p.partitionsCache.clear(targetTableName)
+	p.partitionsCache.clear(replacementTable.Name)
+	return p.SQLAdapterBase.ReplaceTable(ctx, targetTableName, replacementTable, dropOldTable)
+}
+
+// PatchTableSchema adds new columns and primary key to the table and creates GIN indexes on new jsonb columns
+func (p *Postgres) PatchTableSchema(ctx context.Context, patchTable *Table) error {
+	if err := p.SQLAdapterBase.PatchTableSchema(ctx, patchTable); err != nil {
+		return err
+	}
+	return p.createGinIndexes(ctx, patchTable)
+}
+
+// createGinIndexes creates GIN indexes on jsonb columns of the table if enabled in config
+func (p *Postgres) createGinIndexes(ctx context.Context, table *Table) error {
+	if !p.config.JsonbGinIndex {
+		return nil
+	}
+	quotedTableName := p.quotedTableName(table.Name)
+	for _, columnName := range table.SortedColumnNames() {
+		if strings.ToLower(table.Columns[columnName].GetDDLType()) != "jsonb" {
+			continue
+		}
+		statement := fmt.Sprintf(pgCreateGinIndexTemplate, quotedTableName, p.quotedColumnName(columnName))
+		if _, err := p.txOrDb(ctx).ExecContext(ctx, statement); err != nil {
+			return errorj.AlterTableError.Wrap(err, "failed to create GIN index").
+				WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+					Table:       quotedTableName,
+					PrimaryKeys: table.GetPKFields(),
+					Statement:   statement,
+				})
+		}
+	}
 	return nil
 }
 
@@ -486,6 +685,408 @@ func (p *Postgres) createIndex(ctx context.Context, table *Table) error {
 	return nil
 }
 
+// createPartitionedTable creates table partitioned by range of Table.Partition field with default partition for null values.
+// Granularity of partitions is stored in table comment
+func (p *Postgres) createPartitionedTable(ctx context.Context, table *Table) error {
+	quotedTableName := p.quotedTableName(table.Name)
+	if len(table.PKFields) > 0 && !table.PKFields.Contains(table.Partition.Field) {
+		return errorj.CreateTableError.Wrap(fmt.Errorf("primary key of partitioned table must include partition column: %s", table.Partition.Field), "failed to create table").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Table:       quotedTableName,
+				PrimaryKeys: table.GetPKFields(),
+			})
+	}
+	columns := table.SortedColumnNames()
+	columnsDDL := make([]string, len(columns))
+	for i, columnName := range columns {
+		columnsDDL[i] = p.columnDDL(columnName, table)
+	}
+	statements := []string{
+		fmt.Sprintf(pgCreatePartitionedTableTemplate, quotedTableName, strings.Join(columnsDDL, ", "), p.quotedColumnName(table.Partition.Field)),
+		fmt.Sprintf(pgCreateDefaultPartitionTemplate, p.quotedTableName(pgPartitionName(table.Name, "default", p.tableHelper.maxIdentifierLength)), quotedTableName),
+		fmt.Sprintf(pgCommentTableTemplate, quotedTableName, pgPartitionGranularityComment+table.Partition.Granularity.String()),
+	}
+	for _, statement := range statements {
+		if _, err := p.txOrDb(ctx).ExecContext(ctx, statement); err != nil {
+			return errorj.CreateTableError.Wrap(err, "failed to create table").
+				WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+					Table:       quotedTableName,
+					PrimaryKeys: table.GetPKFields(),
+					Statement:   statement,
+				})
+		}
+	}
+	return p.createPrimaryKey(ctx, table)
+}
+
+// ensurePartitions creates missing partitions of the table for provided lower bounds.
+// Table partitions are looked up only when some of the bounds are not in partitionsCache
+func (p *Postgres) ensurePartitions(ctx context.Context, table *Table, bounds pgPartitionBounds) error {
+	if len(bounds.bounds) == 0 {
+		return nil
+	}
+	missing := p.partitionsCache.missing(table.Name, bounds.sorted())
+	if len(missing) == 0 {
+		return nil
+	}
+	//partitions read or created in transaction may be rolled back, so they are cached only outside of transaction
+	_, inTx := ctx.Value(ContextTransactionKey).(TxOrDB)
+	partitions, err := p.getPartitions(ctx, table)
+	if err != nil {
+		return err
+	}
+	if !inTx {
+		p.partitionsCache.put(table.Name, partitions)
+	}
+	quotedTableName := p.quotedTableName(table.Name)
+	granularity := table.Partition.Granularity
+	for _, lower := range missing {
+		if _, ok := partitions[lower.Unix()]; ok {
+			continue
+		}
+		upper := granularity.Upper(lower).Add(time.Nanosecond)
+		partitionName := pgPartitionName(table.Name, pgPartitionSuffix(granularity, lower), p.tableHelper.maxIdentifierLength)
+		statement := fmt.Sprintf(pgCreatePartitionTemplate, p.quotedTableName(partitionName), quotedTableName,
+			lower.Format(time.RFC3339), upper.Format(time.RFC3339))
+		if _, err := p.txOrDb(ctx).ExecContext(ctx, statement); err != nil {
+			//partitions may be changed by another instance. reread them next time
+			p.partitionsCache.clear(table.Name)
+			return errorj.CreateTableError.Wrap(err, "failed to create partition").
+				WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+					Table:     quotedTableName,
+					Partition: partitionName,
+					Statement: statement,
+				})
+		}
+		if !inTx {
+			p.partitionsCache.add(table.Name, lower, partitionName)
+		}
+	}
+	return nil
+}
+
+// getPartitions returns names of the table partitions by unix time of partition lower bound.
+// Partitions are looked up by bounds because names of partitions don't change when table is renamed
+func (p *Postgres) getPartitions(ctx context.Context, table *Table) (map[int64]string, error) {
+	boundCast := "::timestamptz AT TIME ZONE 'UTC'"
+	if pgTimestampWithoutTimeZone(table) {
+		boundCast = "::timestamp"
+	}
+	query := fmt.Sprintf(pgPartitionsQuery, boundCast)
+	rows, err := p.txOrDb(ctx).QueryContext(ctx, query, p.config.Schema, table.Name)
+	if err != nil {
+		return nil, errorj.GetTableError.Wrap(err, "failed to get table partitions").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Schema:    p.config.Schema,
+				Table:     table.Name,
+				Statement: query,
+				Values:    []any{p.config.Schema, table.Name},
+			})
+	}
+	defer rows.Close()
+	partitions := map[int64]string{}
+	for rows.Next() {
+		var name string
+		var lower sql.NullTime
+		if err := rows.Scan(&name, &lower); err != nil {
+			return nil, errorj.GetTableError.Wrap(err, "failed to scan result").
+				WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+					Schema:    p.config.Schema,
+					Table:     table.Name,
+					Statement: query,
+					Values:    []any{p.config.Schema, table.Name},
+				})
+		}
+		if lower.Valid {
+			partitions[pgUTCWallTime(lower.Time).Unix()] = name
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errorj.GetTableError.Wrap(err, "failed read last row").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Schema:    p.config.Schema,
+				Table:     table.Name,
+				Statement: query,
+				Values:    []any{p.config.Schema, table.Name},
+			})
+	}
+	return partitions, nil
+}
+
+// selectPartitionBounds selects lower bounds of partitions required for data of sourceTable
+func (p *Postgres) selectPartitionBounds(ctx context.Context, targetTable *Table, sourceTable *Table) (pgPartitionBounds, error) {
+	granularity := targetTable.Partition.Granularity
+	bounds := newPgPartitionBounds(granularity)
+	if _, ok := sourceTable.Columns[targetTable.Partition.Field]; !ok {
+		return bounds, nil
+	}
+	var unit string
+	switch granularity {
+	case HOUR:
+		unit = "hour"
+	case DAY, WEEK:
+		unit = "day"
+	default:
+		unit = "month"
+	}
+	quotedColumn := p.quotedColumnName(targetTable.Partition.Field)
+	utcColumn := quotedColumn
+	if !pgTimestampWithoutTimeZone(targetTable) {
+		utcColumn += " AT TIME ZONE 'UTC'"
+	}
+	query := fmt.Sprintf(pgPartitionTimesQuery, unit, utcColumn, p.quotedTableName(sourceTable.Name), quotedColumn)
+	rows, err := p.txOrDb(ctx).QueryContext(ctx, query)
+	if err != nil {
+		return bounds, errorj.CopyError.Wrap(err, "failed to select partitions of data").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Table:     p.quotedTableName(sourceTable.Name),
+				Statement: query,
+			})
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return bounds, errorj.CopyError.Wrap(err, "failed to scan result").
+				WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+					Table:     p.quotedTableName(sourceTable.Name),
+					Statement: query,
+				})
+		}
+		bounds.add(pgUTCWallTime(t))
+	}
+	if err := rows.Err(); err != nil {
+		return bounds, errorj.CopyError.Wrap(err, "failed read last row").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Table:     p.quotedTableName(sourceTable.Name),
+				Statement: query,
+			})
+	}
+	return bounds, nil
+}
+
+// TruncateDatePartition truncates partitions of the table that cover datePartition interval.
+// Works only for tables partitioned by bulker with granularity that is aligned with datePartition interval
+func (p *Postgres) TruncateDatePartition(ctx context.Context, table *Table, datePartition *DatePartition) (bool, error) {
+	if !pgPartitioned(table) {
+		return false, nil
+	}
+	lowerBounds, ok := pgPartitionLowerBounds(table.Partition.Granularity, datePartition)
+	if !ok {
+		return false, nil
+	}
+	partitions, err := p.getPartitions(ctx, table)
+	if err != nil {
+		return false, err
+	}
+	for _, lower := range lowerBounds {
+		if partitionName, ok := partitions[lower.Unix()]; ok {
+			if err := p.TruncateTable(ctx, partitionName); err != nil {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// pgPartitioned returns true if table is partitioned with partitions managed by bulker
+func pgPartitioned(table *Table) bool {
+	return table.Partition.Field != "" && table.Partition.Granularity != "" && table.Partition.Granularity != ALL
+}
+
+// pgTimestampWithoutTimeZone returns true if partition column of the table is 'timestamp without time zone'.
+// Values of such columns are considered to be UTC
+func pgTimestampWithoutTimeZone(table *Table) bool {
+	sqlType := strings.ToLower(table.Columns[table.Partition.Field].Type)
+	return strings.HasPrefix(sqlType, "timestamp") && sqlType != "timestamptz" && !strings.Contains(sqlType, "with time zone")
+}
+
+// pgUTCWallTime returns time with the same wall clock in UTC.
+// 'timestamp without time zone' values are scanned in arbitrary location
+func pgUTCWallTime(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// pgPartitionLowerBounds returns lower bounds of table partitions that cover datePartition interval.
+// Returns false if interval doesn't consist of whole partitions
+func pgPartitionLowerBounds(granularity Granularity, datePartition *DatePartition) ([]time.Time, bool) {
+	value := datePartition.Value.UTC()
+	start := datePartition.Granularity.Lower(value)
+	end := datePartition.Granularity.Upper(value).Add(time.Nanosecond)
+	if !granularity.Lower(start).Equal(start) {
+		return nil, false
+	}
+	var lowerBounds []time.Time
+	lower := start
+	for lower.Before(end) {
+		lowerBounds = append(lowerBounds, lower)
+		lower = granularity.Upper(lower).Add(time.Nanosecond)
+	}
+	if !lower.Equal(end) {
+		return nil, false
+	}
+	return lowerBounds, true
+}
+
+// pgPartitionSuffix returns suffix of partition name for partition lower bound
+func pgPartitionSuffix(granularity Granularity, lower time.Time) string {
+	switch granularity {
+	case HOUR:
+		return lower.Format("p2006010215")
+	case DAY, WEEK:
+		return lower.Format("p20060102")
+	case MONTH, QUARTER:
+		return lower.Format("p200601")
+	default:
+		return lower.Format("p2006")
+	}
+}
+
+// pgPartitionName returns name of the table partition with provided suffix.
+// Table name is shortened to fit max identifier length. Hash of table name keeps names of partitions unique
+func pgPartitionName(tableName, suffix string, maxIdentifierLength int) string {
+	name := tableName + "_" + suffix
+	if len(name) <= maxIdentifierLength {
+		return name
+	}
+	hash := fmt.Sprintf("%x", utils.HashString(tableName))[:8]
+	maxPrefixLength := maxIdentifierLength - len(hash) - len(suffix) - 2
+	prefix := tableName
+	for len(prefix) > maxPrefixLength {
+		_, size := utf8.DecodeLastRuneInString(prefix)
+		prefix = prefix[:len(prefix)-size]
+	}
+	return prefix + "_" + hash + "_" + suffix
+}
+
+// pgPartitionBounds is a set of lower bounds of partitions required for data
+// pgPartitionsCache keeps names of known partitions of tables by unix time of partition lower bound
+type pgPartitionsCache struct {
+	sync.Mutex
+	tables map[string]map[int64]string
+}
+
+func newPgPartitionsCache() *pgPartitionsCache {
+	return &pgPartitionsCache{tables: map[string]map[int64]string{}}
+}
+
+// missing returns lower bounds that have no known partition in the table
+func (pc *pgPartitionsCache) missing(tableName string, lowerBounds []time.Time) []time.Time {
+	pc.Lock()
+	defer pc.Unlock()
+	partitions := pc.tables[tableName]
+	var missing []time.Time
+	for _, lower := range lowerBounds {
+		if _, ok := partitions[lower.Unix()]; !ok {
+			missing = append(missing, lower)
+		}
+	}
+	return missing
+}
+
+func (pc *pgPartitionsCache) put(tableName string, partitions map[int64]string) {
+	pc.Lock()
+	defer pc.Unlock()
+	cached := make(map[int64]string, len(partitions))
+	for lower, name := range partitions {
+		cached[lower] = name
+	}
+	pc.tables[tableName] = cached
+}
+
+func (pc *pgPartitionsCache) add(tableName string, lower time.Time, partitionName string) {
+	pc.Lock()
+	defer pc.Unlock()
+	partitions, ok := pc.tables[tableName]
+	if !ok {
+		partitions = map[int64]string{}
+		pc.tables[tableName] = partitions
+	}
+	partitions[lower.Unix()] = partitionName
+}
+
+func (pc *pgPartitionsCache) clear(tableName string) {
+	pc.Lock()
+	defer pc.Unlock()
+	delete(pc.tables, tableName)
+}
+
+type pgPartitionBounds struct {
+	granularity Granularity
+	bounds      map[int64]time.Time
+}
+
+func newPgPartitionBounds(granularity Granularity) pgPartitionBounds {
+	return pgPartitionBounds{granularity: granularity, bounds: map[int64]time.Time{}}
+}
+
+// add adds lower bound of partition for value if value is a timestamp
+func (pb pgPartitionBounds) add(value any) {
+	t, ok := types2.ReformatTimeValue(value).(time.Time)
+	if !ok {
+		return
+	}
+	lower := pb.granularity.Lower(t.UTC())
+	pb.bounds[lower.Unix()] = lower
+}
+
+func (pb pgPartitionBounds) sorted() []time.Time {
+	sorted := make([]time.Time, 0, len(pb.bounds))
+	for _, lower := range pb.bounds {
+		sorted = append(sorted, lower)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Before(sorted[j])
+	})
+	return sorted
+}
+
+// readPartitionBounds reads lower bounds of partitions required for data of local CSV batch file
+func readPartitionBounds(table *Table, loadSource *LoadSource) (pgPartitionBounds, error) {
+	bounds := newPgPartitionBounds(table.Partition.Granularity)
+	file, err := os.Open(loadSource.Path)
+	if err != nil {
+		return bounds, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	reader, err := types2.NewCompressionReader(loadSource.Compression, file)
+	if err != nil {
+		return bounds, err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	csvReader := csv.NewReader(reader)
+	csvReader.ReuseRecord = true
+	header, err := csvReader.Read()
+	if err == io.EOF {
+		return bounds, nil
+	} else if err != nil {
+		return bounds, fmt.Errorf("failed to read file header: %v", err)
+	}
+	index := -1
+	for i, column := range header {
+		if column == table.Partition.Field {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return bounds, nil
+	}
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return bounds, nil
+		} else if err != nil {
+			return bounds, fmt.Errorf("failed to read file: %v", err)
+		}
+		bounds.add(record[index])
+	}
+}
+
 // Close underlying sql.DB
 func (p *Postgres) Close() error {
 	if p.tmpDir != "" {
@@ -505,7 +1106,7 @@ const (
 	SSLModeNotProvided string = ""
 )
 
-// SSLConfig is a dto for deserialized SSL configuration for Postgres
+// SSLConfig is a dto for deserialized SSL configuration for Postgres and MySQL
 type SSLConfig struct {
 	SSLMode       string `mapstructure:"sslMode,omitempty"`
 	SSLServerCA   string `mapstructure:"sslServerCA,omitempty"`
@@ -0,0 +1,111 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+type testPgCopier struct {
+	chunks []string
+}
+
+func (c *testPgCopier) CopyData(_ context.Context, line string) (driver.Result, error) {
+	c.chunks = append(c.chunks, line)
+	return driver.RowsAffected(0), nil
+}
+
+func TestPgCopyData(t *testing.T) {
+	reqr := require.New(t)
+	longLine := strings.Repeat("a", pgCopyChunkSize+10)
+	tests := []struct {
+		name           string
+		data           string
+		expectedChunks []string
+	}{
+		{name: "empty", data: ""},
+		{name: "small", data: "id,name\n1,a\n2,b\n", expectedChunks: []string{"id,name\n1,a\n2,b"}},
+		{name: "no_trailing_new_line", data: "id,name\n1,a", expectedChunks: []string{"id,name\n1,a"}},
+		{name: "long_line", data: longLine + "\n1\n", expectedChunks: []string{longLine, "1"}},
+	}
+	for _, tt := range tests {
+		copier := &testPgCopier{}
+		reqr.NoError(pgCopyData(context.Background(), copier, strings.NewReader(tt.data)), tt.name)
+		reqr.Equal(tt.expectedChunks, copier.chunks, tt.name)
+		//pq appends new line to every chunk
+		if len(tt.expectedChunks) > 0 {
+			reqr.Equal(strings.TrimSuffix(tt.data, "\n"), strings.Join(copier.chunks, "\n"), tt.name)
+		}
+	}
+}
+
+func TestPgPartitionLowerBounds(t *testing.T) {
+	reqr := require.New(t)
+	day := time.Date(2023, 3, 5, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		granularity   Granularity
+		partitionId   string
+		expectedCount int
+		expectedOk    bool
+	}{
+		{name: "day_by_day", granularity: DAY, partitionId: "DAY/2023-03-05T00:00:00Z", expectedCount: 1, expectedOk: true},
+		{name: "day_by_hour", granularity: HOUR, partitionId: "DAY/2023-03-05T00:00:00Z", expectedCount: 24, expectedOk: true},
+		{name: "month_by_day", granularity: DAY, partitionId: "MONTH/2023-03-05T00:00:00Z", expectedCount: 31, expectedOk: true},
+		{name: "day_by_month", granularity: MONTH, partitionId: "DAY/2023-03-05T00:00:00Z"},
+		{name: "month_by_week", granularity: WEEK, partitionId: "MONTH/2023-03-05T00:00:00Z"},
+	}
+	for _, tt := range tests {
+		datePartition, ok := DatePartitionFromId(tt.partitionId)
+		reqr.True(ok, tt.name)
+		lowerBounds, ok := pgPartitionLowerBounds(tt.granularity, datePartition)
+		reqr.Equal(tt.expectedOk, ok, tt.name)
+		reqr.Len(lowerBounds, tt.expectedCount, tt.name)
+		if tt.expectedCount > 0 && tt.granularity != MONTH {
+			reqr.True(lowerBounds[0].Equal(datePartition.Granularity.Lower(day)), tt.name)
+		}
+	}
+	_, ok := DatePartitionFromId("some_partition_id")
+	reqr.False(ok)
+	_, ok = DatePartitionFromId("ALL/2023-03-05T00:00:00Z")
+	reqr.False(ok)
+}
+
+func TestPgPartitionName(t *testing.T) {
+	reqr := require.New(t)
+	lower := time.Date(2023, 3, 5, 0, 0, 0, 0, time.UTC)
+	reqr.Equal("events_p20230305", pgPartitionName("events", pgPartitionSuffix(DAY, lower), 63))
+	reqr.Equal("events_p2023030500", pgPartitionName("events", pgPartitionSuffix(HOUR, lower), 63))
+
+	longName := strings.Repeat("ы", 40)
+	name := pgPartitionName(longName, pgPartitionSuffix(DAY, lower), 63)
+	reqr.LessOrEqual(len(name), 63)
+	reqr.True(strings.HasSuffix(name, "_p20230305"))
+	reqr.True(utf8.ValidString(name))
+	reqr.NotEqual(name, pgPartitionName(longName+"a", pgPartitionSuffix(DAY, lower), 63))
+}
+
+func TestPgPartitionsCache(t *testing.T) {
+	reqr := require.New(t)
+	march := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	april := time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC)
+	pc := newPgPartitionsCache()
+	reqr.Equal([]time.Time{march, april}, pc.missing("events", []time.Time{march, april}))
+
+	partitions := map[int64]string{march.Unix(): "events_p202303"}
+	pc.put("events", partitions)
+	//cached partitions are not affected by changes of provided map
+	delete(partitions, march.Unix())
+	reqr.Equal([]time.Time{april}, pc.missing("events", []time.Time{march, april}))
+	reqr.Equal([]time.Time{march}, pc.missing("users", []time.Time{march}))
+
+	pc.add("events", april, "events_p202304")
+	reqr.Empty(pc.missing("events", []time.Time{march, april}))
+
+	pc.clear("events")
+	reqr.Equal([]time.Time{march, april}, pc.missing("events", []time.Time{march, april}))
+}
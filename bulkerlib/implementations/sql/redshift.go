@@ -25,7 +25,7 @@ const (
     				SECRET_ACCESS_KEY '%s'
     				region '%s'
     				csv
-					gzip
+					%s
 					IGNOREHEADER 1
                     dateformat 'auto'
                     timeformat 'auto'`
@@ -90,7 +90,7 @@ func NewRedshift(bulkerConfig bulker.Config) (bulker.Bulker, error) {
 	r.temporaryTables = true
 	r._columnDDLFunc = redshiftColumnDDL
 	r.initTypes(redshiftTypes)
-	r.tableHelper = NewTableHelper(127, '"')
+	r.tableHelper = NewTableHelper(bulkerConfig.Id, 127, '"')
 	//// Redshift is case insensitive by default
 	//r._columnNameFunc = strings.ToLower
 	//r._tableNameFunc = func(config *DataSourceConfig, tableName string) string { return tableName }
@@ -168,6 +168,20 @@ func (p *Redshift) Insert(ctx context.Context, table *Table, merge bool, objects
 	return nil
 }
 
+// redshiftCompression returns COPY command compression parameter for batch file compression
+func redshiftCompression(compression types2.FileCompression) (string, error) {
+	switch compression {
+	case types2.FileCompressionGZIP:
+		return "gzip", nil
+	case types2.FileCompressionZSTD:
+		return "zstd", nil
+	case types2.FileCompressionNONE, types2.FileCompressionUNKNOWN:
+		return "", nil
+	default:
+		return "", fmt.Errorf("LoadTable: compression %s is not supported", compression)
+	}
+}
+
 // LoadTable copy transfer data from s3 to redshift by passing COPY request to redshift
 func (p *Redshift) LoadTable(ctx context.Context, targetTable *Table, loadSource *LoadSource) (err error) {
 	quotedTableName := p.quotedTableName(targetTable.Name)
@@ -188,13 +202,17 @@ func (p *Redshift) LoadTable(ctx context.Context, targetTable *Table, loadSource
 	if s3Config.Folder != "" {
 		fileKey = s3Config.Folder + "/" + fileKey
 	}
-	statement := fmt.Sprintf(redshiftCopyTemplate, quotedTableName, strings.Join(columnNames, ","), s3Config.Bucket, fileKey, s3Config.AccessKeyID, s3Config.SecretKey, s3Config.Region)
+	compression, err := redshiftCompression(loadSource.Compression)
+	if err != nil {
+		return err
+	}
+	statement := fmt.Sprintf(redshiftCopyTemplate, quotedTableName, strings.Join(columnNames, ","), s3Config.Bucket, fileKey, s3Config.AccessKeyID, s3Config.SecretKey, s3Config.Region, compression)
 	if _, err := p.txOrDb(ctx).ExecContext(ctx, statement); err != nil {
 		return errorj.CopyError.Wrap(err, "failed to copy data from s3").
 			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
 				Schema:    p.config.Schema,
 				Table:     quotedTableName,
-				Statement: fmt.Sprintf(redshiftCopyTemplate, quotedTableName, strings.Join(columnNames, ","), s3Config.Bucket, fileKey, credentialsMask, credentialsMask, s3Config.Region),
+				Statement: fmt.Sprintf(redshiftCopyTemplate, quotedTableName, strings.Join(columnNames, ","), s3Config.Bucket, fileKey, credentialsMask, credentialsMask, s3Config.Region, compression),
 			})
 	}
 
@@ -79,8 +79,13 @@ func (ps *ReplacePartitionStream) Complete(ctx context.Context) (state bulker.St
 		if err = ps.init(ctx); err != nil {
 			return
 		}
-		err = ps.clearPartition(ctx, ps.tx)
-		if err == nil && ps.state.SuccessfulRows > 0 {
+		var table *Table
+		table, err = ps.partitionTable(ctx, ps.tx)
+		if err != nil {
+			return
+		}
+		var sourceTable *Table
+		if ps.state.SuccessfulRows > 0 {
 			if ps.batchFile != nil {
 				if err = ps.flushBatchFile(ctx); err != nil {
 					return ps.state, err
@@ -94,8 +99,29 @@ func (ps *ReplacePartitionStream) Complete(ctx context.Context) (state bulker.St
 			}
 			ps.dstTable = dstTable
 			ps.updateRepresentationTable(ps.dstTable)
+			sourceTable = ps.tmpTable
+		}
+		if table.Exists() {
+			targetTable := table
+			if sourceTable != nil {
+				targetTable = ps.dstTable
+			}
+			//partition may be replaced natively. Otherwise, previous data is deleted before copying new data
+			var replaced bool
+			replaced, err = ps.tx.ReplacePartition(ctx, targetTable, sourceTable, ps.partitionId)
+			if err != nil {
+				return ps.state, fmt.Errorf("failed to replace partition: %s error: %w", ps.partitionId, err)
+			}
+			if replaced {
+				return
+			}
+			if err = ps.clearPartition(ctx, ps.tx, table); err != nil {
+				return
+			}
+		}
+		if sourceTable != nil {
 			//copy data from tmp table to destination table
-			err = ps.tx.CopyTables(ctx, ps.dstTable, ps.tmpTable, ps.merge)
+			err = ps.tx.CopyTables(ctx, ps.dstTable, sourceTable, ps.merge)
 			if err != nil {
 				return ps.state, err
 			}
@@ -108,24 +134,39 @@ func (ps *ReplacePartitionStream) Complete(ctx context.Context) (state bulker.St
 	}
 }
 
-func (ps *ReplacePartitionStream) clearPartition(ctx context.Context, tx *TxSQLAdapter) error {
+// partitionTable returns destination table. Returns error if table exists but isn't managed by ReplacePartitionStream
+func (ps *ReplacePartitionStream) partitionTable(ctx context.Context, tx *TxSQLAdapter) (*Table, error) {
 	//check if destination table already exists
 	table, err := tx.GetTableSchema(ctx, ps.tableName)
 	if err != nil {
-		return fmt.Errorf("couldn't start ReplacePartitionStream: failed to check existence of table: %s error: %s", ps.tableName, err)
+		return nil, fmt.Errorf("couldn't start ReplacePartitionStream: failed to check existence of table: %s error: %s", ps.tableName, err)
 	}
 	if table.Exists() {
 		//if table exists we need to delete previous data associated with partitionId,
 		//but we need to check if partitionId column exists in table first
 		_, ok := table.Columns[tx.ColumnName(PartitonIdKeyword)]
 		if !ok {
-			return fmt.Errorf("couldn't start ReplacePartitionStream: destination table [%s] exist but it is not managed by ReplacePartitionStream: %s column is missing", ps.tableName, tx.ColumnName(PartitonIdKeyword))
+			return nil, fmt.Errorf("couldn't start ReplacePartitionStream: destination table [%s] exist but it is not managed by ReplacePartitionStream: %s column is missing", ps.tableName, tx.ColumnName(PartitonIdKeyword))
 		}
-		//delete previous data by provided partition id
-		err = tx.Delete(ctx, ps.tableName, ByPartitionId(ps.partitionId))
+	}
+	return table, nil
+}
+
+func (ps *ReplacePartitionStream) clearPartition(ctx context.Context, tx *TxSQLAdapter, table *Table) error {
+	//date partition may be replaced by truncating native partitions of the table
+	if datePartition, ok := DatePartitionFromId(ps.partitionId); ok {
+		truncated, err := tx.TruncateDatePartition(ctx, table, datePartition)
 		if err != nil {
-			return fmt.Errorf("couldn't start ReplacePartitionStream: failed to delete data for partitionId: %s error: %s", ps.partitionId, err)
+			return fmt.Errorf("couldn't start ReplacePartitionStream: failed to truncate partitions for partitionId: %s error: %s", ps.partitionId, err)
+		}
+		if truncated {
+			return nil
 		}
 	}
+	//delete previous data by provided partition id
+	err := tx.Delete(ctx, ps.tableName, ByPartitionId(ps.partitionId))
+	if err != nil {
+		return fmt.Errorf("couldn't start ReplacePartitionStream: failed to delete data for partitionId: %s error: %s", ps.partitionId, err)
+	}
 	return nil
 }
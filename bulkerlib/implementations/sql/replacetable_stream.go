@@ -31,6 +31,8 @@ func newReplaceTableStream(id string, p SQLAdapter, tableName string, streamOpti
 			PKFields:        tableForObject.PKFields,
 			Columns:         tableForObject.Columns,
 			TimestampColumn: tableForObject.TimestampColumn,
+			VersionColumn:   tableForObject.VersionColumn,
+			Partition:       tableForObject.Partition,
 		}
 	}
 	return &ps, nil
@@ -40,8 +42,13 @@ func (ps *ReplaceTableStream) Complete(ctx context.Context) (state bulker.State,
 	if ps.state.Status != bulker.Active {
 		return ps.state, errors.New("stream is not active")
 	}
+	replaced := false
 	defer func() {
 		state, err = ps.postComplete(ctx, err)
+		if err == nil && replaced {
+			//table was swapped after commit: no bulker instance may use cached schema of the old table
+			ps.sqlAdapter.TableHelper().clearCache(ps.tableName)
+		}
 	}()
 	if ps.state.LastError == nil {
 		//if at least one object was inserted
@@ -58,6 +65,7 @@ func (ps *ReplaceTableStream) Complete(ctx context.Context) (state bulker.State,
 			if err != nil {
 				return ps.state, err
 			}
+			replaced = true
 		} else {
 			//when no objects were consumed. we need to replace table with empty one.
 			//truncation seems like a more straightforward approach.
@@ -179,7 +179,7 @@ func NewSnowflake(bulkerConfig bulker.Config) (bulker.Bulker, error) {
 		return value
 	}
 
-	s.tableHelper = NewTableHelper(255, '"')
+	s.tableHelper = NewTableHelper(bulkerConfig.Id, 255, '"')
 	s.tableHelper.tableNameFunc = sfIdentifierFunction
 	s.tableHelper.columnNameFunc = sfIdentifierFunction
 	return s, err
@@ -55,6 +55,22 @@ type SQLAdapter interface {
 	TableName(rawTableName string) string
 }
 
+// DatePartitionTruncater is implemented by adapters that can remove data of date partition
+// by truncating native partitions of the table
+type DatePartitionTruncater interface {
+	// TruncateDatePartition truncates native partitions of the table that cover datePartition interval.
+	// Returns false if table partitioning doesn't match datePartition and data must be deleted by condition
+	TruncateDatePartition(ctx context.Context, table *Table, datePartition *DatePartition) (bool, error)
+}
+
+// PartitionReplacer is implemented by adapters that can replace data of partition natively
+// e.g. by swapping partitions of the table with partitions of staging table
+type PartitionReplacer interface {
+	// ReplacePartition replaces data of partitionId in targetTable with data of sourceTable (nil if there is no new data).
+	// Returns false if targetTable isn't partitioned by partition id and data must be deleted by condition
+	ReplacePartition(ctx context.Context, targetTable *Table, sourceTable *Table, partitionId string) (bool, error)
+}
+
 type LoadSourceType string
 
 const (
@@ -64,10 +80,11 @@ const (
 )
 
 type LoadSource struct {
-	Type     LoadSourceType
-	Format   types2.FileFormat
-	Path     string
-	S3Config *S3OptionConfig
+	Type        LoadSourceType
+	Format      types2.FileFormat
+	Compression types2.FileCompression
+	Path        string
+	S3Config    *S3OptionConfig
 }
 
 type TxSQLAdapter struct {
@@ -148,6 +165,22 @@ func (tx *TxSQLAdapter) Delete(ctx context.Context, tableName string, deleteCond
 	ctx = context.WithValue(ctx, ContextTransactionKey, tx.tx)
 	return tx.sqlAdapter.Delete(ctx, tableName, deleteConditions)
 }
+func (tx *TxSQLAdapter) TruncateDatePartition(ctx context.Context, table *Table, datePartition *DatePartition) (bool, error) {
+	truncater, ok := tx.sqlAdapter.(DatePartitionTruncater)
+	if !ok {
+		return false, nil
+	}
+	ctx = context.WithValue(ctx, ContextTransactionKey, tx.tx)
+	return truncater.TruncateDatePartition(ctx, table, datePartition)
+}
+func (tx *TxSQLAdapter) ReplacePartition(ctx context.Context, targetTable *Table, sourceTable *Table, partitionId string) (bool, error) {
+	replacer, ok := tx.sqlAdapter.(PartitionReplacer)
+	if !ok {
+		return false, nil
+	}
+	ctx = context.WithValue(ctx, ContextTransactionKey, tx.tx)
+	return replacer.ReplacePartition(ctx, targetTable, sourceTable, partitionId)
+}
 func (tx *TxSQLAdapter) DropTable(ctx context.Context, tableName string, ifExists bool) error {
 	ctx = context.WithValue(ctx, ContextTransactionKey, tx.tx)
 	return tx.sqlAdapter.DropTable(ctx, tableName, ifExists)
@@ -153,6 +153,7 @@ func (b *SQLAdapterBase[T]) Ping(ctx context.Context) error {
 
 // Close underlying sql.DB
 func (b *SQLAdapterBase[T]) Close() error {
+	b.tableHelper.Close()
 	if b.dataSource != nil {
 		return b.dataSource.Close()
 	}
@@ -169,6 +170,21 @@ func (b *SQLAdapterBase[T]) openTx(ctx context.Context, sqlAdapter SQLAdapter) (
 	return &TxSQLAdapter{sqlAdapter: sqlAdapter, tx: NewTxWrapper(b.Type(), tx, b.queryLogger, b.checkErrFunc)}, nil
 }
 
+// openConnTx opens sql transaction on dedicated connection so driver connection of transaction is accessible with TxWrapper.Raw
+func (b *SQLAdapterBase[T]) openConnTx(ctx context.Context, sqlAdapter SQLAdapter) (*TxSQLAdapter, error) {
+	conn, err := b.dataSource.Conn(ctx)
+	if err != nil {
+		return nil, errorj.BeginTransactionError.Wrap(err, "failed to get connection")
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		_ = conn.Close()
+		return nil, errorj.BeginTransactionError.Wrap(err, "failed to begin transaction")
+	}
+
+	return &TxSQLAdapter{sqlAdapter: sqlAdapter, tx: NewConnTxWrapper(b.Type(), conn, tx, b.queryLogger, b.checkErrFunc)}, nil
+}
+
 func (b *SQLAdapterBase[T]) txOrDb(ctx context.Context) TxOrDB {
 	txOrDb, ok := ctx.Value(ContextTransactionKey).(TxOrDB)
 	if !ok {
@@ -0,0 +1,532 @@
+package sql
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	types2 "github.com/jitsucom/bulker/bulkerlib/types"
+	"github.com/jitsucom/bulker/jitsubase/errorj"
+	"github.com/jitsucom/bulker/jitsubase/logging"
+	"github.com/jitsucom/bulker/jitsubase/timestamp"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	jsoniter "github.com/json-iterator/go"
+	_ "modernc.org/sqlite"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+func init() {
+	bulker.RegisterBulker(SQLiteBulkerTypeId, NewSQLite)
+}
+
+const (
+	SQLiteBulkerTypeId = "sqlite"
+
+	sqliteTableSchemaQuery      = `SELECT name, type FROM pragma_table_info($1)`
+	sqlitePrimaryKeyFieldsQuery = `SELECT name FROM pragma_table_info($1) WHERE pk > 0 ORDER BY pk`
+
+	sqliteCreateTableWithPKTemplate = `CREATE TABLE %s (%s, PRIMARY KEY (%s))`
+	sqliteTruncateTableTemplate     = `DELETE FROM %s`
+
+	//SQLite requires explicit conflict target for DO UPDATE. 'WHERE true' resolves parsing ambiguity of INSERT ... SELECT ... ON CONFLICT
+	sqliteMergeQuery     = `INSERT INTO {{.TableName}}({{.Columns}}) VALUES ({{.Placeholders}}) ON CONFLICT (%s) DO %s`
+	sqliteBulkMergeQuery = `INSERT INTO {{.TableTo}}({{.Columns}}) SELECT {{.Columns}} FROM {{.TableFrom}} WHERE true ON CONFLICT (%s) DO %s`
+)
+
+var (
+	sqliteTypes = map[types2.DataType][]string{
+		types2.STRING:    {"TEXT", "VARCHAR", "CHAR", "CLOB"},
+		types2.INT64:     {"INTEGER", "BIGINT", "INT"},
+		types2.FLOAT64:   {"REAL", "DOUBLE", "FLOAT", "NUMERIC"},
+		types2.TIMESTAMP: {"TIMESTAMP", "DATETIME"},
+		types2.BOOL:      {"BOOLEAN"},
+		types2.JSON:      {"TEXT"},
+		types2.UNKNOWN:   {"TEXT"},
+	}
+
+	//sqliteDefaultPragmas applied to every connection unless overridden in config pragmas
+	sqliteDefaultPragmas = map[string]string{
+		"busy_timeout": "10000",
+		"journal_mode": "WAL",
+		"synchronous":  "NORMAL",
+	}
+)
+
+// SQLiteConfig dto for deserialized SQLite config
+type SQLiteConfig struct {
+	// Path to database file
+	Path string `mapstructure:"path,omitempty" json:"path,omitempty" yaml:"path,omitempty"`
+	// Pragmas applied to every connection e.g. busy_timeout, journal_mode, synchronous
+	Pragmas map[string]string `mapstructure:"pragmas,omitempty" json:"pragmas,omitempty" yaml:"pragmas,omitempty"`
+}
+
+// Validate required fields in SQLiteConfig
+func (sc *SQLiteConfig) Validate() error {
+	if sc == nil {
+		return errors.New("SQLite config is required")
+	}
+	if sc.Path == "" {
+		return errors.New("path is required parameter")
+	}
+	if sc.Pragmas == nil {
+		sc.Pragmas = map[string]string{}
+	}
+	for k, v := range sqliteDefaultPragmas {
+		utils.MapPutIfAbsent(sc.Pragmas, k, v)
+	}
+	return nil
+}
+
+// SQLite is adapter for creating, patching (schema or table), inserting data to SQLite database file
+type SQLite struct {
+	*SQLAdapterBase[SQLiteConfig]
+}
+
+// NewSQLite returns configured SQLite adapter instance
+func NewSQLite(bulkerConfig bulker.Config) (bulker.Bulker, error) {
+	config := &SQLiteConfig{}
+	if err := utils.ParseObject(bulkerConfig.DestinationConfig, config); err != nil {
+		return nil, fmt.Errorf("failed to parse destination config: %v", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	dbConnectFunction := func(cfg *SQLiteConfig) (*sql.DB, error) {
+		logging.Infof("[%s] opening: %s", bulkerConfig.Id, cfg.Path)
+		dataSource, err := sql.Open("sqlite", sqliteDriverConnectionString(cfg))
+		if err != nil {
+			return nil, err
+		}
+		if err := dataSource.Ping(); err != nil {
+			_ = dataSource.Close()
+			return nil, err
+		}
+		dataSource.SetConnMaxIdleTime(3 * time.Minute)
+		dataSource.SetMaxIdleConns(10)
+		return dataSource, nil
+	}
+	typecastFunc := func(placeholder string, column types2.SQLColumn) string {
+		if column.Override {
+			return fmt.Sprintf("CAST(%s AS %s)", placeholder, column.Type)
+		}
+		if column.DataType == types2.JSON {
+			//json() fails on malformed JSON and minifies valid one
+			return fmt.Sprintf("json(%s)", placeholder)
+		}
+		return placeholder
+	}
+	var queryLogger *logging.QueryLogger
+	if bulkerConfig.LogLevel == bulker.Verbose {
+		queryLogger = logging.NewQueryLogger(bulkerConfig.Id, os.Stderr, os.Stderr)
+	}
+	sqlAdapterBase, err := newSQLAdapterBase(bulkerConfig.Id, SQLiteBulkerTypeId, config, dbConnectFunction, sqliteTypes, queryLogger, typecastFunc, IndexParameterPlaceholder, sqliteColumnDDL, sqliteMapColumnValue, checkErr)
+	s := &SQLite{SQLAdapterBase: sqlAdapterBase}
+	s.tableHelper = NewTableHelper(bulkerConfig.Id, 63, '"')
+	return s, err
+}
+
+func (s *SQLite) CreateStream(id, tableName string, mode bulker.BulkMode, streamOptions ...bulker.StreamOption) (bulker.BulkerStream, error) {
+	streamOptions = append(streamOptions, withLocalBatchFile(fmt.Sprintf("bulker_%s", utils.SanitizeString(id))))
+	if err := s.validateOptions(streamOptions); err != nil {
+		return nil, err
+	}
+	switch mode {
+	case bulker.Stream:
+		return newAutoCommitStream(id, s, tableName, streamOptions...)
+	case bulker.Batch:
+		return newTransactionalStream(id, s, tableName, streamOptions...)
+	case bulker.ReplaceTable:
+		return newReplaceTableStream(id, s, tableName, streamOptions...)
+	case bulker.ReplacePartition:
+		return newReplacePartitionStream(id, s, tableName, streamOptions...)
+	}
+	return nil, fmt.Errorf("unsupported bulk mode: %s", mode)
+}
+
+func (s *SQLite) validateOptions(streamOptions []bulker.StreamOption) error {
+	options := &bulker.StreamOptions{}
+	for _, option := range streamOptions {
+		options.Add(option)
+	}
+	return nil
+}
+
+// OpenTx opens underline sql transaction and return wrapped instance
+func (s *SQLite) OpenTx(ctx context.Context) (*TxSQLAdapter, error) {
+	return s.openTx(ctx, s)
+}
+
+// InitDatabase SQLite database file is created on first connection
+func (s *SQLite) InitDatabase(ctx context.Context) error {
+	return nil
+}
+
+// GetTableSchema returns table (name,columns with name and types) representation wrapped in Table struct
+func (s *SQLite) GetTableSchema(ctx context.Context, tableName string) (*Table, error) {
+	table, err := s.getTable(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	//don't select primary keys of non-existent table
+	if len(table.Columns) == 0 {
+		return table, nil
+	}
+
+	pkFields, err := s.getPrimaryKeys(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	table.PKFields = pkFields
+	if len(pkFields) > 0 {
+		//SQLite doesn't keep names of primary key constraints
+		table.PrimaryKeyName = BuildConstraintName(table.Name)
+	}
+	return table, nil
+}
+
+func (s *SQLite) getTable(ctx context.Context, tableName string) (*Table, error) {
+	tableName = s.TableName(tableName)
+	table := &Table{Name: tableName, Columns: Columns{}, PKFields: utils.NewSet[string]()}
+	rows, err := s.txOrDb(ctx).QueryContext(ctx, sqliteTableSchemaQuery, tableName)
+	if err != nil {
+		return nil, errorj.GetTableError.Wrap(err, "failed to get table columns").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Table:       tableName,
+				PrimaryKeys: table.GetPKFields(),
+				Statement:   sqliteTableSchemaQuery,
+				Values:      []any{tableName},
+			})
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var columnName, columnType string
+		if err := rows.Scan(&columnName, &columnType); err != nil {
+			return nil, errorj.GetTableError.Wrap(err, "failed to scan result").
+				WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+					Table:       tableName,
+					PrimaryKeys: table.GetPKFields(),
+					Statement:   sqliteTableSchemaQuery,
+					Values:      []any{tableName},
+				})
+		}
+		dt, _ := s.GetDataType(columnType)
+		table.Columns[columnName] = types2.SQLColumn{Type: columnType, DataType: dt}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errorj.GetTableError.Wrap(err, "failed read last row").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Table:       tableName,
+				PrimaryKeys: table.GetPKFields(),
+				Statement:   sqliteTableSchemaQuery,
+				Values:      []any{tableName},
+			})
+	}
+
+	return table, nil
+}
+
+func (s *SQLite) getPrimaryKeys(ctx context.Context, tableName string) (utils.Set[string], error) {
+	tableName = s.TableName(tableName)
+	pkFieldsRows, err := s.txOrDb(ctx).QueryContext(ctx, sqlitePrimaryKeyFieldsQuery, tableName)
+	if err != nil {
+		return nil, errorj.GetPrimaryKeysError.Wrap(err, "failed to get primary key").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Table:     tableName,
+				Statement: sqlitePrimaryKeyFieldsQuery,
+				Values:    []any{tableName},
+			})
+	}
+
+	defer pkFieldsRows.Close()
+
+	pkFields := utils.NewSet[string]()
+	for pkFieldsRows.Next() {
+		var fieldName string
+		if err := pkFieldsRows.Scan(&fieldName); err != nil {
+			return nil, errorj.GetPrimaryKeysError.Wrap(err, "failed to scan result").
+				WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+					Table:     tableName,
+					Statement: sqlitePrimaryKeyFieldsQuery,
+					Values:    []any{tableName},
+				})
+		}
+		pkFields.Put(fieldName)
+	}
+	if err := pkFieldsRows.Err(); err != nil {
+		return nil, errorj.GetPrimaryKeysError.Wrap(err, "failed read last row").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Table:     tableName,
+				Statement: sqlitePrimaryKeyFieldsQuery,
+				Values:    []any{tableName},
+			})
+	}
+
+	return pkFields, nil
+}
+
+// CreateTable creates table with columns and primary key.
+// SQLite doesn't support adding primary key to existing table so it is created inline
+func (s *SQLite) CreateTable(ctx context.Context, schemaToCreate *Table) error {
+	if len(schemaToCreate.PKFields) == 0 {
+		return s.SQLAdapterBase.CreateTable(ctx, schemaToCreate)
+	}
+	quotedTableName := s.quotedTableName(schemaToCreate.Name)
+
+	columns := schemaToCreate.SortedColumnNames()
+	columnsDDL := make([]string, len(columns))
+	for i, columnName := range columns {
+		columnsDDL[i] = s.columnDDL(columnName, schemaToCreate)
+	}
+	pkFields := schemaToCreate.GetPKFields()
+	quotedPKFields := make([]string, len(pkFields))
+	for i, pkField := range pkFields {
+		quotedPKFields[i] = s.quotedColumnName(pkField)
+	}
+
+	query := fmt.Sprintf(sqliteCreateTableWithPKTemplate, quotedTableName, strings.Join(columnsDDL, ", "), strings.Join(quotedPKFields, ", "))
+
+	if _, err := s.txOrDb(ctx).ExecContext(ctx, query); err != nil {
+		return errorj.CreateTableError.Wrap(err, "failed to create table").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Table:       quotedTableName,
+				PrimaryKeys: pkFields,
+				Statement:   query,
+			})
+	}
+
+	return nil
+}
+
+// PatchTableSchema adds new columns to the table.
+// SQLite doesn't support altering primary key of existing table
+func (s *SQLite) PatchTableSchema(ctx context.Context, patchTable *Table) error {
+	if patchTable.DeletePkFields || len(patchTable.PKFields) > 0 {
+		return errorj.PatchTableError.Wrap(errors.New("SQLite doesn't support changing primary key of existing table"), "failed to patch table").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Table:       s.quotedTableName(patchTable.Name),
+				PrimaryKeys: patchTable.GetPKFields(),
+			})
+	}
+	return s.SQLAdapterBase.PatchTableSchema(ctx, patchTable)
+}
+
+// TruncateTable deletes all records in tableName table. SQLite doesn't have TRUNCATE statement
+func (s *SQLite) TruncateTable(ctx context.Context, tableName string) error {
+	quotedTableName := s.quotedTableName(tableName)
+
+	statement := fmt.Sprintf(sqliteTruncateTableTemplate, quotedTableName)
+	if _, err := s.txOrDb(ctx).ExecContext(ctx, statement); err != nil {
+		return errorj.TruncateError.Wrap(err, "failed to truncate table").
+			WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+				Table:     quotedTableName,
+				Statement: statement,
+			})
+	}
+
+	return nil
+}
+
+func (s *SQLite) Insert(ctx context.Context, table *Table, merge bool, objects ...types2.Object) error {
+	if !merge || len(table.PKFields) == 0 {
+		return s.insert(ctx, table, objects)
+	}
+	mergeQueryTemplate, err := s.mergeQueryTemplate(sqliteMergeQuery, table, table)
+	if err != nil {
+		return errorj.ExecuteInsertError.Wrap(err, "failed to build query from template")
+	}
+	return s.insertOrMerge(ctx, table, objects, mergeQueryTemplate)
+}
+
+func (s *SQLite) CopyTables(ctx context.Context, targetTable *Table, sourceTable *Table, merge bool) error {
+	if !merge || len(targetTable.PKFields) == 0 {
+		return s.copy(ctx, targetTable, sourceTable)
+	}
+	mergeQueryTemplate, err := s.mergeQueryTemplate(sqliteBulkMergeQuery, targetTable, sourceTable)
+	if err != nil {
+		return errorj.BulkMergeError.Wrap(err, "failed to build query from template")
+	}
+	return s.copyOrMerge(ctx, targetTable, sourceTable, mergeQueryTemplate, "")
+}
+
+// mergeQueryTemplate completes upsert query with conflict target (primary key columns) and update of the rest of columns
+func (s *SQLite) mergeQueryTemplate(query string, targetTable *Table, sourceTable *Table) (*template.Template, error) {
+	pkFields := targetTable.GetPKFields()
+	conflictColumns := make([]string, len(pkFields))
+	for i, pkField := range pkFields {
+		conflictColumns[i] = s.quotedColumnName(pkField)
+	}
+	var updateColumns []string
+	for _, name := range sourceTable.SortedColumnNames() {
+		if !targetTable.PKFields.Contains(name) {
+			quotedName := s.quotedColumnName(name)
+			updateColumns = append(updateColumns, fmt.Sprintf("%s=excluded.%s", quotedName, quotedName))
+		}
+	}
+	action := "NOTHING"
+	if len(updateColumns) > 0 {
+		action = "UPDATE SET " + strings.Join(updateColumns, ", ")
+	}
+	return template.New("sqliteMergeQuery").Parse(fmt.Sprintf(query, strings.Join(conflictColumns, ", "), action))
+}
+
+// LoadTable inserts rows of local NDJSON batch file using prepared statement. SQLite has no bulk loading statements
+func (s *SQLite) LoadTable(ctx context.Context, targetTable *Table, loadSource *LoadSource) (err error) {
+	quotedTableName := s.quotedTableName(targetTable.Name)
+	if loadSource.Type != LocalFile {
+		return fmt.Errorf("LoadTable: only local file is supported")
+	}
+	if loadSource.Format != s.batchFileFormat {
+		return fmt.Errorf("LoadTable: only %s format is supported", s.batchFileFormat)
+	}
+	columns := targetTable.SortedColumnNames()
+	columnNames := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	for i, name := range columns {
+		columnNames[i] = s.quotedColumnName(name)
+		placeholders[i] = s.typecastFunc(s.parameterPlaceholder(i+1, name), targetTable.Columns[name])
+	}
+	insertPayload := QueryPayload{
+		TableName:    quotedTableName,
+		Columns:      strings.Join(columnNames, ", "),
+		Placeholders: strings.Join(placeholders, ", "),
+	}
+	buf := strings.Builder{}
+	err = insertQueryTemplate.Execute(&buf, insertPayload)
+	if err != nil {
+		return errorj.ExecuteInsertError.Wrap(err, "failed to build query from template")
+	}
+	statement := buf.String()
+	defer func() {
+		if err != nil {
+			err = errorj.LoadError.Wrap(err, "failed to load table").
+				WithProperty(errorj.DBInfo, &types2.ErrorPayload{
+					Table:       quotedTableName,
+					PrimaryKeys: targetTable.GetPKFields(),
+					Statement:   statement,
+				})
+		}
+	}()
+
+	stmt, err := s.txOrDb(ctx).PrepareContext(ctx, statement)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	file, err := os.Open(loadSource.Path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	reader, err := types2.NewCompressionReader(loadSource.Compression, file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 1024*100), 1024*1024*10)
+	for scanner.Scan() {
+		object := map[string]any{}
+		decoder := jsoniter.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		decoder.UseNumber()
+		if err = decoder.Decode(&object); err != nil {
+			return err
+		}
+		args := make([]any, len(columns))
+		for i, name := range columns {
+			value, valuePresent := object[name]
+			args[i] = s.valueMappingFunction(types2.ReformatValue(value), valuePresent, targetTable.Columns[name])
+		}
+		if _, err = stmt.ExecContext(ctx, args...); err != nil {
+			return checkErr(err)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("LoadTable: failed to read file: %v", err)
+	}
+	return nil
+}
+
+// ReplaceTable replaces target table with replacement table in a single transaction.
+// SQLite supports transactional DDL so readers never see missing target table
+func (s *SQLite) ReplaceTable(ctx context.Context, targetTableName string, replacementTable *Table, dropOldTable bool) (err error) {
+	if _, ok := ctx.Value(ContextTransactionKey).(TxOrDB); !ok {
+		tx, err := s.OpenTx(ctx)
+		if err != nil {
+			return err
+		}
+		if err = tx.ReplaceTable(ctx, targetTableName, replacementTable, dropOldTable); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	}
+	if dropOldTable {
+		if err = s.DropTable(ctx, targetTableName, true); err != nil {
+			return err
+		}
+	} else {
+		existingTable, err := s.getTable(ctx, targetTableName)
+		if err != nil {
+			return err
+		}
+		if existingTable.Exists() {
+			tmpTable := "deprecated_" + targetTableName + timestamp.Now().Format("_20060102_150405")
+			if err = s.renameTable(ctx, false, targetTableName, tmpTable); err != nil {
+				return err
+			}
+		}
+	}
+	return s.renameTable(ctx, false, replacementTable.Name, targetTableName)
+}
+
+// sqliteDriverConnectionString returns connection string for modernc.org/sqlite driver
+func sqliteDriverConnectionString(config *SQLiteConfig) string {
+	pragmas := make([]string, 0, len(config.Pragmas))
+	for k, v := range config.Pragmas {
+		pragmas = append(pragmas, fmt.Sprintf("%s(%s)", k, v))
+	}
+	sort.Strings(pragmas)
+	params := url.Values{"_pragma": pragmas}
+	//acquire write lock at the beginning of transaction to avoid deadlocks between concurrent writers
+	params.Set("_txlock", "immediate")
+	return "file:" + config.Path + "?" + params.Encode()
+}
+
+// sqliteColumnDDL returns column DDL (quoted column name, mapped sql type)
+func sqliteColumnDDL(quotedName, name string, table *Table) string {
+	column := table.Columns[name]
+	return fmt.Sprintf(`%s %s`, quotedName, column.GetDDLType())
+}
+
+// sqliteMapColumnValue serializes objects and arrays to JSON text
+func sqliteMapColumnValue(value any, valuePresent bool, column types2.SQLColumn) any {
+	switch v := value.(type) {
+	case map[string]any, []any:
+		b, err := jsoniter.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+	return value
+}
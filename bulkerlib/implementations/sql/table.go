@@ -31,6 +31,8 @@ type Table struct {
 	PKFields        utils.Set[string]
 	PrimaryKeyName  string
 	TimestampColumn string
+	// VersionColumn column that defines which of the rows with the same primary key is the latest one
+	VersionColumn string
 
 	Partition DatePartition
 
@@ -72,6 +74,7 @@ func (t *Table) Clone() *Table {
 		PrimaryKeyName:  t.PrimaryKeyName,
 		Temporary:       t.Temporary,
 		TimestampColumn: t.TimestampColumn,
+		VersionColumn:   t.VersionColumn,
 		Partition:       t.Partition,
 		Cached:          t.Cached,
 		DeletePkFields:  t.DeletePkFields,
@@ -0,0 +1,99 @@
+package sql
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"io"
+	"sync"
+	"time"
+)
+
+// TableCacheInvalidator shares table schema changes between bulker instances.
+// When one instance changes schema of a table (e.g. adds a column) other instances drop their cached copy of that table schema
+// instead of failing on the next insert.
+type TableCacheInvalidator interface {
+	io.Closer
+	// NextVersion increments schema version of the table. Returns new schema version.
+	NextVersion(destinationId, tableName string) (version int64, err error)
+	// Publish notifies all subscribed instances that schema of the table was changed to provided version.
+	Publish(destinationId, tableName string, version int64) error
+	// Subscribe registers callback for schema changes of tables of provided destination.
+	// Returns function that cancels subscription.
+	Subscribe(destinationId string, callback TableCacheInvalidationCallback) (unsubscribe func())
+}
+
+// TableCacheInvalidationCallback is called when schema of the table was changed by some bulker instance
+type TableCacheInvalidationCallback func(tableName string, version int64)
+
+type DummyTableCacheInvalidator struct {
+}
+
+func (d DummyTableCacheInvalidator) NextVersion(destinationId, tableName string) (int64, error) {
+	return 0, nil
+}
+
+func (d DummyTableCacheInvalidator) Publish(destinationId, tableName string, version int64) error {
+	return nil
+}
+
+func (d DummyTableCacheInvalidator) Subscribe(destinationId string, callback TableCacheInvalidationCallback) func() {
+	return func() {}
+}
+
+func (d DummyTableCacheInvalidator) Close() error {
+	return nil
+}
+
+var (
+	tableCacheSettingsMu  sync.RWMutex
+	tableCacheInvalidator TableCacheInvalidator = DummyTableCacheInvalidator{}
+	tableCacheTTL         time.Duration
+)
+
+// SetTableCacheSettings configures table schema cache of all SQL bulkers created after this call.
+// invalidator - propagates table schema changes between bulker instances. nil means no cross-instance invalidation.
+// ttl - max time a table schema stays in cache. 0 means no expiration.
+func SetTableCacheSettings(invalidator TableCacheInvalidator, ttl time.Duration) {
+	tableCacheSettingsMu.Lock()
+	defer tableCacheSettingsMu.Unlock()
+	if invalidator == nil {
+		invalidator = DummyTableCacheInvalidator{}
+	}
+	tableCacheInvalidator = invalidator
+	tableCacheTTL = ttl
+}
+
+func getTableCacheSettings() (TableCacheInvalidator, time.Duration) {
+	tableCacheSettingsMu.RLock()
+	defer tableCacheSettingsMu.RUnlock()
+	return tableCacheInvalidator, tableCacheTTL
+}
+
+// cachedTable table schema in TableHelper cache
+type cachedTable struct {
+	table    *Table
+	version  int64
+	cachedAt time.Time
+}
+
+var (
+	tableCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bulkerlib",
+		Subsystem: "table_cache",
+		Name:      "requests",
+		Help:      "Table schema cache requests by destination Id. result: hit, miss, expired",
+	}, []string{"destinationId", "result"})
+	TableCacheRequests = func(destinationId, result string) prometheus.Counter {
+		return tableCacheRequests.WithLabelValues(destinationId, result)
+	}
+
+	tableCacheInvalidations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bulkerlib",
+		Subsystem: "table_cache",
+		Name:      "invalidations",
+		Help:      "Table schema cache invalidations by destination Id. source: local - schema changed by this instance, remote - by another instance, error - invalidation failed",
+	}, []string{"destinationId", "source"})
+	TableCacheInvalidations = func(destinationId, source string) prometheus.Counter {
+		return tableCacheInvalidations.WithLabelValues(destinationId, source)
+	}
+)
@@ -0,0 +1,236 @@
+package sql
+
+import (
+	types2 "github.com/jitsucom/bulker/bulkerlib/types"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testTableCacheInvalidator in-memory TableCacheInvalidator. Keeps published changes until deliver() call
+// unless synchronous is set
+type testTableCacheInvalidator struct {
+	sync.Mutex
+	synchronous   bool
+	versions      map[string]int64
+	lastId        int
+	subscriptions map[string]map[int]TableCacheInvalidationCallback
+	pending       []tableCacheChange
+}
+
+type tableCacheChange struct {
+	destinationId string
+	tableName     string
+	version       int64
+}
+
+func newTestTableCacheInvalidator() *testTableCacheInvalidator {
+	return &testTableCacheInvalidator{versions: map[string]int64{}, subscriptions: map[string]map[int]TableCacheInvalidationCallback{}}
+}
+
+func (ti *testTableCacheInvalidator) NextVersion(destinationId, tableName string) (int64, error) {
+	ti.Lock()
+	defer ti.Unlock()
+	key := destinationId + ":" + tableName
+	ti.versions[key]++
+	return ti.versions[key], nil
+}
+
+func (ti *testTableCacheInvalidator) Publish(destinationId, tableName string, version int64) error {
+	ti.Lock()
+	ti.pending = append(ti.pending, tableCacheChange{destinationId: destinationId, tableName: tableName, version: version})
+	ti.Unlock()
+	if ti.synchronous {
+		ti.deliver()
+	}
+	return nil
+}
+
+func (ti *testTableCacheInvalidator) Subscribe(destinationId string, callback TableCacheInvalidationCallback) func() {
+	ti.Lock()
+	defer ti.Unlock()
+	ti.lastId++
+	id := ti.lastId
+	if ti.subscriptions[destinationId] == nil {
+		ti.subscriptions[destinationId] = map[int]TableCacheInvalidationCallback{}
+	}
+	ti.subscriptions[destinationId][id] = callback
+	return func() {
+		ti.Lock()
+		defer ti.Unlock()
+		delete(ti.subscriptions[destinationId], id)
+	}
+}
+
+// deliver notifies subscribers about all published changes
+func (ti *testTableCacheInvalidator) deliver() {
+	ti.Lock()
+	pending := ti.pending
+	ti.pending = nil
+	var calls []func()
+	for _, change := range pending {
+		change := change
+		for _, callback := range ti.subscriptions[change.destinationId] {
+			callback := callback
+			calls = append(calls, func() { callback(change.tableName, change.version) })
+		}
+	}
+	ti.Unlock()
+	for _, call := range calls {
+		call()
+	}
+}
+
+func (ti *testTableCacheInvalidator) subscribersCount(destinationId string) int {
+	ti.Lock()
+	defer ti.Unlock()
+	return len(ti.subscriptions[destinationId])
+}
+
+func (ti *testTableCacheInvalidator) Close() error {
+	return nil
+}
+
+func testCachedTable(name string, columns ...string) *Table {
+	table := &Table{Name: name, Columns: Columns{}}
+	for _, column := range columns {
+		table.Columns[column] = types2.SQLColumn{Type: "text"}
+	}
+	return table
+}
+
+func TestTableHelperCacheTTL(t *testing.T) {
+	reqr := require.New(t)
+	th := NewTableHelper("ttl", 63, '"')
+	th.cacheTTL = 100 * time.Millisecond
+	th.updateCached("events", testCachedTable("events", "id"))
+
+	cached, ok := th.GetCached("events")
+	reqr.True(ok)
+	reqr.True(cached.Cached)
+	reqr.Equal(testCachedTable("events", "id").Columns, cached.Columns)
+	//cached copy is not affected by changes of returned table
+	cached.Columns["name"] = types2.SQLColumn{Type: "text"}
+	cached, ok = th.GetCached("events")
+	reqr.True(ok)
+	reqr.Len(cached.Columns, 1)
+
+	time.Sleep(150 * time.Millisecond)
+	_, ok = th.GetCached("events")
+	reqr.False(ok, "table schema must expire after ttl")
+	reqr.NotContains(th.tablesCache, "events")
+
+	//updating cache restarts ttl
+	th.updateCached("events", testCachedTable("events", "id", "name"))
+	cached, ok = th.GetCached("events")
+	reqr.True(ok)
+	reqr.Len(cached.Columns, 2)
+
+	th.cacheTTL = 0
+	th.tablesCache["events"].cachedAt = time.Now().Add(-time.Hour)
+	_, ok = th.GetCached("events")
+	reqr.True(ok, "table schema must not expire without ttl")
+}
+
+func TestTableHelperCacheInvalidation(t *testing.T) {
+	reqr := require.New(t)
+	invalidator := newTestTableCacheInvalidator()
+	newHelper := func(destinationId string) *TableHelper {
+		th := NewTableHelper(destinationId, 63, '"')
+		th.cacheInvalidator = invalidator
+		return &th
+	}
+	th1, th2, other := newHelper("dest"), newHelper("dest"), newHelper("other")
+	//subscription is created lazily on the first cached table
+	reqr.Equal(0, invalidator.subscribersCount("dest"))
+	for _, th := range []*TableHelper{th1, th2, other} {
+		th.updateCached("events", testCachedTable("events", "id"))
+		th.updateCached("users", testCachedTable("users", "id"))
+	}
+	reqr.Equal(2, invalidator.subscribersCount("dest"))
+	reqr.Equal(1, invalidator.subscribersCount("other"))
+
+	//th1 patched 'events' table
+	th1.updateCached("events", testCachedTable("events", "id", "name"))
+	th1.publishSchemaChange("events")
+	reqr.Equal(int64(1), th1.tablesCache["events"].version)
+	invalidator.deliver()
+
+	cached, ok := th1.GetCached("events")
+	reqr.True(ok, "schema changed by this instance must stay in cache")
+	reqr.Len(cached.Columns, 2)
+	_, ok = th2.GetCached("events")
+	reqr.False(ok, "schema changed by another instance must be removed from cache")
+	_, ok = th2.GetCached("users")
+	reqr.True(ok, "schema of other tables must stay in cache")
+	_, ok = other.GetCached("events")
+	reqr.True(ok, "schema of other destinations must stay in cache")
+
+	//outdated notification doesn't remove newer schema
+	th1.onSchemaChange("events", 1)
+	th1.onSchemaChange("events", 0)
+	_, ok = th1.GetCached("events")
+	reqr.True(ok)
+	th1.onSchemaChange("events", 2)
+	_, ok = th1.GetCached("events")
+	reqr.False(ok)
+
+	//notification for table that isn't cached is ignored
+	th2.onSchemaChange("events", 5)
+	_, ok = th2.GetCached("events")
+	reqr.False(ok)
+
+	//closed helper doesn't receive notifications anymore
+	th2.Close()
+	reqr.Equal(1, invalidator.subscribersCount("dest"))
+	th1.updateCached("users", testCachedTable("users", "id", "email"))
+	th1.publishSchemaChange("users")
+	invalidator.deliver()
+	_, ok = th2.GetCached("users")
+	reqr.True(ok)
+	th1.Close()
+	reqr.Equal(0, invalidator.subscribersCount("dest"))
+}
+
+func TestTableHelperCacheOwnNotification(t *testing.T) {
+	reqr := require.New(t)
+	invalidator := newTestTableCacheInvalidator()
+	invalidator.synchronous = true
+	newHelper := func() *TableHelper {
+		th := NewTableHelper("dest", 63, '"')
+		th.cacheInvalidator = invalidator
+		return &th
+	}
+	th1, th2 := newHelper(), newHelper()
+	th1.updateCached("events", testCachedTable("events", "id"))
+	th2.updateCached("events", testCachedTable("events", "id"))
+
+	//notification is delivered to the publisher before publishSchemaChange returns
+	th1.updateCached("events", testCachedTable("events", "id", "name"))
+	th1.publishSchemaChange("events")
+	cached, ok := th1.GetCached("events")
+	reqr.True(ok, "own notification must not remove schema cached by this instance")
+	reqr.Len(cached.Columns, 2)
+	_, ok = th2.GetCached("events")
+	reqr.False(ok)
+
+	//clearing cache removes schema in all instances
+	th2.updateCached("events", testCachedTable("events", "id", "name"))
+	th2.clearCache("events")
+	_, ok = th1.GetCached("events")
+	reqr.False(ok)
+	_, ok = th2.GetCached("events")
+	reqr.False(ok)
+	reqr.Equal(int64(2), invalidator.versions["dest:events"])
+
+	//expiration doesn't notify other instances
+	th1.cacheTTL = time.Millisecond
+	th1.updateCached("events", testCachedTable("events", "id", "name"))
+	time.Sleep(5 * time.Millisecond)
+	_, ok = th1.GetCached("events")
+	reqr.False(ok)
+	reqr.Equal(int64(2), invalidator.versions["dest:events"])
+	th1.Close()
+	th2.Close()
+}
@@ -32,8 +32,12 @@ var (
 type TableHelper struct {
 	sync.RWMutex
 
+	destinationId       string
 	coordinationService coordination.Service
-	tablesCache         map[string]*Table
+	tablesCache         map[string]*cachedTable
+	cacheInvalidator    TableCacheInvalidator
+	cacheTTL            time.Duration
+	unsubscribe         func()
 
 	maxColumns int
 
@@ -46,10 +50,14 @@ type TableHelper struct {
 
 // NewTableHelper returns configured TableHelper instance
 // Note: columnTypesMapping must be not empty (or fields will be ignored)
-func NewTableHelper(maxIdentifierLength int, identifierQuoteChar rune) TableHelper {
+func NewTableHelper(destinationId string, maxIdentifierLength int, identifierQuoteChar rune) TableHelper {
+	cacheInvalidator, cacheTTL := getTableCacheSettings()
 	return TableHelper{
+		destinationId:       destinationId,
 		coordinationService: coordination.DummyCoordinationService{},
-		tablesCache:         map[string]*Table{},
+		tablesCache:         map[string]*cachedTable{},
+		cacheInvalidator:    cacheInvalidator,
+		cacheTTL:            cacheTTL,
 
 		maxColumns: 1000,
 
@@ -217,7 +225,11 @@ func (th *TableHelper) patchTableWithLock(ctx context.Context, sqlAdapter SQLAda
 		dbSchema.PKFields = utils.Set[string]{}
 	}
 
-	th.updateCached(dbSchema.Name, dbSchema)
+	//tmp tables are visible only to transaction that created them
+	if !dataSchema.Temporary {
+		th.updateCached(dbSchema.Name, dbSchema)
+		th.publishSchemaChange(dbSchema.Name)
+	}
 
 	return dbSchema, nil
 }
@@ -280,6 +292,7 @@ func (th *TableHelper) getOrCreate(ctx context.Context, sqlAdapter SQLAdapter, d
 		dbTableSchema.Columns = dataSchema.Columns
 		dbTableSchema.PKFields = dataSchema.PKFields
 		dbTableSchema.PrimaryKeyName = dataSchema.PrimaryKeyName
+		dbTableSchema.Partition = dataSchema.Partition
 	}
 
 	return dbTableSchema, nil
@@ -308,30 +321,92 @@ func (th *TableHelper) getTableIdentifier(destinationID, tableName string) strin
 
 func (th *TableHelper) GetCached(tableName string) (*Table, bool) {
 	th.RLock()
-	dbSchema, ok := th.tablesCache[tableName]
+	cached, ok := th.tablesCache[tableName]
 	th.RUnlock()
 
-	if ok {
-		return dbSchema.Clone(), true
+	if !ok {
+		TableCacheRequests(th.destinationId, "miss").Inc()
+		return nil, false
 	}
-	return nil, false
+	if th.cacheTTL > 0 && time.Since(cached.cachedAt) > th.cacheTTL {
+		TableCacheRequests(th.destinationId, "expired").Inc()
+		th.removeCached(tableName)
+		return nil, false
+	}
+	TableCacheRequests(th.destinationId, "hit").Inc()
+	return cached.table.Clone(), true
 }
 
 func (th *TableHelper) updateCached(tableName string, dbSchema *Table) {
 	th.Lock()
+	defer th.Unlock()
+	if th.unsubscribe == nil {
+		//subscribe lazily: TableHelper is copied by value to the adapter struct on creation
+		th.unsubscribe = th.cacheInvalidator.Subscribe(th.destinationId, th.onSchemaChange)
+	}
+	var version int64
+	if existing, ok := th.tablesCache[tableName]; ok {
+		version = existing.version
+	}
 	cloned := dbSchema.Clone()
 	cloned.Cached = true
-	th.tablesCache[tableName] = cloned
-	th.Unlock()
+	th.tablesCache[tableName] = &cachedTable{table: cloned, version: version, cachedAt: time.Now()}
 }
 
-// clearCache removes cached table schema for cache for provided table
+// clearCache removes cached table schema for provided table and notifies other bulker instances to do the same
 func (th *TableHelper) clearCache(tableName string) {
+	th.removeCached(tableName)
+	th.publishSchemaChange(tableName)
+}
+
+func (th *TableHelper) removeCached(tableName string) {
 	th.Lock()
 	delete(th.tablesCache, tableName)
 	th.Unlock()
 }
 
+// publishSchemaChange notifies other bulker instances that schema of the table was changed by this instance
+func (th *TableHelper) publishSchemaChange(tableName string) {
+	version, err := th.cacheInvalidator.NextVersion(th.destinationId, tableName)
+	if err != nil {
+		TableCacheInvalidations(th.destinationId, "error").Inc()
+		logging.Errorf("[%s] failed to publish schema change of table %s: %v", th.destinationId, tableName, err)
+		return
+	}
+	//raise version of cached schema before publishing, so notification received by this instance doesn't remove it
+	th.Lock()
+	if cached, ok := th.tablesCache[tableName]; ok && cached.version < version {
+		cached.version = version
+	}
+	th.Unlock()
+	if err = th.cacheInvalidator.Publish(th.destinationId, tableName, version); err != nil {
+		TableCacheInvalidations(th.destinationId, "error").Inc()
+		logging.Errorf("[%s] failed to publish schema change of table %s: %v", th.destinationId, tableName, err)
+		return
+	}
+	TableCacheInvalidations(th.destinationId, "local").Inc()
+}
+
+// onSchemaChange removes cached table schema if it is older than the version changed by another bulker instance
+func (th *TableHelper) onSchemaChange(tableName string, version int64) {
+	th.Lock()
+	defer th.Unlock()
+	if cached, ok := th.tablesCache[tableName]; ok && cached.version < version {
+		delete(th.tablesCache, tableName)
+		TableCacheInvalidations(th.destinationId, "remote").Inc()
+	}
+}
+
+// Close cancels subscription to schema changes made by other bulker instances
+func (th *TableHelper) Close() {
+	th.Lock()
+	defer th.Unlock()
+	if th.unsubscribe != nil {
+		th.unsubscribe()
+		th.unsubscribe = nil
+	}
+}
+
 // quotedColumnName adapts table name to sql identifier rules of database and quotes accordingly (if needed)
 func (th *TableHelper) quotedTableName(tableName string) string {
 	quoted, _ := th.adaptTableName(tableName)
@@ -367,7 +442,12 @@ func (th *TableHelper) adaptSqlIdentifier(identifier string, kind string, idFunc
 	}
 
 	if useQuoting {
-		return fmt.Sprintf(`%c%s%c`, th.identifierQuoteChar, result, th.identifierQuoteChar), result
+		closingQuoteChar := th.identifierQuoteChar
+		if closingQuoteChar == '[' {
+			//SQL Server style: [identifier]
+			closingQuoteChar = ']'
+		}
+		return fmt.Sprintf(`%c%s%c`, th.identifierQuoteChar, result, closingQuoteChar), result
 	} else {
 		return result, result
 	}
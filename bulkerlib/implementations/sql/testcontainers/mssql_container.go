@@ -0,0 +1,107 @@
+package testcontainers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jitsucom/bulker/jitsubase/logging"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	_ "github.com/microsoft/go-mssqldb"
+	"github.com/testcontainers/testcontainers-go"
+	tcWait "github.com/testcontainers/testcontainers-go/wait"
+	"time"
+)
+
+const (
+	mssqlUser     = "sa"
+	mssqlPassword = "Test_Passw0rd"
+	mssqlDatabase = "test_database"
+)
+
+// MSSQLContainer is a Microsoft SQL Server testcontainer
+type MSSQLContainer struct {
+	Container testcontainers.Container
+	Context   context.Context
+	Host      string
+	Port      int
+	Database  string
+	Username  string
+	Password  string
+}
+
+// NewMSSQLContainer creates new SQL Server test container and test database
+func NewMSSQLContainer(ctx context.Context) (*MSSQLContainer, error) {
+	exposedPort := fmt.Sprintf("%d:%d", utils.GetPort(), 1433)
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "mcr.microsoft.com/mssql/server:2022-latest",
+			ExposedPorts: []string{exposedPort},
+			Env:          map[string]string{"ACCEPT_EULA": "Y", "MSSQL_SA_PASSWORD": mssqlPassword},
+			WaitingFor:   tcWait.ForLog("SQL Server is now ready for client connections").WithStartupTimeout(time.Second * 180),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		container.Terminate(ctx)
+		return nil, err
+	}
+
+	port, err := container.MappedPort(ctx, "1433")
+	if err != nil {
+		container.Terminate(ctx)
+		return nil, err
+	}
+
+	//SQL Server image doesn't support creating database on startup
+	connectionString := fmt.Sprintf("sqlserver://%s:%s@%s:%d?encrypt=disable", mssqlUser, mssqlPassword, host, port.Int())
+	dataSource, err := sql.Open("sqlserver", connectionString)
+	if err != nil {
+		container.Terminate(ctx)
+		return nil, err
+	}
+	defer func() {
+		_ = dataSource.Close()
+	}()
+	//server may not accept logins right after the log message
+	for i := 0; i < 30; i++ {
+		if err = dataSource.PingContext(ctx); err == nil {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+	if err == nil {
+		_, err = dataSource.ExecContext(ctx, "CREATE DATABASE "+mssqlDatabase)
+	}
+	if err != nil {
+		container.Terminate(ctx)
+		return nil, fmt.Errorf("failed to create test database: %v", err)
+	}
+
+	return &MSSQLContainer{
+		Container: container,
+		Context:   ctx,
+		Host:      host,
+		Port:      port.Int(),
+		Database:  mssqlDatabase,
+		Username:  mssqlUser,
+		Password:  mssqlPassword,
+	}, nil
+}
+
+// Close terminates underlying docker container
+func (mc *MSSQLContainer) Close() error {
+	if mc.Container != nil {
+		err := mc.Container.Terminate(mc.Context)
+		if err != nil {
+			logging.Errorf("Failed to stop SQL Server container: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -20,6 +20,8 @@ const (
 	mySQLDatabase     = "test_database"
 
 	envMySQLPortVariable = "MYSQL_TEST_PORT"
+	//port of db with local_infile disabled
+	envMySQLNoInfilePortVariable = "MYSQL_NO_INFILE_TEST_PORT"
 )
 
 // MySQLContainer is a MySQL testcontainer
@@ -35,11 +37,18 @@ type MySQLContainer struct {
 	Password  string
 }
 
-// NewMySQLContainer creates new MySQL test container if MYSQL_TEST_PORT is not defined. Otherwise uses db at defined port.
-// This logic is required for running test at CI environment
-func NewMySQLContainer(ctx context.Context) (*MySQLContainer, error) {
-	if os.Getenv(envMySQLPortVariable) != "" {
-		port, err := strconv.Atoi(os.Getenv(envMySQLPortVariable))
+// NewMySQLContainer creates new MySQL test container if MYSQL_TEST_PORT (MYSQL_NO_INFILE_TEST_PORT for localInfile=false) is not defined.
+// Otherwise uses db at defined port. This logic is required for running test at CI environment
+// localInfile controls whether server allows LOAD DATA LOCAL INFILE statements
+func NewMySQLContainer(ctx context.Context, localInfile bool) (*MySQLContainer, error) {
+	envPortVariable := envMySQLPortVariable
+	localInfileFlag := "--local-infile=1"
+	if !localInfile {
+		envPortVariable = envMySQLNoInfilePortVariable
+		localInfileFlag = "--local-infile=0"
+	}
+	if os.Getenv(envPortVariable) != "" {
+		port, err := strconv.Atoi(os.Getenv(envPortVariable))
 		if err != nil {
 			return nil, err
 		}
@@ -75,6 +84,7 @@ func NewMySQLContainer(ctx context.Context) (*MySQLContainer, error) {
 			Image:        "mysql/mysql-server:8.0",
 			ExposedPorts: []string{exposedPort},
 			Env:          dbSettings,
+			Cmd:          []string{localInfileFlag},
 			WaitingFor:   tcWait.ForLog("port: 3306  MySQL Community Server - GPL").WithStartupTimeout(time.Second * 180),
 		},
 		Started: true,
@@ -11,8 +11,10 @@ import (
 // TxWrapper is sql transaction wrapper. Used for handling and log errors with db type (postgres, mySQL, redshift or snowflake)
 // on Commit() and Rollback() calls
 type TxWrapper struct {
-	dbType       string
-	db           *sql.DB
+	dbType string
+	db     *sql.DB
+	// conn dedicated connection of transaction. Set only for transactions opened with NewConnTxWrapper
+	conn         *sql.Conn
 	tx           *sql.Tx
 	queryLogger  *logging.QueryLogger
 	errorAdapter ErrorAdapter
@@ -33,6 +35,12 @@ func NewTxWrapper(dbType string, tx *sql.Tx, queryLogger *logging.QueryLogger, e
 	return &TxWrapper{dbType: dbType, tx: tx, queryLogger: queryLogger, errorAdapter: errorAdapter}
 }
 
+// NewConnTxWrapper wraps transaction opened on dedicated connection. Connection is released on Commit() or Rollback().
+// Allows access to driver connection of transaction with Raw method
+func NewConnTxWrapper(dbType string, conn *sql.Conn, tx *sql.Tx, queryLogger *logging.QueryLogger, errorAdapter ErrorAdapter) *TxWrapper {
+	return &TxWrapper{dbType: dbType, conn: conn, tx: tx, queryLogger: queryLogger, errorAdapter: errorAdapter}
+}
+
 func NewDbWrapper(dbType string, db *sql.DB, queryLogger *logging.QueryLogger, errorAdapter ErrorAdapter) *TxWrapper {
 	return &TxWrapper{dbType: dbType, db: db, queryLogger: queryLogger, errorAdapter: errorAdapter}
 }
@@ -160,9 +168,24 @@ func (t *TxWrapper) Prepare(query string) (*sql.Stmt, error) {
 	}, query)
 }
 
+// Raw executes f with underlying driver connection of transaction.
+// Only transactions opened with NewConnTxWrapper have dedicated connection.
+// Pool connections aren't supported: statements like Postgres COPY are only allowed inside transaction
+func (t *TxWrapper) Raw(f func(driverConn any) error) error {
+	if t.conn == nil {
+		return fmt.Errorf("driver connection is available only for transaction opened with dedicated connection")
+	}
+	err := t.conn.Raw(f)
+	if t.errorAdapter != nil {
+		err = t.errorAdapter(err)
+	}
+	return err
+}
+
 // Commit commits underlying transaction and returns err if occurred
 func (t *TxWrapper) Commit() error {
 	if t.tx != nil {
+		defer t.releaseConn()
 		if err := t.tx.Commit(); err != nil {
 			if t.errorAdapter != nil {
 				err = t.errorAdapter(err)
@@ -176,6 +199,7 @@ func (t *TxWrapper) Commit() error {
 // Rollback cancels underlying transaction and logs system err if occurred
 func (t *TxWrapper) Rollback() error {
 	if t.tx != nil {
+		defer t.releaseConn()
 		if err := t.tx.Rollback(); err != nil {
 			//TODO: uncomment?
 			//if !(t.dbType == "MySQL" && (strings.HasSuffix(err.Error(), mysql.ErrInvalidConn.Error()) || strings.HasSuffix(err.Error(), "bad connection"))) {
@@ -192,3 +216,10 @@ func (t *TxWrapper) Rollback() error {
 	}
 	return nil
 }
+
+// releaseConn returns dedicated connection of transaction to the pool
+func (t *TxWrapper) releaseConn() {
+	if t.conn != nil {
+		_ = t.conn.Close()
+	}
+}
@@ -91,7 +91,7 @@ func TestTypesMappingAndCollision(t *testing.T) {
 				{"id": 1, "int_1": 1, "roundfloat": 1.0, "float1": 1.2, "intstring": "1", "roundfloatstring": "1.0", "floatstring": "1.1", "string1": "test", "bool1": false, "bool2": true, "time1": constantTime, "time2": constantTime, "time3": "2022-08-18", "_unmapped_data": nil},
 				{"id": 2, "int_1": nil, "roundfloat": 1.0, "float1": 1.0, "intstring": "1.1", "roundfloatstring": "1.1", "floatstring": "1.0", "string1": "test", "bool1": false, "bool2": true, "time1": constantTime, "time2": constantTime, "time3": "2022-08-18", "_unmapped_data": "{\"int_1\":\"a\"}"},
 			},
-			configIds: utils.ArrayIntersection(allBulkerConfigs, []string{RedshiftBulkerTypeId}),
+			configIds: utils.ArrayIntersection(allBulkerConfigs, []string{RedshiftBulkerTypeId, MSSQLBulkerTypeId}),
 		},
 		{
 			name:              "types_collision_stream",
@@ -183,6 +183,43 @@ func TestSQLTypeHints(t *testing.T) {
 			streamOptions: []bulker.StreamOption{WithColumnTypes(types2.SQLTypes{}.
 				With("nested_json4", "json"))},
 			configIds: utils.ArrayIntersection(allBulkerConfigs, []string{PostgresBulkerTypeId}),
+		}, {
+			name:                      "jsonb_columns_all_postgres",
+			modes:                     []bulker.BulkMode{bulker.Batch, bulker.Stream},
+			dataFile:                  "test_data/jsonb_columns.ndjson",
+			expectedTableTypeChecking: TypeCheckingSQLTypesOnly,
+			expectedTable: ExpectedTable{
+				Columns: Columns{
+					"id":      {Type: "bigint"},
+					"context": {Type: "jsonb"},
+					"tags":    {Type: "jsonb"},
+					"props":   {Type: "jsonb"},
+				},
+			},
+			expectedRows: []map[string]any{
+				{"id": 1, "context": "{\"ip\": \"1.1.1.1\", \"page\": {\"url\": \"https://jitsu.com\"}}", "tags": "[\"a\", \"b\"]", "props": "{\"a\": 1}"},
+			},
+			streamOptions: []bulker.StreamOption{WithJsonbColumns("*")},
+			configIds:     utils.ArrayIntersection(allBulkerConfigs, []string{PostgresBulkerTypeId}),
+		}, {
+			name:                      "jsonb_columns_selected_postgres",
+			modes:                     []bulker.BulkMode{bulker.Batch, bulker.Stream},
+			dataFile:                  "test_data/jsonb_columns.ndjson",
+			expectedTableTypeChecking: TypeCheckingSQLTypesOnly,
+			expectedTable: ExpectedTable{
+				Columns: Columns{
+					"id":           {Type: "bigint"},
+					"context_ip":   {Type: "text"},
+					"context_page": {Type: "jsonb"},
+					"tags":         {Type: "jsonb"},
+					"props_a":      {Type: "bigint"},
+				},
+			},
+			expectedRows: []map[string]any{
+				{"id": 1, "context_ip": "1.1.1.1", "context_page": "{\"url\": \"https://jitsu.com\"}", "tags": "[\"a\", \"b\"]", "props_a": 1},
+			},
+			streamOptions: []bulker.StreamOption{WithJsonbColumns("context_page", "tags")},
+			configIds:     utils.ArrayIntersection(allBulkerConfigs, []string{PostgresBulkerTypeId}),
 		}, {
 			name:                      "sql_types_hints_bigquery",
 			modes:                     []bulker.BulkMode{bulker.Batch},
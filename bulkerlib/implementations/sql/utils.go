@@ -23,6 +23,9 @@ func (s *ColumnScanner) Scan(src any) error {
 		if s.ColumnType.DatabaseTypeName() == "TINYINT" && (v == 1 || v == 0) {
 			//hack for mysql where boolean is represented as tinyint(1)
 			s.value = v == 1
+		} else if s.ColumnType.DatabaseTypeName() == "BOOLEAN" && (v == 1 || v == 0) {
+			//hack for SQLite where boolean is stored as integer
+			s.value = v == 1
 		} else {
 			s.value = int(v)
 		}
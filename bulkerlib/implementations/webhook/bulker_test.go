@@ -0,0 +1,265 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	"github.com/jitsucom/bulker/bulkerlib/types"
+	"github.com/jitsucom/bulker/jitsubase/errorj"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testHMACSecret = "test_secret"
+
+type webhookTestConfig struct {
+	//name of the test
+	name string
+	//bulker stream mode
+	mode bulker.BulkMode
+	//destination config. url is set automatically
+	config map[string]any
+	//response codes returned by test server for consecutive requests. 200 when list is exhausted
+	responseCodes []int
+	//test server handling delay of every request
+	responseDelay time.Duration
+	//objects to consume
+	objects []types.Object
+	//expected requests received by test server including retried ones
+	expectedRequests []webhookTestRequest
+	//expected number of requests in stream state. Retries are not counted. Default: len(expectedRequests)
+	expectedStateRequests int
+	//error expected from Consume or Complete call. String is used for error message partial matching
+	expectedError string
+	//whether expected error is non retryable
+	expectedNonRetryable bool
+}
+
+type webhookTestRequest struct {
+	contentType string
+	body        string
+	headers     map[string]string
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func TestWebhook(t *testing.T) {
+	objects := []types.Object{
+		{"id": 1, "event": "page"},
+		{"id": 2, "event": "track"},
+		{"id": 3, "event": "identify"},
+	}
+	tests := []webhookTestConfig{
+		{
+			name: "stream",
+			mode: bulker.Stream,
+			config: map[string]any{
+				"headers":    map[string]any{"X-Table": "{{.Table}}", "X-Event": "{{.Object.event}}", "X-Count": "{{.Count}}"},
+				"hmacSecret": testHMACSecret,
+			},
+			objects: objects,
+			expectedRequests: []webhookTestRequest{
+				{contentType: contentTypeJSON, body: `{"event":"page","id":1}`, headers: map[string]string{"X-Table": "stream", "X-Event": "page", "X-Count": "1"}},
+				{contentType: contentTypeJSON, body: `{"event":"track","id":2}`, headers: map[string]string{"X-Table": "stream", "X-Event": "track", "X-Count": "1"}},
+				{contentType: contentTypeJSON, body: `{"event":"identify","id":3}`, headers: map[string]string{"X-Table": "stream", "X-Event": "identify", "X-Count": "1"}},
+			},
+		},
+		{
+			name: "batch_ndjson",
+			mode: bulker.Batch,
+			config: map[string]any{
+				"chunkSize":  2,
+				"headers":    map[string]any{"X-Count": "{{.Count}}"},
+				"hmacSecret": testHMACSecret,
+			},
+			objects: objects,
+			expectedRequests: []webhookTestRequest{
+				{contentType: contentTypeNDJSON, body: "{\"event\":\"page\",\"id\":1}\n{\"event\":\"track\",\"id\":2}\n", headers: map[string]string{"X-Count": "2"}},
+				{contentType: contentTypeNDJSON, body: "{\"event\":\"identify\",\"id\":3}\n", headers: map[string]string{"X-Count": "1"}},
+			},
+		},
+		{
+			name: "batch_json_array",
+			mode: bulker.Batch,
+			config: map[string]any{
+				"format": FormatJSONArray,
+			},
+			objects: objects,
+			expectedRequests: []webhookTestRequest{
+				{contentType: contentTypeJSON, body: `[{"event":"page","id":1},{"event":"track","id":2},{"event":"identify","id":3}]`},
+			},
+		},
+		{
+			name: "retry_server_error",
+			mode: bulker.Stream,
+			config: map[string]any{
+				"retryBackoffMs": 10,
+			},
+			responseCodes:         []int{http.StatusServiceUnavailable, http.StatusTooManyRequests},
+			objects:               objects[:1],
+			expectedStateRequests: 1,
+			expectedRequests: []webhookTestRequest{
+				{contentType: contentTypeJSON, body: `{"event":"page","id":1}`},
+				{contentType: contentTypeJSON, body: `{"event":"page","id":1}`},
+				{contentType: contentTypeJSON, body: `{"event":"page","id":1}`},
+			},
+		},
+		{
+			name: "retries_exhausted",
+			mode: bulker.Batch,
+			config: map[string]any{
+				"maxRetries":     1,
+				"retryBackoffMs": 10,
+			},
+			responseCodes: []int{http.StatusInternalServerError, http.StatusBadGateway},
+			objects:       objects[:1],
+			expectedRequests: []webhookTestRequest{
+				{contentType: contentTypeNDJSON, body: "{\"event\":\"page\",\"id\":1}\n"},
+				{contentType: contentTypeNDJSON, body: "{\"event\":\"page\",\"id\":1}\n"},
+			},
+			expectedError: "response code: 502",
+		},
+		{
+			name:          "client_error",
+			mode:          bulker.Stream,
+			config:        map[string]any{},
+			responseCodes: []int{http.StatusBadRequest},
+			objects:       objects[:1],
+			expectedRequests: []webhookTestRequest{
+				{contentType: contentTypeJSON, body: `{"event":"page","id":1}`},
+			},
+			expectedError:        "response code: 400",
+			expectedNonRetryable: true,
+		},
+		{
+			name: "timeout",
+			mode: bulker.Stream,
+			config: map[string]any{
+				"timeoutMs":  50,
+				"maxRetries": -1,
+			},
+			responseDelay: 500 * time.Millisecond,
+			objects:       objects[:1],
+			expectedRequests: []webhookTestRequest{
+				{contentType: contentTypeJSON, body: `{"event":"page","id":1}`},
+			},
+			expectedError: "failed to send request",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			testWebhookStream(t, tt)
+		})
+	}
+}
+
+func testWebhookStream(t *testing.T, tt webhookTestConfig) {
+	reqr := require.New(t)
+	var mutex sync.Mutex
+	var requests []receivedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		requests = append(requests, receivedRequest{header: r.Header, body: body})
+		i := len(requests) - 1
+		mutex.Unlock()
+		time.Sleep(tt.responseDelay)
+		if i < len(tt.responseCodes) {
+			w.WriteHeader(tt.responseCodes[i])
+			_, _ = w.Write([]byte("test error"))
+		}
+	}))
+	defer server.Close()
+
+	config := utils.MapPutAll(map[string]any{"url": server.URL + "/hook?token=secret"}, tt.config)
+	blk, err := bulker.CreateBulker(bulker.Config{Id: tt.name, BulkerType: WebhookBulkerTypeId, DestinationConfig: config})
+	reqr.NoError(err)
+	defer func() {
+		_ = blk.Close()
+	}()
+	ctx := context.Background()
+	stream, err := blk.CreateStream(tt.name, tt.name, tt.mode)
+	reqr.NoError(err)
+	for _, object := range tt.objects {
+		if _, _, err = stream.Consume(ctx, object); err != nil {
+			break
+		}
+	}
+	var state bulker.State
+	if err == nil {
+		state, err = stream.Complete(ctx)
+	} else {
+		state, _ = stream.Abort(ctx)
+	}
+	if tt.expectedError != "" {
+		reqr.ErrorContains(err, tt.expectedError)
+		reqr.Equal(tt.expectedNonRetryable, errorj.IsNonRetryableError(err))
+	} else {
+		reqr.NoError(err)
+		reqr.Equal(bulker.Completed, state.Status)
+		reqr.Equal(RepresentationRequests{URL: server.URL + "/hook", Requests: utils.Nvl(tt.expectedStateRequests, len(tt.expectedRequests))}, state.Representation)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	reqr.Len(requests, len(tt.expectedRequests))
+	for i, expected := range tt.expectedRequests {
+		request := requests[i]
+		//map keys order is not guaranteed. Compare JSON values
+		if expected.contentType == contentTypeNDJSON {
+			expectedLines := strings.Split(expected.body, "\n")
+			lines := strings.Split(string(request.body), "\n")
+			reqr.Len(lines, len(expectedLines), "lines of request %d", i)
+			for j := range expectedLines {
+				if expectedLines[j] == "" {
+					reqr.Empty(lines[j])
+				} else {
+					reqr.JSONEq(expectedLines[j], lines[j], "line %d of request %d", j, i)
+				}
+			}
+		} else {
+			reqr.JSONEq(expected.body, string(request.body), "body of request %d", i)
+		}
+		reqr.Equal(expected.contentType, request.header.Get("Content-Type"), "content type of request %d", i)
+		for name, value := range expected.headers {
+			reqr.Equal(value, request.header.Get(name), "header %s of request %d", name, i)
+		}
+		if tt.config["hmacSecret"] != nil {
+			mac := hmac.New(sha256.New, []byte(testHMACSecret))
+			mac.Write(request.body)
+			reqr.Equal("sha256="+hex.EncodeToString(mac.Sum(nil)), request.header.Get("X-Signature"), "signature of request %d", i)
+		} else {
+			reqr.Empty(request.header.Get("X-Signature"))
+		}
+	}
+}
+
+func TestWebhookConfig(t *testing.T) {
+	reqr := require.New(t)
+	_, err := NewWebhookBulker(bulker.Config{BulkerType: WebhookBulkerTypeId, DestinationConfig: map[string]any{"url": "ftp://example.com"}})
+	reqr.ErrorContains(err, "invalid webhook url")
+	_, err = NewWebhookBulker(bulker.Config{BulkerType: WebhookBulkerTypeId, DestinationConfig: map[string]any{"url": "https://example.com", "format": "csv"}})
+	reqr.ErrorContains(err, "unsupported webhook format")
+	_, err = NewWebhookBulker(bulker.Config{BulkerType: WebhookBulkerTypeId, DestinationConfig: map[string]any{"url": "https://example.com", "headers": map[string]any{"X-Test": "{{.Table"}}})
+	reqr.ErrorContains(err, "failed to parse template of header X-Test")
+	blk, err := NewWebhookBulker(bulker.Config{BulkerType: WebhookBulkerTypeId, DestinationConfig: map[string]any{"url": "https://example.com"}})
+	reqr.NoError(err)
+	_, err = blk.CreateStream("replace", "events", bulker.ReplaceTable)
+	reqr.EqualError(err, WebhookReplaceUnsupported)
+
+	reqr.Equal(5*time.Second, parseRetryAfter("5"))
+	reqr.Equal(time.Duration(0), parseRetryAfter("soon"))
+}
@@ -0,0 +1,292 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	"github.com/jitsucom/bulker/bulkerlib/types"
+	"github.com/jitsucom/bulker/jitsubase/errorj"
+	"github.com/jitsucom/bulker/jitsubase/utils"
+	"github.com/joomcode/errorx"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const WebhookBulkerTypeId = "webhook"
+
+const WebhookReplaceUnsupported = "Webhook destination doesn't support replace modes. Please use 'stream' or 'batch' mode"
+
+const (
+	FormatNDJSON    = "ndjson"
+	FormatJSONArray = "json_array"
+
+	defaultChunkSize      = 1000
+	defaultTimeoutMs      = 10_000
+	defaultMaxRetries     = 3
+	defaultRetryBackoffMs = 1000
+	// maxRetryBackoff max delay between retries including one requested by Retry-After response header
+	maxRetryBackoff = time.Minute
+	// maxErrorBodySize max number of bytes of response body included in error message
+	maxErrorBodySize = 1024
+)
+
+var (
+	// defaultRetryStatusCodes response codes that are retried by default. Requests failed with connection errors or timeouts are always retried
+	defaultRetryStatusCodes = []int{http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	hmacAlgorithms = map[string]func() hash.Hash{
+		"sha1":   sha1.New,
+		"sha256": sha256.New,
+		"sha512": sha512.New,
+	}
+)
+
+func init() {
+	bulker.RegisterBulker(WebhookBulkerTypeId, NewWebhookBulker)
+}
+
+// WebhookConfig is a dto for config deserialization
+type WebhookConfig struct {
+	URL string `mapstructure:"url,omitempty" json:"url,omitempty" yaml:"url,omitempty"`
+	// Method HTTP method. Default: POST
+	Method string `mapstructure:"method,omitempty" json:"method,omitempty" yaml:"method,omitempty"`
+	// Headers request headers. Values are Go text/template templates. See headerTemplateData for available fields
+	Headers map[string]string `mapstructure:"headers,omitempty" json:"headers,omitempty" yaml:"headers,omitempty"`
+	// Format of batch request body: ndjson or json_array. Default: ndjson
+	Format string `mapstructure:"format,omitempty" json:"format,omitempty" yaml:"format,omitempty"`
+	// ChunkSize max number of objects in a single batch request. Default: 1000
+	ChunkSize int `mapstructure:"chunkSize,omitempty" json:"chunkSize,omitempty" yaml:"chunkSize,omitempty"`
+	// TimeoutMs timeout of a single request attempt in milliseconds. Default: 10000
+	TimeoutMs int `mapstructure:"timeoutMs,omitempty" json:"timeoutMs,omitempty" yaml:"timeoutMs,omitempty"`
+	// MaxRetries max number of retries of failed request. Default: 3. Negative value disables retries
+	MaxRetries int `mapstructure:"maxRetries,omitempty" json:"maxRetries,omitempty" yaml:"maxRetries,omitempty"`
+	// RetryBackoffMs delay before the first retry in milliseconds. Doubled for every next retry. Default: 1000
+	RetryBackoffMs int `mapstructure:"retryBackoffMs,omitempty" json:"retryBackoffMs,omitempty" yaml:"retryBackoffMs,omitempty"`
+	// RetryStatusCodes response codes to retry. Default: 408, 429, 500, 502, 503, 504
+	RetryStatusCodes []int `mapstructure:"retryStatusCodes,omitempty" json:"retryStatusCodes,omitempty" yaml:"retryStatusCodes,omitempty"`
+	// HMACSecret when set request body is signed with HMAC. Signature is sent in HMACHeader as <algorithm>=<hex digest>
+	HMACSecret string `mapstructure:"hmacSecret,omitempty" json:"hmacSecret,omitempty" yaml:"hmacSecret,omitempty"`
+	// HMACHeader name of signature header. Default: X-Signature
+	HMACHeader string `mapstructure:"hmacHeader,omitempty" json:"hmacHeader,omitempty" yaml:"hmacHeader,omitempty"`
+	// HMACAlgorithm sha1, sha256 or sha512. Default: sha256
+	HMACAlgorithm string `mapstructure:"hmacAlgorithm,omitempty" json:"hmacAlgorithm,omitempty" yaml:"hmacAlgorithm,omitempty"`
+}
+
+// Validate returns err if invalid
+func (wc *WebhookConfig) Validate() error {
+	if wc == nil {
+		return errors.New("Webhook config is required")
+	}
+	if wc.URL == "" {
+		return errors.New("Webhook url is required parameter")
+	}
+	if u, err := url.Parse(wc.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid webhook url: %s", wc.URL)
+	}
+	if wc.Format != FormatNDJSON && wc.Format != FormatJSONArray {
+		return fmt.Errorf("unsupported webhook format: %s. Supported: %s, %s", wc.Format, FormatNDJSON, FormatJSONArray)
+	}
+	if _, ok := hmacAlgorithms[wc.HMACAlgorithm]; !ok {
+		return fmt.Errorf("unsupported HMAC algorithm: %s. Supported: sha1, sha256, sha512", wc.HMACAlgorithm)
+	}
+	if wc.ChunkSize < 0 || wc.TimeoutMs < 0 || wc.RetryBackoffMs < 0 {
+		return errors.New("chunkSize, timeoutMs and retryBackoffMs must not be negative")
+	}
+	return nil
+}
+
+func (wc *WebhookConfig) setDefaults() {
+	wc.Method = strings.ToUpper(utils.NvlString(wc.Method, http.MethodPost))
+	wc.Format = utils.NvlString(wc.Format, FormatNDJSON)
+	wc.HMACHeader = utils.NvlString(wc.HMACHeader, "X-Signature")
+	wc.HMACAlgorithm = utils.NvlString(wc.HMACAlgorithm, "sha256")
+	if wc.ChunkSize == 0 {
+		wc.ChunkSize = defaultChunkSize
+	}
+	if wc.TimeoutMs == 0 {
+		wc.TimeoutMs = defaultTimeoutMs
+	}
+	if wc.MaxRetries == 0 {
+		wc.MaxRetries = defaultMaxRetries
+	} else if wc.MaxRetries < 0 {
+		wc.MaxRetries = 0
+	}
+	if wc.RetryBackoffMs == 0 {
+		wc.RetryBackoffMs = defaultRetryBackoffMs
+	}
+	if len(wc.RetryStatusCodes) == 0 {
+		wc.RetryStatusCodes = defaultRetryStatusCodes
+	}
+}
+
+// headerTemplateData data available in header templates e.g. "{{.Table}}" or "{{.Object.event_type}}"
+type headerTemplateData struct {
+	// Table name of the table stream was created for
+	Table string
+	// Mode bulk mode of the stream
+	Mode bulker.BulkMode
+	// Count number of objects in request body
+	Count int
+	// Object sent object. Only in stream mode
+	Object types.Object
+}
+
+// WebhookBulker sends objects to HTTP endpoint.
+// Stream mode sends each object in a separate request. Batch mode sends objects on Complete in chunks of ChunkSize objects
+type WebhookBulker struct {
+	config *WebhookConfig
+	// displayURL url without credentials and query string that may contain secrets. For errors and stream state
+	displayURL      string
+	headerTemplates map[string]*template.Template
+	httpClient      *http.Client
+}
+
+func NewWebhookBulker(bulkerConfig bulker.Config) (bulker.Bulker, error) {
+	webhookConfig := &WebhookConfig{}
+	if err := utils.ParseObject(bulkerConfig.DestinationConfig, webhookConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse destination config: %v", err)
+	}
+	webhookConfig.setDefaults()
+	if err := webhookConfig.Validate(); err != nil {
+		return nil, err
+	}
+	headerTemplates := make(map[string]*template.Template, len(webhookConfig.Headers))
+	for name, value := range webhookConfig.Headers {
+		tmpl, err := template.New(name).Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template of header %s: %v", name, err)
+		}
+		headerTemplates[name] = tmpl
+	}
+	u, _ := url.Parse(webhookConfig.URL)
+	return &WebhookBulker{
+		config:          webhookConfig,
+		displayURL:      u.Scheme + "://" + u.Host + u.Path,
+		headerTemplates: headerTemplates,
+		httpClient:      &http.Client{Timeout: time.Duration(webhookConfig.TimeoutMs) * time.Millisecond},
+	}, nil
+}
+
+func (wb *WebhookBulker) CreateStream(id, tableName string, mode bulker.BulkMode, streamOptions ...bulker.StreamOption) (bulker.BulkerStream, error) {
+	switch mode {
+	case bulker.Stream, bulker.Batch:
+		return newWebhookStream(id, wb, tableName, mode, streamOptions...)
+	case bulker.ReplaceTable, bulker.ReplacePartition:
+		return nil, errors.New(WebhookReplaceUnsupported)
+	}
+	return nil, fmt.Errorf("unsupported bulk mode: %s", mode)
+}
+
+// send sends request with provided body. Retries request on connection errors and on response codes from RetryStatusCodes list
+func (wb *WebhookBulker) send(ctx context.Context, body []byte, contentType string, data headerTemplateData) error {
+	headers, err := wb.headers(body, contentType, data)
+	if err != nil {
+		return err
+	}
+	errorPayload := &types.ErrorPayload{Table: data.Table, Statement: wb.config.Method + " " + wb.displayURL, TotalObjects: data.Count}
+	backoff := time.Duration(wb.config.RetryBackoffMs) * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := wb.sendOnce(ctx, body, headers)
+		if err == nil {
+			return nil
+		}
+		if errorj.IsNonRetryableError(err) || attempt >= wb.config.MaxRetries {
+			return err.WithProperty(errorj.DBInfo, errorPayload)
+		}
+		delay := backoff
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		if delay > maxRetryBackoff {
+			delay = maxRetryBackoff
+		}
+		select {
+		case <-ctx.Done():
+			return errorj.HTTPRequestError.Wrap(ctx.Err(), "request was cancelled").
+				WithProperty(errorj.DBInfo, errorPayload)
+		case <-time.After(delay):
+		}
+		backoff *= 2
+	}
+}
+
+// sendOnce makes single request attempt. Returns delay requested by Retry-After header of response if any
+func (wb *WebhookBulker) sendOnce(ctx context.Context, body []byte, headers http.Header) (time.Duration, *errorx.Error) {
+	req, reqErr := http.NewRequestWithContext(ctx, wb.config.Method, wb.config.URL, bytes.NewReader(body))
+	if reqErr != nil {
+		return 0, errorj.HTTPRequestError.Wrap(reqErr, "failed to create request")
+	}
+	req.Header = headers.Clone()
+	resp, reqErr := wb.httpClient.Do(req)
+	if reqErr != nil {
+		return 0, errorj.HTTPRequestError.Wrap(reqErr, "failed to send request")
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	//read the rest of the body to let client reuse connection
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return 0, nil
+	}
+	respErr := fmt.Errorf("response code: %d body: %s", resp.StatusCode, respBody)
+	if utils.ArrayContains(wb.config.RetryStatusCodes, resp.StatusCode) {
+		return parseRetryAfter(resp.Header.Get("Retry-After")), errorj.HTTPServerError.Wrap(respErr, "request failed")
+	}
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+		return 0, errorj.HTTPClientError.Wrap(respErr, "request was rejected")
+	}
+	return 0, errorj.HTTPServerError.Wrap(respErr, "request failed")
+}
+
+// headers returns request headers with rendered templates and HMAC signature of the body
+func (wb *WebhookBulker) headers(body []byte, contentType string, data headerTemplateData) (http.Header, error) {
+	headers := http.Header{}
+	headers.Set("Content-Type", contentType)
+	for name, tmpl := range wb.headerTemplates {
+		var sb strings.Builder
+		if err := tmpl.Execute(&sb, data); err != nil {
+			return nil, fmt.Errorf("failed to render template of header %s: %v", name, err)
+		}
+		headers.Set(name, sb.String())
+	}
+	if wb.config.HMACSecret != "" {
+		mac := hmac.New(hmacAlgorithms[wb.config.HMACAlgorithm], []byte(wb.config.HMACSecret))
+		mac.Write(body)
+		headers.Set(wb.config.HMACHeader, wb.config.HMACAlgorithm+"="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	return headers, nil
+}
+
+// parseRetryAfter parses Retry-After header value in seconds or in HTTP date format
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+func (wb *WebhookBulker) Close() error {
+	wb.httpClient.CloseIdleConnections()
+	return nil
+}
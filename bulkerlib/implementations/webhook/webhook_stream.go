@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	bulker "github.com/jitsucom/bulker/bulkerlib"
+	"github.com/jitsucom/bulker/bulkerlib/types"
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	contentTypeJSON   = "application/json"
+	contentTypeNDJSON = "application/x-ndjson"
+)
+
+// RepresentationRequests requests sent by stream
+type RepresentationRequests struct {
+	URL      string `json:"url"`
+	Requests int    `json:"requests"`
+}
+
+// WebhookStream sends consumed objects to webhook.
+// In Stream mode each object is sent in a separate request on Consume.
+// In Batch mode objects are buffered and sent on Complete in chunks.
+// Delivery is at-least-once: if one of chunks fails, chunks sent before it are not recalled
+type WebhookStream struct {
+	id        string
+	mode      bulker.BulkMode
+	bulker    *WebhookBulker
+	tableName string
+	options   bulker.StreamOptions
+
+	// buffer serialized objects of Batch mode stream
+	buffer   [][]byte
+	requests int
+
+	state bulker.State
+}
+
+func newWebhookStream(id string, wb *WebhookBulker, tableName string, mode bulker.BulkMode, streamOptions ...bulker.StreamOption) (*WebhookStream, error) {
+	ps := WebhookStream{id: id, bulker: wb, tableName: tableName, mode: mode}
+	ps.options = bulker.StreamOptions{}
+	for _, option := range streamOptions {
+		ps.options.Add(option)
+	}
+	ps.state = bulker.State{Status: bulker.Active}
+	ps.updateRepresentation()
+	return &ps, nil
+}
+
+func (ps *WebhookStream) Consume(ctx context.Context, object types.Object) (state bulker.State, processedObjects []types.Object, err error) {
+	defer func() {
+		if err != nil {
+			ps.state.ErrorRowIndex = ps.state.ProcessedRows
+			ps.state.SetError(err)
+		} else {
+			ps.state.SuccessfulRows++
+		}
+		state = ps.state
+	}()
+	ps.state.ProcessedRows++
+	body, err := jsoniter.Marshal(object)
+	if err != nil {
+		err = fmt.Errorf("failed to serialize object: %v", err)
+		return
+	}
+	if ps.mode == bulker.Batch {
+		ps.buffer = append(ps.buffer, body)
+		return
+	}
+	err = ps.send(ctx, body, contentTypeJSON, headerTemplateData{Table: ps.tableName, Mode: ps.mode, Count: 1, Object: object})
+	return
+}
+
+func (ps *WebhookStream) Complete(ctx context.Context) (state bulker.State, err error) {
+	defer func() {
+		ps.buffer = nil
+		if err != nil {
+			ps.state.SetError(err)
+			ps.state.Status = bulker.Failed
+		} else {
+			ps.state.Status = bulker.Completed
+		}
+		state = ps.state
+	}()
+	if ps.mode == bulker.Stream {
+		return
+	}
+	if ps.state.LastError != nil {
+		err = ps.state.LastError
+		return
+	}
+	chunkSize := ps.bulker.config.ChunkSize
+	for start := 0; start < len(ps.buffer); start += chunkSize {
+		end := start + chunkSize
+		if end > len(ps.buffer) {
+			end = len(ps.buffer)
+		}
+		if err = ps.sendChunk(ctx, ps.buffer[start:end]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// sendChunk sends serialized objects in a single request as NDJSON or JSON array
+func (ps *WebhookStream) sendChunk(ctx context.Context, chunk [][]byte) error {
+	var body []byte
+	contentType := contentTypeNDJSON
+	if ps.bulker.config.Format == FormatJSONArray {
+		body = append([]byte{'['}, bytes.Join(chunk, []byte{','})...)
+		body = append(body, ']')
+		contentType = contentTypeJSON
+	} else {
+		body = append(bytes.Join(chunk, []byte{'\n'}), '\n')
+	}
+	return ps.send(ctx, body, contentType, headerTemplateData{Table: ps.tableName, Mode: ps.mode, Count: len(chunk)})
+}
+
+func (ps *WebhookStream) send(ctx context.Context, body []byte, contentType string, data headerTemplateData) error {
+	ps.requests++
+	ps.updateRepresentation()
+	return ps.bulker.send(ctx, body, contentType, data)
+}
+
+func (ps *WebhookStream) updateRepresentation() {
+	ps.state.Representation = RepresentationRequests{URL: ps.bulker.displayURL, Requests: ps.requests}
+}
+
+func (ps *WebhookStream) Abort(ctx context.Context) (state bulker.State, err error) {
+	ps.buffer = nil
+	ps.state.Status = bulker.Aborted
+	return ps.state, nil
+}
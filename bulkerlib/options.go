@@ -86,6 +86,19 @@ var (
 		ParseFunc: utils.ParseString,
 	}
 
+	// MaxFileSizeBytesOption - max size of local batch file in bytes. When exceeded, the file is rolled over:
+	// finished part is uploaded (or loaded into the destination) right away and the new one is started. 0 - unlimited
+	MaxFileSizeBytesOption = ImplementationOption[int]{
+		Key:       "maxFileSizeBytes",
+		ParseFunc: utils.ParseInt,
+	}
+
+	// MaxRowsPerFileOption - max number of rows in local batch file. When exceeded, the file is rolled over. 0 - unlimited
+	MaxRowsPerFileOption = ImplementationOption[int]{
+		Key:       "maxRowsPerFile",
+		ParseFunc: utils.ParseInt,
+	}
+
 	// Not used by bulker. Just added here to be treated as known options
 	FunctionsOption  = ImplementationOption[any]{Key: "functions", ParseFunc: func(serialized any) (any, error) { return nil, nil }}
 	StreamsOption    = ImplementationOption[any]{Key: "streams", ParseFunc: func(serialized any) (any, error) { return nil, nil }}
@@ -104,6 +117,8 @@ func init() {
 	RegisterOption(&MergeRowsOption)
 	RegisterOption(&PartitionIdOption)
 	RegisterOption(&TimestampOption)
+	RegisterOption(&MaxFileSizeBytesOption)
+	RegisterOption(&MaxRowsPerFileOption)
 
 	// Not used by bulker. Just added here to be treated as known options
 	RegisterOption(&FunctionsOption)
@@ -235,3 +250,25 @@ func withTimestamp(o *ImplementationOption[string], timestampField string) Strea
 func WithTimestamp(timestampField string) StreamOption {
 	return withTimestamp(&TimestampOption, timestampField)
 }
+
+func withMaxFileSizeBytes(o *ImplementationOption[int], maxFileSizeBytes int) StreamOption {
+	return func(options *StreamOptions) {
+		o.Set(options, maxFileSizeBytes)
+	}
+}
+
+// WithMaxFileSizeBytes - roll over local batch file to the new part when its size exceeds maxFileSizeBytes
+func WithMaxFileSizeBytes(maxFileSizeBytes int) StreamOption {
+	return withMaxFileSizeBytes(&MaxFileSizeBytesOption, maxFileSizeBytes)
+}
+
+func withMaxRowsPerFile(o *ImplementationOption[int], maxRowsPerFile int) StreamOption {
+	return func(options *StreamOptions) {
+		o.Set(options, maxRowsPerFile)
+	}
+}
+
+// WithMaxRowsPerFile - roll over local batch file to the new part when it contains maxRowsPerFile rows
+func WithMaxRowsPerFile(maxRowsPerFile int) StreamOption {
+	return withMaxRowsPerFile(&MaxRowsPerFileOption, maxRowsPerFile)
+}
@@ -0,0 +1,165 @@
+package types
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"io"
+	"sync"
+)
+
+// CompressionCodec compresses and decompresses streams of row based file formats (csv, ndjson)
+type CompressionCodec interface {
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	NewReader(r io.Reader) (io.ReadCloser, error)
+	// FileExtension returns extension that is added to file name e.g. ".gz"
+	FileExtension() string
+	ContentType() string
+}
+
+var (
+	compressionCodecsMutex sync.RWMutex
+	compressionCodecs      = map[FileCompression]CompressionCodec{}
+)
+
+func init() {
+	RegisterCompressionCodec(FileCompressionGZIP, gzipCodec{})
+	RegisterCompressionCodec(FileCompressionZSTD, zstdCodec{})
+	RegisterCompressionCodec(FileCompressionSNAPPY, snappyCodec{})
+	RegisterCompressionCodec(FileCompressionLZ4, lz4Codec{})
+}
+
+// RegisterCompressionCodec registers codec for compression. Replaces existing codec if any
+func RegisterCompressionCodec(compression FileCompression, codec CompressionCodec) {
+	compressionCodecsMutex.Lock()
+	defer compressionCodecsMutex.Unlock()
+	compressionCodecs[compression] = codec
+}
+
+// GetCompressionCodec returns codec registered for compression.
+// Returns nil for FileCompressionNONE, FileCompressionUNKNOWN and not registered compressions
+func GetCompressionCodec(compression FileCompression) CompressionCodec {
+	compressionCodecsMutex.RLock()
+	defer compressionCodecsMutex.RUnlock()
+	return compressionCodecs[compression]
+}
+
+// ValidateCompression returns error if there is no codec registered for compression
+func ValidateCompression(compression FileCompression) error {
+	if compression == FileCompressionNONE || compression == FileCompressionUNKNOWN || GetCompressionCodec(compression) != nil {
+		return nil
+	}
+	return fmt.Errorf("Unknown file compression: %s", compression)
+}
+
+// NewCompressionWriter returns writer that compresses data with codec registered for compression.
+// Returned writer must be closed to flush compressed data. Closing doesn't close underlying writer
+func NewCompressionWriter(compression FileCompression, w io.Writer) (io.WriteCloser, error) {
+	if err := ValidateCompression(compression); err != nil {
+		return nil, err
+	}
+	codec := GetCompressionCodec(compression)
+	if codec == nil {
+		return nopWriteCloser{w}, nil
+	}
+	return codec.NewWriter(w)
+}
+
+// NewCompressionReader returns reader that decompresses data with codec registered for compression
+func NewCompressionReader(compression FileCompression, r io.Reader) (io.ReadCloser, error) {
+	if err := ValidateCompression(compression); err != nil {
+		return nil, err
+	}
+	codec := GetCompressionCodec(compression)
+	if codec == nil {
+		return io.NopCloser(r), nil
+	}
+	return codec.NewReader(r)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+func (gzipCodec) FileExtension() string {
+	return ".gz"
+}
+
+func (gzipCodec) ContentType() string {
+	return "application/gzip"
+}
+
+type zstdCodec struct{}
+
+func (zstdCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w)
+}
+
+func (zstdCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	decoder, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return decoder.IOReadCloser(), nil
+}
+
+func (zstdCodec) FileExtension() string {
+	return ".zst"
+}
+
+func (zstdCodec) ContentType() string {
+	return "application/zstd"
+}
+
+// snappyCodec uses snappy framing format
+type snappyCodec struct{}
+
+func (snappyCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return snappy.NewBufferedWriter(w), nil
+}
+
+func (snappyCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(snappy.NewReader(r)), nil
+}
+
+func (snappyCodec) FileExtension() string {
+	return ".sz"
+}
+
+func (snappyCodec) ContentType() string {
+	return "application/x-snappy-framed"
+}
+
+type lz4Codec struct{}
+
+func (lz4Codec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return lz4.NewWriter(w), nil
+}
+
+func (lz4Codec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(lz4.NewReader(r)), nil
+}
+
+func (lz4Codec) FileExtension() string {
+	return ".lz4"
+}
+
+func (lz4Codec) ContentType() string {
+	return "application/x-lz4"
+}
@@ -1,11 +1,12 @@
 package types
 
 import (
-	"compress/gzip"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	jsoniter "github.com/json-iterator/go"
 	"io"
+	"time"
 )
 
 const quotaByteValue = 34
@@ -20,6 +21,19 @@ type Marshaller interface {
 	Equal(Marshaller) bool
 }
 
+// SchemaMarshaller is a Marshaller that writes typed files and requires column data types before Init
+type SchemaMarshaller interface {
+	Marshaller
+	SetDataTypes(dataTypes map[string]DataType)
+}
+
+// FieldIdsMarshaller is a SchemaMarshaller that stores stable field ids of columns in file schema.
+// Required by table formats that resolve columns by id e.g. Apache Iceberg
+type FieldIdsMarshaller interface {
+	SchemaMarshaller
+	SetFieldIds(fieldIds map[string]int)
+}
+
 type AbstractMarshaller struct {
 	format      FileFormat
 	compression FileCompression
@@ -30,11 +44,22 @@ func (am *AbstractMarshaller) Equal(m Marshaller) bool {
 }
 
 func NewMarshaller(format FileFormat, compression FileCompression) (Marshaller, error) {
+	switch format {
+	case FileFormatCSV, FileFormatNDJSON, FileFormatNDJSONFLAT:
+		//parquet and avro use own compression codecs
+		if err := ValidateCompression(compression); err != nil {
+			return nil, err
+		}
+	}
 	switch format {
 	case FileFormatCSV:
 		return &CSVMarshaller{AbstractMarshaller: AbstractMarshaller{format: format, compression: compression}}, nil
 	case FileFormatNDJSON, FileFormatNDJSONFLAT:
 		return &JSONMarshaller{AbstractMarshaller: AbstractMarshaller{format: format, compression: compression}}, nil
+	case FileFormatAVRO:
+		return &AvroMarshaller{AbstractMarshaller: AbstractMarshaller{format: format, compression: compression}}, nil
+	case FileFormatPARQUET:
+		return &ParquetMarshaller{AbstractMarshaller: AbstractMarshaller{format: format, compression: compression}}, nil
 	default:
 		return nil, fmt.Errorf("Unknown file format: %s", format)
 	}
@@ -42,16 +67,16 @@ func NewMarshaller(format FileFormat, compression FileCompression) (Marshaller,
 
 type JSONMarshaller struct {
 	AbstractMarshaller
-	writer io.Writer
+	writer io.WriteCloser
 }
 
 func (jm *JSONMarshaller) Init(writer io.Writer, _ []string) error {
 	if jm.writer == nil {
-		if jm.compression == FileCompressionGZIP {
-			jm.writer = gzip.NewWriter(writer)
-		} else {
-			jm.writer = writer
+		w, err := NewCompressionWriter(jm.compression, writer)
+		if err != nil {
+			return err
 		}
+		jm.writer = w
 	}
 	return nil
 }
@@ -80,10 +105,7 @@ func (jm *JSONMarshaller) Flush() error {
 	if jm.writer == nil {
 		return fmt.Errorf("marshaller wasn't initialized. Run Init() first")
 	}
-	if jm.compression == FileCompressionGZIP {
-		return jm.writer.(*gzip.Writer).Close()
-	}
-	return nil
+	return jm.writer.Close()
 }
 
 func (jm *JSONMarshaller) NeedHeader() bool {
@@ -100,21 +122,21 @@ func (jm *JSONMarshaller) Compression() FileCompression {
 
 type CSVMarshaller struct {
 	AbstractMarshaller
-	writer     *csv.Writer
-	gzipWriter *gzip.Writer
-	fields     []string
+	writer           *csv.Writer
+	compressedWriter io.WriteCloser
+	fields           []string
 }
 
 func (cm *CSVMarshaller) Init(writer io.Writer, header []string) error {
 	if cm.writer == nil {
-		if cm.compression == FileCompressionGZIP {
-			cm.gzipWriter = gzip.NewWriter(writer)
-			cm.writer = csv.NewWriter(cm.gzipWriter)
-		} else {
-			cm.writer = csv.NewWriter(writer)
+		w, err := NewCompressionWriter(cm.compression, writer)
+		if err != nil {
+			return err
 		}
+		cm.compressedWriter = w
+		cm.writer = csv.NewWriter(cm.compressedWriter)
 		cm.fields = header
-		err := cm.writer.Write(header)
+		err = cm.writer.Write(header)
 		if err != nil {
 			return err
 		}
@@ -186,10 +208,95 @@ func (cm *CSVMarshaller) Flush() error {
 		return fmt.Errorf("marshaller wasn't initialized. Run Init() first")
 	}
 	cm.writer.Flush()
-	if cm.gzipWriter != nil {
-		return cm.gzipWriter.Close()
+	if err := cm.writer.Error(); err != nil {
+		return err
+	}
+	return cm.compressedWriter.Close()
+}
+
+// typedValue converts value to the go type of provided DataType: bool, int64, float64, time.Time or string.
+// JSON and UNKNOWN types are represented as string
+func typedValue(dataType DataType, v any) (any, error) {
+	switch dataType {
+	case BOOL, INT64, FLOAT64, TIMESTAMP:
+	default:
+		return stringValue(v), nil
+	}
+	v = ReformatValue(v)
+	switch dataType {
+	case TIMESTAMP:
+		t, ok := v.(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("can't convert %v to timestamp", v)
+		}
+		return t, nil
+	case INT64:
+		c, err := Convert(INT64, v)
+		if err != nil {
+			return nil, err
+		}
+		return int64Value(c)
+	case FLOAT64:
+		c, err := Convert(FLOAT64, v)
+		if err != nil {
+			return nil, err
+		}
+		return numberToFloat(c)
+	default:
+		return Convert(BOOL, v)
+	}
+}
+
+func int64Value(v any) (int64, error) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint:
+		return int64(v), nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		return int64(v), nil
+	default:
+		return 0, fmt.Errorf("Value: %v with type: %T isn't int", v, v)
+	}
+}
+
+// stringValue returns string representation of value. Complex types and times are marshaled the same way as in CSVMarshaller
+func stringValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	default:
+		b, err := jsoniter.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		lastIndex := len(b) - 1
+		if len(b) >= 2 && b[0] == quotaByteValue && b[lastIndex] == quotaByteValue {
+			b = b[1:lastIndex]
+		}
+		return string(b)
 	}
-	return nil
 }
 
 type FileFormat string
@@ -198,6 +305,8 @@ const (
 	FileFormatCSV        FileFormat = "csv"
 	FileFormatNDJSON     FileFormat = "ndjson"
 	FileFormatNDJSONFLAT FileFormat = "ndjson_flat"
+	FileFormatPARQUET    FileFormat = "parquet"
+	FileFormatAVRO       FileFormat = "avro"
 )
 
 type FileCompression string
@@ -205,5 +314,8 @@ type FileCompression string
 const (
 	FileCompressionGZIP    FileCompression = "gzip"
 	FileCompressionNONE    FileCompression = "none"
+	FileCompressionSNAPPY  FileCompression = "snappy"
+	FileCompressionZSTD    FileCompression = "zstd"
+	FileCompressionLZ4     FileCompression = "lz4"
 	FileCompressionUNKNOWN FileCompression = ""
 )
@@ -0,0 +1,186 @@
+package types
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/linkedin/goavro/v2"
+	"io"
+	"regexp"
+)
+
+// avroBlockLength max number of records buffered in memory before writing OCF block
+const avroBlockLength = 10 * 1024
+
+var avroNameInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// AvroMarshaller writes flat objects into Avro Object Container File with embedded schema.
+// Record schema is built from column data types provided with SetDataTypes before Init.
+// All fields are nullable unions with null default so schemas of consecutive batches stay compatible
+// when columns are added or removed
+type AvroMarshaller struct {
+	AbstractMarshaller
+	dataTypes  map[string]DataType
+	fields     []avroField
+	writer     *goavro.OCFWriter
+	records    []any
+	schemaJSON string
+}
+
+type avroField struct {
+	column    string
+	name      string
+	dataType  DataType
+	unionType string
+}
+
+func (am *AvroMarshaller) SetDataTypes(dataTypes map[string]DataType) {
+	am.dataTypes = dataTypes
+}
+
+func (am *AvroMarshaller) Init(writer io.Writer, header []string) error {
+	if am.writer == nil {
+		codec, err := avroCodec(am.compression)
+		if err != nil {
+			return err
+		}
+		am.fields = make([]avroField, 0, len(header))
+		names := make(map[string]bool, len(header))
+		schemaFields := make([]map[string]any, 0, len(header))
+		for _, column := range header {
+			name := avroFieldName(column)
+			for i := 1; names[name]; i++ {
+				name = fmt.Sprintf("%s_%d", avroFieldName(column), i)
+			}
+			names[name] = true
+			dataType := am.dataTypes[column]
+			fieldType, unionType := avroType(dataType)
+			am.fields = append(am.fields, avroField{column: column, name: name, dataType: dataType, unionType: unionType})
+			schemaField := map[string]any{"name": name, "type": []any{"null", fieldType}, "default": nil}
+			if name != column {
+				//keep original column name for readers
+				schemaField["doc"] = column
+			}
+			schemaFields = append(schemaFields, schemaField)
+		}
+		schema, err := jsoniter.MarshalToString(map[string]any{
+			"type":      "record",
+			"name":      "bulker_record",
+			"namespace": "com.jitsu.bulker",
+			"fields":    schemaFields,
+		})
+		if err != nil {
+			return err
+		}
+		am.schemaJSON = schema
+		//wrap writer so OCFWriter doesn't try to read existing header from *os.File
+		am.writer, err = goavro.NewOCFWriter(goavro.OCFConfig{
+			W:               nopCloseWriter{writer},
+			Schema:          schema,
+			CompressionName: codec,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create avro writer: %v", err)
+		}
+	}
+	return nil
+}
+
+// Marshal converts objects to avro records. Records are written in blocks of avroBlockLength
+func (am *AvroMarshaller) Marshal(object ...Object) error {
+	if am.writer == nil {
+		return fmt.Errorf("marshaller wasn't initialized. Run Init() first")
+	}
+	for _, obj := range object {
+		record := make(map[string]any, len(am.fields))
+		for _, field := range am.fields {
+			v := obj[field.column]
+			if v == nil {
+				record[field.name] = nil
+				continue
+			}
+			value, err := typedValue(field.dataType, v)
+			if err != nil {
+				return fmt.Errorf("failed to write value of column %s: %v", field.column, err)
+			}
+			record[field.name] = goavro.Union(field.unionType, value)
+		}
+		am.records = append(am.records, record)
+		if len(am.records) >= avroBlockLength {
+			if err := am.writeBlock(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (am *AvroMarshaller) writeBlock() error {
+	if len(am.records) == 0 {
+		return nil
+	}
+	err := am.writer.Append(am.records)
+	am.records = am.records[:0]
+	return err
+}
+
+func (am *AvroMarshaller) Flush() error {
+	if am.writer == nil {
+		return fmt.Errorf("marshaller wasn't initialized. Run Init() first")
+	}
+	return am.writeBlock()
+}
+
+// Schema returns avro schema of the file. Available after Init
+func (am *AvroMarshaller) Schema() string {
+	return am.schemaJSON
+}
+
+func (am *AvroMarshaller) NeedHeader() bool {
+	return true
+}
+
+func (am *AvroMarshaller) Format() FileFormat {
+	return am.format
+}
+
+func (am *AvroMarshaller) Compression() FileCompression {
+	return am.compression
+}
+
+func avroCodec(compression FileCompression) (string, error) {
+	switch compression {
+	case FileCompressionGZIP:
+		return goavro.CompressionDeflateLabel, nil
+	case FileCompressionSNAPPY:
+		return goavro.CompressionSnappyLabel, nil
+	case FileCompressionNONE, FileCompressionUNKNOWN:
+		return goavro.CompressionNullLabel, nil
+	default:
+		return "", fmt.Errorf("compression %s is not supported by avro format", compression)
+	}
+}
+
+// avroType returns avro schema type and name of union branch for DataType
+func avroType(dataType DataType) (any, string) {
+	switch dataType {
+	case BOOL:
+		return "boolean", "boolean"
+	case INT64:
+		return "long", "long"
+	case FLOAT64:
+		return "double", "double"
+	case TIMESTAMP:
+		return map[string]any{"type": "long", "logicalType": "timestamp-micros"}, "long.timestamp-micros"
+	default:
+		return "string", "string"
+	}
+}
+
+// avroFieldName returns name that matches avro naming rules: [A-Za-z_][A-Za-z0-9_]*
+func avroFieldName(column string) string {
+	name := avroNameInvalidChars.ReplaceAllString(column, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
@@ -0,0 +1,197 @@
+package types
+
+import (
+	"fmt"
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/memory"
+	"github.com/apache/arrow/go/v10/parquet"
+	"github.com/apache/arrow/go/v10/parquet/compress"
+	"github.com/apache/arrow/go/v10/parquet/pqarrow"
+	"io"
+	"strconv"
+	"time"
+)
+
+// parquetRowGroupLength max number of rows buffered in memory before writing row group
+const parquetRowGroupLength = 64 * 1024
+
+// ParquetMarshaller writes flat objects into parquet file.
+// Parquet schema is built from column data types provided with SetDataTypes before Init.
+// Compression is applied to column chunks inside parquet file
+type ParquetMarshaller struct {
+	AbstractMarshaller
+	dataTypes map[string]DataType
+	fieldIds  map[string]int
+	fields    []string
+	schema    *arrow.Schema
+	builder   *array.RecordBuilder
+	writer    *pqarrow.FileWriter
+	rows      int
+}
+
+func (pm *ParquetMarshaller) SetDataTypes(dataTypes map[string]DataType) {
+	pm.dataTypes = dataTypes
+}
+
+// SetFieldIds sets parquet field ids of columns. Columns without id are written without field id
+func (pm *ParquetMarshaller) SetFieldIds(fieldIds map[string]int) {
+	pm.fieldIds = fieldIds
+}
+
+func (pm *ParquetMarshaller) Init(writer io.Writer, header []string) error {
+	if pm.writer == nil {
+		codec, err := parquetCodec(pm.compression)
+		if err != nil {
+			return err
+		}
+		pm.fields = header
+		fields := make([]arrow.Field, len(header))
+		for i, name := range header {
+			fields[i] = arrow.Field{Name: name, Type: parquetArrowType(pm.dataTypes[name]), Nullable: true}
+			if id, ok := pm.fieldIds[name]; ok {
+				fields[i].Metadata = arrow.NewMetadata([]string{"PARQUET:field_id"}, []string{strconv.Itoa(id)})
+			}
+		}
+		pm.schema = arrow.NewSchema(fields, nil)
+		props := parquet.NewWriterProperties(
+			parquet.WithCompression(codec),
+			parquet.WithMaxRowGroupLength(parquetRowGroupLength),
+		)
+		//parquet writer closes underlying writer on Close. We need to keep file open for upload
+		w, err := pqarrow.NewFileWriter(pm.schema, nopCloseWriter{writer}, props, pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema()))
+		if err != nil {
+			return err
+		}
+		pm.writer = w
+		pm.builder = array.NewRecordBuilder(memory.DefaultAllocator, pm.schema)
+	}
+	return nil
+}
+
+// Marshal appends objects to the current row group. Row group is written when it reaches parquetRowGroupLength rows
+func (pm *ParquetMarshaller) Marshal(object ...Object) error {
+	if pm.writer == nil {
+		return fmt.Errorf("marshaller wasn't initialized. Run Init() first")
+	}
+	for _, obj := range object {
+		for i, field := range pm.fields {
+			if err := appendParquetValue(pm.builder.Field(i), obj[field]); err != nil {
+				return fmt.Errorf("failed to write value of column %s: %v", field, err)
+			}
+		}
+		pm.rows++
+		if pm.rows >= parquetRowGroupLength {
+			if err := pm.writeRowGroup(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (pm *ParquetMarshaller) writeRowGroup() error {
+	if pm.rows == 0 {
+		return nil
+	}
+	record := pm.builder.NewRecord()
+	defer record.Release()
+	pm.rows = 0
+	return pm.writer.Write(record)
+}
+
+func (pm *ParquetMarshaller) Flush() error {
+	if pm.writer == nil {
+		return fmt.Errorf("marshaller wasn't initialized. Run Init() first")
+	}
+	defer pm.builder.Release()
+	if err := pm.writeRowGroup(); err != nil {
+		return err
+	}
+	return pm.writer.Close()
+}
+
+func (pm *ParquetMarshaller) NeedHeader() bool {
+	return true
+}
+
+func (pm *ParquetMarshaller) Format() FileFormat {
+	return pm.format
+}
+
+func (pm *ParquetMarshaller) Compression() FileCompression {
+	return pm.compression
+}
+
+func parquetCodec(compression FileCompression) (compress.Compression, error) {
+	switch compression {
+	case FileCompressionGZIP:
+		return compress.Codecs.Gzip, nil
+	case FileCompressionSNAPPY:
+		return compress.Codecs.Snappy, nil
+	case FileCompressionZSTD:
+		return compress.Codecs.Zstd, nil
+	case FileCompressionNONE, FileCompressionUNKNOWN:
+		return compress.Codecs.Uncompressed, nil
+	default:
+		return compress.Codecs.Uncompressed, fmt.Errorf("compression %s is not supported by parquet format", compression)
+	}
+}
+
+func parquetArrowType(dataType DataType) arrow.DataType {
+	switch dataType {
+	case BOOL:
+		return arrow.FixedWidthTypes.Boolean
+	case INT64:
+		return arrow.PrimitiveTypes.Int64
+	case FLOAT64:
+		return arrow.PrimitiveTypes.Float64
+	case TIMESTAMP:
+		return arrow.FixedWidthTypes.Timestamp_us
+	default:
+		return arrow.BinaryTypes.String
+	}
+}
+
+// appendParquetValue converts value to the type of column builder and appends it
+func appendParquetValue(builder array.Builder, v any) error {
+	if v == nil {
+		builder.AppendNull()
+		return nil
+	}
+	switch b := builder.(type) {
+	case *array.StringBuilder:
+		b.Append(stringValue(v))
+	case *array.TimestampBuilder:
+		t, err := typedValue(TIMESTAMP, v)
+		if err != nil {
+			return err
+		}
+		b.Append(arrow.Timestamp(t.(time.Time).UnixMicro()))
+	case *array.BooleanBuilder:
+		c, err := typedValue(BOOL, v)
+		if err != nil {
+			return err
+		}
+		b.Append(c.(bool))
+	case *array.Int64Builder:
+		i, err := typedValue(INT64, v)
+		if err != nil {
+			return err
+		}
+		b.Append(i.(int64))
+	case *array.Float64Builder:
+		f, err := typedValue(FLOAT64, v)
+		if err != nil {
+			return err
+		}
+		b.Append(f.(float64))
+	default:
+		return fmt.Errorf("unsupported column type: %s", builder.Type())
+	}
+	return nil
+}
+
+type nopCloseWriter struct {
+	io.Writer
+}
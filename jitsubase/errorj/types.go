@@ -36,6 +36,27 @@ var (
 	SaveOnStageError     = stageErr.NewSubtype("save_on_stage")
 	DeleteFromStageError = stageErr.NewSubtype("delete_from_stage")
 
+	producerError            = reportedErrors.NewType("producer")
+	ProduceError             = producerError.NewSubtype("produce")
+	ProducerTransactionError = producerError.NewSubtype("transaction")
+
+	// NonRetryable trait of errors that will fail the same way on retry e.g. data rejected by destination as invalid
+	NonRetryable = errorx.RegisterTrait("non_retryable")
+
+	httpError = reportedErrors.NewType("http")
+	// HTTPRequestError request wasn't sent or response wasn't received e.g. connection refused or timeout
+	HTTPRequestError = httpError.NewSubtype("request")
+	// HTTPClientError request was rejected with 4xx response code
+	HTTPClientError = httpError.NewSubtype("client_error", NonRetryable)
+	// HTTPServerError request failed with 5xx response code or with retryable 4xx code e.g. 429 Too Many Requests
+	HTTPServerError = httpError.NewSubtype("server_error")
+
+	documentError = reportedErrors.NewType("document")
+	// BulkWriteError some documents of bulk write request failed with errors that may succeed on retry
+	BulkWriteError = documentError.NewSubtype("bulk_write")
+	// BulkWriteRejectedError all failed documents of bulk write request were rejected by destination e.g. as not matching mapping
+	BulkWriteRejectedError = documentError.NewSubtype("bulk_write_rejected", NonRetryable)
+
 	innerError             = reportedErrors.NewType("inner")
 	ManageMySQLPrimaryKeys = innerError.NewSubtype("manage_mysql_primary_keys")
 
@@ -73,6 +94,11 @@ func Group(errs ...error) error {
 	return errorx.Cast(mainErr).WithUnderlyingErrors(suppressed...)
 }
 
+// IsNonRetryableError returns true if error has NonRetryable trait. Retrying of such errors is pointless
+func IsNonRetryableError(err error) bool {
+	return errorx.HasTrait(err, NonRetryable)
+}
+
 func IsSystemError(err error) bool {
 	flag, ok := errorx.Cast(err).Property(SystemErrorFlag)
 	if ok {